@@ -0,0 +1,158 @@
+/* armeval.go - poses an armature from a timeline at the given times, and
+prints the angles, without needing a window.
+
+go run armeval.go [-armature art/robotarm.json] [-fk] timeline.json t...
+
+Times are seconds since the timeline started playing, so they go through
+its loop or ping-pong.  -fk prints where every part and effector is too,
+and the box round each shape.  With -check, each time is followed by the
+axis and angle expected there, or @ and a part or effector and the x y z
+expected there, and the run fails if any is more than 0.01 off (round the
+circle, for axes that wrap):
+
+go run armeval.go -check art/robotarm_wave.json 4.5 base 90 6 upperArm -33.75 @leftTip -0.35 -5.17 -33.24
+*/
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	gl "github.com/chsc/gogl/gl33"
+	glut "github.com/ysgard/opengl-go-tut/glutil"
+	"os"
+	"strconv"
+	"strings"
+)
+
+func fatal(err error) {
+	fmt.Fprintf(os.Stderr, "%s\n", err)
+	os.Exit(1)
+}
+
+func printFK(a *glut.Armature) {
+	for _, p := range a.Parts {
+		pos, _ := a.Position(nil, p.Name)
+		fmt.Printf("%-17s at %6.2f %6.2f %6.2f", p.Name, pos.X, pos.Y, pos.Z)
+		if b, err := a.AABB(nil, p.Name); err == nil {
+			fmt.Printf(", box %6.2f %6.2f %6.2f to %6.2f %6.2f %6.2f",
+				b.Min.X, b.Min.Y, b.Min.Z, b.Max.X, b.Max.Y, b.Max.Z)
+		}
+		fmt.Printf("\n")
+	}
+	for _, e := range a.Effectors {
+		pos, _ := a.Position(nil, e.Name)
+		fmt.Printf("%-17s at %6.2f %6.2f %6.2f\n", e.Name, pos.X, pos.Y, pos.Z)
+	}
+	fmt.Printf("\n")
+}
+
+// checkPosition checks the named part or effector is at xyz.
+func checkPosition(a *glut.Armature, t float64, name string, xyz []string) bool {
+	var want glut.Vec3
+	for i, s := range xyz {
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			fatal(fmt.Errorf("@%s: %q isn't a number", name, s))
+		}
+		want.SetElem(i, gl.Float(f))
+	}
+	got, err := a.Position(nil, name)
+	switch {
+	case err != nil:
+		fmt.Printf("%gs: %s\n", t, err)
+		return false
+	case got.Sub(&want).Length() > 0.01:
+		fmt.Printf("%gs: %s is at %.2f %.2f %.2f, not %g %g %g\n", t, name, got.X, got.Y, got.Z, want.X, want.Y, want.Z)
+		return false
+	}
+	fmt.Printf("%gs: %s at %.2f %.2f %.2f ok\n", t, name, got.X, got.Y, got.Z)
+	return true
+}
+
+func main() {
+	armatureFile := flag.String("armature", "art/robotarm.json", "the armature the timeline poses")
+	check := flag.Bool("check", false, "check the angles and positions given after each time")
+	fk := flag.Bool("fk", false, "print where the parts and effectors are")
+	flag.Parse()
+	if flag.NArg() < 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	a, err := glut.LoadArmature(*armatureFile)
+	if err != nil {
+		fatal(err)
+	}
+	tl, err := glut.LoadTimeline(flag.Arg(0))
+	if err != nil {
+		fatal(err)
+	}
+	if err := tl.Check(a); err != nil {
+		fatal(fmt.Errorf("%s: %s", flag.Arg(0), err))
+	}
+
+	args := flag.Args()[1:]
+	failed := false
+	for len(args) > 0 {
+		t, err := strconv.ParseFloat(args[0], 64)
+		if err != nil {
+			fatal(fmt.Errorf("%q isn't a time", args[0]))
+		}
+		args = args[1:]
+		// Pose the armature itself, so its limits apply
+		tl.Apply(a, t)
+		pose := a.Pose()
+		if !*check {
+			fmt.Printf("*** %gs (%gs on the timeline) ***\n%s\n", t, tl.LocalTime(t), pose.Format(a))
+			if *fk {
+				printFK(a)
+			}
+			continue
+		}
+		for len(args) >= 4 && strings.HasPrefix(args[0], "@") {
+			if !checkPosition(a, t, args[0][1:], args[1:4]) {
+				failed = true
+			}
+			args = args[4:]
+		}
+		for len(args) >= 2 {
+			want, err := strconv.ParseFloat(args[1], 64)
+			if err != nil {
+				break
+			}
+			got, ok := pose[args[0]]
+			if !ok {
+				fmt.Printf("%gs: no axis %q\n", t, args[0])
+				failed = true
+				args = args[2:]
+				continue
+			}
+			d := glut.AbsGL(got - gl.Float(want))
+			if !a.Axis(args[0]).Limited {
+				// 270 is -90 on an axis that wraps
+				d = glut.ModGL(d, 360.0)
+				if d > 180.0 {
+					d = 360.0 - d
+				}
+			}
+			switch {
+			case d > 0.01:
+				fmt.Printf("%gs: %s is %.2f, not %g\n", t, args[0], got, want)
+				failed = true
+			default:
+				fmt.Printf("%gs: %s %.2f ok\n", t, args[0], got)
+			}
+			args = args[2:]
+			for len(args) >= 4 && strings.HasPrefix(args[0], "@") {
+				if !checkPosition(a, t, args[0][1:], args[1:4]) {
+					failed = true
+				}
+				args = args[4:]
+			}
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
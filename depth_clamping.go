@@ -112,7 +112,11 @@ func glInit() {
 	gl.Init()
 
 	// Load the shaders
-	currentShader = CreateShaderProgram(shaders)
+	var err error
+	if currentShader, err = CreateShaderProgram(shaders); err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
 	offsetUniform = gl.GetUniformLocation(currentShader, gl.GLString("offset"))
 	perspectiveMatrixUnif = gl.GetUniformLocation(currentShader, gl.GLString("perspectiveMatrix"))
 	gl.UseProgram(currentShader)
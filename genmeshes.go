@@ -0,0 +1,126 @@
+/* genmeshes.go - builds the unit meshes in world_tut, instead of the Lua
+scripts that used to.  No GL needed.
+
+go run genmeshes.go [-check] [-dir world_tut]
+go run genmeshes.go [-segments n] [-rings n] [-radius r] [-height h]
+	[-tube r] [-repeat n] [-tint] shape out.xml
+
+The first form writes every Unit*.xml into dir, or with -check compares
+them with what's there.  The second writes one shape: cube, cubecolor,
+plane, cylinder, cone, sphere, torus or capsule.
+*/
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	glut "github.com/ysgard/opengl-go-tut/glutil"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+var shapes = map[string]func(*glut.GenOptions) *glut.Mesh{
+	"cube":     glut.GenCube,
+	"plane":    glut.GenPlane,
+	"cylinder": glut.GenCylinder,
+	"cone":     glut.GenCone,
+	"sphere":   glut.GenSphere,
+	"torus":    glut.GenTorus,
+	"capsule":  glut.GenCapsule,
+	"cubecolor": func(o *glut.GenOptions) *glut.Mesh {
+		o.FaceColors = true
+		return glut.GenCube(o)
+	},
+}
+
+// The files the Lua scripts made, with their default options.
+var unitMeshes = []struct {
+	file  string
+	shape string
+	tint  bool
+}{
+	{"UnitCube.xml", "cube", false},
+	{"UnitCubeTint.xml", "cube", true},
+	{"UnitCubeColor.xml", "cubecolor", false},
+	{"UnitPlane.xml", "plane", false},
+	{"UnitCylinder.xml", "cylinder", false},
+	{"UnitCylinderTint.xml", "cylinder", true},
+	{"UnitCone.xml", "cone", false},
+	{"UnitConeTint.xml", "cone", true},
+}
+
+func gen(shape string, opts *glut.GenOptions) ([]byte, error) {
+	fn, ok := shapes[shape]
+	if !ok {
+		return nil, fmt.Errorf("no shape %q", shape)
+	}
+	var buf bytes.Buffer
+	if err := fn(opts).WriteGLUTMesh(&buf); err != nil {
+		return nil, fmt.Errorf("%s: %s", shape, err)
+	}
+	return buf.Bytes(), nil
+}
+
+func main() {
+	check := flag.Bool("check", false, "compare with the files in -dir instead of writing them")
+	dir := flag.String("dir", "world_tut", "where the unit meshes go")
+	opts := glut.GenOptions{}
+	flag.IntVar(&opts.Segments, "segments", 0, "segments round the Y axis")
+	flag.IntVar(&opts.Rings, "rings", 0, "rings top to bottom")
+	flag.Float64Var(&opts.Radius, "radius", 0, "radius, or half the width")
+	flag.Float64Var(&opts.Height, "height", 0, "height")
+	flag.Float64Var(&opts.TubeRadius, "tube", 0, "radius of a torus's tube")
+	flag.IntVar(&opts.ColorRepeat, "repeat", 0, "how many times the tint goes round")
+	flag.BoolVar(&opts.Tint, "tint", false, "add colours")
+	flag.Parse()
+
+	if flag.NArg() == 2 {
+		data, err := gen(flag.Arg(0), &opts)
+		if err == nil {
+			err = ioutil.WriteFile(flag.Arg(1), data, 0644)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if flag.NArg() != 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	failed := false
+	for _, u := range unitMeshes {
+		file := filepath.Join(*dir, u.file)
+		data, err := gen(u.shape, &glut.GenOptions{Tint: u.tint})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err)
+			os.Exit(1)
+		}
+		if !*check {
+			if err := ioutil.WriteFile(file, data, 0644); err != nil {
+				fmt.Fprintf(os.Stderr, "%s\n", err)
+				os.Exit(1)
+			}
+			continue
+		}
+		old, err := ioutil.ReadFile(file)
+		switch {
+		case err != nil:
+			fmt.Printf("%s: %s\n", file, err)
+			failed = true
+		case !bytes.Equal(old, data):
+			fmt.Printf("%s: differs\n", file)
+			failed = true
+		default:
+			fmt.Printf("%s: ok\n", file)
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
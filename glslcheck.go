@@ -0,0 +1,310 @@
+// glslcheck - offline checks of the shaders, no window or GL context needed.
+//
+//	go run glslcheck.go [-v] [-src .] [dirs...]
+//
+// Every shader in the dirs (shaders/ and world_tut/ by default) is checked
+// for a #version, uniform blocks are compared across files, and vertex
+// attribute locations are compared across vertex shaders.  The programs are
+// found by reading the demos' Go source for shader lists, and each stage's
+// outputs are matched against the next stage's inputs.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	gl "github.com/chsc/gogl/gl33"
+	glut "github.com/ysgard/opengl-go-tut/glutil"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var verbose = flag.Bool("v", false, "list what was checked")
+var srcDir = flag.String("src", ".", "directory of Go sources to find programs in")
+
+// A shader file as one stage of a program.
+type stageFile struct {
+	file  string
+	stage gl.Enum
+}
+
+type program struct {
+	from   string // where in the Go source it was found
+	stages []stageFile
+}
+
+// shaderLike is true for anything CreateShaderProgram or LoadShaders could
+// be given.
+func shaderLike(file string) bool {
+	if _, err := glut.ShaderType(file); err == nil {
+		return true
+	}
+	return strings.HasSuffix(file, ".glsl")
+}
+
+// Words in a .glsl file's name that say which stage it is.
+var glslStageNames = []struct {
+	word  string
+	stage gl.Enum
+}{
+	{"vert", gl.VERTEX_SHADER},
+	{"frag", gl.FRAGMENT_SHADER},
+	{"geom", gl.GEOMETRY_SHADER},
+}
+
+var mainFunc = regexp.MustCompile(`\bvoid\s+main\s*\(`)
+
+// stageOf is the stage of a shader file: by its extension, or for a .glsl
+// file by its name, or failing that by whether it sets gl_Position.  A .glsl
+// file without a main is an include, and not a stage at all.
+func stageOf(file string) (gl.Enum, bool) {
+	if stage, err := glut.ShaderType(file); err == nil {
+		return stage, true
+	}
+	if !strings.HasSuffix(file, ".glsl") {
+		return 0, false
+	}
+	data, err := ioutil.ReadFile(file)
+	if err != nil || !mainFunc.Match(data) {
+		return 0, false
+	}
+	name := strings.ToLower(filepath.Base(file))
+	for _, n := range glslStageNames {
+		if strings.Contains(name, n.word) {
+			return n.stage, true
+		}
+	}
+	if bytes.Contains(data, []byte("gl_Position")) {
+		return gl.VERTEX_SHADER, true
+	}
+	return gl.FRAGMENT_SHADER, true
+}
+
+// stringLits returns the shader paths in exprs, which must all be string
+// literals or names of string constants in consts.
+func stringLits(exprs []ast.Expr, consts map[string]string) ([]string, bool) {
+	var strs []string
+	for _, e := range exprs {
+		var s string
+		switch e := e.(type) {
+		case *ast.BasicLit:
+			if e.Kind != token.STRING {
+				return nil, false
+			}
+			s, _ = strconv.Unquote(e.Value)
+		case *ast.Ident:
+			s = consts[e.Name]
+		}
+		if !shaderLike(s) {
+			return nil, false
+		}
+		strs = append(strs, s)
+	}
+	return strs, len(strs) > 0
+}
+
+// stringConsts collects the file's top-level string constants and vars, as
+// the tutorials keep their shader paths in them.
+func stringConsts(f *ast.File) map[string]string {
+	consts := make(map[string]string)
+	for _, decl := range f.Decls {
+		gd, ok := decl.(*ast.GenDecl)
+		if !ok {
+			continue
+		}
+		for _, spec := range gd.Specs {
+			vs, ok := spec.(*ast.ValueSpec)
+			if !ok {
+				continue
+			}
+			for i, name := range vs.Names {
+				if i >= len(vs.Values) {
+					break
+				}
+				if lit, ok := vs.Values[i].(*ast.BasicLit); ok && lit.Kind == token.STRING {
+					consts[name.Name], _ = strconv.Unquote(lit.Value)
+				}
+			}
+		}
+	}
+	return consts
+}
+
+// findPrograms looks through the Go files in dir for []string{...} shader
+// lists and LoadShaders(vert, frag) calls.  Some demos don't compile, so
+// whatever the parser manages to read is used.
+func findPrograms(dir string) []program {
+	var progs []program
+	files, _ := filepath.Glob(filepath.Join(dir, "*.go"))
+	fset := token.NewFileSet()
+	for _, file := range files {
+		f, _ := parser.ParseFile(fset, file, nil, parser.AllErrors)
+		if f == nil {
+			continue
+		}
+		consts := stringConsts(f)
+		ast.Inspect(f, func(n ast.Node) bool {
+			var prog program
+			switch n := n.(type) {
+			case *ast.CompositeLit:
+				strs, ok := stringLits(n.Elts, consts)
+				if !ok {
+					return true
+				}
+				for _, s := range strs {
+					stage, ok := stageOf(filepath.Join(dir, s))
+					if !ok {
+						return true
+					}
+					prog.stages = append(prog.stages, stageFile{filepath.Join(dir, s), stage})
+				}
+			case *ast.CallExpr:
+				id, ok := n.Fun.(*ast.Ident)
+				if !ok || id.Name != "LoadShaders" {
+					return true
+				}
+				strs, ok := stringLits(n.Args, consts)
+				if !ok || len(strs) != 2 {
+					return true
+				}
+				prog.stages = []stageFile{
+					{filepath.Join(dir, strs[0]), gl.VERTEX_SHADER},
+					{filepath.Join(dir, strs[1]), gl.FRAGMENT_SHADER},
+				}
+			default:
+				return true
+			}
+			prog.from = fset.Position(n.Pos()).String()
+			progs = append(progs, prog)
+			return true
+		})
+	}
+	return progs
+}
+
+// The parsed shaders, by file and stage.
+var parsed = make(map[stageFile]*glut.GLSLInfo)
+
+func parse(sf stageFile) (*glut.GLSLInfo, error) {
+	if info, ok := parsed[sf]; ok {
+		return info, nil
+	}
+	info, err := glut.ParseGLSLStage(sf.file, sf.stage, nil)
+	if err != nil {
+		return nil, err
+	}
+	parsed[sf] = info
+	return info, nil
+}
+
+var nErrors, nWarnings int
+
+func report(diags []glut.Diagnostic) {
+	for _, d := range diags {
+		fmt.Fprintf(os.Stderr, "%s:%s\n", d.File, d.String())
+		if d.Severity == glut.SeverityError {
+			nErrors++
+		} else {
+			nWarnings++
+		}
+	}
+}
+
+func fail(err error) {
+	fmt.Fprintf(os.Stderr, "%s\n", err)
+	nErrors++
+}
+
+// checkProgram lines the stages up in pipeline order and matches each
+// one's outputs to the next one's inputs.
+func checkProgram(prog program) {
+	var types []gl.Enum
+	byStage := make(map[gl.Enum]*glut.GLSLInfo)
+	for _, sf := range prog.stages {
+		types = append(types, sf.stage)
+		info, err := parse(sf)
+		if err != nil {
+			fail(err)
+			return
+		}
+		byStage[sf.stage] = info
+	}
+	if err := glut.ValidateStages(types); err != nil {
+		fail(fmt.Errorf("%s: %s", prog.from, err))
+		return
+	}
+	var prev *glut.GLSLInfo
+	for _, stage := range glut.StageOrder {
+		info, ok := byStage[stage]
+		if !ok {
+			continue
+		}
+		if prev != nil {
+			report(glut.CheckInterface(prev, info))
+		}
+		prev = info
+	}
+	if *verbose {
+		var files []string
+		for _, sf := range prog.stages {
+			files = append(files, sf.file)
+		}
+		fmt.Fprintf(os.Stdout, "program %s: %s\n", prog.from, strings.Join(files, " + "))
+	}
+}
+
+func main() {
+	flag.Parse()
+	dirs := flag.Args()
+	if len(dirs) == 0 {
+		dirs = []string{"shaders", "world_tut"}
+	}
+
+	// Every stage file in the directories, on its own.  .glsl files
+	// without a main are includes, and are only checked where they're
+	// included.
+	var infos []*glut.GLSLInfo
+	for _, dir := range dirs {
+		files, err := filepath.Glob(filepath.Join(dir, "*"))
+		if err != nil {
+			fail(err)
+			continue
+		}
+		sort.Strings(files)
+		for _, file := range files {
+			stage, ok := stageOf(file)
+			if !ok {
+				continue
+			}
+			info, err := parse(stageFile{file, stage})
+			if err != nil {
+				fail(err)
+				continue
+			}
+			if *verbose {
+				fmt.Fprintf(os.Stdout, "%s: %s shader, #version %s\n", file, glut.StageName(stage), info.Version)
+			}
+			report(glut.CheckVersion(info))
+			infos = append(infos, info)
+		}
+	}
+	report(glut.CheckBlocks(infos))
+	report(glut.CheckLocations(infos))
+
+	for _, prog := range findPrograms(*srcDir) {
+		checkProgram(prog)
+	}
+
+	fmt.Fprintf(os.Stdout, "glslcheck: %d errors, %d warnings\n", nErrors, nWarnings)
+	if nErrors > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,189 @@
+/*
+animation.go - keyframed curves, and clips of them that pose a Skeleton.
+
+A channel writes a curve's output into one of a joint's transform
+elements - a whole <translate>, say, or just the ANGLE of a <rotate> - and
+the joint's local matrix is built again from the changed elements.  Outside
+its keys a curve holds its first or last value.
+*/
+package glutil
+
+import (
+	"fmt"
+	gl "github.com/chsc/gogl/gl33"
+)
+
+type AnimationCurve struct {
+	Times   []float64 // seconds, increasing
+	Stride  int       // values per key
+	Values  []float64
+	Interps []string // per key, for the segment after it: LINEAR, BEZIER or STEP
+
+	// BEZIER control points, (time, value) pairs for every value of every
+	// key.  The segment after key i uses its out tangent and key i+1's in
+	// tangent.
+	InTangents  []float64
+	OutTangents []float64
+}
+
+type AnimationChannel struct {
+	Target  string // as the file wrote it
+	Joint   int
+	Element int   // which of the joint's Elements
+	Members []int // which of the element's values; all of them if nil
+	Curve   *AnimationCurve
+}
+
+type AnimationClip struct {
+	Name       string
+	Skeleton   *Skeleton
+	Channels   []*AnimationChannel
+	Start, End float64
+
+	// Channel targets that aren't on the skeleton, like Maya's
+	// blendParent1.
+	Unbound []string
+}
+
+// Interpolations we know how to do.
+var animationInterps = map[string]bool{"LINEAR": true, "BEZIER": true, "STEP": true}
+
+// Validate checks the curve's arrays agree with each other.
+func (c *AnimationCurve) Validate() error {
+	n := len(c.Times)
+	if n == 0 {
+		return fmt.Errorf("curve has no keys")
+	}
+	if c.Stride < 1 || len(c.Values) != n*c.Stride {
+		return fmt.Errorf("curve has %d values for %d keys of %d", len(c.Values), n, c.Stride)
+	}
+	if len(c.Interps) != n {
+		return fmt.Errorf("curve has %d interpolations for %d keys", len(c.Interps), n)
+	}
+	for i := 1; i < n; i++ {
+		if c.Times[i] < c.Times[i-1] {
+			return fmt.Errorf("curve key %d goes back in time", i)
+		}
+	}
+	bezier := false
+	for _, in := range c.Interps {
+		if !animationInterps[in] {
+			return fmt.Errorf("unsupported interpolation %s", in)
+		}
+		bezier = bezier || in == "BEZIER"
+	}
+	if bezier && (len(c.InTangents) != 2*n*c.Stride || len(c.OutTangents) != 2*n*c.Stride) {
+		return fmt.Errorf("BEZIER curve needs 2 tangent values per value")
+	}
+	return nil
+}
+
+// Eval samples the curve at time t.
+func (c *AnimationCurve) Eval(t float64) []float64 {
+	n, st := len(c.Times), c.Stride
+	out := make([]float64, st)
+	if t <= c.Times[0] {
+		copy(out, c.Values[:st])
+		return out
+	}
+	if t >= c.Times[n-1] {
+		copy(out, c.Values[(n-1)*st:])
+		return out
+	}
+	i := 0
+	for c.Times[i+1] <= t {
+		i++
+	}
+	t0, t1 := c.Times[i], c.Times[i+1]
+	v0, v1 := c.Values[i*st:(i+1)*st], c.Values[(i+1)*st:(i+2)*st]
+	switch c.Interps[i] {
+	case "STEP":
+		copy(out, v0)
+	case "BEZIER":
+		for k := range out {
+			o := 2 * (i*st + k)
+			in := 2 * ((i+1)*st + k)
+			out[k] = bezierAt(t,
+				t0, v0[k],
+				c.OutTangents[o], c.OutTangents[o+1],
+				c.InTangents[in], c.InTangents[in+1],
+				t1, v1[k])
+		}
+	default:
+		s := (t - t0) / (t1 - t0)
+		for k := range out {
+			out[k] = v0[k] + (v1[k]-v0[k])*s
+		}
+	}
+	return out
+}
+
+// bezierAt finds the value of a 2D cubic bezier at time t, by searching
+// for the parameter that gives t.  Authoring tools keep the time of the
+// control points in order, so time only ever increases along the curve.
+func bezierAt(t, x0, y0, x1, y1, x2, y2, x3, y3 float64) float64 {
+	cubic := func(a, b, c, d, s float64) float64 {
+		r := 1 - s
+		return r*r*r*a + 3*r*r*s*b + 3*r*s*s*c + s*s*s*d
+	}
+	lo, hi := 0.0, 1.0
+	s := (t - x0) / (x3 - x0)
+	for i := 0; i < 32; i++ {
+		x := cubic(x0, x1, x2, x3, s)
+		if x < t {
+			lo = s
+		} else {
+			hi = s
+		}
+		s = (lo + hi) / 2
+	}
+	return cubic(y0, y1, y2, y3, s)
+}
+
+// Duration is how long the clip runs.
+func (a *AnimationClip) Duration() float64 {
+	return a.End - a.Start
+}
+
+// Pose evaluates every channel at time t and poses the skeleton with them.
+// Joints no channel touches stay in their rest pose.
+func (a *AnimationClip) Pose(t float64) *Pose {
+	p := a.Skeleton.RestPose()
+	elems := make(map[int][]TransformElement)
+	for _, ch := range a.Channels {
+		es, ok := elems[ch.Joint]
+		if !ok {
+			// Copy, as the channels write into them.
+			es = append([]TransformElement(nil), a.Skeleton.Joints[ch.Joint].Elements...)
+			for i := range es {
+				es[i].Values = append([]gl.Float(nil), es[i].Values...)
+			}
+			elems[ch.Joint] = es
+		}
+		v := ch.Curve.Eval(t)
+		e := &es[ch.Element]
+		if ch.Members == nil {
+			for i := range e.Values {
+				e.Values[i] = gl.Float(v[i])
+			}
+			continue
+		}
+		for i, m := range ch.Members {
+			e.Values[m] = gl.Float(v[i])
+		}
+	}
+	for j, es := range elems {
+		if m, err := a.Skeleton.Local(es); err == nil {
+			p.Local[j] = *m
+		}
+	}
+	return p
+}
+
+// Debug dumps a summary of the clip to stdout.
+func (a *AnimationClip) Debug() {
+	fmt.Printf("Clip %s: %d channels, %g to %g seconds\n", a.Name, len(a.Channels), a.Start, a.End)
+	for _, t := range a.Unbound {
+		fmt.Printf("  %s isn't on the skeleton\n", t)
+	}
+}
@@ -0,0 +1,243 @@
+package glutil
+
+import (
+	"encoding/xml"
+	gl "github.com/chsc/gogl/gl33"
+	"math"
+	"testing"
+)
+
+func mat4Near(a, b *Mat4, eps float64) bool {
+	for c := range a {
+		d := a[c].Sub(&b[c])
+		if math.Abs(float64(d.X)) > eps || math.Abs(float64(d.Y)) > eps ||
+			math.Abs(float64(d.Z)) > eps || math.Abs(float64(d.W)) > eps {
+			return false
+		}
+	}
+	return true
+}
+
+func TestAnimationCurveEval(t *testing.T) {
+	tests := []struct {
+		name  string
+		curve AnimationCurve
+		t     float64
+		want  []float64
+	}{
+		{"linear", AnimationCurve{Times: []float64{1, 3}, Stride: 2, Values: []float64{0, 10, 4, 20},
+			Interps: []string{"LINEAR", "LINEAR"}}, 1.5, []float64{1, 12.5}},
+		{"step", AnimationCurve{Times: []float64{1, 3}, Stride: 1, Values: []float64{5, 7},
+			Interps: []string{"STEP", "STEP"}}, 2.9, []float64{5}},
+		{"step on the key", AnimationCurve{Times: []float64{1, 3, 4}, Stride: 1, Values: []float64{5, 7, 9},
+			Interps: []string{"STEP", "STEP", "STEP"}}, 3, []float64{7}},
+		{"held before the first key", AnimationCurve{Times: []float64{1, 3}, Stride: 1, Values: []float64{5, 7},
+			Interps: []string{"LINEAR", "LINEAR"}}, -2, []float64{5}},
+		{"held after the last key", AnimationCurve{Times: []float64{1, 3}, Stride: 1, Values: []float64{5, 7},
+			Interps: []string{"LINEAR", "LINEAR"}}, 10, []float64{7}},
+		// Tangents a third of the way along, flat at the ends: y = 3s² - 2s³
+		// with s = t.
+		{"bezier ease", AnimationCurve{Times: []float64{0, 1}, Stride: 1, Values: []float64{0, 1},
+			Interps:     []string{"BEZIER", "BEZIER"},
+			InTangents:  []float64{-1.0 / 3, 0, 2.0 / 3, 1},
+			OutTangents: []float64{1.0 / 3, 0, 4.0 / 3, 1}}, 0.25, []float64{0.15625}},
+		{"bezier midpoint", AnimationCurve{Times: []float64{0, 1}, Stride: 1, Values: []float64{0, 1},
+			Interps:     []string{"BEZIER", "BEZIER"},
+			InTangents:  []float64{-1.0 / 3, 0, 2.0 / 3, 1},
+			OutTangents: []float64{1.0 / 3, 0, 4.0 / 3, 1}}, 0.5, []float64{0.5}},
+		{"bezier then linear", AnimationCurve{Times: []float64{0, 1, 2}, Stride: 1, Values: []float64{0, 1, 3},
+			Interps:     []string{"BEZIER", "LINEAR", "LINEAR"},
+			InTangents:  []float64{0, 0, 2.0 / 3, 1, 0, 0},
+			OutTangents: []float64{1.0 / 3, 0, 0, 0, 0, 0}}, 1.5, []float64{2}},
+	}
+	for _, tt := range tests {
+		if err := tt.curve.Validate(); err != nil {
+			t.Errorf("%s: %s", tt.name, err)
+			continue
+		}
+		got := tt.curve.Eval(tt.t)
+		for i := range tt.want {
+			if math.Abs(got[i]-tt.want[i]) > 1e-6 {
+				t.Errorf("%s: Eval(%g) = %v, want %v", tt.name, tt.t, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+// astroBoyLocal is a joint's local matrix from its jointOrient and
+// animated rotate angles, the order the file's nodes have them in.
+func astroBoyLocal(tr Vec3, orient, rot Vec3) *Mat4 {
+	m := TranslateMat4(&tr)
+	m = m.MulM(RotateZMat4(orient.Z)).MulM(RotateYMat4(orient.Y)).MulM(RotateXMat4(orient.X))
+	return m.MulM(RotateZMat4(rot.Z)).MulM(RotateYMat4(rot.Y)).MulM(RotateXMat4(rot.X))
+}
+
+func TestAnimationClipPose(t *testing.T) {
+	skins, clip, err := LoadColladaSkins("../art/astroBoy_walk_Maya.dae")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(skins) != 1 {
+		t.Fatalf("%d skins", len(skins))
+	}
+
+	// The file has 63 channels.  spine01's blendParent1 is a Maya
+	// constraint weight, not a transform, so it can't go on the skeleton.
+	if len(clip.Channels) != 62 {
+		t.Errorf("%d bound channels, want 62", len(clip.Channels))
+	}
+	if len(clip.Unbound) != 1 || clip.Unbound[0] != "astroBoy_newSkeleton_spine01/blendParent1" {
+		t.Errorf("unbound %v", clip.Unbound)
+	}
+	if math.Abs(clip.Start-0.041666) > 1e-9 || math.Abs(clip.End-1.08333) > 1e-9 {
+		t.Errorf("clip runs %g to %g", clip.Start, clip.End)
+	}
+
+	skel := clip.Skeleton
+	root, spine := skel.Find("root"), skel.Find("spine01")
+	if root < 0 || spine < 0 || skel.Joints[spine].Parent != root {
+		t.Fatalf("root %d, spine01 %d", root, spine)
+	}
+	rootOrient := Vec3{X: 90, Y: 84.6854, Z: 90}
+	spineOrient := Vec3{X: 180, Y: 0, Z: 157.931}
+	spineTr := Vec3{X: 0, Y: 1.17004, Z: 0}
+
+	// The values are the file's keys, so the bezier segments land on them
+	// exactly.  Before the first key and after the last are held.
+	tests := []struct {
+		t               float64
+		rootTr, rootRot Vec3
+		spineRot        Vec3
+	}{
+		{0, Vec3{X: 0, Y: 6.84326, Z: -0.145007}, Vec3{X: 0, Y: -4.56751, Z: 0}, Vec3{X: 0, Y: -8.7437, Z: 0}},
+		{0.5, Vec3{X: -0.109411, Y: 7.05776, Z: -0.125054}, Vec3{X: 0, Y: 4.45718, Z: 0},
+			Vec3{X: 0.053676, Y: 8.08651, Z: -0.073861}},
+		{5, Vec3{X: 0, Y: 6.84326, Z: -0.145007}, Vec3{X: 0, Y: -4.56751, Z: 0}, Vec3{X: 0, Y: -8.7437, Z: 0}},
+	}
+	for _, tt := range tests {
+		p := clip.Pose(tt.t)
+		wantRoot := astroBoyLocal(tt.rootTr, rootOrient, tt.rootRot)
+		wantSpine := astroBoyLocal(spineTr, spineOrient, tt.spineRot)
+		if !mat4Near(&p.Local[root], wantRoot, 1e-4) {
+			t.Errorf("t %g: root\n%v\nwant\n%v", tt.t, p.Local[root], *wantRoot)
+		}
+		if !mat4Near(&p.Local[spine], wantSpine, 1e-4) {
+			t.Errorf("t %g: spine01\n%v\nwant\n%v", tt.t, p.Local[spine], *wantSpine)
+		}
+		world := p.World()
+		if !mat4Near(&world[spine], wantRoot.MulM(wantSpine), 1e-4) {
+			t.Errorf("t %g: spine01 world\n%v", tt.t, world[spine])
+		}
+	}
+
+	// Joints no channel touches keep their rest transform.
+	p := clip.Pose(0.5)
+	touched := make(map[int]bool)
+	for _, ch := range clip.Channels {
+		touched[ch.Joint] = true
+	}
+	for j := range skel.Joints {
+		if !touched[j] && p.Local[j] != skel.Joints[j].Rest {
+			t.Errorf("joint %s moved", skel.Joints[j].Name)
+		}
+	}
+}
+
+func TestColladaSkinInfluences(t *testing.T) {
+	skins, _, err := LoadColladaSkins("../art/astroBoy_walk_Maya.dae")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := skins[0]
+	// astroBoy never has more than 4 joints on a vertex.
+	if s.Capped != 0 {
+		t.Errorf("%d vertices capped", s.Capped)
+	}
+	for v := range s.Weights {
+		var total gl.Float
+		for k, w := range s.Weights[v] {
+			total += w
+			if k > 0 && w > s.Weights[v][k-1] {
+				t.Fatalf("vertex %d: weights %v aren't heaviest first", v, s.Weights[v])
+			}
+		}
+		if math.Abs(float64(total)-1) > 1e-5 {
+			t.Fatalf("vertex %d: weights %v add up to %g", v, s.Weights[v], total)
+		}
+	}
+}
+
+// One triangle on six joints.  The first vertex has all six, out of order;
+// the last has a -1, which is the bind shape.
+const cappedSkinDAE = `<COLLADA version="1.4.1">
+<library_geometries><geometry id="tri"><mesh>
+	<source id="pos"><float_array id="pos-a" count="9">0 0 0 1 0 0 0 1 0</float_array>
+		<technique_common><accessor source="#pos-a" count="3" stride="3">
+			<param name="X" type="float"/><param name="Y" type="float"/><param name="Z" type="float"/>
+		</accessor></technique_common></source>
+	<vertices id="verts"><input semantic="POSITION" source="#pos"/></vertices>
+	<triangles count="1"><input semantic="VERTEX" source="#verts" offset="0"/><p>0 1 2</p></triangles>
+</mesh></geometry></library_geometries>
+<library_controllers><controller id="skin"><skin source="#tri">
+	<source id="names"><Name_array id="names-a" count="6">j0 j1 j2 j3 j4 j5</Name_array>
+		<technique_common><accessor source="#names-a" count="6"><param name="JOINT" type="name"/></accessor></technique_common></source>
+	<source id="binds"><float_array id="binds-a" count="96">
+		1 0 0 0 0 1 0 0 0 0 1 0 0 0 0 1  1 0 0 0 0 1 0 0 0 0 1 0 0 0 0 1
+		1 0 0 0 0 1 0 0 0 0 1 0 0 0 0 1  1 0 0 0 0 1 0 0 0 0 1 0 0 0 0 1
+		1 0 0 0 0 1 0 0 0 0 1 0 0 0 0 1  1 0 0 0 0 1 0 0 0 0 1 0 0 0 0 1</float_array>
+		<technique_common><accessor source="#binds-a" count="6" stride="16"><param name="TRANSFORM" type="float4x4"/></accessor></technique_common></source>
+	<source id="weights"><float_array id="weights-a" count="7">0.05 0.3 0.1 0.25 0.1 0.2 1</float_array>
+		<technique_common><accessor source="#weights-a" count="7"><param name="WEIGHT" type="float"/></accessor></technique_common></source>
+	<joints><input semantic="JOINT" source="#names"/><input semantic="INV_BIND_MATRIX" source="#binds"/></joints>
+	<vertex_weights count="3">
+		<input semantic="JOINT" source="#names" offset="0"/><input semantic="WEIGHT" source="#weights" offset="1"/>
+		<vcount>6 1 2</vcount>
+		<v>0 0 1 1 2 2 3 3 4 4 5 5  2 6  -1 1 4 3</v>
+	</vertex_weights>
+</skin></controller></library_controllers>
+<library_visual_scenes><visual_scene id="scene">
+	<node id="j0" sid="j0" type="JOINT"/><node id="j1" sid="j1" type="JOINT"/><node id="j2" sid="j2" type="JOINT"/>
+	<node id="j3" sid="j3" type="JOINT"/><node id="j4" sid="j4" type="JOINT"/><node id="j5" sid="j5" type="JOINT"/>
+	<node id="body"><instance_controller url="#skin"/></node>
+</visual_scene></library_visual_scenes>
+</COLLADA>`
+
+func TestColladaSkinCapped(t *testing.T) {
+	var c Collada
+	if err := xml.Unmarshal([]byte(cappedSkinDAE), &c); err != nil {
+		t.Fatal(err)
+	}
+	skins, _, err := c.ImportSkins()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := skins[0]
+	if s.Capped != 1 {
+		t.Errorf("%d vertices capped, want 1", s.Capped)
+	}
+	if len(s.Influences) != 3 {
+		t.Fatalf("%d vertices", len(s.Influences))
+	}
+	// The four heaviest, renormalized; j4 and j0 are dropped.
+	tests := []struct {
+		joints  [MaxInfluences]int
+		weights [MaxInfluences]gl.Float
+	}{
+		{[MaxInfluences]int{1, 3, 5, 2}, [MaxInfluences]gl.Float{0.3 / 0.85, 0.25 / 0.85, 0.2 / 0.85, 0.1 / 0.85}},
+		{[MaxInfluences]int{2}, [MaxInfluences]gl.Float{1}},
+		// The bind shape is slot 6, after the joints.
+		{[MaxInfluences]int{6, 4}, [MaxInfluences]gl.Float{0.3 / 0.55, 0.25 / 0.55}},
+	}
+	for v, tt := range tests {
+		if s.Influences[v] != tt.joints {
+			t.Errorf("vertex %d: joints %v, want %v", v, s.Influences[v], tt.joints)
+		}
+		for k := range tt.weights {
+			if math.Abs(float64(s.Weights[v][k]-tt.weights[k])) > 1e-6 {
+				t.Errorf("vertex %d: weights %v, want %v", v, s.Weights[v], tt.weights)
+				break
+			}
+		}
+	}
+}
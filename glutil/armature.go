@@ -0,0 +1,464 @@
+/*
+armature.go - a tree of joints, each turned about its parent by a few
+rotation axes, loaded from JSON or XML so a model like the hierarchy
+demo's robot arm is data rather than code.
+
+	{"name": "arm", "joints": [
+		{"name": "base", "offset": [3, -5, -40],
+		 "axes": [{"name": "base", "axis": "y", "angle": -45, "keys": "ad"}]},
+		{"name": "upperArm", "parent": "base",
+		 "axes": [{"name": "upperArm", "axis": "x", "angle": -33.75, "min": -90, "max": 0}],
+		 "shape": {"offset": [0, 0, 3.5], "scale": [1, 1, 4.5]}}]}
+
+	<armature name="arm">
+		<joint name="base" offset="3 -5 -40">
+			<axis name="base" axis="y" angle="-45" keys="ad"/>
+		</joint>
+		...
+	</armature>
+
+A joint's frame is its parent's, moved by offset and then turned by each of
+its axes in order.  Its shape, if it has one, is a unit cube (or whatever
+the drawing code likes) moved and scaled within that frame.  An axis with
+min and max stays between them; one without wraps round at 360.  An axis
+that links to another follows its angle, times scale - the robot's right
+finger mirrors the left.
+
+Effectors are named points on parts, like the robot's fingertips, for
+the queries in armfk.go:
+
+	"effectors": [{"name": "leftTip", "part": "leftLowerFinger", "offset": [0, 0, 2]}]
+	<effector name="leftTip" part="leftLowerFinger" offset="0 0 2"/>
+
+An "ik" entry (an <ik> element in XML) names a chain of axes for the IK
+solvers in armik.go, and the point on its end part that reaches:
+
+	"ik": {"end": "wrist", "offset": [0, 0, 1], "axes": ["base", "upperArm"]}
+	<ik end="wrist" offset="0 0 1" axes="base upperArm"/>
+*/
+package glutil
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	gl "github.com/chsc/gogl/gl33"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+type Armature struct {
+	Name  string
+	Parts []*Part // parents before children
+	Roots []*Part
+	Axes  []*JointAxis // the named ones, in file order
+	IK    *IKChain     // if the file has one
+
+	Effectors []*Effector
+
+	parts     map[string]*Part
+	axes      map[string]*JointAxis
+	effectors map[string]*Effector
+}
+
+// A joint in the tree, and the shape hung off it.  (Joint is the skinning
+// code's.)
+type Part struct {
+	Name     string
+	Parent   *Part
+	Children []*Part
+	Offset   Vec3 // from the parent's frame
+	Axes     []*JointAxis
+	Shape    *JointShape // may be nil
+}
+
+// A rotation about Axis, in degrees.
+type JointAxis struct {
+	Name    string // "" for an axis nothing controls
+	Axis    Vec3
+	Angle   gl.Float
+	Limited bool
+	Min     gl.Float
+	Max     gl.Float
+	Step    gl.Float // how far Adjust turns it
+	Keys    string   // the demo's keys to turn it up and down
+
+	// Follow another axis instead: Angle is Scale times its angle.
+	Link  *JointAxis
+	Scale gl.Float
+}
+
+// A point on a part, in its frame.
+type Effector struct {
+	Name   string
+	Part   *Part
+	Offset Vec3
+}
+
+// What's drawn for a joint: the unit cube moved and scaled in its frame.
+type JointShape struct {
+	Offset Vec3
+	Scale  Vec3
+}
+
+// The file, in either format.
+type armatureFile struct {
+	XMLName   xml.Name    `json:"-" xml:"armature"`
+	Name      string      `json:"name" xml:"name,attr"`
+	Joints    []jointFile `json:"joints" xml:"joint"`
+	Effectors []struct {
+		Name   string    `json:"name" xml:"name,attr"`
+		Part   string    `json:"part" xml:"part,attr"`
+		Offset floatList `json:"offset" xml:"offset,attr"`
+	} `json:"effectors" xml:"effector"`
+	IK *struct {
+		End    string    `json:"end" xml:"end,attr"`
+		Offset floatList `json:"offset" xml:"offset,attr"`
+		Axes   nameList  `json:"axes" xml:"axes,attr"`
+	} `json:"ik" xml:"ik"`
+}
+
+type jointFile struct {
+	Name   string     `json:"name" xml:"name,attr"`
+	Parent string     `json:"parent" xml:"parent,attr"`
+	Offset floatList  `json:"offset" xml:"offset,attr"`
+	Axes   []axisFile `json:"axes" xml:"axis"`
+	Shape  *struct {
+		Offset floatList `json:"offset" xml:"offset,attr"`
+		Scale  floatList `json:"scale" xml:"scale,attr"`
+	} `json:"shape" xml:"shape"`
+}
+
+type axisFile struct {
+	Name  string   `json:"name" xml:"name,attr"`
+	Axis  string   `json:"axis" xml:"axis,attr"` // x, y, z, or "x y z"
+	Angle float64  `json:"angle" xml:"angle,attr"`
+	Min   *float64 `json:"min" xml:"min,attr"`
+	Max   *float64 `json:"max" xml:"max,attr"`
+	Step  float64  `json:"step" xml:"step,attr"`
+	Keys  string   `json:"keys" xml:"keys,attr"`
+	Link  string   `json:"link" xml:"link,attr"`
+	Scale *float64 `json:"scale" xml:"scale,attr"`
+}
+
+// A JSON array of numbers, or an XML attribute of them separated by
+// spaces.
+type floatList []float64
+
+func (f *floatList) UnmarshalXMLAttr(attr xml.Attr) error {
+	for _, s := range strings.Fields(attr.Value) {
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return fmt.Errorf("%s: %q isn't a number", attr.Name.Local, s)
+		}
+		*f = append(*f, v)
+	}
+	return nil
+}
+
+// Names, as a JSON array or separated by spaces in XML.
+type nameList []string
+
+func (n *nameList) UnmarshalXMLAttr(attr xml.Attr) error {
+	*n = strings.Fields(attr.Value)
+	return nil
+}
+
+// vec3 is the list as a Vec3, or def if it's empty.
+func (f floatList) vec3(def Vec3) (Vec3, error) {
+	switch len(f) {
+	case 0:
+		return def, nil
+	case 3:
+		return Vec3{gl.Float(f[0]), gl.Float(f[1]), gl.Float(f[2])}, nil
+	}
+	return def, fmt.Errorf("%d numbers, not 3", len(f))
+}
+
+const DefaultJointStep = 11.25
+
+// LoadArmature reads an armature from a .json or .xml file.
+func LoadArmature(file string) (*Armature, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	a, err := ParseArmature(data, strings.EqualFold(filepath.Ext(file), ".xml"))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", file, err)
+	}
+	return a, nil
+}
+
+// ParseArmature reads an armature from JSON, or XML if isXML.
+func ParseArmature(data []byte, isXML bool) (*Armature, error) {
+	var f armatureFile
+	var err error
+	if isXML {
+		err = xml.Unmarshal(data, &f)
+	} else {
+		err = json.Unmarshal(data, &f)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	a := &Armature{Name: f.Name, parts: make(map[string]*Part), axes: make(map[string]*JointAxis),
+		effectors: make(map[string]*Effector)}
+	links := make(map[*JointAxis]string)
+	var all []*Part
+	for _, jf := range f.Joints {
+		fail := func(format string, args ...interface{}) (*Armature, error) {
+			return nil, fmt.Errorf("joint %q: %s", jf.Name, fmt.Sprintf(format, args...))
+		}
+		if jf.Name == "" {
+			return nil, fmt.Errorf("a joint has no name")
+		}
+		if a.parts[jf.Name] != nil {
+			return fail("defined twice")
+		}
+		j := &Part{Name: jf.Name}
+		if j.Offset, err = jf.Offset.vec3(Vec3{}); err != nil {
+			return fail("offset: %s", err)
+		}
+		if s := jf.Shape; s != nil {
+			j.Shape = &JointShape{}
+			if j.Shape.Offset, err = s.Offset.vec3(Vec3{}); err != nil {
+				return fail("shape offset: %s", err)
+			}
+			if j.Shape.Scale, err = s.Scale.vec3(Vec3{1.0, 1.0, 1.0}); err != nil {
+				return fail("shape scale: %s", err)
+			}
+		}
+		for _, af := range jf.Axes {
+			ax, err := parseJointAxis(&af)
+			if err != nil {
+				return fail("%s", err)
+			}
+			if ax.Name != "" {
+				if a.axes[ax.Name] != nil {
+					return fail("axis %q defined twice", ax.Name)
+				}
+				a.axes[ax.Name] = ax
+				a.Axes = append(a.Axes, ax)
+			}
+			if af.Link != "" {
+				links[ax] = af.Link
+			}
+			j.Axes = append(j.Axes, ax)
+		}
+		a.parts[j.Name] = j
+		all = append(all, j)
+	}
+	for ax, name := range links {
+		if ax.Link = a.axes[name]; ax.Link == nil {
+			return nil, fmt.Errorf("axis %q links to %q, which isn't there", ax.Name, name)
+		}
+		if ax.Link.Link != nil {
+			return nil, fmt.Errorf("axis %q links to %q, which is itself linked", ax.Name, name)
+		}
+	}
+
+	for i, jf := range f.Joints {
+		j := all[i]
+		if jf.Parent == "" {
+			a.Roots = append(a.Roots, j)
+			continue
+		}
+		p := a.parts[jf.Parent]
+		if p == nil {
+			return nil, fmt.Errorf("joint %q: no parent %q", j.Name, jf.Parent)
+		}
+		j.Parent = p
+		p.Children = append(p.Children, j)
+	}
+	// Anything not reached from a root is in a loop.
+	for _, r := range a.Roots {
+		r.Walk(func(j *Part) bool {
+			a.Parts = append(a.Parts, j)
+			return true
+		})
+	}
+	if len(a.Parts) != len(all) {
+		return nil, fmt.Errorf("joints are their own ancestors")
+	}
+	for _, ef := range f.Effectors {
+		e := &Effector{Name: ef.Name, Part: a.parts[ef.Part]}
+		switch {
+		case e.Name == "":
+			return nil, fmt.Errorf("an effector has no name")
+		case a.effectors[e.Name] != nil || a.parts[e.Name] != nil:
+			return nil, fmt.Errorf("effector %q: there's already a part or effector called that", e.Name)
+		case e.Part == nil:
+			return nil, fmt.Errorf("effector %q: no part %q", e.Name, ef.Part)
+		}
+		if e.Offset, err = ef.Offset.vec3(Vec3{}); err != nil {
+			return nil, fmt.Errorf("effector %q: offset: %s", e.Name, err)
+		}
+		a.effectors[e.Name] = e
+		a.Effectors = append(a.Effectors, e)
+	}
+	if ik := f.IK; ik != nil {
+		offset, err := ik.Offset.vec3(Vec3{})
+		if err != nil {
+			return nil, fmt.Errorf("ik offset: %s", err)
+		}
+		if a.IK, err = NewIKChain(a, ik.End, offset, ik.Axes...); err != nil {
+			return nil, fmt.Errorf("ik: %s", err)
+		}
+	}
+	return a, nil
+}
+
+func parseJointAxis(af *axisFile) (*JointAxis, error) {
+	ax := &JointAxis{Name: af.Name, Angle: gl.Float(af.Angle), Step: gl.Float(af.Step),
+		Keys: strings.ToLower(af.Keys), Scale: 1.0}
+	switch strings.ToLower(af.Axis) {
+	case "x":
+		ax.Axis = Vec3{1.0, 0.0, 0.0}
+	case "y":
+		ax.Axis = Vec3{0.0, 1.0, 0.0}
+	case "z":
+		ax.Axis = Vec3{0.0, 0.0, 1.0}
+	default:
+		var f floatList
+		if err := f.UnmarshalXMLAttr(xml.Attr{Name: xml.Name{Local: "axis"}, Value: af.Axis}); err != nil {
+			return nil, err
+		}
+		v, err := f.vec3(Vec3{})
+		if err != nil || v.Length() == 0 {
+			return nil, fmt.Errorf("axis %q: bad axis %q", af.Name, af.Axis)
+		}
+		ax.Axis = *v.Normalize()
+	}
+	if ax.Step == 0 {
+		ax.Step = DefaultJointStep
+	}
+	if af.Scale != nil {
+		ax.Scale = gl.Float(*af.Scale)
+	}
+	if (af.Min == nil) != (af.Max == nil) {
+		return nil, fmt.Errorf("axis %q has a min or a max, but not both", af.Name)
+	}
+	if af.Min != nil {
+		ax.Limited = true
+		ax.Min, ax.Max = gl.Float(*af.Min), gl.Float(*af.Max)
+		if ax.Min > ax.Max {
+			return nil, fmt.Errorf("axis %q: min %g is over max %g", af.Name, ax.Min, ax.Max)
+		}
+	}
+	ax.Set(ax.Angle)
+	return ax, nil
+}
+
+// Part returns the part with the given name, or nil.
+func (a *Armature) Part(name string) *Part { return a.parts[name] }
+
+// Effector returns the named effector, or nil.
+func (a *Armature) Effector(name string) *Effector { return a.effectors[name] }
+
+// Axis returns the named axis, or nil.
+func (a *Armature) Axis(name string) *JointAxis { return a.axes[name] }
+
+// KeyAxis finds the axis a key turns, and whether it turns it up (the
+// first of its keys) or down (the second).
+func (a *Armature) KeyAxis(key rune) (*JointAxis, bool) {
+	for _, ax := range a.Axes {
+		switch strings.IndexRune(ax.Keys, key) {
+		case 0:
+			return ax, true
+		case 1:
+			return ax, false
+		}
+	}
+	return nil, false
+}
+
+// Set turns the axis to angle, kept inside its limits or wrapped to
+// within 360.  A linked axis can't be set.
+func (ax *JointAxis) Set(angle gl.Float) {
+	if ax.Link != nil {
+		return
+	}
+	if ax.Limited {
+		ax.Angle = Clamp(angle, ax.Min, ax.Max)
+	} else {
+		ax.Angle = ModGL(angle, 360.0)
+	}
+}
+
+// Adjust turns the axis by Step, up or down.
+func (ax *JointAxis) Adjust(bIncrement bool) {
+	if bIncrement {
+		ax.Set(ax.Angle + ax.Step)
+	} else {
+		ax.Set(ax.Angle - ax.Step)
+	}
+}
+
+// Current is the angle the axis is at, following its link if it has one.
+func (ax *JointAxis) Current() gl.Float {
+	if ax.Link != nil {
+		return ax.Link.Angle * ax.Scale
+	}
+	return ax.Angle
+}
+
+// Walk calls fn for j and everything below it, parents first.  Returning
+// false skips a joint's children.
+func (j *Part) Walk(fn func(*Part) bool) {
+	if !fn(j) {
+		return
+	}
+	for _, c := range j.Children {
+		c.Walk(fn)
+	}
+}
+
+// LocalMatrix is the joint's frame relative to its parent's.
+func (j *Part) LocalMatrix() *Mat4 {
+	m := TranslateMat4(&j.Offset)
+	for _, ax := range j.Axes {
+		m = m.MulM(RotateAxisMat4(&ax.Axis, ax.Current()))
+	}
+	return m
+}
+
+// ShapeMatrix places the unit shape in the joint's frame.
+func (s *JointShape) ShapeMatrix() *Mat4 {
+	return TranslateMat4(&s.Offset).MulM(ScaleMat4(&s.Scale))
+}
+
+// Draw walks the armature on ms, calling draw for every joint with a shape
+// with ms holding that shape's model matrix on top.  ms is left as it was
+// found.
+func (a *Armature) Draw(ms *MatrixStack, draw func(j *Part, ms *MatrixStack)) {
+	for _, r := range a.Roots {
+		r.Draw(ms, draw)
+	}
+}
+
+func (j *Part) Draw(ms *MatrixStack, draw func(j *Part, ms *MatrixStack)) {
+	j.walkStack(ms, func(j *Part, ms *MatrixStack) {
+		if j.Shape != nil {
+			ms.Push()
+			ms.ApplyMatrix(j.Shape.ShapeMatrix())
+			draw(j, ms)
+			ms.Pop()
+		}
+	})
+}
+
+// walkStack calls fn for j and everything below it, parents first, with
+// each one's frame on top of ms.
+func (j *Part) walkStack(ms *MatrixStack, fn func(j *Part, ms *MatrixStack)) {
+	ms.Push()
+	ms.ApplyMatrix(j.LocalMatrix())
+	fn(j, ms)
+	for _, c := range j.Children {
+		c.walkStack(ms, fn)
+	}
+	ms.Pop()
+}
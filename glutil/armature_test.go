@@ -0,0 +1,190 @@
+package glutil
+
+import (
+	"fmt"
+	gl "github.com/chsc/gogl/gl33"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// The robot arm of art/robotarm.json, as XML.
+const robotArmXML = `<armature name="robotarm">
+	<joint name="base" offset="3 -5 -40">
+		<axis name="base" axis="y" angle="-45" keys="ad"/>
+	</joint>
+	<joint name="baseLeft" parent="base" offset="2 0 0"><shape scale="1 1 3"/></joint>
+	<joint name="baseRight" parent="base" offset="-2 0 0"><shape scale="1 1 3"/></joint>
+
+	<joint name="upperArm" parent="base">
+		<axis name="upperArm" axis="x" angle="-33.75" min="-90" max="0" keys="sw"/>
+		<shape offset="0 0 3.5" scale="1 1 4.5"/>
+	</joint>
+	<joint name="lowerArm" parent="upperArm" offset="0 0 8">
+		<axis name="lowerArm" axis="x" angle="146.25" min="0" max="146.25" keys="fr"/>
+		<shape offset="0 0 2.5" scale="0.75 0.75 2.5"/>
+	</joint>
+
+	<joint name="wrist" parent="lowerArm" offset="0 0 5">
+		<axis name="wristRoll" axis="z" angle="0" keys="zc"/>
+		<axis name="wristPitch" axis="x" angle="67.5" min="0" max="90" keys="gt"/>
+		<shape scale="1 1 1"/>
+	</joint>
+
+	<joint name="leftFinger" parent="wrist" offset="1 0 1">
+		<axis name="fingerOpen" axis="y" angle="180" min="9" max="180" step="9" keys="qe"/>
+		<shape offset="0 0 1" scale="0.25 0.25 1"/>
+	</joint>
+	<joint name="leftLowerFinger" parent="leftFinger" offset="0 0 2">
+		<axis axis="y" angle="-45" min="-45" max="-45"/>
+		<shape offset="0 0 1" scale="0.25 0.25 1"/>
+	</joint>
+	<joint name="rightFinger" parent="wrist" offset="-1 0 1">
+		<axis axis="y" link="fingerOpen" scale="-1"/>
+		<shape offset="0 0 1" scale="0.25 0.25 1"/>
+	</joint>
+	<joint name="rightLowerFinger" parent="rightFinger" offset="0 0 2">
+		<axis axis="y" angle="45" min="45" max="45"/>
+		<shape offset="0 0 1" scale="0.25 0.25 1"/>
+	</joint>
+
+	<effector name="gripper" part="wrist" offset="0 0 1"/>
+	<effector name="leftTip" part="leftLowerFinger" offset="0 0 2"/>
+	<effector name="rightTip" part="rightLowerFinger" offset="0 0 2"/>
+	<ik end="wrist" offset="0 0 1" axes="base upperArm lowerArm wristPitch"/>
+</armature>`
+
+// describeArmature lists everything the file set, one line a part, axis
+// or effector.
+func describeArmature(a *Armature) []string {
+	s := []string{a.Name}
+	for _, p := range a.Parts {
+		parent := ""
+		if p.Parent != nil {
+			parent = p.Parent.Name
+		}
+		s = append(s, fmt.Sprintf("part %s < %s at %v", p.Name, parent, p.Offset))
+		if p.Shape != nil {
+			s = append(s, fmt.Sprintf("  shape %v %v", p.Shape.Offset, p.Shape.Scale))
+		}
+		for _, ax := range p.Axes {
+			link := ""
+			if ax.Link != nil {
+				link = ax.Link.Name
+			}
+			s = append(s, fmt.Sprintf("  axis %q %v %g limited %t [%g, %g] step %g keys %q link %q * %g",
+				ax.Name, ax.Axis, ax.Angle, ax.Limited, ax.Min, ax.Max, ax.Step, ax.Keys, link, ax.Scale))
+		}
+	}
+	for _, e := range a.Effectors {
+		s = append(s, fmt.Sprintf("effector %s on %s at %v", e.Name, e.Part.Name, e.Offset))
+	}
+	if ik := a.IK; ik != nil {
+		var axes []string
+		for _, ax := range ik.Axes {
+			axes = append(axes, ax.Name)
+		}
+		s = append(s, fmt.Sprintf("ik %s at %v: %v", ik.End.Name, ik.EndOffset, axes))
+	}
+	return s
+}
+
+func TestArmatureXMLMatchesJSON(t *testing.T) {
+	fromJSON := loadRobotArm(t)
+	fromXML, err := ParseArmature([]byte(robotArmXML), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, want := describeArmature(fromXML), describeArmature(fromJSON)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("XML:\n%s\nJSON:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if len(want) != 1+10+9+9+3+1 {
+		t.Errorf("%d lines: %q", len(want), want)
+	}
+}
+
+func TestParseArmatureErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		joints string
+		want   string
+	}{
+		{"min only", `{"name": "a", "axes": [{"name": "x", "axis": "x", "min": 0}]}`,
+			`joint "a": axis "x" has a min or a max, but not both`},
+		{"max only", `{"name": "a", "axes": [{"name": "x", "axis": "x", "max": 0}]}`,
+			`joint "a": axis "x" has a min or a max, but not both`},
+		{"min over max", `{"name": "a", "axes": [{"name": "x", "axis": "x", "min": 10, "max": -10}]}`,
+			`joint "a": axis "x": min 10 is over max -10`},
+		{"bad axis", `{"name": "a", "axes": [{"name": "x", "axis": "0 0 0"}]}`,
+			`joint "a": axis "x": bad axis "0 0 0"`},
+		{"duplicate axis", `{"name": "a", "axes": [{"name": "x", "axis": "x"}]},
+			{"name": "b", "parent": "a", "axes": [{"name": "x", "axis": "y"}]}`,
+			`joint "b": axis "x" defined twice`},
+		{"duplicate joint", `{"name": "a"}, {"name": "a"}`,
+			`joint "a": defined twice`},
+		{"missing link", `{"name": "a", "axes": [{"name": "x", "axis": "x", "link": "y"}]}`,
+			`axis "x" links to "y", which isn't there`},
+		{"chained link", `{"name": "a", "axes": [{"name": "x", "axis": "x"},
+			{"name": "y", "axis": "y", "link": "x"}, {"name": "z", "axis": "z", "link": "y"}]}`,
+			`axis "z" links to "y", which is itself linked`},
+		{"no parent", `{"name": "a", "parent": "b"}`,
+			`joint "a": no parent "b"`},
+		{"cycle", `{"name": "root"}, {"name": "a", "parent": "b"}, {"name": "b", "parent": "a"}`,
+			`joints are their own ancestors`},
+		{"own parent", `{"name": "a", "parent": "a"}`,
+			`joints are their own ancestors`},
+	}
+	for _, tt := range tests {
+		_, err := ParseArmature([]byte(`{"name": "arm", "joints": [`+tt.joints+`]}`), false)
+		if err == nil || err.Error() != tt.want {
+			t.Errorf("%s: %v, want %s", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestJointAxisSet(t *testing.T) {
+	a := loadRobotArm(t)
+	tests := []struct {
+		axis      string
+		set, want gl.Float
+	}{
+		// upperArm is limited to [-90, 0].
+		{"upperArm", -45, -45},
+		{"upperArm", 30, 0},
+		{"upperArm", -200, -90},
+		// base isn't, so wraps at 360, keeping its sign as the original's
+		// fmodf did.
+		{"base", 370, 10},
+		{"base", 360, 0},
+		{"base", -45, -45},
+		{"base", -400, -40},
+		{"base", -720, 0},
+	}
+	for _, tt := range tests {
+		ax := a.Axis(tt.axis)
+		ax.Set(tt.set)
+		if !near(ax.Angle, tt.want) {
+			t.Errorf("%s.Set(%g) = %g, want %g", tt.axis, tt.set, ax.Angle, tt.want)
+		}
+	}
+
+	// Adjust steps by Step and stays inside the limits too.
+	finger := a.Axis("fingerOpen")
+	finger.Set(175)
+	finger.Adjust(true)
+	if finger.Angle != 180 {
+		t.Errorf("fingerOpen stepped up from 175 to %g", finger.Angle)
+	}
+	finger.Adjust(false)
+	if finger.Angle != 171 {
+		t.Errorf("fingerOpen stepped down from 180 to %g", finger.Angle)
+	}
+
+	// A linked axis follows its link, and setting it does nothing.
+	right := a.Part("rightFinger").Axes[0]
+	right.Set(10)
+	if right.Angle != 0 || right.Current() != -171 {
+		t.Errorf("rightFinger is at %g, %g", right.Angle, right.Current())
+	}
+}
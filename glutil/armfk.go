@@ -0,0 +1,262 @@
+/*
+armfk.go - where an Armature's parts and effectors are in its current
+pose, and the boxes round its shapes, without drawing anything.
+
+Model space is the armature's own, the frame its roots hang in; world
+space is whatever the caller puts under it, the matrix that would be on
+the stack when it's drawn.  A nil world is the identity, so world and
+model are the same - as they are in the hierarchy demo, which draws the
+arm straight into camera space.  The matrices come from the same
+MatrixStack walk Draw does, so they're what's drawn.
+
+Shapes are the ±1 cube scaled and moved by their JointShape, so a part's
+OBB is its shape matrix taken apart and its AABB is the box round the
+OBB's corners.
+*/
+package glutil
+
+import (
+	"fmt"
+	gl "github.com/chsc/gogl/gl33"
+)
+
+// An oriented box: Center plus or minus HalfSize along each of Axes.
+type OBB struct {
+	Center   Vec3
+	Axes     [3]Vec3 // unit length
+	HalfSize Vec3
+}
+
+// An axis-aligned box.
+type AABB struct {
+	Min, Max Vec3
+}
+
+// ModelMatrix is the part's frame in its armature's space.
+func (p *Part) ModelMatrix() *Mat4 {
+	var chain []*Part
+	for q := p; q != nil; q = q.Parent {
+		chain = append(chain, q)
+	}
+	var ms MatrixStack
+	ms.Init()
+	for i := len(chain) - 1; i >= 0; i-- {
+		ms.ApplyMatrix(chain[i].LocalMatrix())
+	}
+	return ms.Current()
+}
+
+// ModelMatrix is the effector's frame in its armature's space: its part's,
+// moved to the effector.
+func (e *Effector) ModelMatrix() *Mat4 {
+	return e.Part.ModelMatrix().MulM(TranslateMat4(&e.Offset))
+}
+
+// Frames is every part's frame, placed by world, in one walk down the
+// armature.
+func (a *Armature) Frames(world *Mat4) map[*Part]*Mat4 {
+	frames := make(map[*Part]*Mat4)
+	var ms MatrixStack
+	ms.Init()
+	if world != nil {
+		ms.Set(world)
+	}
+	for _, r := range a.Roots {
+		r.walkStack(&ms, func(p *Part, ms *MatrixStack) {
+			frames[p] = ms.Current()
+		})
+	}
+	return frames
+}
+
+// ModelMatrix is the frame of the named part or effector in the
+// armature's space.
+func (a *Armature) ModelMatrix(name string) (*Mat4, error) {
+	if p := a.parts[name]; p != nil {
+		return p.ModelMatrix(), nil
+	}
+	if e := a.effectors[name]; e != nil {
+		return e.ModelMatrix(), nil
+	}
+	return nil, fmt.Errorf("%s has no part or effector %q", a.Name, name)
+}
+
+// WorldMatrix is ModelMatrix placed by world.
+func (a *Armature) WorldMatrix(world *Mat4, name string) (*Mat4, error) {
+	m, err := a.ModelMatrix(name)
+	if err != nil || world == nil {
+		return m, err
+	}
+	return world.MulM(m), nil
+}
+
+// Position is where the named part's origin or effector is, placed by
+// world.
+func (a *Armature) Position(world *Mat4, name string) (*Vec3, error) {
+	m, err := a.WorldMatrix(world, name)
+	if err != nil {
+		return nil, err
+	}
+	return m.TransformPoint(&Vec3{}), nil
+}
+
+// ShapeMatrix is the named part's shape's model matrix, placed by world,
+// as Draw hands it over.
+func (a *Armature) ShapeMatrix(world *Mat4, name string) (*Mat4, error) {
+	p := a.parts[name]
+	if p == nil {
+		return nil, fmt.Errorf("%s has no part %q", a.Name, name)
+	}
+	if p.Shape == nil {
+		return nil, fmt.Errorf("part %q has no shape", name)
+	}
+	m, _ := a.WorldMatrix(world, name)
+	return m.MulM(p.Shape.ShapeMatrix()), nil
+}
+
+// OBB is the box round the named part's shape.
+func (a *Armature) OBB(world *Mat4, name string) (*OBB, error) {
+	m, err := a.ShapeMatrix(world, name)
+	if err != nil {
+		return nil, err
+	}
+	return MatrixOBB(m), nil
+}
+
+// AABB is the axis-aligned box round the named part's shape.
+func (a *Armature) AABB(world *Mat4, name string) (*AABB, error) {
+	b, err := a.OBB(world, name)
+	if err != nil {
+		return nil, err
+	}
+	return b.AABB(), nil
+}
+
+// Bounds is the box round every shape in the armature, or nil if it has
+// none.
+func (a *Armature) Bounds(world *Mat4) *AABB {
+	var all *AABB
+	for p, m := range a.Frames(world) {
+		if p.Shape == nil {
+			continue
+		}
+		b := MatrixOBB(m.MulM(p.Shape.ShapeMatrix())).AABB()
+		if all == nil {
+			all = b
+		} else {
+			all = all.Union(b)
+		}
+	}
+	return all
+}
+
+// MatrixOBB is where m puts the ±1 cube.  m may shear it, in which case
+// this is only roughly right.
+func MatrixOBB(m *Mat4) *OBB {
+	b := &OBB{Center: Vec3{m[3].X, m[3].Y, m[3].Z}}
+	for i := 0; i < 3; i++ {
+		col := Vec3{m[i].X, m[i].Y, m[i].Z}
+		l := col.Length()
+		b.HalfSize.SetElem(i, l)
+		if l > 0 {
+			b.Axes[i] = *col.MulS(1.0 / l)
+		}
+	}
+	return b
+}
+
+// Corners are the box's eight corners.
+func (b *OBB) Corners() [8]Vec3 {
+	var c [8]Vec3
+	for i := range c {
+		p := b.Center
+		for k := 0; k < 3; k++ {
+			s := b.HalfSize.Elem(k)
+			if i&(1<<uint(k)) == 0 {
+				s = -s
+			}
+			p = *p.Add(b.Axes[k].MulS(s))
+		}
+		c[i] = p
+	}
+	return c
+}
+
+// AABB is the axis-aligned box round b.
+func (b *OBB) AABB() *AABB {
+	var half Vec3
+	for k := 0; k < 3; k++ {
+		for i := 0; i < 3; i++ {
+			half.SetElem(k, half.Elem(k)+AbsGL(b.Axes[i].Elem(k))*b.HalfSize.Elem(i))
+		}
+	}
+	return &AABB{Min: *b.Center.Sub(&half), Max: *b.Center.Add(&half)}
+}
+
+// Contains says whether p is inside b, or on its surface.
+func (b *OBB) Contains(p *Vec3) bool {
+	d := p.Sub(&b.Center)
+	for i := 0; i < 3; i++ {
+		if AbsGL(d.Dot(&b.Axes[i])) > b.HalfSize.Elem(i)+1e-5 {
+			return false
+		}
+	}
+	return true
+}
+
+// Intersects says whether b and o overlap, by the separating axis test.
+func (b *OBB) Intersects(o *OBB) bool {
+	axes := []Vec3{b.Axes[0], b.Axes[1], b.Axes[2], o.Axes[0], o.Axes[1], o.Axes[2]}
+	for i := 0; i < 3; i++ {
+		for k := 0; k < 3; k++ {
+			if c := b.Axes[i].Cross(&o.Axes[k]); c.Length() > 1e-5 {
+				axes = append(axes, *c.Normalize())
+			}
+		}
+	}
+	d := o.Center.Sub(&b.Center)
+	for _, ax := range axes {
+		if AbsGL(d.Dot(&ax)) > b.radius(&ax)+o.radius(&ax) {
+			return false
+		}
+	}
+	return true
+}
+
+// How far b reaches along ax either side of its centre.
+func (b *OBB) radius(ax *Vec3) gl.Float {
+	var r gl.Float
+	for i := 0; i < 3; i++ {
+		r += AbsGL(b.Axes[i].Dot(ax)) * b.HalfSize.Elem(i)
+	}
+	return r
+}
+
+func (b *AABB) Center() *Vec3 { return b.Min.Lerp(&b.Max, 0.5) }
+func (b *AABB) Size() *Vec3   { return b.Max.Sub(&b.Min) }
+
+func (b *AABB) Contains(p *Vec3) bool {
+	return p.X >= b.Min.X && p.X <= b.Max.X &&
+		p.Y >= b.Min.Y && p.Y <= b.Max.Y &&
+		p.Z >= b.Min.Z && p.Z <= b.Max.Z
+}
+
+func (b *AABB) Intersects(o *AABB) bool {
+	return b.Min.X <= o.Max.X && o.Min.X <= b.Max.X &&
+		b.Min.Y <= o.Max.Y && o.Min.Y <= b.Max.Y &&
+		b.Min.Z <= o.Max.Z && o.Min.Z <= b.Max.Z
+}
+
+// Union is the box round both.
+func (b *AABB) Union(o *AABB) *AABB {
+	u := *b
+	for k := 0; k < 3; k++ {
+		if o.Min.Elem(k) < u.Min.Elem(k) {
+			u.Min.SetElem(k, o.Min.Elem(k))
+		}
+		if o.Max.Elem(k) > u.Max.Elem(k) {
+			u.Max.SetElem(k, o.Max.Elem(k))
+		}
+	}
+	return &u
+}
@@ -0,0 +1,164 @@
+package glutil
+
+import (
+	gl "github.com/chsc/gogl/gl33"
+	"testing"
+)
+
+// stackFrame walks down to p on a MatrixStack the way the hierarchy demo
+// did, a translate and a rotate at a time.
+func stackFrame(world *Mat4, p *Part) *Mat4 {
+	var chain []*Part
+	for q := p; q != nil; q = q.Parent {
+		chain = append(chain, q)
+	}
+	ms := NewMatrixStack()
+	if world != nil {
+		ms.Set(world)
+	}
+	for i := len(chain) - 1; i >= 0; i-- {
+		q := chain[i]
+		ms.Translate(&Vec4{X: q.Offset.X, Y: q.Offset.Y, Z: q.Offset.Z, W: 1})
+		for _, ax := range q.Axes {
+			ms.Rotate(&ax.Axis, ax.Current())
+		}
+	}
+	return ms.Current()
+}
+
+func TestArmaturePositions(t *testing.T) {
+	a := loadRobotArm(t)
+	a.SetPose(ArmPose{"base": 20, "upperArm": -60, "lowerArm": 100, "wristRoll": 30, "wristPitch": 45, "fingerOpen": 90})
+	world := TranslateMat4(&Vec3{X: 1, Y: 2, Z: 3}).MulM(RotateYMat4(90))
+	frames := a.Frames(world)
+	for _, w := range []*Mat4{nil, world} {
+		for _, p := range a.Parts {
+			want := stackFrame(w, p)
+			got, err := a.WorldMatrix(w, p.Name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !mat4Near(got, want, 1e-4) {
+				t.Errorf("%s: frame %v, want %v", p.Name, *got, *want)
+			}
+			if w != nil && !mat4Near(frames[p], want, 1e-4) {
+				t.Errorf("%s: Frames has %v, want %v", p.Name, *frames[p], *want)
+			}
+			pos, _ := a.Position(w, p.Name)
+			if !vec3Near(pos, want.TransformPoint(&Vec3{}), 1e-4) {
+				t.Errorf("%s: at %v", p.Name, *pos)
+			}
+		}
+		for _, e := range a.Effectors {
+			want := stackFrame(w, e.Part).TransformPoint(&e.Offset)
+			got, err := a.Position(w, e.Name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !vec3Near(got, want, 1e-4) {
+				t.Errorf("%s: at %v, want %v", e.Name, *got, *want)
+			}
+		}
+	}
+
+	if _, err := a.Position(nil, "elbow"); err == nil {
+		t.Errorf("found elbow")
+	}
+}
+
+func TestArmatureShapes(t *testing.T) {
+	// ShapeMatrix is what Draw hands over.
+	a := loadRobotArm(t)
+	world := TranslateMat4(&Vec3{X: 0, Y: 0, Z: -10})
+	ms := NewMatrixStack()
+	ms.Set(world)
+	drawn := 0
+	a.Draw(ms, func(p *Part, ms *MatrixStack) {
+		drawn++
+		got, err := a.ShapeMatrix(world, p.Name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !mat4Near(got, ms.Current(), 1e-4) {
+			t.Errorf("%s: shape %v, drawn at %v", p.Name, *got, *ms.Current())
+		}
+	})
+	if drawn != len(a.Parts)-1 {
+		t.Errorf("drew %d parts, want %d", drawn, len(a.Parts)-1)
+	}
+	if _, err := a.ShapeMatrix(nil, "base"); err == nil {
+		t.Errorf("base has a shape")
+	}
+}
+
+func TestArmatureWaveTip(t *testing.T) {
+	// The README's example: armeval -check art/robotarm_wave.json 4.5 @leftTip 7.43 -3.76 -30.64
+	a := loadRobotArm(t)
+	tl, err := LoadTimeline("../art/robotarm_wave.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tl.Apply(a, 4.5)
+	got, err := a.Position(nil, "leftTip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (Vec3{X: 7.43, Y: -3.76, Z: -30.64}); !vec3Near(got, &want, 0.01) {
+		t.Errorf("leftTip at %v at 4.5s, want %v", *got, want)
+	}
+}
+
+func TestArmatureBoxes(t *testing.T) {
+	// baseLeft is the 1x1x3 half-size box, turned -45 degrees about y with
+	// the base: its x and z reach cos 45 (1+3) either way.
+	a := loadRobotArm(t)
+	b, err := a.OBB(nil, "baseLeft")
+	if err != nil {
+		t.Fatal(err)
+	}
+	center, _ := a.Position(nil, "baseLeft")
+	if !vec3Near(&b.Center, center, 1e-4) || !vec3Near(&b.HalfSize, &Vec3{X: 1, Y: 1, Z: 3}, 1e-4) {
+		t.Errorf("baseLeft OBB %+v", *b)
+	}
+	if !b.Contains(center) || b.Contains(center.Add(&Vec3{X: 0, Y: 1.1, Z: 0})) {
+		t.Errorf("baseLeft OBB contains the wrong points")
+	}
+	box, _ := a.AABB(nil, "baseLeft")
+	reach := gl.Float(0.70710678 * 4)
+	if !vec3Near(box.Size(), &Vec3{X: 2 * reach, Y: 2, Z: 2 * reach}, 1e-4) || !vec3Near(box.Center(), center, 1e-4) {
+		t.Errorf("baseLeft AABB %+v", *box)
+	}
+	for _, c := range b.Corners() {
+		if !box.Contains(c.Add(c.Sub(box.Center()).MulS(-1e-5))) {
+			t.Errorf("corner %v outside %+v", c, *box)
+		}
+	}
+
+	// Moving the world moves the boxes.
+	shift := Vec3{X: 5, Y: -2, Z: 7}
+	moved, _ := a.AABB(TranslateMat4(&shift), "baseLeft")
+	if !vec3Near(&moved.Min, box.Min.Add(&shift), 1e-4) || !vec3Near(&moved.Max, box.Max.Add(&shift), 1e-4) {
+		t.Errorf("moved AABB %+v", *moved)
+	}
+
+	// The bounds hold every part's box, and the base's two halves are 2
+	// apart.
+	all := a.Bounds(nil)
+	for _, p := range a.Parts {
+		if p.Shape == nil {
+			continue
+		}
+		pb, _ := a.AABB(nil, p.Name)
+		if !all.Contains(&pb.Min) || !all.Contains(&pb.Max) {
+			t.Errorf("%s's box %+v is outside %+v", p.Name, *pb, *all)
+		}
+	}
+	left, _ := a.OBB(nil, "baseLeft")
+	right, _ := a.OBB(nil, "baseRight")
+	if left.Intersects(right) {
+		t.Errorf("baseLeft and baseRight overlap")
+	}
+	if far := (&OBB{Center: Vec3{X: 100}, Axes: left.Axes, HalfSize: left.HalfSize}); left.Intersects(far) {
+		t.Errorf("an OBB 100 units away overlaps")
+	}
+}
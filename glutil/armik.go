@@ -0,0 +1,406 @@
+/*
+armik.go - inverse kinematics for an Armature: turn a chain of its axes so
+a point on the end part reaches a target.
+
+Both solvers work in the armature's space (the frame its roots hang in)
+and leave the armature posed as well as they could get it, every axis
+inside its limits.  CCD turns one axis at a time, end first, to swing the
+end at the target.  FABRIK drags the chain's joint positions back and forth
+between the target and the root, then turns the axes to follow them -
+limits and all - and goes round again from wherever that left it.
+*/
+package glutil
+
+import (
+	"fmt"
+	gl "github.com/chsc/gogl/gl33"
+	"math"
+)
+
+type IKChain struct {
+	Armature  *Armature
+	Axes      []*JointAxis // root first
+	End       *Part
+	EndOffset Vec3 // the point on End that reaches, in its frame
+
+	Tolerance     gl.Float // close enough, in the armature's units
+	MaxIterations int
+
+	owner map[*JointAxis]*Part
+}
+
+type IKResult struct {
+	Reached    bool     // to within Tolerance
+	InReach    bool     // the target's no further than the chain is long
+	Distance   gl.Float // from the end to the target, when it gave up
+	Iterations int
+	Pose       ArmPose // the chain's named axes
+}
+
+// NewIKChain chains the named axes, root first, to reach with offset on
+// the end part.  Each axis must be on end or one of its ancestors, and
+// none can be linked - something else turns those.
+func NewIKChain(a *Armature, end string, offset Vec3, axes ...string) (*IKChain, error) {
+	c := &IKChain{Armature: a, End: a.Part(end), EndOffset: offset,
+		Tolerance: 0.01, MaxIterations: 50, owner: make(map[*JointAxis]*Part)}
+	if c.End == nil {
+		return nil, fmt.Errorf("%s has no part %q", a.Name, end)
+	}
+	for p := c.End; p != nil; p = p.Parent {
+		for _, ax := range p.Axes {
+			c.owner[ax] = p
+		}
+	}
+	for _, name := range axes {
+		ax := a.Axis(name)
+		switch {
+		case ax == nil:
+			return nil, fmt.Errorf("%s has no axis %q", a.Name, name)
+		case c.owner[ax] == nil:
+			return nil, fmt.Errorf("axis %q doesn't move %q", name, end)
+		case ax.Link != nil:
+			return nil, fmt.Errorf("axis %q is linked, so IK can't turn it", name)
+		}
+		c.Axes = append(c.Axes, ax)
+	}
+	if len(c.Axes) == 0 {
+		return nil, fmt.Errorf("an IK chain needs an axis")
+	}
+	return c, nil
+}
+
+// Effector is where the end point is now.
+func (c *IKChain) Effector() *Vec3 {
+	return c.End.ModelMatrix().TransformPoint(&c.EndOffset)
+}
+
+// Reach is the furthest the end can get from the first axis, limits
+// aside - the length of the chain.
+func (c *IKChain) Reach() gl.Float {
+	pts := c.chainPoints()
+	var l gl.Float
+	for i := 1; i < len(pts); i++ {
+		l += pts[i].Sub(&pts[i-1]).Length()
+	}
+	return l
+}
+
+// axisFrame is where ax turns about and which way it points, in the
+// armature's space, as the armature is now.
+func (c *IKChain) axisFrame(ax *JointAxis) (pivot, dir *Vec3) {
+	p := c.owner[ax]
+	m := TranslateMat4(&p.Offset)
+	if p.Parent != nil {
+		m = p.Parent.ModelMatrix().MulM(m)
+	}
+	for _, a := range p.Axes {
+		if a == ax {
+			break
+		}
+		m = m.MulM(RotateAxisMat4(&a.Axis, a.Current()))
+	}
+	return m.TransformPoint(&Vec3{}), m.TransformDir(&ax.Axis).Normalize()
+}
+
+// turnToward turns ax as far as it can to swing from round its pivot
+// toward to, and says how many degrees it went.
+func (c *IKChain) turnToward(ax *JointAxis, from, to *Vec3) gl.Float {
+	pivot, dir := c.axisFrame(ax)
+	d := signedAngle(from.Sub(pivot), to.Sub(pivot), dir)
+	old := ax.Angle
+	ax.Set(limitedAngle(ax, old+d))
+	return ax.Angle - old
+}
+
+// limitedAngle picks whichever of angle and its turns either way round
+// lands nearest ax's limits, so a limited axis isn't sent the long way.
+func limitedAngle(ax *JointAxis, angle gl.Float) gl.Float {
+	if !ax.Limited {
+		return angle
+	}
+	best, bestOut := angle, gl.Float(math.MaxFloat32)
+	for _, a := range []gl.Float{angle, angle - 360.0, angle + 360.0} {
+		out := AbsGL(a - Clamp(a, ax.Min, ax.Max))
+		if out < bestOut {
+			best, bestOut = a, out
+		}
+	}
+	return best
+}
+
+func (c *IKChain) result(target *Vec3, iterations int) IKResult {
+	d := c.Effector().Sub(target).Length()
+	pose := make(ArmPose)
+	for _, ax := range c.Axes {
+		if ax.Name != "" {
+			pose[ax.Name] = ax.Angle
+		}
+	}
+	root := c.chainPoints()[0]
+	return IKResult{Reached: d <= c.Tolerance, InReach: target.Sub(&root).Length() <= c.Reach(),
+		Distance: d, Iterations: iterations, Pose: pose}
+}
+
+// SolveCCD reaches for target by cyclic coordinate descent.
+func (c *IKChain) SolveCCD(target *Vec3) IKResult {
+	return c.retry(target, c.ccd)
+}
+
+func (c *IKChain) ccd(target *Vec3) IKResult {
+	for i := 1; i <= c.MaxIterations; i++ {
+		var moved gl.Float
+		for k := len(c.Axes) - 1; k >= 0; k-- {
+			moved += AbsGL(c.turnToward(c.Axes[k], c.Effector(), target))
+		}
+		if c.Effector().Sub(target).Length() <= c.Tolerance || moved < 1e-4 {
+			return c.result(target, i)
+		}
+	}
+	return c.result(target, c.MaxIterations)
+}
+
+// The chain's joints for FABRIK: each part that owns an axis, root
+// first, with the parts that sit on top of each other (the robot's base
+// and upper arm, say) as one joint.  Then the end.
+type ikJoint struct {
+	axes []*JointAxis
+	part *Part
+}
+
+func (c *IKChain) ikJoints() []ikJoint {
+	var joints []ikJoint
+	var last *Vec3
+	for _, ax := range c.Axes {
+		p := c.owner[ax]
+		pos := p.ModelMatrix().TransformPoint(&Vec3{})
+		n := len(joints)
+		if n > 0 && (joints[n-1].part == p || pos.Sub(last).Length() < 1e-4) {
+			joints[n-1].axes = append(joints[n-1].axes, ax)
+			continue
+		}
+		joints = append(joints, ikJoint{axes: []*JointAxis{ax}, part: p})
+		last = pos
+	}
+	return joints
+}
+
+// chainPoints is where the FABRIK joints and the end are now.
+func (c *IKChain) chainPoints() []Vec3 {
+	var pts []Vec3
+	for _, j := range c.ikJoints() {
+		pts = append(pts, *j.part.ModelMatrix().TransformPoint(&Vec3{}))
+	}
+	return append(pts, *c.Effector())
+}
+
+// SolveFABRIK reaches for target by forward and backward reaching.
+func (c *IKChain) SolveFABRIK(target *Vec3) IKResult {
+	return c.retry(target, c.fabrik)
+}
+
+// retry solves from where the chain is, which is what keeps it steady
+// while it's dragged about.  But a chain folded up against its limits can
+// stick, so if it doesn't get there it tries again from halfway between
+// every limit and keeps whichever got closer.
+func (c *IKChain) retry(target *Vec3, solve func(*Vec3) IKResult) IKResult {
+	res := solve(target)
+	if res.Reached {
+		return res
+	}
+	first := c.Armature.Pose()
+	for _, ax := range c.Axes {
+		if ax.Limited {
+			ax.Set((ax.Min + ax.Max) / 2.0)
+		}
+	}
+	again := solve(target)
+	again.Iterations += res.Iterations
+	if again.Distance < res.Distance {
+		return again
+	}
+	c.Armature.SetPose(first)
+	res.Iterations = again.Iterations
+	return res
+}
+
+func (c *IKChain) fabrik(target *Vec3) IKResult {
+	joints := c.ikJoints()
+	last := c.Effector().Sub(target).Length()
+	for i := 1; i <= c.MaxIterations; i++ {
+		pts := c.chainPoints()
+		n := len(pts)
+		lens := make([]gl.Float, n-1)
+		for k := range lens {
+			lens[k] = pts[k+1].Sub(&pts[k]).Length()
+		}
+		root := pts[0]
+		// A joint with one axis can only bend across it, so its
+		// neighbours stay on that plane, and only as far as its limits.
+		// How far it bends is its angle give or take what it bends
+		// by at 0, which this pose tells us.
+		hinges := make([]*Vec3, len(joints))
+		bendAt0 := make([]gl.Float, len(joints))
+		for k, j := range joints {
+			if len(j.axes) == 1 {
+				_, hinges[k] = c.axisFrame(j.axes[0])
+				if k > 0 {
+					bendAt0[k] = bend(&pts[k-1], &pts[k], &pts[k+1], hinges[k]) - j.axes[0].Angle
+				}
+			}
+		}
+		cur := append([]Vec3(nil), pts...)
+
+		// Out to the target, and back to the root
+		pts[n-1] = *target
+		for k := n - 2; k >= 0; k-- {
+			pts[k] = *fabrikPull(&pts[k+1], onHinge(&pts[k], &pts[k+1], hinges[k]), lens[k])
+			if j := k + 1; j < len(joints) && j > 0 {
+				if b, ok := c.limitBend(joints[j], hinges[j], bendAt0[j], &pts[k], &pts[j], &pts[j+1]); ok {
+					out := pts[j+1].Sub(&pts[j]).Normalize()
+					in := RotateAxisMat4(hinges[j], -b).TransformDir(out)
+					pts[k] = *pts[j].Sub(in.MulS(lens[k]))
+				}
+			}
+		}
+		// Going out again each joint turns the ones after it, and their
+		// hinges with them
+		pts[0] = root
+		turned := IdentMat4()
+		for k := 1; k < n; k++ {
+			j := k - 1
+			was := turned.TransformDir(cur[k].Sub(&cur[j]))
+			if j == 0 {
+				var out *Vec3
+				out, turned = c.rootTurn(joints[0], was, pts[1].Sub(&pts[0]))
+				pts[1] = *pts[0].Add(out.Normalize().MulS(lens[0]))
+				continue
+			}
+			var hinge *Vec3
+			if hinges[j] != nil {
+				hinge = turned.TransformDir(hinges[j]).Normalize()
+			}
+			pts[k] = *fabrikPull(&pts[j], onHinge(&pts[k], &pts[j], hinge), lens[j])
+			if b, ok := c.limitBend(joints[j], hinge, bendAt0[j], &pts[j-1], &pts[j], &pts[k]); ok {
+				in := pts[j].Sub(&pts[j-1]).Normalize()
+				out := RotateAxisMat4(hinge, b).TransformDir(in)
+				pts[k] = *pts[j].Add(out.MulS(lens[j]))
+			}
+			if hinge != nil {
+				now := pts[k].Sub(&pts[j])
+				turned = RotateAxisMat4(hinge, signedAngle(was, now, hinge)).MulM(turned)
+			}
+		}
+
+		// Turn the joints to follow, root first, as their limits allow
+		var moved gl.Float
+		for k, j := range joints {
+			for _, ax := range j.axes {
+				var from *Vec3
+				if k+1 < len(joints) {
+					from = joints[k+1].part.ModelMatrix().TransformPoint(&Vec3{})
+				} else {
+					from = c.Effector()
+				}
+				moved += AbsGL(c.turnToward(ax, from, &pts[k+1]))
+			}
+		}
+		// Stuck against a limit, FABRIK goes round in the same circle;
+		// a CCD sweep straight at the target knocks it out
+		d := c.Effector().Sub(target).Length()
+		if d > c.Tolerance && d > last-c.Tolerance {
+			for k := len(c.Axes) - 1; k >= 0; k-- {
+				moved += AbsGL(c.turnToward(c.Axes[k], c.Effector(), target))
+			}
+			d = c.Effector().Sub(target).Length()
+		}
+		last = d
+		if d <= c.Tolerance || moved < 1e-4 {
+			return c.result(target, i)
+		}
+	}
+	return c.result(target, c.MaxIterations)
+}
+
+// rootTurn is where the root joint's axes, turning in order as their
+// limits allow, would swing its bone from out toward want, and the turn
+// that takes it there.  Nothing bends in to the root to measure its limits
+// against, so this works them out as the armature's axes would.
+func (c *IKChain) rootTurn(j ikJoint, out, want *Vec3) (*Vec3, *Mat4) {
+	dirs := make([]*Vec3, len(j.axes))
+	for i, ax := range j.axes {
+		_, dirs[i] = c.axisFrame(ax)
+	}
+	turned := IdentMat4()
+	for i, ax := range j.axes {
+		d := signedAngle(out, want, dirs[i])
+		if ax.Limited {
+			d = Clamp(limitedAngle(ax, ax.Angle+d), ax.Min, ax.Max) - ax.Angle
+		}
+		turn := RotateAxisMat4(dirs[i], d)
+		out = turn.TransformDir(out)
+		for k := i + 1; k < len(dirs); k++ {
+			dirs[k] = turn.TransformDir(dirs[k])
+		}
+		turned = turn.MulM(turned)
+	}
+	return out, turned
+}
+
+// signedAngle is how far round axis u has to turn to line up with v,
+// looking only across axis.
+func signedAngle(u, v, axis *Vec3) gl.Float {
+	u = u.Sub(axis.MulS(u.Dot(axis)))
+	v = v.Sub(axis.MulS(v.Dot(axis)))
+	if u.Length() < 1e-5 || v.Length() < 1e-5 {
+		return 0
+	}
+	return RadToDeg(gl.Float(math.Atan2(float64(axis.Dot(u.Cross(v))), float64(u.Dot(v)))))
+}
+
+// limitBend says how far joint j, a hinge, should bend at b on the line a,
+// b, c to stay inside its limits, if it's outside them now.
+func (c *IKChain) limitBend(j ikJoint, hinge *Vec3, bendAt0 gl.Float, a, b, cc *Vec3) (gl.Float, bool) {
+	if hinge == nil || !j.axes[0].Limited {
+		return 0, false
+	}
+	ax := j.axes[0]
+	angle := limitedAngle(ax, bend(a, b, cc, hinge)-bendAt0)
+	if angle >= ax.Min && angle <= ax.Max {
+		return 0, false
+	}
+	return Clamp(angle, ax.Min, ax.Max) + bendAt0, true
+}
+
+// bend is how far the line a, b, c turns at b, about hinge.
+func bend(a, b, c, hinge *Vec3) gl.Float {
+	return signedAngle(b.Sub(a), c.Sub(b), hinge)
+}
+
+// onHinge moves p onto the plane through anchor across hinge, if there is
+// one.
+func onHinge(p, anchor, hinge *Vec3) *Vec3 {
+	if hinge == nil {
+		return p
+	}
+	return p.Sub(hinge.MulS(p.Sub(anchor).Dot(hinge)))
+}
+
+// fabrikPull puts p on the line from anchor to p, l from anchor.
+func fabrikPull(anchor, p *Vec3, l gl.Float) *Vec3 {
+	d := p.Sub(anchor)
+	if d.Length() < 1e-6 {
+		return anchor
+	}
+	return anchor.Add(d.Normalize().MulS(l))
+}
+
+// Solve runs the solver called "ccd" or "fabrik".
+func (c *IKChain) Solve(solver string, target *Vec3) (IKResult, error) {
+	switch solver {
+	case "ccd":
+		return c.SolveCCD(target), nil
+	case "fabrik":
+		return c.SolveFABRIK(target), nil
+	}
+	return IKResult{}, fmt.Errorf("no IK solver %q", solver)
+}
@@ -0,0 +1,107 @@
+package glutil
+
+import (
+	"fmt"
+	gl "github.com/chsc/gogl/gl33"
+	"testing"
+)
+
+// The robot arm's limits, as in art/robotarm.json.
+var robotArmLimits = map[string][2]gl.Float{
+	"upperArm":   {-90, 0},
+	"lowerArm":   {0, 146.25},
+	"wristPitch": {0, 90},
+}
+
+func checkLimits(t *testing.T, what string, a *Armature) {
+	for name, lim := range robotArmLimits {
+		if v := a.Axis(name).Angle; v < lim[0] || v > lim[1] {
+			t.Errorf("%s: %s is %g, outside %g to %g", what, name, v, lim[0], lim[1])
+		}
+	}
+}
+
+func TestIKReachable(t *testing.T) {
+	// Targets the arm can reach, made by posing it and seeing where the
+	// gripper goes.  Both solvers start from the rest pose, so these stay
+	// away from the limits they'd have to fold against.
+	poses := []ArmPose{
+		{"base": 30, "upperArm": -45, "lowerArm": 60, "wristPitch": 30},
+		{"base": -120, "upperArm": -60, "lowerArm": 90, "wristPitch": 45},
+		{"base": 150, "upperArm": -20, "lowerArm": 45, "wristPitch": 20},
+		{"base": -45, "upperArm": -70, "lowerArm": 100, "wristPitch": 60},
+	}
+	for _, solver := range []string{"ccd", "fabrik"} {
+		for i, p := range poses {
+			a := loadRobotArm(t)
+			rest := a.Pose()
+			if a.IK == nil {
+				t.Fatal("robotarm.json has no IK chain")
+			}
+			a.SetPose(p)
+			target := a.IK.Effector()
+			a.SetPose(rest)
+
+			res, err := a.IK.Solve(solver, target)
+			if err != nil {
+				t.Fatal(err)
+			}
+			what := fmt.Sprintf("%s %d", solver, i)
+			if !res.Reached || !res.InReach || res.Distance > a.IK.Tolerance {
+				t.Errorf("%s: %+v", what, res)
+			}
+			if d := a.IK.Effector().Sub(target).Length(); d > a.IK.Tolerance {
+				t.Errorf("%s: gripper %g from the target", what, d)
+			}
+			checkLimits(t, what, a)
+			for name, v := range res.Pose {
+				if a.Axis(name).Angle != v {
+					t.Errorf("%s: result has %s at %g, the arm %g", what, name, v, a.Axis(name).Angle)
+				}
+			}
+		}
+	}
+}
+
+func TestIKOutOfReach(t *testing.T) {
+	for _, solver := range []string{"ccd", "fabrik"} {
+		a := loadRobotArm(t)
+		base, err := a.Position(nil, "base")
+		if err != nil {
+			t.Fatal(err)
+		}
+		target := base.Add(&Vec3{X: 0, Y: 0, Z: 100})
+		res, err := a.IK.Solve(solver, target)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Reached || res.InReach {
+			t.Errorf("%s: %+v", solver, res)
+		}
+		// It still gets as close as it can, and stays in its limits.
+		if res.Distance < 100-a.IK.Reach()-0.1 {
+			t.Errorf("%s: %g from a target %g away with a reach of %g", solver, res.Distance, 100.0, a.IK.Reach())
+		}
+		checkLimits(t, solver, a)
+	}
+
+	a := loadRobotArm(t)
+	if _, err := a.IK.Solve("jacobian", &Vec3{}); err == nil {
+		t.Errorf("solver jacobian ran")
+	}
+}
+
+func TestIKLimitedTargets(t *testing.T) {
+	// Targets the limits keep it from: behind the base and below it.
+	targets := []Vec3{{X: 3, Y: -5, Z: -52}, {X: 3, Y: -20, Z: -40}, {X: 3, Y: -5, Z: -38}}
+	for _, solver := range []string{"ccd", "fabrik"} {
+		for _, target := range targets {
+			a := loadRobotArm(t)
+			target := target
+			if _, err := a.IK.Solve(solver, &target); err != nil {
+				t.Fatal(err)
+			}
+			checkLimits(t, solver, a)
+		}
+	}
+}
@@ -0,0 +1,327 @@
+/*
+armpose.go - poses of an Armature, and timelines of keyframed poses.
+
+A pose is the angle of each named axis.  A timeline is a list of keys, each
+a time and a pose, that's evaluated without any GL so the angles at any
+time can be checked headless:
+
+	{"mode": "pingpong", "ease": {"base": "inout"},
+	 "keys": [
+		{"time": 0, "pose": {"base": -45, "upperArm": -33.75}},
+		{"time": 2, "pose": {"base": 90}, "ease": {"upperArm": "step"}}]}
+
+Each axis eases between the keys that mention it, and holds its first or
+last value outside them.  An ease on a key is for the segment after it, and
+beats the timeline's ease for that axis; the default is linear.  Axes that
+wrap round at 360 go the short way, so 350 to 10 turns through 0.
+*/
+package glutil
+
+import (
+	"encoding/json"
+	"fmt"
+	gl "github.com/chsc/gogl/gl33"
+	"io/ioutil"
+	"math"
+	"sort"
+)
+
+// Axis name to angle, in degrees.
+type ArmPose map[string]gl.Float
+
+// How a timeline runs past its last key.
+const (
+	PlayOnce     = "once"
+	PlayLoop     = "loop"
+	PlayPingPong = "pingpong"
+)
+
+// Takes 0..1 through a segment to 0..1 of the way between its values.
+type Easing func(t gl.Float) gl.Float
+
+var Easings = map[string]Easing{
+	"linear": func(t gl.Float) gl.Float { return t },
+	"step":   func(t gl.Float) gl.Float { return 0 },
+	"in":     func(t gl.Float) gl.Float { return t * t },
+	"out":    func(t gl.Float) gl.Float { return t * (2.0 - t) },
+	"inout":  func(t gl.Float) gl.Float { return t * t * (3.0 - 2.0*t) },
+}
+
+type PoseKey struct {
+	Time float64           `json:"time"` // seconds
+	Pose ArmPose           `json:"pose"`
+	Ease map[string]string `json:"ease,omitempty"`
+}
+
+type Timeline struct {
+	Mode string            `json:"mode,omitempty"` // PlayOnce if empty
+	Ease map[string]string `json:"ease,omitempty"`
+	Keys []*PoseKey        `json:"keys"`
+}
+
+// Pose is the armature's current pose.
+func (a *Armature) Pose() ArmPose {
+	p := make(ArmPose)
+	for _, ax := range a.Axes {
+		p[ax.Name] = ax.Angle
+	}
+	return p
+}
+
+// SetPose turns the armature's axes to p, within their limits.  Axes p
+// doesn't mention are left alone, and names a doesn't have are ignored.
+func (a *Armature) SetPose(p ArmPose) {
+	for name, angle := range p {
+		if ax := a.axes[name]; ax != nil {
+			ax.Set(angle)
+		}
+	}
+}
+
+// Check complains about axes p has that a doesn't.
+func (p ArmPose) Check(a *Armature) error {
+	for name := range p {
+		if a.axes[name] == nil {
+			return fmt.Errorf("%s has no axis %q", a.Name, name)
+		}
+	}
+	return nil
+}
+
+// Format lists the pose an axis to a line, in the armature's order.
+func (p ArmPose) Format(a *Armature) string {
+	s := ""
+	for _, ax := range a.Axes {
+		if angle, ok := p[ax.Name]; ok {
+			s += fmt.Sprintf("%-15s %6.2f\n", ax.Name+":", angle)
+		}
+	}
+	return s
+}
+
+func LoadPose(file string) (ArmPose, error) {
+	var p ArmPose
+	if err := loadJSON(file, &p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p ArmPose) Save(file string) error { return saveJSON(file, p) }
+
+func LoadTimeline(file string) (*Timeline, error) {
+	tl := &Timeline{}
+	if err := loadJSON(file, tl); err != nil {
+		return nil, err
+	}
+	if err := tl.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %s", file, err)
+	}
+	return tl, nil
+}
+
+func (tl *Timeline) Save(file string) error { return saveJSON(file, tl) }
+
+func loadJSON(file string, v interface{}) error {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("%s: %s", file, err)
+	}
+	return nil
+}
+
+func saveJSON(file string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "\t")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(file, append(data, '\n'), 0644)
+}
+
+// Validate checks the keys are in order and the eases and mode are ones we
+// know.
+func (tl *Timeline) Validate() error {
+	switch tl.Mode {
+	case "", PlayOnce, PlayLoop, PlayPingPong:
+	default:
+		return fmt.Errorf("no play mode %q", tl.Mode)
+	}
+	checkEase := func(ease map[string]string) error {
+		for name, e := range ease {
+			if Easings[e] == nil {
+				return fmt.Errorf("axis %q: no ease %q", name, e)
+			}
+		}
+		return nil
+	}
+	if err := checkEase(tl.Ease); err != nil {
+		return err
+	}
+	for i, k := range tl.Keys {
+		if i > 0 && k.Time < tl.Keys[i-1].Time {
+			return fmt.Errorf("key %d goes back in time", i)
+		}
+		if err := checkEase(k.Ease); err != nil {
+			return fmt.Errorf("key %d: %s", i, err)
+		}
+	}
+	return nil
+}
+
+// Check complains about axes the keys have that a doesn't.
+func (tl *Timeline) Check(a *Armature) error {
+	for i, k := range tl.Keys {
+		if err := k.Pose.Check(a); err != nil {
+			return fmt.Errorf("key %d: %s", i, err)
+		}
+	}
+	return nil
+}
+
+// AddKey puts p on the timeline at time t, in order, replacing any key
+// already there.
+func (tl *Timeline) AddKey(t float64, p ArmPose) {
+	i := sort.Search(len(tl.Keys), func(i int) bool { return tl.Keys[i].Time >= t })
+	if i < len(tl.Keys) && tl.Keys[i].Time == t {
+		tl.Keys[i].Pose = p
+		return
+	}
+	tl.Keys = append(tl.Keys, nil)
+	copy(tl.Keys[i+1:], tl.Keys[i:])
+	tl.Keys[i] = &PoseKey{Time: t, Pose: p}
+}
+
+func (tl *Timeline) Start() float64 {
+	if len(tl.Keys) == 0 {
+		return 0
+	}
+	return tl.Keys[0].Time
+}
+
+func (tl *Timeline) Duration() float64 {
+	if len(tl.Keys) == 0 {
+		return 0
+	}
+	return tl.Keys[len(tl.Keys)-1].Time - tl.Keys[0].Time
+}
+
+// LocalTime maps a time since the timeline started playing onto its keys,
+// by its mode.
+func (tl *Timeline) LocalTime(t float64) float64 {
+	d := tl.Duration()
+	if d <= 0 || t <= 0 {
+		return tl.Start()
+	}
+	switch tl.Mode {
+	case PlayLoop:
+		t = math.Mod(t, d)
+	case PlayPingPong:
+		t = math.Mod(t, 2*d)
+		if t > d {
+			t = 2*d - t
+		}
+	default:
+		t = math.Min(t, d)
+	}
+	return tl.Start() + t
+}
+
+// Done says whether a timeline that plays once has finished by t.
+func (tl *Timeline) Done(t float64) bool {
+	return (tl.Mode == "" || tl.Mode == PlayOnce) && t >= tl.Duration()
+}
+
+// PoseAt is the pose t seconds after the timeline started playing.  a is
+// only asked which axes wrap, and can be nil if none do; nothing's
+// changed.
+func (tl *Timeline) PoseAt(a *Armature, t float64) ArmPose {
+	return tl.Eval(a, tl.LocalTime(t))
+}
+
+// Apply poses a as it is t seconds into playing.
+func (tl *Timeline) Apply(a *Armature, t float64) {
+	a.SetPose(tl.PoseAt(a, t))
+}
+
+// Eval is the pose at time t on the keys, ignoring the mode.
+func (tl *Timeline) Eval(a *Armature, t float64) ArmPose {
+	p := make(ArmPose)
+	for _, name := range tl.axisNames() {
+		var prev, next *PoseKey
+		for _, k := range tl.Keys {
+			if _, ok := k.Pose[name]; !ok {
+				continue
+			}
+			if k.Time <= t {
+				prev = k
+			} else {
+				next = k
+				break
+			}
+		}
+		switch {
+		case prev == nil:
+			p[name] = next.Pose[name]
+		case next == nil:
+			p[name] = prev.Pose[name]
+		default:
+			f := gl.Float((t - prev.Time) / (next.Time - prev.Time))
+			f = tl.easing(prev, name)(f)
+			p[name] = lerpAngle(prev.Pose[name], next.Pose[name], f, a.wraps(name))
+		}
+	}
+	return p
+}
+
+// Whether the named axis wraps round at 360 rather than having limits.
+func (a *Armature) wraps(name string) bool {
+	if a == nil {
+		return false
+	}
+	ax := a.axes[name]
+	return ax != nil && !ax.Limited
+}
+
+// Every axis any key mentions.
+func (tl *Timeline) axisNames() []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, k := range tl.Keys {
+		for name := range k.Pose {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (tl *Timeline) easing(k *PoseKey, name string) Easing {
+	if e := Easings[k.Ease[name]]; e != nil {
+		return e
+	}
+	if e := Easings[tl.Ease[name]]; e != nil {
+		return e
+	}
+	return Easings["linear"]
+}
+
+// lerpAngle goes f of the way from a to b, the short way round if wrap.
+func lerpAngle(a, b, f gl.Float, wrap bool) gl.Float {
+	d := b - a
+	if wrap {
+		d = ModGL(d, 360.0)
+		if d > 180.0 {
+			d -= 360.0
+		} else if d < -180.0 {
+			d += 360.0
+		}
+		return ModGL(a+d*f, 360.0)
+	}
+	return a + d*f
+}
@@ -0,0 +1,165 @@
+package glutil
+
+import (
+	gl "github.com/chsc/gogl/gl33"
+	"math"
+	"testing"
+)
+
+func loadRobotArm(t *testing.T) *Armature {
+	a, err := LoadArmature("../art/robotarm.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+func near(a, b gl.Float) bool { return math.Abs(float64(a-b)) < 1e-4 }
+
+func TestTimelineWave(t *testing.T) {
+	// The README's example: armeval -check art/robotarm_wave.json 4.5 upperArm -67.5
+	a := loadRobotArm(t)
+	tl, err := LoadTimeline("../art/robotarm_wave.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tl.Check(a); err != nil {
+		t.Fatal(err)
+	}
+	// Ping-pong: 4.5s is on the way back, at the middle key.
+	tl.Apply(a, 4.5)
+	p := a.Pose()
+	want := ArmPose{"upperArm": -67.5, "lowerArm": 90, "wristPitch": 22.5, "fingerOpen": 45}
+	for name, v := range want {
+		if !near(p[name], v) {
+			t.Errorf("%s is %g at 4.5s, want %g", name, p[name], v)
+		}
+	}
+}
+
+func TestTimelineWrap(t *testing.T) {
+	tests := []struct {
+		a, b, f gl.Float
+		wrap    bool
+		want    gl.Float
+	}{
+		{350, 10, 0.5, true, 0},
+		{10, 350, 0.25, true, 5},
+		{350, 10, 0.5, false, 180},
+		{0, 270, 0.5, true, -45}, // which is 315
+		{-45, 90, 0.5, true, 22.5},
+	}
+	for _, tt := range tests {
+		if got := lerpAngle(tt.a, tt.b, tt.f, tt.wrap); !near(got, tt.want) {
+			t.Errorf("lerpAngle(%g, %g, %g, %v) = %g, want %g", tt.a, tt.b, tt.f, tt.wrap, got, tt.want)
+		}
+	}
+
+	// wristRoll has no limits, so the wave's 0 to 270 goes back through 0
+	// to -90 rather than forward through 135.
+	a := loadRobotArm(t)
+	tl, err := LoadTimeline("../art/robotarm_wave.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Axis("wristRoll").Limited || a.Axis("base").Limited {
+		t.Fatal("wristRoll or base has limits")
+	}
+	if got := tl.Eval(a, 1.5)["wristRoll"]; !near(got, -45) {
+		t.Errorf("wristRoll %g at 1.5s, want -45", got)
+	}
+	// Without the armature nothing wraps.
+	if got := tl.Eval(nil, 1.5)["wristRoll"]; !near(got, 135) {
+		t.Errorf("unwrapped wristRoll %g at 1.5s, want 135", got)
+	}
+}
+
+func TestTimelineModes(t *testing.T) {
+	// Keys from 1 to 4 seconds.
+	keys := []*PoseKey{{Time: 1, Pose: ArmPose{"x": 0}}, {Time: 4, Pose: ArmPose{"x": 30}}}
+	tests := []struct {
+		mode string
+		t    float64
+		want float64 // on the keys
+		done bool
+	}{
+		{PlayOnce, 0, 1, false},
+		{PlayOnce, 2, 3, false},
+		{PlayOnce, 10, 4, true},
+		{"", 3, 4, true},
+		{PlayLoop, 2, 3, false},
+		{PlayLoop, 4, 2, false},
+		{PlayLoop, 9.5, 1.5, false},
+		{PlayPingPong, 2, 3, false},
+		{PlayPingPong, 4, 3, false},
+		{PlayPingPong, 5.5, 1.5, false},
+		{PlayPingPong, 6, 1, false},
+		{PlayPingPong, 7, 2, false},
+	}
+	for _, tt := range tests {
+		tl := &Timeline{Mode: tt.mode, Keys: keys}
+		if err := tl.Validate(); err != nil {
+			t.Fatal(err)
+		}
+		if got := tl.LocalTime(tt.t); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("%s: LocalTime(%g) = %g, want %g", tt.mode, tt.t, got, tt.want)
+		}
+		if got := tl.Done(tt.t); got != tt.done {
+			t.Errorf("%s: Done(%g) = %v", tt.mode, tt.t, got)
+		}
+		// x goes 10 a second.
+		if got := tl.PoseAt(nil, tt.t)["x"]; !near(got, gl.Float((tt.want-1)*10)) {
+			t.Errorf("%s: x is %g at %g", tt.mode, got, tt.t)
+		}
+	}
+
+	if err := (&Timeline{Mode: "bounce"}).Validate(); err == nil {
+		t.Errorf("mode bounce validated")
+	}
+}
+
+func TestTimelineEasing(t *testing.T) {
+	tl := &Timeline{
+		Ease: map[string]string{"in": "in", "step": "inout", "out": "inout"},
+		Keys: []*PoseKey{
+			{Time: 0, Pose: ArmPose{"linear": 0, "in": 0, "step": 0, "out": 0},
+				// The key's ease beats the timeline's.
+				Ease: map[string]string{"step": "step", "out": "out"}},
+			{Time: 2, Pose: ArmPose{"linear": 100, "in": 100, "step": 100, "out": 100}},
+		},
+	}
+	if err := tl.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		t    float64
+		want ArmPose
+	}{
+		{0.5, ArmPose{"linear": 25, "in": 6.25, "step": 0, "out": 43.75}},
+		{1, ArmPose{"linear": 50, "in": 25, "step": 0, "out": 75}},
+		{2, ArmPose{"linear": 100, "in": 100, "step": 100, "out": 100}},
+	}
+	for _, tt := range tests {
+		got := tl.Eval(nil, tt.t)
+		for name, v := range tt.want {
+			if !near(got[name], v) {
+				t.Errorf("%s at %g: %g, want %g", name, tt.t, got[name], v)
+			}
+		}
+	}
+
+	// An axis only some keys mention eases between those.
+	tl.Keys[1].Pose["late"] = 10
+	tl.AddKey(4, ArmPose{"late": 30})
+	if got := tl.Eval(nil, 3)["late"]; !near(got, 20) {
+		t.Errorf("late at 3: %g, want 20", got)
+	}
+	if got := tl.Eval(nil, 1)["late"]; !near(got, 10) {
+		t.Errorf("late at 1 is held at %g, want 10", got)
+	}
+
+	tl.Ease["in"] = "bouncy"
+	if err := tl.Validate(); err == nil {
+		t.Errorf("ease bouncy validated")
+	}
+}
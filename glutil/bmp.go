@@ -0,0 +1,230 @@
+/*
+bmp.go - a Windows BMP decoder, registered with the image package.
+
+Reads the BITMAPINFOHEADER family (v3, v4 and v5, which only add colour
+space fields we skip) and the old OS/2 core header, at 1, 4, 8, 16, 24 and
+32 bits per pixel, uncompressed or with BI_BITFIELDS masks.  RLE compressed
+files are rejected.
+*/
+package glutil
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"io/ioutil"
+)
+
+const (
+	bmpRGB       = 0
+	bmpBitfields = 3
+)
+
+func init() {
+	image.RegisterFormat("bmp", "BM????\x00\x00\x00\x00", DecodeBMP, DecodeBMPConfig)
+}
+
+type bmpHeader struct {
+	width, height int
+	topDown       bool
+	bpp           int
+	compression   uint32
+	masks         [4]uint32 // r, g, b, a
+	palette       color.Palette
+	pixelOffset   int
+}
+
+// readBMPHeader parses the file and info headers out of the start of data.
+func readBMPHeader(data []byte) (*bmpHeader, error) {
+	if len(data) < 18 || data[0] != 'B' || data[1] != 'M' {
+		return nil, errors.New("bmp: not a BMP file")
+	}
+	le := binary.LittleEndian
+	h := &bmpHeader{pixelOffset: int(le.Uint32(data[10:]))}
+	infoSize := int(le.Uint32(data[14:]))
+	if len(data) < 14+infoSize {
+		return nil, io.ErrUnexpectedEOF
+	}
+	info := data[14 : 14+infoSize]
+
+	paletteEntry := 4
+	switch {
+	case infoSize == 12:
+		// OS/2 BITMAPCOREHEADER: 16 bit sizes, 3 byte palette entries.
+		h.width = int(le.Uint16(info[4:]))
+		h.height = int(int16(le.Uint16(info[6:])))
+		h.bpp = int(le.Uint16(info[10:]))
+		paletteEntry = 3
+	case infoSize >= 40:
+		h.width = int(int32(le.Uint32(info[4:])))
+		h.height = int(int32(le.Uint32(info[8:])))
+		h.bpp = int(le.Uint16(info[14:]))
+		h.compression = le.Uint32(info[16:])
+	default:
+		return nil, fmt.Errorf("bmp: unsupported header size %d", infoSize)
+	}
+	if h.height < 0 {
+		h.height = -h.height
+		h.topDown = true
+	}
+	if h.width <= 0 || h.height == 0 {
+		return nil, errors.New("bmp: empty image")
+	}
+
+	switch h.compression {
+	case bmpRGB:
+		switch h.bpp {
+		case 16:
+			h.masks = [4]uint32{0x7c00, 0x03e0, 0x001f, 0}
+		case 24, 32:
+			h.masks = [4]uint32{0xff0000, 0xff00, 0xff, 0}
+		}
+	case bmpBitfields:
+		if h.bpp != 16 && h.bpp != 32 {
+			return nil, fmt.Errorf("bmp: bitfields at %d bits per pixel", h.bpp)
+		}
+		// The masks follow a v3 header, or are part of a v4/v5 one.
+		if len(data) < 14+40+12 {
+			return nil, io.ErrUnexpectedEOF
+		}
+		m := data[14+40:]
+		h.masks = [4]uint32{le.Uint32(m), le.Uint32(m[4:]), le.Uint32(m[8:]), 0}
+		if infoSize >= 56 {
+			h.masks[3] = le.Uint32(m[12:])
+		}
+	default:
+		return nil, fmt.Errorf("bmp: unsupported compression %d", h.compression)
+	}
+
+	switch h.bpp {
+	case 1, 4, 8:
+		colors := 1 << uint(h.bpp)
+		if infoSize >= 40 {
+			if used := int(le.Uint32(info[32:])); used > 0 && used < colors {
+				colors = used
+			}
+		}
+		start := 14 + infoSize
+		if len(data) < start+colors*paletteEntry {
+			return nil, io.ErrUnexpectedEOF
+		}
+		for i := 0; i < colors; i++ {
+			p := data[start+i*paletteEntry:]
+			h.palette = append(h.palette, color.RGBA{p[2], p[1], p[0], 0xff})
+		}
+	case 16, 24, 32:
+	default:
+		return nil, fmt.Errorf("bmp: unsupported depth %d", h.bpp)
+	}
+	return h, nil
+}
+
+func (h *bmpHeader) colorModel() color.Model {
+	if h.palette != nil {
+		return h.palette
+	}
+	return color.NRGBAModel
+}
+
+// DecodeBMPConfig returns the size and colour model without reading the
+// pixels.
+func DecodeBMPConfig(r io.Reader) (image.Config, error) {
+	// The palette can be some way in, so read generously.
+	data, err := ioutil.ReadAll(io.LimitReader(r, 14+124+256*4+12))
+	if err != nil {
+		return image.Config{}, err
+	}
+	h, err := readBMPHeader(data)
+	if err != nil {
+		return image.Config{}, err
+	}
+	return image.Config{ColorModel: h.colorModel(), Width: h.width, Height: h.height}, nil
+}
+
+// maskValue pulls the field selected by mask out of v, scaled to 8 bits.
+func maskValue(v, mask uint32) uint8 {
+	if mask == 0 {
+		return 0xff
+	}
+	shift := uint(0)
+	for mask&1 == 0 {
+		mask >>= 1
+		shift++
+	}
+	bits := uint(0)
+	for m := mask; m&1 == 1; m >>= 1 {
+		bits++
+	}
+	x := (v >> shift) & mask
+	if bits >= 8 {
+		return uint8(x >> (bits - 8))
+	}
+	// Replicate the high bits into the low ones, so full scale is 255.
+	return uint8((x * 255) / mask)
+}
+
+// DecodeBMP reads a BMP image.  Palette images come back as
+// *image.Paletted, everything else as *image.NRGBA.
+func DecodeBMP(r io.Reader) (image.Image, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	h, err := readBMPHeader(data)
+	if err != nil {
+		return nil, err
+	}
+	// Rows are padded to 4 bytes.
+	stride := ((h.width*h.bpp + 31) / 32) * 4
+	if h.pixelOffset < 0 || len(data) < h.pixelOffset+stride*h.height {
+		return nil, io.ErrUnexpectedEOF
+	}
+	pix := data[h.pixelOffset:]
+	rect := image.Rect(0, 0, h.width, h.height)
+
+	row := func(y int) []byte {
+		if !h.topDown {
+			y = h.height - 1 - y
+		}
+		return pix[y*stride : (y+1)*stride]
+	}
+
+	if h.palette != nil {
+		img := image.NewPaletted(rect, h.palette)
+		perByte := 8 / h.bpp
+		for y := 0; y < h.height; y++ {
+			src := row(y)
+			for x := 0; x < h.width; x++ {
+				b := src[x/perByte]
+				shift := uint(8 - h.bpp - (x%perByte)*h.bpp)
+				i := (b >> shift) & (1<<uint(h.bpp) - 1)
+				if int(i) >= len(h.palette) {
+					return nil, fmt.Errorf("bmp: colour index %d out of range", i)
+				}
+				img.Pix[y*img.Stride+x] = i
+			}
+		}
+		return img, nil
+	}
+
+	img := image.NewNRGBA(rect)
+	bytesPP := h.bpp / 8
+	for y := 0; y < h.height; y++ {
+		src := row(y)
+		for x := 0; x < h.width; x++ {
+			var v uint32
+			for i := 0; i < bytesPP; i++ {
+				v |= uint32(src[x*bytesPP+i]) << uint(8*i)
+			}
+			o := y*img.Stride + x*4
+			img.Pix[o] = maskValue(v, h.masks[0])
+			img.Pix[o+1] = maskValue(v, h.masks[1])
+			img.Pix[o+2] = maskValue(v, h.masks[2])
+			img.Pix[o+3] = maskValue(v, h.masks[3])
+		}
+	}
+	return img, nil
+}
@@ -0,0 +1,251 @@
+package glutil
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"io"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+// bmpInfo is a BITMAPINFOHEADER.
+type bmpInfo struct {
+	Size          uint32
+	Width, Height int32
+	Planes, Bpp   uint16
+	Compression   uint32
+	ImageSize     uint32
+	XPels, YPels  int32
+	Used          uint32
+	Important     uint32
+}
+
+// bmpBytes makes a BMP file from an info header, the masks or palette
+// that follow it, and the pixel rows.
+func bmpBytes(info bmpInfo, table, pix []byte) []byte {
+	info.Size, info.Planes = 40, 1
+	var buf bytes.Buffer
+	buf.WriteString("BM")
+	binary.Write(&buf, binary.LittleEndian, uint32(14+40+len(table)+len(pix)))
+	binary.Write(&buf, binary.LittleEndian, uint32(0))
+	binary.Write(&buf, binary.LittleEndian, uint32(14+40+len(table)))
+	binary.Write(&buf, binary.LittleEndian, &info)
+	buf.Write(table)
+	buf.Write(pix)
+	return buf.Bytes()
+}
+
+func bmpPixels(t *testing.T, data []byte) []color.NRGBA {
+	img, err := DecodeBMP(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var px []color.NRGBA
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			px = append(px, color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA))
+		}
+	}
+	return px
+}
+
+func samePixels(got, want []color.NRGBA) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestDecodeBMPFiles(t *testing.T) {
+	// kih has a v4 header, CDtest a v3 one.  Both are 24 bit and bottom up,
+	// so the first row in the file is the last in the image.
+	tests := []struct {
+		file string
+		w, h int
+	}{
+		{"../art/kih.BMP", 300, 300},
+		{"../art/CDtest.BMP", 256, 256},
+	}
+	for _, tt := range tests {
+		f, err := os.Open(tt.file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cfg, format, err := image.DecodeConfig(f)
+		f.Close()
+		if err != nil || format != "bmp" || cfg.Width != tt.w || cfg.Height != tt.h {
+			t.Errorf("%s: config %s %dx%d, %v", tt.file, format, cfg.Width, cfg.Height, err)
+		}
+
+		img := decodeFile(t, tt.file)
+		if b := img.Bounds(); b.Dx() != tt.w || b.Dy() != tt.h {
+			t.Errorf("%s: %dx%d, want %dx%d", tt.file, b.Dx(), b.Dy(), tt.w, tt.h)
+			continue
+		}
+		data, err := ioutil.ReadFile(tt.file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		offset := int(binary.LittleEndian.Uint32(data[10:]))
+		stride := (tt.w*3 + 3) / 4 * 4
+		for _, p := range []image.Point{{0, 0}, {tt.w - 1, 0}, {tt.w / 2, tt.h / 3}, {0, tt.h - 1}, {tt.w - 1, tt.h - 1}} {
+			bgr := data[offset+(tt.h-1-p.Y)*stride+p.X*3:]
+			want := color.NRGBA{bgr[2], bgr[1], bgr[0], 0xff}
+			if got := color.NRGBAModel.Convert(img.At(p.X, p.Y)); got != want {
+				t.Errorf("%s: %v is %v, want %v", tt.file, p, got, want)
+			}
+		}
+	}
+}
+
+// 2x2 at 24 bits, rows padded to 8 bytes: red green, blue white.
+var (
+	bmpTopRow    = []byte{0, 0, 0xff, 0, 0xff, 0, 0, 0}
+	bmpBottomRow = []byte{0xff, 0, 0, 0xff, 0xff, 0xff, 0, 0}
+)
+
+func TestDecodeBMPOrientation(t *testing.T) {
+	want := []color.NRGBA{red, green, blue, white}
+	up := bmpBytes(bmpInfo{Width: 2, Height: 2, Bpp: 24}, nil, append(append([]byte{}, bmpBottomRow...), bmpTopRow...))
+	if got := bmpPixels(t, up); !samePixels(got, want) {
+		t.Errorf("bottom up: got %v, want %v", got, want)
+	}
+	// A negative height is stored top down.
+	down := bmpBytes(bmpInfo{Width: 2, Height: -2, Bpp: 24}, nil, append(append([]byte{}, bmpTopRow...), bmpBottomRow...))
+	if got := bmpPixels(t, down); !samePixels(got, want) {
+		t.Errorf("top down: got %v, want %v", got, want)
+	}
+}
+
+func TestDecodeBMPPaletted(t *testing.T) {
+	// Three colours used, BGR0 entries.
+	palette := []byte{0, 0, 0xff, 0, 0, 0xff, 0, 0, 0xff, 0, 0, 0}
+	tests := []struct {
+		name string
+		bpp  uint16
+		rows []byte // bottom up, each padded to 4 bytes
+	}{
+		// 3x2: red green blue over blue green red.
+		{"8 bit", 8, []byte{2, 1, 0, 0, 0, 1, 2, 0}},
+		{"4 bit", 4, []byte{0x21, 0x00, 0, 0, 0x01, 0x20, 0, 0}},
+	}
+	want := []color.NRGBA{red, green, blue, blue, green, red}
+	for _, tt := range tests {
+		data := bmpBytes(bmpInfo{Width: 3, Height: 2, Bpp: tt.bpp, Used: 3}, palette, tt.rows)
+		img, err := DecodeBMP(bytes.NewReader(data))
+		if err != nil {
+			t.Errorf("%s: %s", tt.name, err)
+			continue
+		}
+		if p, ok := img.(*image.Paletted); !ok || len(p.Palette) != 3 {
+			t.Errorf("%s: a %T", tt.name, img)
+		}
+		if got := bmpPixels(t, data); !samePixels(got, want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, want)
+		}
+	}
+
+	// 1 bit, 10 wide so a row spills into a second byte: white then
+	// alternating black and white.
+	mono := []byte{0, 0, 0, 0, 0xff, 0xff, 0xff, 0}
+	data := bmpBytes(bmpInfo{Width: 10, Height: 1, Bpp: 1}, mono, []byte{0xd5, 0x40, 0, 0})
+	black := color.NRGBA{0, 0, 0, 0xff}
+	wantMono := []color.NRGBA{white, white, black, white, black, white, black, white, black, white}
+	if got := bmpPixels(t, data); !samePixels(got, wantMono) {
+		t.Errorf("1 bit: got %v, want %v", got, wantMono)
+	}
+
+	// An index past the palette.
+	data = bmpBytes(bmpInfo{Width: 3, Height: 2, Bpp: 8, Used: 3}, palette, []byte{2, 1, 3, 0, 0, 1, 2, 0})
+	if _, err := DecodeBMP(bytes.NewReader(data)); err == nil || err.Error() != "bmp: colour index 3 out of range" {
+		t.Errorf("index 3 of 3: %v", err)
+	}
+}
+
+func TestDecodeBMPOS2(t *testing.T) {
+	// A BITMAPCOREHEADER, 3 byte palette entries: 2x1, blue then red.
+	var buf bytes.Buffer
+	le := binary.LittleEndian
+	buf.WriteString("BM")
+	binary.Write(&buf, le, []uint32{0, 0, 14 + 12 + 2*3, 12})
+	binary.Write(&buf, le, []uint16{2, 1, 1, 8})
+	buf.Write([]byte{0xff, 0, 0, 0, 0, 0xff})
+	buf.Write([]byte{0, 1, 0, 0})
+	// 8 bits and no colours-used field means a 256 colour palette, which
+	// this file hasn't got.
+	if _, err := DecodeBMP(bytes.NewReader(buf.Bytes())); err != io.ErrUnexpectedEOF {
+		t.Errorf("short palette: %v", err)
+	}
+
+	buf.Reset()
+	buf.WriteString("BM")
+	binary.Write(&buf, le, []uint32{0, 0, 14 + 12 + 2*3, 12})
+	binary.Write(&buf, le, []uint16{2, 1, 1, 1})
+	buf.Write([]byte{0xff, 0, 0, 0, 0, 0xff})
+	buf.Write([]byte{0x40, 0, 0, 0})
+	if got, want := bmpPixels(t, buf.Bytes()), []color.NRGBA{blue, red}; !samePixels(got, want) {
+		t.Errorf("OS/2: got %v, want %v", got, want)
+	}
+}
+
+func TestDecodeBMPBitfields(t *testing.T) {
+	masks := func(m ...uint32) []byte {
+		var buf bytes.Buffer
+		binary.Write(&buf, binary.LittleEndian, m)
+		return buf.Bytes()
+	}
+	tests := []struct {
+		name  string
+		info  bmpInfo
+		table []byte
+		pix   []byte
+		want  color.NRGBA
+	}{
+		// 5-5-5 by default: full red, green 16 of 31, no blue.
+		{"16 bit", bmpInfo{Width: 1, Height: 1, Bpp: 16}, nil,
+			[]byte{0x00, 0x7c | 0x02, 0, 0}, color.NRGBA{0xff, 16 * 255 / 31, 0, 0xff}},
+		{"5-6-5", bmpInfo{Width: 1, Height: 1, Bpp: 16, Compression: bmpBitfields}, masks(0xf800, 0x07e0, 0x001f),
+			[]byte{0x1f, 0x07, 0, 0}, color.NRGBA{0, 56 * 255 / 63, 0xff, 0xff}},
+		{"32 bit BGRX", bmpInfo{Width: 1, Height: 1, Bpp: 32}, nil,
+			[]byte{0x10, 0x20, 0x30, 0x99}, color.NRGBA{0x30, 0x20, 0x10, 0xff}},
+		{"32 bit RGBA masks", bmpInfo{Width: 1, Height: 1, Bpp: 32, Compression: bmpBitfields}, masks(0xff, 0xff00, 0xff0000),
+			[]byte{0x10, 0x20, 0x30, 0x99}, color.NRGBA{0x10, 0x20, 0x30, 0xff}},
+	}
+	for _, tt := range tests {
+		if got := bmpPixels(t, bmpBytes(tt.info, tt.table, tt.pix)); got[0] != tt.want {
+			t.Errorf("%s: %v, want %v", tt.name, got[0], tt.want)
+		}
+	}
+}
+
+func TestDecodeBMPErrors(t *testing.T) {
+	quad := append(append([]byte{}, bmpBottomRow...), bmpTopRow...)
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"not a bmp", []byte("GIF89a............"), "bmp: not a BMP file"},
+		{"RLE", bmpBytes(bmpInfo{Width: 2, Height: 2, Bpp: 8, Compression: 1}, nil, quad), "bmp: unsupported compression 1"},
+		{"bitfields at 24 bits", bmpBytes(bmpInfo{Width: 2, Height: 2, Bpp: 24, Compression: bmpBitfields}, make([]byte, 12), quad),
+			"bmp: bitfields at 24 bits per pixel"},
+		{"empty", bmpBytes(bmpInfo{Width: 0, Height: 2, Bpp: 24}, nil, quad), "bmp: empty image"},
+		{"depth", bmpBytes(bmpInfo{Width: 2, Height: 2, Bpp: 7}, nil, quad), "bmp: unsupported depth 7"},
+		{"short pixels", bmpBytes(bmpInfo{Width: 2, Height: 2, Bpp: 24}, nil, quad[:12]), io.ErrUnexpectedEOF.Error()},
+	}
+	for _, tt := range tests {
+		_, err := DecodeBMP(bytes.NewReader(tt.data))
+		if err == nil || err.Error() != tt.want {
+			t.Errorf("%s: %v, want %s", tt.name, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,674 @@
+/*
+collada.go - pulls the contents of a COLLADA (.dae) file into a struct tree,
+and turns its geometry into Meshes.
+
+The struct tree mirrors the XML closely and is filled in by encoding/xml;
+only what we use is there.  The importer goes through each <mesh>, splits
+the interleaved <p> streams by input offset, triangulates polygons, and
+re-indexes the result so every unique combination of position, normal,
+texcoord... becomes one vertex, ready for a Mesh.
+
+Z_UP and X_UP files are rotated into our Y-up convention on the way in.
+*/
+package glutil
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	gl "github.com/chsc/gogl/gl33"
+	"os"
+	"strconv"
+	"strings"
+)
+
+type Collada struct {
+	XMLName      xml.Name             `xml:"COLLADA"`
+	Version      string               `xml:"version,attr"`
+	Asset        ColladaAsset         `xml:"asset"`
+	Geometries   []ColladaGeometry    `xml:"library_geometries>geometry"`
+	Cameras      []ColladaCamera      `xml:"library_cameras>camera"`
+	Lights       []ColladaLight       `xml:"library_lights>light"`
+	Controllers  []ColladaController  `xml:"library_controllers>controller"`
+	Animations   []ColladaAnimation   `xml:"library_animations>animation"`
+	Nodes        []ColladaNode        `xml:"library_nodes>node"`
+	VisualScenes []ColladaVisualScene `xml:"library_visual_scenes>visual_scene"`
+	Scene        struct {
+		VisualScene ColladaInstance `xml:"instance_visual_scene"`
+	} `xml:"scene"`
+}
+
+type ColladaAsset struct {
+	UpAxis string `xml:"up_axis"` // X_UP, Y_UP or Z_UP; Y_UP if missing
+	Unit   struct {
+		Name  string  `xml:"name,attr"`
+		Meter float64 `xml:"meter,attr"`
+	} `xml:"unit"`
+}
+
+type ColladaGeometry struct {
+	ID   string       `xml:"id,attr"`
+	Name string       `xml:"name,attr"`
+	Mesh *ColladaMesh `xml:"mesh"` // nil for splines and the like
+}
+
+type ColladaMesh struct {
+	Sources   []ColladaSource    `xml:"source"`
+	Vertices  ColladaVertices    `xml:"vertices"`
+	Triangles []ColladaPrimitive `xml:"triangles"`
+	Polylists []ColladaPrimitive `xml:"polylist"`
+	Polygons  []ColladaPrimitive `xml:"polygons"`
+	Lines     []ColladaPrimitive `xml:"lines"`
+}
+
+type ColladaSource struct {
+	ID         string          `xml:"id,attr"`
+	FloatArray *ColladaArray   `xml:"float_array"`
+	NameArray  *ColladaArray   `xml:"Name_array"`
+	IDRefArray *ColladaArray   `xml:"IDREF_array"`
+	Accessor   ColladaAccessor `xml:"technique_common>accessor"`
+	floats     []float64       // FloatArray, parsed
+}
+
+type ColladaArray struct {
+	ID    string `xml:"id,attr"`
+	Count int    `xml:"count,attr"`
+	Text  string `xml:",chardata"`
+}
+
+type ColladaAccessor struct {
+	Source string         `xml:"source,attr"`
+	Count  int            `xml:"count,attr"`
+	Offset int            `xml:"offset,attr"`
+	Stride int            `xml:"stride,attr"`
+	Params []ColladaParam `xml:"param"`
+}
+
+type ColladaParam struct {
+	Name string `xml:"name,attr"`
+	Type string `xml:"type,attr"`
+}
+
+type ColladaVertices struct {
+	ID     string         `xml:"id,attr"`
+	Inputs []ColladaInput `xml:"input"`
+}
+
+type ColladaInput struct {
+	Semantic string `xml:"semantic,attr"`
+	Source   string `xml:"source,attr"`
+	Offset   int    `xml:"offset,attr"`
+	Set      int    `xml:"set,attr"`
+}
+
+// <triangles>, <polylist>, <polygons> or <lines>.  Polygons have one <p>
+// per polygon, the rest one <p> for the lot.
+type ColladaPrimitive struct {
+	Material string         `xml:"material,attr"`
+	Count    int            `xml:"count,attr"`
+	Inputs   []ColladaInput `xml:"input"`
+	VCount   string         `xml:"vcount"`
+	P        []string       `xml:"p"`
+}
+
+type ColladaController struct {
+	ID   string       `xml:"id,attr"`
+	Name string       `xml:"name,attr"`
+	Skin *ColladaSkin `xml:"skin"` // nil for morphs
+}
+
+type ColladaSkin struct {
+	Source          string          `xml:"source,attr"` // the geometry
+	BindShapeMatrix string          `xml:"bind_shape_matrix"`
+	Sources         []ColladaSource `xml:"source"`
+	Joints          []ColladaInput  `xml:"joints>input"`
+	VertexWeights   struct {
+		Count  int            `xml:"count,attr"`
+		Inputs []ColladaInput `xml:"input"`
+		VCount string         `xml:"vcount"`
+		V      string         `xml:"v"`
+	} `xml:"vertex_weights"`
+}
+
+// Animations nest, and a sampler's sources can be anywhere in the library.
+type ColladaAnimation struct {
+	ID         string             `xml:"id,attr"`
+	Name       string             `xml:"name,attr"`
+	Sources    []ColladaSource    `xml:"source"`
+	Samplers   []ColladaSampler   `xml:"sampler"`
+	Channels   []ColladaChannel   `xml:"channel"`
+	Animations []ColladaAnimation `xml:"animation"`
+}
+
+type ColladaSampler struct {
+	ID     string         `xml:"id,attr"`
+	Inputs []ColladaInput `xml:"input"`
+}
+
+type ColladaChannel struct {
+	Source string `xml:"source,attr"` // the sampler
+	Target string `xml:"target,attr"` // node id/transform sid and member
+}
+
+type ColladaCamera struct {
+	ID           string             `xml:"id,attr"`
+	Name         string             `xml:"name,attr"`
+	Perspective  *ColladaProjection `xml:"optics>technique_common>perspective"`
+	Orthographic *ColladaProjection `xml:"optics>technique_common>orthographic"`
+}
+
+// Any two of the fov/mag pair and aspect_ratio may be given.
+type ColladaProjection struct {
+	XFov        *float64 `xml:"xfov"`
+	YFov        *float64 `xml:"yfov"`
+	XMag        *float64 `xml:"xmag"`
+	YMag        *float64 `xml:"ymag"`
+	AspectRatio *float64 `xml:"aspect_ratio"`
+	ZNear       float64  `xml:"znear"`
+	ZFar        float64  `xml:"zfar"`
+}
+
+type ColladaLight struct {
+	ID          string              `xml:"id,attr"`
+	Name        string              `xml:"name,attr"`
+	Ambient     *ColladaLightParams `xml:"technique_common>ambient"`
+	Directional *ColladaLightParams `xml:"technique_common>directional"`
+	Point       *ColladaLightParams `xml:"technique_common>point"`
+	Spot        *ColladaLightParams `xml:"technique_common>spot"`
+}
+
+type ColladaLightParams struct {
+	Color                string   `xml:"color"`
+	ConstantAttenuation  *float64 `xml:"constant_attenuation"`
+	LinearAttenuation    float64  `xml:"linear_attenuation"`
+	QuadraticAttenuation float64  `xml:"quadratic_attenuation"`
+	FalloffAngle         *float64 `xml:"falloff_angle"`
+	FalloffExponent      float64  `xml:"falloff_exponent"`
+}
+
+type ColladaVisualScene struct {
+	ID    string        `xml:"id,attr"`
+	Name  string        `xml:"name,attr"`
+	Nodes []ColladaNode `xml:"node"`
+}
+
+type ColladaNode struct {
+	ID   string `xml:"id,attr"`
+	Name string `xml:"name,attr"`
+	SID  string `xml:"sid,attr"`
+	Type string `xml:"type,attr"` // NODE or JOINT
+
+	// matrix, translate, rotate, scale and lookat, in document order.
+	// Anything else not named below ends up here too, and is skipped.
+	Transforms []ColladaTransform `xml:",any"`
+
+	InstanceGeometries  []ColladaInstance `xml:"instance_geometry"`
+	InstanceControllers []ColladaInstance `xml:"instance_controller"`
+	InstanceCameras     []ColladaInstance `xml:"instance_camera"`
+	InstanceLights      []ColladaInstance `xml:"instance_light"`
+	InstanceNodes       []ColladaInstance `xml:"instance_node"`
+	Nodes               []ColladaNode     `xml:"node"`
+}
+
+type ColladaTransform struct {
+	XMLName xml.Name
+	SID     string `xml:"sid,attr"`
+	Text    string `xml:",chardata"`
+}
+
+type ColladaInstance struct {
+	URL       string                    `xml:"url,attr"`
+	Name      string                    `xml:"name,attr"`
+	Skeletons []string                  `xml:"skeleton"`
+	Materials []ColladaInstanceMaterial `xml:"bind_material>technique_common>instance_material"`
+}
+
+type ColladaInstanceMaterial struct {
+	Symbol string `xml:"symbol,attr"`
+	Target string `xml:"target,attr"`
+}
+
+// LoadCollada reads a .dae file into the struct tree.
+func LoadCollada(daeFile string) (*Collada, error) {
+	fp, err := os.Open(daeFile)
+	if err != nil {
+		return nil, err
+	}
+	defer fp.Close()
+	c := new(Collada)
+	if err := xml.NewDecoder(fp).Decode(c); err != nil {
+		return nil, fmt.Errorf("%s: %s", daeFile, err)
+	}
+	return c, nil
+}
+
+// Strings splits a Name_array or IDREF_array.
+func (a *ColladaArray) Strings() []string {
+	return strings.Fields(a.Text)
+}
+
+// Floats parses a float_array.
+func (a *ColladaArray) Floats() ([]float64, error) {
+	fields := strings.Fields(a.Text)
+	fs := make([]float64, len(fields))
+	for i, f := range fields {
+		v, err := strconv.ParseFloat(f, 64)
+		if err != nil {
+			return nil, fmt.Errorf("float_array %s: bad number %q", a.ID, f)
+		}
+		fs[i] = v
+	}
+	if a.Count != 0 && len(fs) != a.Count {
+		return nil, fmt.Errorf("float_array %s: count is %d but has %d numbers", a.ID, a.Count, len(fs))
+	}
+	return fs, nil
+}
+
+// Size is the number of values the accessor gives per element.
+func (s *ColladaSource) Size() int {
+	named, all := 0, 0
+	for _, p := range s.Accessor.Params {
+		if p.Name != "" {
+			named += p.Width()
+		}
+		all += p.Width()
+	}
+	if named == 0 {
+		return all
+	}
+	return named
+}
+
+// Width is how many numbers the param covers: 16 for a float4x4.
+func (p *ColladaParam) Width() int {
+	switch p.Type {
+	case "float2x2":
+		return 4
+	case "float3x3":
+		return 9
+	case "float4x4":
+		return 16
+	}
+	return 1
+}
+
+// Element returns element i of a float source, going through the
+// accessor's offset and stride.  Unnamed params are skipped, as the spec
+// says, unless none have names.
+func (s *ColladaSource) Element(i int) ([]float64, error) {
+	if s.floats == nil {
+		if s.FloatArray == nil {
+			return nil, fmt.Errorf("source %s has no float_array", s.ID)
+		}
+		fs, err := s.FloatArray.Floats()
+		if err != nil {
+			return nil, err
+		}
+		s.floats = fs
+	}
+	a := &s.Accessor
+	stride := a.Stride
+	if stride == 0 {
+		stride = 1
+	}
+	if i < 0 || i >= a.Count {
+		return nil, fmt.Errorf("source %s: index %d out of range, count is %d", s.ID, i, a.Count)
+	}
+	skipUnnamed := false
+	for _, p := range a.Params {
+		skipUnnamed = skipUnnamed || p.Name != ""
+	}
+	var v []float64
+	k := a.Offset + i*stride
+	for _, p := range a.Params {
+		w := p.Width()
+		if skipUnnamed && p.Name == "" {
+			k += w
+			continue
+		}
+		if k+w > len(s.floats) {
+			return nil, fmt.Errorf("source %s: accessor runs past the end of its array", s.ID)
+		}
+		v = append(v, s.floats[k:k+w]...)
+		k += w
+	}
+	return v, nil
+}
+
+// UpAxisVec3 rotates a vector from the file's up axis into Y-up.
+func (c *Collada) UpAxisVec3(v []float64) []float64 {
+	if len(v) < 3 {
+		return v
+	}
+	switch c.Asset.UpAxis {
+	case "Z_UP":
+		return []float64{v[0], v[2], -v[1]}
+	case "X_UP":
+		return []float64{-v[1], v[0], v[2]}
+	}
+	return v
+}
+
+// Semantics whose values are directions or points, and so need turning
+// into Y-up.
+var colladaSpatial = map[string]bool{
+	"POSITION": true, "NORMAL": true, "TANGENT": true, "BINORMAL": true,
+	"TEXTANGENT": true, "TEXBINORMAL": true,
+}
+
+// The attribute each semantic ends up in.
+var colladaAttribs = map[string]gl.Uint{
+	"POSITION": AttribPosition,
+	"COLOR":    AttribColor,
+	"NORMAL":   AttribNormal,
+	"TEXCOORD": AttribTexCoord,
+}
+
+// One input of a primitive, with <vertices> already expanded.
+type colladaStream struct {
+	semantic string
+	set      int
+	offset   int
+	source   *ColladaSource
+	attrib   *MeshAttrib
+}
+
+// Meshes imports every geometry that has a <mesh>.
+func (c *Collada) Meshes() ([]*Mesh, error) {
+	var meshes []*Mesh
+	for i := range c.Geometries {
+		g := &c.Geometries[i]
+		if g.Mesh == nil {
+			continue
+		}
+		m, err := c.importMesh(g)
+		if err != nil {
+			return nil, err
+		}
+		meshes = append(meshes, m)
+	}
+	return meshes, nil
+}
+
+// Mesh imports the geometry with the given id ("Cube-mesh") or name.
+func (c *Collada) Mesh(id string) (*Mesh, error) {
+	for i := range c.Geometries {
+		g := &c.Geometries[i]
+		if (g.ID == id || g.Name == id) && g.Mesh != nil {
+			return c.importMesh(g)
+		}
+	}
+	return nil, fmt.Errorf("no geometry %q", id)
+}
+
+func (c *Collada) importMesh(g *ColladaGeometry) (*Mesh, error) {
+	m, _, err := c.buildMesh(g)
+	if err != nil {
+		return nil, fmt.Errorf("geometry %s: %s", g.ID, err)
+	}
+	return m, nil
+}
+
+// buildMesh also returns the position each vertex came from, which is
+// what skin weights are given per.
+func (c *Collada) buildMesh(g *ColladaGeometry) (*Mesh, []int, error) {
+	cm := g.Mesh
+	sources := make(map[string]*ColladaSource)
+	for i := range cm.Sources {
+		sources["#"+cm.Sources[i].ID] = &cm.Sources[i]
+	}
+
+	m := NewMesh(g.Name)
+	if m.Name == "" {
+		m.Name = g.ID
+	}
+	m.VAOs = make(map[string][]gl.Uint)
+	attribs := make(map[gl.Uint]*MeshAttrib)
+
+	// Every primitive shares the one vertex list, keyed on the source
+	// indices that made each vertex.
+	seen := make(map[string]gl.Uint)
+	vertexCount := 0
+	var positions []int
+
+	// streams expands a primitive's inputs.  Attributes only some
+	// primitives have are padded with zeroes for the others.
+	streams := func(prim *ColladaPrimitive) ([]*colladaStream, int, error) {
+		var ss []*colladaStream
+		stride := 0
+		texSet := -1
+		for _, in := range prim.Inputs {
+			if in.Offset+1 > stride {
+				stride = in.Offset + 1
+			}
+			inputs := []ColladaInput{in}
+			if in.Semantic == "VERTEX" {
+				if in.Source != "#"+cm.Vertices.ID {
+					return nil, 0, fmt.Errorf("VERTEX input %s isn't the mesh's <vertices>", in.Source)
+				}
+				inputs = cm.Vertices.Inputs
+			}
+			for _, vin := range inputs {
+				index, ok := colladaAttribs[vin.Semantic]
+				if !ok {
+					continue // tangents etc. - nothing to put them in
+				}
+				if vin.Semantic == "TEXCOORD" {
+					// Only the first set we see.
+					if texSet != -1 && in.Set != texSet {
+						continue
+					}
+					texSet = in.Set
+				}
+				src, ok := sources[vin.Source]
+				if !ok {
+					return nil, 0, fmt.Errorf("%s input: no source %s", vin.Semantic, vin.Source)
+				}
+				if src.Accessor.Count == 0 {
+					continue // Blender writes empty normal sources for lines
+				}
+				a, ok := attribs[index]
+				if !ok {
+					a = &MeshAttrib{Index: index, Type: "float", Size: src.Size()}
+					if a.Size < 1 || a.Size > 4 {
+						return nil, 0, fmt.Errorf("source %s has %d values per element", src.ID, a.Size)
+					}
+					a.Data = make([]float64, vertexCount*a.Size)
+					attribs[index] = a
+					m.Attribs = append(m.Attribs, a)
+				}
+				ss = append(ss, &colladaStream{vin.Semantic, in.Set, in.Offset, src, a})
+			}
+		}
+		if stride == 0 {
+			return nil, 0, errors.New("primitive has no inputs")
+		}
+		return ss, stride, nil
+	}
+
+	// vertex returns the index of the vertex made from one stride of <p>.
+	vertex := func(ss []*colladaStream, p []int) (gl.Uint, error) {
+		var key []byte
+		for _, s := range ss {
+			key = strconv.AppendInt(key, int64(s.attrib.Index), 10)
+			key = append(key, ':')
+			key = strconv.AppendInt(key, int64(p[s.offset]), 10)
+			key = append(key, ' ')
+		}
+		if v, ok := seen[string(key)]; ok {
+			return v, nil
+		}
+		for _, s := range ss {
+			value, err := s.source.Element(p[s.offset])
+			if err != nil {
+				return 0, err
+			}
+			if colladaSpatial[s.semantic] {
+				value = c.UpAxisVec3(value)
+			}
+			if len(value) != s.attrib.Size {
+				return 0, fmt.Errorf("source %s has %d values per element, expected %d",
+					s.source.ID, len(value), s.attrib.Size)
+			}
+			s.attrib.Data = append(s.attrib.Data, value...)
+		}
+		// Pad attributes this primitive doesn't have.
+		for _, a := range m.Attribs {
+			if len(a.Data) < (vertexCount+1)*a.Size {
+				a.Data = append(a.Data, make([]float64, a.Size)...)
+			}
+		}
+		position := -1
+		for _, s := range ss {
+			if s.semantic == "POSITION" {
+				position = p[s.offset]
+			}
+		}
+		positions = append(positions, position)
+		v := gl.Uint(vertexCount)
+		vertexCount++
+		seen[string(key)] = v
+		return v, nil
+	}
+
+	type primitive struct {
+		kind string
+		prim *ColladaPrimitive
+	}
+	var prims []primitive
+	for i := range cm.Triangles {
+		prims = append(prims, primitive{"triangles", &cm.Triangles[i]})
+	}
+	for i := range cm.Polylists {
+		prims = append(prims, primitive{"polylist", &cm.Polylists[i]})
+	}
+	for i := range cm.Polygons {
+		prims = append(prims, primitive{"polygons", &cm.Polygons[i]})
+	}
+	for i := range cm.Lines {
+		prims = append(prims, primitive{"lines", &cm.Lines[i]})
+	}
+
+	for _, pr := range prims {
+		prim := pr.prim
+		ss, stride, err := streams(prim)
+		if err != nil {
+			return nil, nil, fmt.Errorf("<%s>: %s", pr.kind, err)
+		}
+		// The vertex counts of each polygon.
+		var counts []int
+		switch pr.kind {
+		case "triangles":
+			counts = repeatInt(3, prim.Count)
+		case "lines":
+			counts = repeatInt(2, prim.Count)
+		case "polylist":
+			if counts, err = parseInts(prim.VCount); err != nil {
+				return nil, nil, fmt.Errorf("<polylist> vcount: %s", err)
+			}
+			if len(counts) != prim.Count {
+				return nil, nil, fmt.Errorf("<polylist> count is %d but vcount has %d", prim.Count, len(counts))
+			}
+		}
+		var p []int
+		if pr.kind == "polygons" {
+			for _, poly := range prim.P {
+				ps, err := parseInts(poly)
+				if err != nil {
+					return nil, nil, fmt.Errorf("<polygons> p: %s", err)
+				}
+				counts = append(counts, len(ps)/stride)
+				p = append(p, ps...)
+			}
+		} else if len(prim.P) > 0 {
+			if p, err = parseInts(prim.P[0]); err != nil {
+				return nil, nil, fmt.Errorf("<%s> p: %s", pr.kind, err)
+			}
+		}
+		total := 0
+		for _, n := range counts {
+			total += n
+		}
+		if len(p) != total*stride {
+			return nil, nil, fmt.Errorf("<%s> p has %d indices, expected %d (%d vertices of %d)",
+				pr.kind, len(p), total*stride, total, stride)
+		}
+
+		cmd := &RenderCmd{Cmd: "triangles", Indexed: true, Material: prim.Material}
+		if pr.kind == "lines" {
+			cmd.Cmd = "lines"
+		}
+		for _, n := range counts {
+			poly := make([]gl.Uint, n)
+			for i := range poly {
+				if poly[i], err = vertex(ss, p[i*stride:(i+1)*stride]); err != nil {
+					return nil, nil, fmt.Errorf("<%s>: %s", pr.kind, err)
+				}
+			}
+			p = p[n*stride:]
+			if pr.kind == "lines" {
+				cmd.Indices = append(cmd.Indices, poly...)
+				continue
+			}
+			for _, tri := range triangulateMeshPolygon(m, poly) {
+				cmd.Indices = append(cmd.Indices, poly[tri[0]], poly[tri[1]], poly[tri[2]])
+			}
+		}
+		if len(cmd.Indices) == 0 {
+			continue
+		}
+		cmd.Count = len(cmd.Indices)
+		m.Commands = append(m.Commands, cmd)
+	}
+
+	for _, c := range m.Commands {
+		c.IndexType = "ushort"
+		if vertexCount > 0xffff {
+			c.IndexType = "uint"
+		}
+	}
+	if err := m.Validate(); err != nil {
+		return nil, nil, err
+	}
+	return m, positions, nil
+}
+
+// triangulateMeshPolygon splits a polygon of mesh vertices using their
+// positions.
+func triangulateMeshPolygon(m *Mesh, poly []gl.Uint) [][3]int {
+	pos := m.Attrib(AttribPosition)
+	if len(poly) == 3 || pos == nil || pos.Size < 3 {
+		var tris [][3]int
+		for i := 1; i+1 < len(poly); i++ {
+			tris = append(tris, [3]int{0, i, i + 1})
+		}
+		return tris
+	}
+	pts := make([]Vec3, len(poly))
+	for i, v := range poly {
+		d := pos.Data[int(v)*pos.Size:]
+		pts[i] = Vec3{gl.Float(d[0]), gl.Float(d[1]), gl.Float(d[2])}
+	}
+	return Triangulate(pts)
+}
+
+func repeatInt(v, n int) []int {
+	r := make([]int, n)
+	for i := range r {
+		r[i] = v
+	}
+	return r
+}
+
+func parseInts(s string) ([]int, error) {
+	fields := strings.Fields(s)
+	is := make([]int, len(fields))
+	for i, f := range fields {
+		v, err := strconv.Atoi(f)
+		if err != nil {
+			return nil, fmt.Errorf("bad index %q", f)
+		}
+		is[i] = v
+	}
+	return is, nil
+}
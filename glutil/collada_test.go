@@ -0,0 +1,198 @@
+package glutil
+
+import (
+	"encoding/xml"
+	"fmt"
+	"math"
+	"testing"
+)
+
+func loadColladaMesh(t *testing.T, file string) *Mesh {
+	c, err := LoadCollada(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	meshes, err := c.Meshes()
+	if err != nil {
+		t.Fatalf("%s: %s", file, err)
+	}
+	if len(meshes) != 1 {
+		t.Fatalf("%s: %d meshes", file, len(meshes))
+	}
+	return meshes[0]
+}
+
+// colladaGeometry wraps the insides of a <mesh> in a file with the given
+// up axis.  The geometry is "g".
+func colladaGeometry(t *testing.T, upAxis, mesh string) *Collada {
+	src := `<COLLADA version="1.4.1"><asset><up_axis>` + upAxis + `</up_axis></asset>
+<library_geometries><geometry id="g"><mesh>` + mesh + `</mesh></geometry></library_geometries>
+</COLLADA>`
+	var c Collada
+	if err := xml.Unmarshal([]byte(src), &c); err != nil {
+		t.Fatal(err)
+	}
+	return &c
+}
+
+// colladaPositions is a source of n XYZ positions and the <vertices>
+// that use it.
+func colladaPositions(n int, floats string) string {
+	return fmt.Sprintf(`<source id="pos"><float_array id="pos-a">%s</float_array>
+	<technique_common><accessor source="#pos-a" count="%d" stride="3">
+		<param name="X" type="float"/><param name="Y" type="float"/><param name="Z" type="float"/>
+	</accessor></technique_common></source>
+<vertices id="verts"><input semantic="POSITION" source="#pos"/></vertices>`, floats, n)
+}
+
+func TestColladaFiles(t *testing.T) {
+	tests := []struct {
+		file     string
+		cmd      string
+		vertices int
+		indices  int
+		attribs  int
+	}{
+		// Blender's lines come with an empty normal source, which is
+		// left out.
+		{"../world_tut/hexagon.dae", "lines", 6, 12, 1},
+		// A <polylist> of triangles, positions at offset 0 and normals
+		// at 1.  Every triangle has a normal index of its own, so no
+		// corner is shared.
+		{"../world_tut/unitcone.dae", "triangles", 186, 186, 2},
+		// Six quads of 4 corners each, none of which share a normal and
+		// texcoord with a corner of another face.
+		{"../world_tut/texture_cube.dae", "triangles", 24, 36, 3},
+	}
+	for _, tt := range tests {
+		m := loadColladaMesh(t, tt.file)
+		if len(m.Commands) != 1 || len(m.Attribs) != tt.attribs {
+			t.Errorf("%s: %d commands, %d attribs", tt.file, len(m.Commands), len(m.Attribs))
+			continue
+		}
+		c := m.Commands[0]
+		n := m.Attrib(AttribPosition).VertexCount()
+		if c.Cmd != tt.cmd || len(c.Indices) != tt.indices || c.Count != tt.indices || n != tt.vertices {
+			t.Errorf("%s: %s of %d indices on %d vertices, want %s of %d on %d",
+				tt.file, c.Cmd, len(c.Indices), n, tt.cmd, tt.indices, tt.vertices)
+		}
+	}
+
+	// hexagon.dae is Z_UP, so its first point, (0, 1, 0) in the file, is
+	// (0, 0, -1) here.
+	m := loadColladaMesh(t, "../world_tut/hexagon.dae")
+	if got := attribVec3(m.Attrib(AttribPosition), 0); !vec3Near(got, &Vec3{Z: -1}, 1e-6) {
+		t.Errorf("hexagon: first position %v, want (0, 0, -1)", *got)
+	}
+}
+
+func TestColladaAccessor(t *testing.T) {
+	// Two numbers of padding, then a stride of 4 with an unnamed param
+	// between X and Y, which is skipped.
+	c := colladaGeometry(t, "Y_UP", `<source id="pos"><float_array id="pos-a">9 9  1 0 2 3  4 0 5 6  7 0 8 9</float_array>
+	<technique_common><accessor source="#pos-a" count="3" offset="2" stride="4">
+		<param name="X" type="float"/><param type="float"/><param name="Y" type="float"/><param name="Z" type="float"/>
+	</accessor></technique_common></source>
+<vertices id="verts"><input semantic="POSITION" source="#pos"/></vertices>
+<triangles count="1"><input semantic="VERTEX" source="#verts" offset="0"/><p>0 1 2</p></triangles>`)
+	m, err := c.Mesh("g")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pos := m.Attrib(AttribPosition)
+	if pos.Size != 3 || pos.VertexCount() != 3 {
+		t.Fatalf("%d positions of %d", pos.VertexCount(), pos.Size)
+	}
+	for v, want := range []Vec3{{X: 1, Y: 2, Z: 3}, {X: 4, Y: 5, Z: 6}, {X: 7, Y: 8, Z: 9}} {
+		if got := attribVec3(pos, v); *got != want {
+			t.Errorf("vertex %d: %v, want %v", v, *got, want)
+		}
+	}
+}
+
+func TestColladaConcavePolygon(t *testing.T) {
+	// An L of area 3, starting at the corner a fan from the first vertex
+	// would cut across: (2,1) (1,1) (1,2) lies outside it.
+	c := colladaGeometry(t, "Y_UP", colladaPositions(6, "2 1 0  1 1 0  1 2 0  0 2 0  0 0 0  2 0 0")+
+		`<polygons count="1"><input semantic="VERTEX" source="#verts" offset="0"/><p>0 1 2 3 4 5</p></polygons>`)
+	m, err := c.Mesh("g")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tris := m.Triangles()
+	if len(tris) != 4 {
+		t.Fatalf("%d triangles", len(tris))
+	}
+	// Every triangle winds the same way as the polygon, and between them
+	// they cover it.
+	pos := m.Attrib(AttribPosition)
+	var area float64
+	for _, tri := range tris {
+		a, b, c := attribVec3(pos, tri[0]), attribVec3(pos, tri[1]), attribVec3(pos, tri[2])
+		n := b.Sub(a).Cross(c.Sub(a))
+		if n.Z <= 0 {
+			t.Errorf("triangle %v winds backwards", tri)
+		}
+		area += float64(n.Z) / 2
+	}
+	if math.Abs(area-3) > 1e-6 {
+		t.Errorf("triangles cover %g, want 3", area)
+	}
+}
+
+func TestColladaUpAxis(t *testing.T) {
+	normals := `<source id="n"><float_array id="n-a">1 0 0</float_array>
+	<technique_common><accessor source="#n-a" count="1" stride="3">
+		<param name="X" type="float"/><param name="Y" type="float"/><param name="Z" type="float"/>
+	</accessor></technique_common></source>`
+	tests := []struct {
+		up            string
+		point, normal Vec3
+	}{
+		{"Y_UP", Vec3{X: 1, Y: 2, Z: 3}, Vec3{X: 1}},
+		{"Z_UP", Vec3{X: 1, Y: 3, Z: -2}, Vec3{X: 1}},
+		// X is up in the file, so its normal points up here.
+		{"X_UP", Vec3{X: -2, Y: 1, Z: 3}, Vec3{Y: 1}},
+	}
+	for _, tt := range tests {
+		c := colladaGeometry(t, tt.up, colladaPositions(3, "1 2 3  0 0 0  0 0 0")+normals+
+			`<triangles count="1"><input semantic="VERTEX" source="#verts" offset="0"/><input semantic="NORMAL" source="#n" offset="1"/>
+			<p>0 0 1 0 2 0</p></triangles>`)
+		m, err := c.Mesh("g")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := attribVec3(m.Attrib(AttribPosition), 0); !vec3Near(got, &tt.point, 1e-6) {
+			t.Errorf("%s: position %v, want %v", tt.up, *got, tt.point)
+		}
+		if got := attribVec3(m.Attrib(AttribNormal), 0); !vec3Near(got, &tt.normal, 1e-6) {
+			t.Errorf("%s: normal %v, want %v", tt.up, *got, tt.normal)
+		}
+	}
+}
+
+func TestColladaMeshErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		prim string
+		want string
+	}{
+		{"vcount", `<polylist count="2"><input semantic="VERTEX" source="#verts" offset="0"/><vcount>3</vcount><p>0 1 2</p></polylist>`,
+			"geometry g: <polylist> count is 2 but vcount has 1"},
+		{"short p", `<triangles count="1"><input semantic="VERTEX" source="#verts" offset="0"/><p>0 1</p></triangles>`,
+			"geometry g: <triangles> p has 2 indices, expected 3 (3 vertices of 1)"},
+		{"short p, two offsets", `<triangles count="1"><input semantic="VERTEX" source="#verts" offset="0"/>
+			<input semantic="TEXCOORD" source="#pos" offset="1"/><p>0 0 1 1 2</p></triangles>`,
+			"geometry g: <triangles> p has 5 indices, expected 6 (3 vertices of 2)"},
+		{"index", `<triangles count="1"><input semantic="VERTEX" source="#verts" offset="0"/><p>0 1 3</p></triangles>`,
+			"geometry g: <triangles>: source pos: index 3 out of range, count is 3"},
+		{"no source", `<triangles count="1"><input semantic="NORMAL" source="#n" offset="0"/><p>0 1 2</p></triangles>`,
+			"geometry g: <triangles>: NORMAL input: no source #n"},
+	}
+	for _, tt := range tests {
+		c := colladaGeometry(t, "Y_UP", colladaPositions(3, "0 0 0  1 0 0  0 1 0")+tt.prim)
+		if _, err := c.Mesh("g"); err == nil || err.Error() != tt.want {
+			t.Errorf("%s: %v, want %s", tt.name, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,387 @@
+/*
+colladascene.go - turns a COLLADA visual scene into a Scene: the node tree
+with its transforms, and the geometry, cameras and lights it instances.
+
+COLLADA node transforms are applied in document order, each on the right,
+which is what MatrixStack does too.  For Z_UP and X_UP files every node's
+transform is conjugated by the up-axis rotation, matching the vertices
+collada.go has already rotated.
+*/
+package glutil
+
+import (
+	"fmt"
+	gl "github.com/chsc/gogl/gl33"
+	"strconv"
+	"strings"
+)
+
+// LoadColladaScene reads a .dae file and imports its scene.
+func LoadColladaScene(daeFile string) (*Scene, error) {
+	c, err := LoadCollada(daeFile)
+	if err != nil {
+		return nil, err
+	}
+	s, err := c.ImportScene()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", daeFile, err)
+	}
+	return s, nil
+}
+
+// UpAxisMatrix rotates the file's up axis onto +Y.
+func (c *Collada) UpAxisMatrix() *Mat4 {
+	m := IdentMat4()
+	switch c.Asset.UpAxis {
+	case "Z_UP":
+		// x, y, z -> x, z, -y
+		m[1] = Vec4{0.0, 0.0, -1.0, 0.0}
+		m[2] = Vec4{0.0, 1.0, 0.0, 0.0}
+	case "X_UP":
+		// x, y, z -> -y, x, z
+		m[0] = Vec4{0.0, 1.0, 0.0, 0.0}
+		m[1] = Vec4{-1.0, 0.0, 0.0, 0.0}
+	}
+	return m
+}
+
+// toYUp re-expresses a transform made in the file's axes in ours.
+func (c *Collada) toYUp(m *Mat4) *Mat4 {
+	up := c.UpAxisMatrix()
+	// Rotations are orthonormal, so the inverse is the transpose.
+	return up.MulM(m).MulM(up.Transpose())
+}
+
+func parseColladaFloats(s string, n int) ([]gl.Float, error) {
+	fields := strings.Fields(s)
+	if len(fields) != n {
+		return nil, fmt.Errorf("expected %d numbers, got %d", n, len(fields))
+	}
+	fs := make([]gl.Float, n)
+	for i, f := range fields {
+		v, err := strconv.ParseFloat(f, 64)
+		if err != nil {
+			return nil, fmt.Errorf("bad number %q", f)
+		}
+		fs[i] = gl.Float(v)
+	}
+	return fs, nil
+}
+
+// ColladaMatrix builds a Mat4 from COLLADA's 16 row-major values.
+func ColladaMatrix(v []gl.Float) *Mat4 {
+	m := new(Mat4)
+	for row := 0; row < 4; row++ {
+		for col := 0; col < 4; col++ {
+			m[col].SetElem(row, v[row*4+col])
+		}
+	}
+	return m
+}
+
+// Matrix is the transform element on its own, in the file's axes.  ok is
+// false for elements that aren't transforms.
+func (t *ColladaTransform) Matrix() (m *Mat4, ok bool, err error) {
+	n, ok := colladaTransformSizes[t.XMLName.Local]
+	if !ok {
+		return nil, false, nil
+	}
+	v, err := parseColladaFloats(t.Text, n)
+	if err == nil {
+		m, err = colladaTransformMatrix(t.XMLName.Local, v)
+	}
+	if err != nil {
+		return nil, true, fmt.Errorf("<%s>: %s", t.XMLName.Local, err)
+	}
+	return m, true, nil
+}
+
+// How many numbers each kind of transform element has.
+var colladaTransformSizes = map[string]int{
+	"matrix": 16, "translate": 3, "scale": 3, "rotate": 4, "lookat": 9,
+}
+
+// colladaTransformMatrix builds the matrix for a transform element's
+// values.  Animation changes the values and builds it again.
+func colladaTransformMatrix(kind string, v []gl.Float) (*Mat4, error) {
+	if len(v) != colladaTransformSizes[kind] {
+		return nil, fmt.Errorf("<%s> needs %d numbers, got %d", kind, colladaTransformSizes[kind], len(v))
+	}
+	switch kind {
+	case "matrix":
+		m := ColladaMatrix(v)
+		if v[12] == 0 && v[13] == 0 && v[14] == 0 && v[15] == 0 {
+			// ColladaMax animates the bottom row as all zeroes.
+			m[3].W = 1.0
+		}
+		return m, nil
+	case "translate":
+		return TranslateMat4(&Vec3{v[0], v[1], v[2]}), nil
+	case "scale":
+		return ScaleMat4(&Vec3{v[0], v[1], v[2]}), nil
+	case "rotate":
+		return RotateAxisMat4(&Vec3{v[0], v[1], v[2]}, v[3]), nil
+	}
+	// lookat places the node at the eye, looking at the interest point.
+	view := LookAt(&Vec3{v[0], v[1], v[2]}, &Vec3{v[3], v[4], v[5]}, &Vec3{v[6], v[7], v[8]})
+	m, ok := view.Inverse()
+	if !ok {
+		return nil, fmt.Errorf("degenerate lookat")
+	}
+	return m, nil
+}
+
+// LocalMatrix is the node's transform elements multiplied together, in
+// the file's axes.
+func (n *ColladaNode) LocalMatrix() (*Mat4, error) {
+	m := IdentMat4()
+	for i := range n.Transforms {
+		t, ok, err := n.Transforms[i].Matrix()
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			m = m.MulM(t)
+		}
+	}
+	return m, nil
+}
+
+// Camera imports the camera with the given id.
+func (c *Collada) Camera(id string) (*Camera, error) {
+	for i := range c.Cameras {
+		cc := &c.Cameras[i]
+		if cc.ID != id {
+			continue
+		}
+		cam := &Camera{Name: cc.Name, Orient: *c.UpAxisMatrix()}
+		p := cc.Perspective
+		if p == nil {
+			if p = cc.Orthographic; p == nil {
+				return nil, fmt.Errorf("camera %s is neither perspective nor orthographic", id)
+			}
+			cam.Ortho = true
+		}
+		cam.ZNear, cam.ZFar = gl.Float(p.ZNear), gl.Float(p.ZFar)
+		if p.AspectRatio != nil {
+			cam.Aspect = gl.Float(*p.AspectRatio)
+		}
+		if cam.Ortho {
+			switch {
+			case p.YMag != nil:
+				cam.YMag = gl.Float(*p.YMag)
+				if p.XMag != nil && cam.Aspect == 0 && *p.YMag != 0 {
+					cam.Aspect = gl.Float(*p.XMag / *p.YMag)
+				}
+			case p.XMag != nil && cam.Aspect != 0:
+				cam.YMag = gl.Float(*p.XMag) / cam.Aspect
+			case p.XMag != nil:
+				cam.YMag = gl.Float(*p.XMag)
+			default:
+				return nil, fmt.Errorf("camera %s has no xmag or ymag", id)
+			}
+			return cam, nil
+		}
+		switch {
+		case p.YFov != nil:
+			cam.YFov = gl.Float(*p.YFov)
+			if p.XFov != nil && cam.Aspect == 0 && *p.YFov != 0 {
+				cam.Aspect = gl.Float(*p.XFov / *p.YFov)
+			}
+		case p.XFov != nil && cam.Aspect != 0:
+			cam.YFov = XFovToYFov(gl.Float(*p.XFov), cam.Aspect)
+		case p.XFov != nil:
+			// Work it out from the window's aspect when drawing.
+			cam.XFov = gl.Float(*p.XFov)
+		default:
+			return nil, fmt.Errorf("camera %s has no xfov or yfov", id)
+		}
+		return cam, nil
+	}
+	return nil, fmt.Errorf("no camera %q", id)
+}
+
+// Light imports the light with the given id.
+func (c *Collada) Light(id string) (*Light, error) {
+	for i := range c.Lights {
+		cl := &c.Lights[i]
+		if cl.ID != id {
+			continue
+		}
+		l := &Light{Name: cl.Name, Orient: *c.UpAxisMatrix()}
+		var p *ColladaLightParams
+		switch {
+		case cl.Ambient != nil:
+			l.Type, p = "ambient", cl.Ambient
+		case cl.Directional != nil:
+			l.Type, p = "directional", cl.Directional
+		case cl.Point != nil:
+			l.Type, p = "point", cl.Point
+		case cl.Spot != nil:
+			l.Type, p = "spot", cl.Spot
+		default:
+			return nil, fmt.Errorf("light %s has no known type", id)
+		}
+		rgb, err := parseColladaFloats(p.Color, 3)
+		if err != nil {
+			return nil, fmt.Errorf("light %s colour: %s", id, err)
+		}
+		l.Color = Vec3{rgb[0], rgb[1], rgb[2]}
+		l.ConstantAttenuation = 1.0
+		if p.ConstantAttenuation != nil {
+			l.ConstantAttenuation = gl.Float(*p.ConstantAttenuation)
+		}
+		l.LinearAttenuation = gl.Float(p.LinearAttenuation)
+		l.QuadraticAttenuation = gl.Float(p.QuadraticAttenuation)
+		l.FalloffAngle = 180.0
+		if p.FalloffAngle != nil {
+			l.FalloffAngle = gl.Float(*p.FalloffAngle)
+		}
+		l.FalloffExponent = gl.Float(p.FalloffExponent)
+		return l, nil
+	}
+	return nil, fmt.Errorf("no light %q", id)
+}
+
+// The state of one ImportScene, so shared geometry is imported once.
+type colladaSceneImport struct {
+	c      *Collada
+	meshes map[string]*Mesh
+	depth  int
+}
+
+// ImportScene builds the scene <scene> points at, or the first visual
+// scene if it doesn't say.
+func (c *Collada) ImportScene() (*Scene, error) {
+	vs, err := c.visualScene()
+	if err != nil {
+		return nil, err
+	}
+	imp := &colladaSceneImport{c: c, meshes: make(map[string]*Mesh)}
+	s := &Scene{Name: vs.Name}
+	for i := range vs.Nodes {
+		n, err := imp.node(&vs.Nodes[i])
+		if err != nil {
+			return nil, err
+		}
+		s.Roots = append(s.Roots, n)
+	}
+	return s, nil
+}
+
+func (c *Collada) visualScene() (*ColladaVisualScene, error) {
+	if len(c.VisualScenes) == 0 {
+		return nil, fmt.Errorf("no visual scenes")
+	}
+	url := c.Scene.VisualScene.URL
+	if url == "" {
+		return &c.VisualScenes[0], nil
+	}
+	for i := range c.VisualScenes {
+		if "#"+c.VisualScenes[i].ID == url {
+			return &c.VisualScenes[i], nil
+		}
+	}
+	return nil, fmt.Errorf("no visual scene %s", url)
+}
+
+func (imp *colladaSceneImport) node(cn *ColladaNode) (*Node, error) {
+	// instance_node can loop back on itself.
+	if imp.depth++; imp.depth > 256 {
+		return nil, fmt.Errorf("node %s: nodes nested too deep", cn.ID)
+	}
+	defer func() { imp.depth-- }()
+
+	name := cn.Name
+	if name == "" {
+		name = cn.ID
+	}
+	n := NewNode(name)
+	local, err := cn.LocalMatrix()
+	if err != nil {
+		return nil, fmt.Errorf("node %s: %s", name, err)
+	}
+	n.Transform = *imp.c.toYUp(local)
+
+	for _, inst := range cn.InstanceGeometries {
+		m, ok := imp.meshes[inst.URL]
+		if !ok {
+			if m, err = imp.c.Mesh(strings.TrimPrefix(inst.URL, "#")); err != nil {
+				return nil, fmt.Errorf("node %s: %s", name, err)
+			}
+			imp.meshes[inst.URL] = m
+		}
+		n.Meshes = append(n.Meshes, m)
+	}
+	// Skinned geometry is drawn in its bind shape here; see ImportSkins for
+	// posing it.
+	for _, inst := range cn.InstanceControllers {
+		ctrl := imp.c.controller(inst.URL)
+		if ctrl == nil || ctrl.Skin == nil {
+			return nil, fmt.Errorf("node %s: no skin controller %s", name, inst.URL)
+		}
+		m, ok := imp.meshes[ctrl.Skin.Source]
+		if !ok {
+			if m, err = imp.c.Mesh(strings.TrimPrefix(ctrl.Skin.Source, "#")); err != nil {
+				return nil, fmt.Errorf("node %s: %s", name, err)
+			}
+			imp.meshes[ctrl.Skin.Source] = m
+		}
+		n.Meshes = append(n.Meshes, m)
+	}
+	for _, inst := range cn.InstanceCameras {
+		if n.Camera, err = imp.c.Camera(strings.TrimPrefix(inst.URL, "#")); err != nil {
+			return nil, fmt.Errorf("node %s: %s", name, err)
+		}
+	}
+	for _, inst := range cn.InstanceLights {
+		if n.Light, err = imp.c.Light(strings.TrimPrefix(inst.URL, "#")); err != nil {
+			return nil, fmt.Errorf("node %s: %s", name, err)
+		}
+	}
+	for i := range cn.Nodes {
+		child, err := imp.node(&cn.Nodes[i])
+		if err != nil {
+			return nil, err
+		}
+		n.AddChild(child)
+	}
+	for _, inst := range cn.InstanceNodes {
+		lib := imp.c.libraryNode(inst.URL)
+		if lib == nil {
+			return nil, fmt.Errorf("node %s: no library node %s", name, inst.URL)
+		}
+		child, err := imp.node(lib)
+		if err != nil {
+			return nil, err
+		}
+		n.AddChild(child)
+	}
+	return n, nil
+}
+
+// libraryNode finds a node in library_nodes, or anywhere in a visual scene.
+func (c *Collada) libraryNode(url string) *ColladaNode {
+	id := strings.TrimPrefix(url, "#")
+	var find func(nodes []ColladaNode) *ColladaNode
+	find = func(nodes []ColladaNode) *ColladaNode {
+		for i := range nodes {
+			if nodes[i].ID == id {
+				return &nodes[i]
+			}
+			if n := find(nodes[i].Nodes); n != nil {
+				return n
+			}
+		}
+		return nil
+	}
+	if n := find(c.Nodes); n != nil {
+		return n
+	}
+	for i := range c.VisualScenes {
+		if n := find(c.VisualScenes[i].Nodes); n != nil {
+			return n
+		}
+	}
+	return nil
+}
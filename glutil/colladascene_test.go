@@ -0,0 +1,154 @@
+package glutil
+
+import (
+	"encoding/xml"
+	gl "github.com/chsc/gogl/gl33"
+	"math"
+	"testing"
+)
+
+func TestColladaCameraAndLight(t *testing.T) {
+	c, err := LoadCollada("../world_tut/texture_cube.dae")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := c.ImportScene()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The file gives an xfov of 49.13 at 16:9, and the camera at
+	// (7.481, -6.508, 5.344) with Z up.
+	cam := s.Find("Camera")
+	if cam == nil || cam.Camera == nil {
+		t.Fatal("no camera")
+	}
+	if c := cam.Camera; c.Ortho || math.Abs(float64(c.Aspect)-1.777778) > 1e-6 ||
+		math.Abs(float64(c.YFov)-28.84) > 0.01 || c.ZNear != 0.1 || c.ZFar != 100 {
+		t.Errorf("camera %+v", *c)
+	}
+	if got := cam.Position(); !vec3Near(got, &Vec3{X: 7.481132, Y: 5.343665, Z: 6.50764}, 1e-5) {
+		t.Errorf("camera at %v", *got)
+	}
+	// It looks at the cube, so the origin is ahead of it and on screen.
+	view := cam.ViewMatrix()
+	if o := view.TransformPoint(&Vec3{}); o.Z > -10 || o.Z < -12 {
+		t.Errorf("the origin is at %v in camera space", *o)
+	}
+	if o := project(cam.Camera.Projection(0).MulM(view), &Vec3{}); math.Abs(float64(o.X)) > 1 || math.Abs(float64(o.Y)) > 1 {
+		t.Errorf("the origin projects to %v", *o)
+	}
+	if got := view.TransformPoint(cam.Position()); !vec3Near(got, &Vec3{}, 1e-5) {
+		t.Errorf("the camera is at %v in its own space", *got)
+	}
+
+	// The lamp's -Z is the file's third column, negated and turned Y-up.
+	lamp := s.Find("Lamp")
+	if lamp == nil || lamp.Light == nil {
+		t.Fatal("no lamp")
+	}
+	l := lamp.Light
+	if l.Type != "point" || l.Color != (Vec3{X: 1, Y: 1, Z: 1}) || l.ConstantAttenuation != 1 ||
+		l.LinearAttenuation != 0 || l.QuadraticAttenuation != 0.00111109 || l.FalloffAngle != 180 {
+		t.Errorf("lamp %+v", *l)
+	}
+	if got := lamp.Position(); !vec3Near(got, &Vec3{X: 4.076245, Y: 5.903862, Z: -1.005454}, 1e-5) {
+		t.Errorf("lamp at %v", *got)
+	}
+	if got := lamp.Direction(); !vec3Near(got, &Vec3{X: -0.5663932, Y: -0.7946723, Z: 0.2183912}, 1e-5) {
+		t.Errorf("lamp shines along %v", *got)
+	}
+}
+
+func TestColladaCameraFov(t *testing.T) {
+	f := func(v float64) *float64 { return &v }
+	tests := []struct {
+		name string
+		p    ColladaProjection
+		yFov gl.Float
+		xFov gl.Float
+		asp  gl.Float
+	}{
+		{"yfov", ColladaProjection{YFov: f(40)}, 40, 0, 0},
+		{"xfov and yfov", ColladaProjection{XFov: f(60), YFov: f(40)}, 40, 0, 1.5},
+		{"xfov and aspect", ColladaProjection{XFov: f(90), AspectRatio: f(2)}, XFovToYFov(90, 2), 0, 2},
+		// Left for the window's aspect.
+		{"xfov", ColladaProjection{XFov: f(70)}, 0, 70, 0},
+	}
+	for _, tt := range tests {
+		c := &Collada{Cameras: []ColladaCamera{{ID: "cam", Perspective: &tt.p}}}
+		cam, err := c.Camera("cam")
+		if err != nil {
+			t.Errorf("%s: %s", tt.name, err)
+			continue
+		}
+		if math.Abs(float64(cam.YFov-tt.yFov)) > 1e-4 || cam.XFov != tt.xFov || cam.Aspect != tt.asp {
+			t.Errorf("%s: yfov %g xfov %g aspect %g", tt.name, cam.YFov, cam.XFov, cam.Aspect)
+		}
+	}
+	if got := XFovToYFov(90, 1); math.Abs(float64(got)-90) > 1e-4 {
+		t.Errorf("XFovToYFov(90, 1) = %g", got)
+	}
+}
+
+// A camera placed with <lookat>, and a library node instanced twice, once
+// inside a node that is itself instanced.
+const colladaNodesDAE = `<COLLADA version="1.4.1"><asset><up_axis>Z_UP</up_axis></asset>
+<library_cameras><camera id="cam"><optics><technique_common><perspective>
+	<yfov>45</yfov><znear>1</znear><zfar>100</zfar>
+</perspective></technique_common></optics></camera></library_cameras>
+<library_nodes>
+	<node id="leaf"><translate>1 0 0</translate></node>
+	<node id="branch"><translate>0 0 2</translate><instance_node url="#leaf"/></node>
+	<node id="loop"><instance_node url="#loop"/></node>
+</library_nodes>
+<library_visual_scenes><visual_scene id="scene">
+	<node id="eye"><lookat>0 -5 0  0 0 0  0 0 1</lookat><instance_camera url="#cam"/></node>
+	<node id="tree"><translate>0 3 0</translate><instance_node url="#leaf"/><instance_node url="#branch"/></node>
+</visual_scene></library_visual_scenes>
+</COLLADA>`
+
+func TestColladaNodes(t *testing.T) {
+	var c Collada
+	if err := xml.Unmarshal([]byte(colladaNodesDAE), &c); err != nil {
+		t.Fatal(err)
+	}
+	s, err := c.ImportScene()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Five units down -Y with Z up is five units along +Z here, looking
+	// back at the origin.
+	eye := s.Find("eye")
+	if got := eye.Position(); !vec3Near(got, &Vec3{Z: 5}, 1e-5) {
+		t.Errorf("eye at %v", *got)
+	}
+	if got := eye.ViewMatrix().TransformPoint(&Vec3{}); !vec3Near(got, &Vec3{Z: -5}, 1e-5) {
+		t.Errorf("the origin is at %v in camera space", *got)
+	}
+	if got := eye.ViewMatrix().TransformDir(&Vec3{Y: 1}); !vec3Near(got, &Vec3{Y: 1}, 1e-5) {
+		t.Errorf("up is %v in camera space", *got)
+	}
+
+	tree := s.Find("tree")
+	if tree == nil || len(tree.Children) != 2 {
+		t.Fatalf("tree %+v", tree)
+	}
+	leaf, branch := tree.Children[0], tree.Children[1]
+	if leaf.Name != "leaf" || branch.Name != "branch" || len(branch.Children) != 1 || branch.Children[0].Name != "leaf" {
+		t.Fatalf("tree's children are %s and %s", leaf.Name, branch.Name)
+	}
+	if got := leaf.Position(); !vec3Near(got, &Vec3{X: 1, Z: -3}, 1e-5) {
+		t.Errorf("leaf at %v", *got)
+	}
+	if got := branch.Children[0].Position(); !vec3Near(got, &Vec3{X: 1, Y: 2, Z: -3}, 1e-5) {
+		t.Errorf("the branch's leaf at %v", *got)
+	}
+
+	// A node that instances itself stops rather than recursing forever.
+	c.VisualScenes[0].Nodes = append(c.VisualScenes[0].Nodes, ColladaNode{ID: "bad", InstanceNodes: []ColladaInstance{{URL: "#loop"}}})
+	if _, err := c.ImportScene(); err == nil || err.Error() != "node loop: nodes nested too deep" {
+		t.Errorf("instance_node loop: %v", err)
+	}
+}
@@ -0,0 +1,585 @@
+/*
+colladaskin.go - imports COLLADA skin controllers and animations as Skins on
+a Skeleton, and an AnimationClip that poses it.
+
+The skeleton is every node under the <skeleton> roots of the scene's
+instance_controllers, plus the nodes above them so their world matrices
+come out right.  Skin joints are found by sid, as the spec says, falling
+back to names and ids.
+*/
+package glutil
+
+import (
+	"fmt"
+	gl "github.com/chsc/gogl/gl33"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// LoadColladaSkins reads a .dae file and imports its skins and animation.
+// The clip has no channels if the file isn't animated.
+func LoadColladaSkins(daeFile string) ([]*Skin, *AnimationClip, error) {
+	c, err := LoadCollada(daeFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	skins, skel, err := c.ImportSkins()
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %s", daeFile, err)
+	}
+	clip, err := c.ImportAnimation(skel)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %s", daeFile, err)
+	}
+	return skins, clip, nil
+}
+
+// controller finds a controller by url.
+func (c *Collada) controller(url string) *ColladaController {
+	for i := range c.Controllers {
+		if "#"+c.Controllers[i].ID == url {
+			return &c.Controllers[i]
+		}
+	}
+	return nil
+}
+
+// ImportSkins imports the skin of every instance_controller in the scene,
+// all on the one skeleton.
+func (c *Collada) ImportSkins() ([]*Skin, *Skeleton, error) {
+	vs, err := c.visualScene()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Find the controllers and the skeleton roots they name.
+	var insts []*ColladaInstance
+	roots := make(map[string]bool)
+	var find func(nodes []ColladaNode)
+	find = func(nodes []ColladaNode) {
+		for i := range nodes {
+			for k := range nodes[i].InstanceControllers {
+				inst := &nodes[i].InstanceControllers[k]
+				insts = append(insts, inst)
+				for _, s := range inst.Skeletons {
+					roots[strings.TrimPrefix(strings.TrimSpace(s), "#")] = true
+				}
+			}
+			find(nodes[i].Nodes)
+		}
+	}
+	find(vs.Nodes)
+	if len(insts) == 0 {
+		return nil, nil, fmt.Errorf("no instance_controllers in the scene")
+	}
+
+	skel, err := c.buildSkeleton(vs, roots)
+	if err != nil {
+		return nil, nil, err
+	}
+	var skins []*Skin
+	for _, inst := range insts {
+		ctrl := c.controller(inst.URL)
+		if ctrl == nil {
+			return nil, nil, fmt.Errorf("no controller %s", inst.URL)
+		}
+		if ctrl.Skin == nil {
+			return nil, nil, fmt.Errorf("controller %s isn't a skin", ctrl.ID)
+		}
+		skin, err := c.importSkin(ctrl, skel)
+		if err != nil {
+			return nil, nil, fmt.Errorf("controller %s: %s", ctrl.ID, err)
+		}
+		skins = append(skins, skin)
+	}
+	return skins, skel, nil
+}
+
+// buildSkeleton takes the nodes under roots and those above them, in
+// scene order.  No roots means the whole scene.
+func (c *Collada) buildSkeleton(vs *ColladaVisualScene, roots map[string]bool) (*Skeleton, error) {
+	keep := make(map[*ColladaNode]bool)
+	var mark func(nodes []ColladaNode, path []*ColladaNode, under bool)
+	mark = func(nodes []ColladaNode, path []*ColladaNode, under bool) {
+		for i := range nodes {
+			n := &nodes[i]
+			here := under || len(roots) == 0 || roots[n.ID]
+			if here {
+				keep[n] = true
+				for _, p := range path {
+					keep[p] = true
+				}
+			}
+			mark(n.Nodes, append(path, n), here)
+		}
+	}
+	mark(vs.Nodes, nil, false)
+
+	skel := &Skeleton{up: *c.UpAxisMatrix()}
+	var add func(nodes []ColladaNode, parent int) error
+	add = func(nodes []ColladaNode, parent int) error {
+		for i := range nodes {
+			n := &nodes[i]
+			if !keep[n] {
+				continue
+			}
+			j := &Joint{Name: n.Name, ID: n.ID, SID: n.SID, Parent: parent}
+			if j.Name == "" {
+				j.Name = n.ID
+			}
+			for _, t := range n.Transforms {
+				size, ok := colladaTransformSizes[t.XMLName.Local]
+				if !ok {
+					continue
+				}
+				v, err := parseColladaFloats(t.Text, size)
+				if err != nil {
+					return fmt.Errorf("node %s: <%s>: %s", j.Name, t.XMLName.Local, err)
+				}
+				j.Elements = append(j.Elements, TransformElement{t.XMLName.Local, t.SID, v})
+			}
+			rest, err := skel.Local(j.Elements)
+			if err != nil {
+				return fmt.Errorf("node %s: %s", j.Name, err)
+			}
+			j.Rest = *rest
+			skel.Joints = append(skel.Joints, j)
+			if err := add(n.Nodes, len(skel.Joints)-1); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := add(vs.Nodes, -1); err != nil {
+		return nil, err
+	}
+	if len(roots) != 0 {
+		for id := range roots {
+			if skel.Find(id) < 0 {
+				return nil, fmt.Errorf("no skeleton node %s", id)
+			}
+		}
+	}
+	return skel, nil
+}
+
+func (c *Collada) importSkin(ctrl *ColladaController, skel *Skeleton) (*Skin, error) {
+	cs := ctrl.Skin
+	var geom *ColladaGeometry
+	for i := range c.Geometries {
+		if "#"+c.Geometries[i].ID == cs.Source && c.Geometries[i].Mesh != nil {
+			geom = &c.Geometries[i]
+		}
+	}
+	if geom == nil {
+		return nil, fmt.Errorf("skin source %s isn't a mesh geometry", cs.Source)
+	}
+	mesh, positions, err := c.buildMesh(geom)
+	if err != nil {
+		return nil, fmt.Errorf("geometry %s: %s", geom.ID, err)
+	}
+
+	skin := &Skin{Name: ctrl.Name, Mesh: mesh, Skeleton: skel, BindShape: *IdentMat4()}
+	if skin.Name == "" {
+		skin.Name = ctrl.ID
+	}
+	if strings.TrimSpace(cs.BindShapeMatrix) != "" {
+		v, err := parseColladaFloats(cs.BindShapeMatrix, 16)
+		if err != nil {
+			return nil, fmt.Errorf("bind_shape_matrix: %s", err)
+		}
+		skin.BindShape = *c.toYUp(ColladaMatrix(v))
+	}
+
+	sources := make(map[string]*ColladaSource)
+	for i := range cs.Sources {
+		sources["#"+cs.Sources[i].ID] = &cs.Sources[i]
+	}
+
+	// <joints>: the names and inverse bind matrices.
+	var names []string
+	var invBind *ColladaSource
+	byID := false
+	for _, in := range cs.Joints {
+		src, ok := sources[in.Source]
+		if !ok {
+			return nil, fmt.Errorf("<joints> %s input: no source %s", in.Semantic, in.Source)
+		}
+		switch in.Semantic {
+		case "JOINT":
+			switch {
+			case src.NameArray != nil:
+				names = src.NameArray.Strings()
+			case src.IDRefArray != nil:
+				names, byID = src.IDRefArray.Strings(), true
+			default:
+				return nil, fmt.Errorf("joint source %s has no names", src.ID)
+			}
+		case "INV_BIND_MATRIX":
+			invBind = src
+		}
+	}
+	if names == nil || invBind == nil {
+		return nil, fmt.Errorf("<joints> needs JOINT and INV_BIND_MATRIX inputs")
+	}
+	for i, name := range names {
+		j := -1
+		if byID {
+			for k, joint := range skel.Joints {
+				if joint.ID == name {
+					j = k
+				}
+			}
+		} else {
+			j = skel.Find(name)
+		}
+		if j < 0 {
+			return nil, fmt.Errorf("joint %s isn't in the skeleton", name)
+		}
+		v, err := invBind.Element(i)
+		if err != nil {
+			return nil, err
+		}
+		if len(v) != 16 {
+			return nil, fmt.Errorf("inverse bind matrix %d has %d values", i, len(v))
+		}
+		m := make([]gl.Float, 16)
+		for k := range v {
+			m[k] = gl.Float(v[k])
+		}
+		skin.Joints = append(skin.Joints, j)
+		skin.InvBind = append(skin.InvBind, *c.toYUp(ColladaMatrix(m)))
+	}
+
+	influences, err := c.skinWeights(cs, sources, len(names))
+	if err != nil {
+		return nil, err
+	}
+	for _, inf := range influences {
+		if len(inf) > MaxInfluences {
+			skin.Capped++
+		}
+	}
+
+	// Weights are per position; the mesh may have split them.
+	skin.Influences = make([][MaxInfluences]int, len(positions))
+	skin.Weights = make([][MaxInfluences]gl.Float, len(positions))
+	for v, p := range positions {
+		if p < 0 || p >= len(influences) {
+			return nil, fmt.Errorf("vertex %d has position %d, but there are weights for %d", v, p, len(influences))
+		}
+		inf := influences[p]
+		if len(inf) > MaxInfluences {
+			inf = inf[:MaxInfluences]
+		}
+		total := 0.0
+		for _, w := range inf {
+			total += w.weight
+		}
+		for k, w := range inf {
+			skin.Influences[v][k] = w.joint
+			if total > 0 {
+				skin.Weights[v][k] = gl.Float(w.weight / total)
+			}
+		}
+	}
+	return skin, nil
+}
+
+type colladaInfluence struct {
+	joint  int
+	weight float64
+}
+
+type colladaInfluences []colladaInfluence
+
+func (s colladaInfluences) Len() int           { return len(s) }
+func (s colladaInfluences) Less(i, j int) bool { return s[i].weight > s[j].weight }
+func (s colladaInfluences) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+// skinWeights reads <vertex_weights>: the joints on each position, heaviest
+// first.
+func (c *Collada) skinWeights(cs *ColladaSkin, sources map[string]*ColladaSource, joints int) ([]colladaInfluences, error) {
+	vw := &cs.VertexWeights
+	jointOffset, weightOffset := -1, -1
+	var weights *ColladaSource
+	stride := 0
+	for _, in := range vw.Inputs {
+		if in.Offset+1 > stride {
+			stride = in.Offset + 1
+		}
+		switch in.Semantic {
+		case "JOINT":
+			jointOffset = in.Offset
+		case "WEIGHT":
+			weightOffset = in.Offset
+			weights = sources[in.Source]
+			if weights == nil {
+				return nil, fmt.Errorf("<vertex_weights> WEIGHT input: no source %s", in.Source)
+			}
+		}
+	}
+	if jointOffset < 0 || weights == nil {
+		return nil, fmt.Errorf("<vertex_weights> needs JOINT and WEIGHT inputs")
+	}
+	counts, err := parseInts(vw.VCount)
+	if err != nil {
+		return nil, fmt.Errorf("<vertex_weights> vcount: %s", err)
+	}
+	if len(counts) != vw.Count {
+		return nil, fmt.Errorf("<vertex_weights> count is %d but vcount has %d", vw.Count, len(counts))
+	}
+	v, err := parseInts(vw.V)
+	if err != nil {
+		return nil, fmt.Errorf("<vertex_weights> v: %s", err)
+	}
+
+	influences := make([]colladaInfluences, len(counts))
+	for i, n := range counts {
+		if len(v) < n*stride {
+			return nil, fmt.Errorf("<vertex_weights> v runs out at vertex %d", i)
+		}
+		for k := 0; k < n; k++ {
+			pair := v[k*stride : (k+1)*stride]
+			j := pair[jointOffset]
+			w, err := weights.Element(pair[weightOffset])
+			if err != nil {
+				return nil, err
+			}
+			if j == -1 {
+				j = joints // the bind shape itself, after the joints in the palette
+			} else if j < 0 || j >= joints {
+				return nil, fmt.Errorf("<vertex_weights> joint %d out of range", j)
+			}
+			influences[i] = append(influences[i], colladaInfluence{j, w[0]})
+		}
+		v = v[n*stride:]
+		sort.Stable(influences[i])
+	}
+	return influences, nil
+}
+
+// ImportAnimation gathers every channel in library_animations into one
+// clip on skel.  Channels that don't target a joint's transform element go
+// in the clip's Unbound instead: astroBoy_walk_Maya.dae has 63 channels,
+// and its spine01/blendParent1 is a Maya constraint weight, so the clip
+// plays 62.
+func (c *Collada) ImportAnimation(skel *Skeleton) (*AnimationClip, error) {
+	clip := &AnimationClip{Skeleton: skel}
+	if vs, err := c.visualScene(); err == nil {
+		clip.Name = vs.Name
+	}
+
+	sources := make(map[string]*ColladaSource)
+	samplers := make(map[string]*ColladaSampler)
+	var channels []ColladaChannel
+	var gather func(anims []ColladaAnimation)
+	gather = func(anims []ColladaAnimation) {
+		for i := range anims {
+			a := &anims[i]
+			for k := range a.Sources {
+				sources["#"+a.Sources[k].ID] = &a.Sources[k]
+			}
+			for k := range a.Samplers {
+				samplers["#"+a.Samplers[k].ID] = &a.Samplers[k]
+			}
+			channels = append(channels, a.Channels...)
+			gather(a.Animations)
+		}
+	}
+	gather(c.Animations)
+
+	first := true
+	for _, ch := range channels {
+		joint, element, members, ok := colladaChannelTarget(skel, ch.Target)
+		if !ok {
+			clip.Unbound = append(clip.Unbound, ch.Target)
+			continue
+		}
+		sampler := samplers[ch.Source]
+		if sampler == nil {
+			return nil, fmt.Errorf("channel %s: no sampler %s", ch.Target, ch.Source)
+		}
+		curve, err := colladaCurve(sampler, sources)
+		if err != nil {
+			return nil, fmt.Errorf("channel %s: %s", ch.Target, err)
+		}
+		size := len(skel.Joints[joint].Elements[element].Values)
+		if members != nil {
+			size = len(members)
+		}
+		if curve.Stride != size {
+			return nil, fmt.Errorf("channel %s: curve has %d values per key, target needs %d", ch.Target, curve.Stride, size)
+		}
+		clip.Channels = append(clip.Channels, &AnimationChannel{ch.Target, joint, element, members, curve})
+
+		start, end := curve.Times[0], curve.Times[len(curve.Times)-1]
+		if first || start < clip.Start {
+			clip.Start = start
+		}
+		if first || end > clip.End {
+			clip.End = end
+		}
+		first = false
+	}
+	return clip, nil
+}
+
+// colladaChannelTarget resolves "node/sid", "node/sid.ANGLE",
+// "node/sid(3)" or "node/sid(col)(row)".
+func colladaChannelTarget(skel *Skeleton, target string) (joint, element int, members []int, ok bool) {
+	slash := strings.Index(target, "/")
+	if slash < 0 {
+		return
+	}
+	joint = -1
+	for i, j := range skel.Joints {
+		if j.ID == target[:slash] {
+			joint = i
+		}
+	}
+	if joint < 0 {
+		return
+	}
+	rest := target[slash+1:]
+	sid, member := rest, ""
+	if k := strings.IndexAny(rest, ".("); k >= 0 {
+		sid, member = rest[:k], rest[k:]
+	}
+	element = -1
+	for i, e := range skel.Joints[joint].Elements {
+		if e.SID == sid {
+			element = i
+		}
+	}
+	if element < 0 {
+		return
+	}
+	kind := skel.Joints[joint].Elements[element].Kind
+	switch {
+	case member == "":
+		return joint, element, nil, true
+	case member[0] == '.':
+		names := map[string]int{"X": 0, "Y": 1, "Z": 2, "ANGLE": 3}
+		i, found := names[member[1:]]
+		if !found || (i == 3 && kind != "rotate") {
+			return
+		}
+		return joint, element, []int{i}, true
+	}
+	// (i) or (col)(row).
+	var idx []int
+	for _, f := range strings.Split(strings.TrimSuffix(strings.TrimPrefix(member, "("), ")"), ")(") {
+		i, err := strconv.Atoi(f)
+		if err != nil {
+			return
+		}
+		idx = append(idx, i)
+	}
+	i := idx[0]
+	if len(idx) == 2 {
+		i = idx[1]*4 + idx[0] // matrix values are row-major
+	}
+	if len(idx) > 2 || i < 0 || i >= len(skel.Joints[joint].Elements[element].Values) {
+		return
+	}
+	return joint, element, []int{i}, true
+}
+
+// colladaCurve reads a sampler's keys.  One-dimensional tangents get times
+// a third of the way along their segment.
+func colladaCurve(s *ColladaSampler, sources map[string]*ColladaSource) (*AnimationCurve, error) {
+	inputs := make(map[string]*ColladaSource)
+	for _, in := range s.Inputs {
+		src, ok := sources[in.Source]
+		if !ok {
+			return nil, fmt.Errorf("%s input: no source %s", in.Semantic, in.Source)
+		}
+		inputs[in.Semantic] = src
+	}
+	input, output := inputs["INPUT"], inputs["OUTPUT"]
+	if input == nil || output == nil {
+		return nil, fmt.Errorf("sampler %s needs INPUT and OUTPUT", s.ID)
+	}
+	n := input.Accessor.Count
+	curve := &AnimationCurve{Stride: output.Size()}
+	if output.Accessor.Count != n {
+		return nil, fmt.Errorf("sampler %s has %d inputs but %d outputs", s.ID, n, output.Accessor.Count)
+	}
+	for i := 0; i < n; i++ {
+		t, err := input.Element(i)
+		if err != nil {
+			return nil, err
+		}
+		v, err := output.Element(i)
+		if err != nil {
+			return nil, err
+		}
+		curve.Times = append(curve.Times, t[0])
+		curve.Values = append(curve.Values, v...)
+	}
+
+	curve.Interps = repeatString("LINEAR", n)
+	if interp := inputs["INTERPOLATION"]; interp != nil {
+		if interp.NameArray == nil {
+			return nil, fmt.Errorf("INTERPOLATION source %s has no Name_array", interp.ID)
+		}
+		names := interp.NameArray.Strings()
+		if len(names) < n {
+			return nil, fmt.Errorf("INTERPOLATION source %s has %d names for %d keys", interp.ID, len(names), n)
+		}
+		curve.Interps = names[:n]
+	}
+
+	tangents := func(semantic string, dir float64) ([]float64, error) {
+		src := inputs[semantic]
+		if src == nil {
+			return nil, nil
+		}
+		var ts []float64
+		for i := 0; i < n; i++ {
+			v, err := src.Element(i)
+			if err != nil {
+				return nil, err
+			}
+			switch len(v) {
+			case 2 * curve.Stride:
+				ts = append(ts, v...)
+			case curve.Stride:
+				// Just values; put them a third of the way to the
+				// neighbouring key.
+				t := curve.Times[i]
+				if k := i + int(dir); k >= 0 && k < n {
+					t += (curve.Times[k] - t) / 3
+				}
+				for _, y := range v {
+					ts = append(ts, t, y)
+				}
+			default:
+				return nil, fmt.Errorf("%s source %s has %d values per key, expected %d or %d",
+					semantic, src.ID, len(v), curve.Stride, 2*curve.Stride)
+			}
+		}
+		return ts, nil
+	}
+	var err error
+	if curve.InTangents, err = tangents("IN_TANGENT", -1); err != nil {
+		return nil, err
+	}
+	if curve.OutTangents, err = tangents("OUT_TANGENT", 1); err != nil {
+		return nil, err
+	}
+	if err := curve.Validate(); err != nil {
+		return nil, fmt.Errorf("sampler %s: %s", s.ID, err)
+	}
+	return curve, nil
+}
+
+func repeatString(s string, n int) []string {
+	r := make([]string, n)
+	for i := range r {
+		r[i] = s
+	}
+	return r
+}
@@ -0,0 +1,715 @@
+/*
+glsl.go - just enough of a GLSL parser to pull out a shader's interface: its
+#version, in/out variables, uniforms and uniform blocks, with the file and
+line each came from.  Function bodies are skipped, and #ifdef'd code is read
+as if every branch were live.  No GL context needed.
+
+The Check functions compare those interfaces the way the linker would, so
+mismatches can be caught offline - see glslcheck.go.
+*/
+package glutil
+
+import (
+	"fmt"
+	gl "github.com/chsc/gogl/gl33"
+	"strconv"
+	"strings"
+)
+
+// A top-level in, out or uniform variable, or a uniform block member.
+type GLSLVar struct {
+	Name     string
+	Type     string
+	Array    string // "", "[]" or "[4]"
+	Storage  string // in, out or uniform
+	Interp   string // smooth, flat or noperspective; smooth when unqualified
+	Location int    // -1 without layout(location=N)
+	File     string
+	Line     int
+}
+
+func (v *GLSLVar) String() string {
+	s := v.Type + " " + v.Name + v.Array
+	if v.Storage == "in" || v.Storage == "out" {
+		s = v.Interp + " " + v.Storage + " " + s
+	}
+	return s
+}
+
+type GLSLBlock struct {
+	Name     string
+	Instance string
+	Storage  string // uniform, in or out
+	Layout   string // std140, shared or packed
+	Members  []GLSLVar
+	File     string
+	Line     int
+}
+
+type GLSLInfo struct {
+	File        string
+	Stage       gl.Enum
+	Version     string // e.g. "330 core"
+	VersionLine int    // 0 when there's no #version
+	Inputs      []GLSLVar
+	Outputs     []GLSLVar
+	Uniforms    []GLSLVar
+	Blocks      []GLSLBlock
+}
+
+// ParseGLSL preprocesses filename and reads its interface.  The stage comes
+// from the extension, as for CreateShaderProgram.
+func ParseGLSL(filename string, defines map[string]string) (*GLSLInfo, error) {
+	stage, err := ShaderType(filename)
+	if err != nil {
+		return nil, err
+	}
+	return ParseGLSLStage(filename, stage, defines)
+}
+
+// ParseGLSLStage is ParseGLSL for files whose extension doesn't say what
+// they are, like the tutorials' .glsl files.
+func ParseGLSLStage(filename string, stage gl.Enum, defines map[string]string) (*GLSLInfo, error) {
+	src, err := Preprocess(filename, defines)
+	if err != nil {
+		return nil, err
+	}
+	return parseGLSLSource(filename, stage, src)
+}
+
+// parseGLSLSource reads the interface out of preprocessed source.
+func parseGLSLSource(filename string, stage gl.Enum, src *Source) (*GLSLInfo, error) {
+	info := &GLSLInfo{File: filename, Stage: stage}
+	p := &glslParser{info: info, toks: info.tokenize(src)}
+	if err := p.parse(); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+type glslToken struct {
+	text string
+	file string
+	line int
+}
+
+// tokenize splits preprocessed source into tokens, following the #line
+// directives back to the original files, and picks up #version on the way.
+func (info *GLSLInfo) tokenize(src *Source) []glslToken {
+	var toks []glslToken
+	file, line := src.Files[0], 1
+	inComment := false
+	for _, text := range strings.Split(src.Code, "\n") {
+		if !inComment {
+			if directive, rest := parseDirective(text); directive != "" {
+				if directive == "line" {
+					// The next line is line N of source S.
+					f := strings.Fields(rest)
+					if len(f) > 0 {
+						line, _ = strconv.Atoi(f[0])
+					}
+					if len(f) > 1 {
+						if i, err := strconv.Atoi(f[1]); err == nil && i < len(src.Files) {
+							file = src.Files[i]
+						}
+					}
+					continue
+				}
+				if directive == "version" && info.VersionLine == 0 && file == src.Files[0] {
+					info.Version = strings.TrimSpace(rest)
+					info.VersionLine = line
+				}
+				line++
+				continue
+			}
+		}
+		for i := 0; i < len(text); {
+			c := text[i]
+			switch {
+			case inComment:
+				end := strings.Index(text[i:], "*/")
+				if end < 0 {
+					i = len(text)
+				} else {
+					i += end + 2
+					inComment = false
+				}
+			case strings.HasPrefix(text[i:], "//"):
+				i = len(text)
+			case strings.HasPrefix(text[i:], "/*"):
+				inComment = true
+				i += 2
+			case c == ' ' || c == '\t' || c == '\r':
+				i++
+			case isIdentChar(c):
+				j := i
+				for j < len(text) && (isIdentChar(text[j]) || text[j] == '.') {
+					j++
+				}
+				toks = append(toks, glslToken{text[i:j], file, line})
+				i = j
+			default:
+				toks = append(toks, glslToken{text[i : i+1], file, line})
+				i++
+			}
+		}
+		line++
+	}
+	return toks
+}
+
+func isIdentChar(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+type glslParser struct {
+	info *GLSLInfo
+	toks []glslToken
+	pos  int
+}
+
+func (p *glslParser) peek() string {
+	if p.pos >= len(p.toks) {
+		return ""
+	}
+	return p.toks[p.pos].text
+}
+
+func (p *glslParser) next() glslToken {
+	if p.pos >= len(p.toks) {
+		return glslToken{}
+	}
+	t := p.toks[p.pos]
+	p.pos++
+	return t
+}
+
+func (p *glslParser) errorf(format string, args ...interface{}) error {
+	t := p.toks[len(p.toks)-1]
+	if p.pos < len(p.toks) {
+		t = p.toks[p.pos]
+	}
+	return fmt.Errorf("%s:%d: %s", t.file, t.line, fmt.Sprintf(format, args...))
+}
+
+func (p *glslParser) expect(text string) error {
+	if p.peek() != text {
+		return p.errorf("expected %q, found %q", text, p.peek())
+	}
+	p.pos++
+	return nil
+}
+
+// skipBalanced skips from an opening bracket to just past its partner.
+func (p *glslParser) skipBalanced(open, close string) error {
+	depth := 0
+	for p.pos < len(p.toks) {
+		switch p.next().text {
+		case open:
+			depth++
+		case close:
+			depth--
+			if depth == 0 {
+				return nil
+			}
+		}
+	}
+	return p.errorf("unbalanced %q", open)
+}
+
+// skipTo skips to just past the next sep at this bracket depth.
+func (p *glslParser) skipTo(sep string) {
+	for p.pos < len(p.toks) {
+		switch t := p.peek(); t {
+		case "(", "[", "{":
+			close := map[string]string{"(": ")", "[": "]", "{": "}"}[t]
+			p.skipBalanced(t, close)
+		case sep:
+			p.pos++
+			return
+		default:
+			p.pos++
+		}
+	}
+}
+
+// array reads any number of [N] suffixes.
+func (p *glslParser) array() string {
+	s := ""
+	for p.peek() == "[" {
+		p.next()
+		s += "["
+		for p.peek() != "]" && p.peek() != "" {
+			s += p.next().text
+		}
+		p.next()
+		s += "]"
+	}
+	return s
+}
+
+var glslStorage = map[string]bool{
+	"in": true, "out": true, "inout": true, "uniform": true, "const": true,
+	"attribute": true, "varying": true, "buffer": true, "shared": true,
+}
+
+var glslInterp = map[string]bool{"smooth": true, "flat": true, "noperspective": true}
+
+var glslOtherQualifiers = map[string]bool{
+	"centroid": true, "sample": true, "patch": true, "invariant": true, "precise": true,
+	"highp": true, "mediump": true, "lowp": true,
+	"coherent": true, "volatile": true, "restrict": true, "readonly": true, "writeonly": true,
+}
+
+type glslQualifiers struct {
+	storage string
+	interp  string
+	layout  map[string]string
+}
+
+// qualifiers reads layout(...) and the storage/interpolation keywords in
+// front of a declaration.
+func (p *glslParser) qualifiers() (glslQualifiers, error) {
+	q := glslQualifiers{layout: make(map[string]string)}
+	for {
+		t := p.peek()
+		switch {
+		case t == "layout":
+			p.next()
+			if err := p.expect("("); err != nil {
+				return q, err
+			}
+			for p.peek() != ")" && p.peek() != "" {
+				key := p.next().text
+				if key == "," {
+					continue
+				}
+				val := ""
+				if p.peek() == "=" {
+					p.next()
+					val = p.next().text
+				}
+				q.layout[key] = val
+			}
+			p.next()
+		case glslStorage[t]:
+			q.storage = p.next().text
+		case glslInterp[t]:
+			q.interp = p.next().text
+		case glslOtherQualifiers[t]:
+			p.next()
+		default:
+			return q, nil
+		}
+	}
+}
+
+func (p *glslParser) parse() error {
+	for p.pos < len(p.toks) {
+		if err := p.declaration(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *glslParser) declaration() error {
+	switch p.peek() {
+	case ";":
+		p.next()
+		return nil
+	case "precision":
+		p.skipTo(";")
+		return nil
+	case "struct":
+		for p.peek() != "{" && p.peek() != "" {
+			p.next()
+		}
+		if err := p.skipBalanced("{", "}"); err != nil {
+			return err
+		}
+		p.skipTo(";")
+		return nil
+	}
+
+	q, err := p.qualifiers()
+	if err != nil {
+		return err
+	}
+	// layout(triangles) in; and friends
+	if p.peek() == ";" {
+		p.next()
+		return nil
+	}
+	typeTok := p.next()
+	if p.peek() == "{" {
+		return p.block(q, typeTok)
+	}
+	typeArray := p.array()
+
+	for {
+		name := p.next()
+		if p.peek() == "(" {
+			// A function: skip the parameters, then the body if there is one.
+			if err := p.skipBalanced("(", ")"); err != nil {
+				return err
+			}
+			if p.peek() == "{" {
+				return p.skipBalanced("{", "}")
+			}
+			p.skipTo(";")
+			return nil
+		}
+		v := GLSLVar{
+			Name:     name.text,
+			Type:     typeTok.text,
+			Array:    typeArray + p.array(),
+			Location: -1,
+			File:     name.file,
+			Line:     name.line,
+		}
+		p.addVar(q, v)
+		if p.peek() == "=" {
+			// Skip the initializer up to the next top-level , or ;
+			for p.peek() != "," && p.peek() != ";" && p.peek() != "" {
+				switch t := p.peek(); t {
+				case "(", "[", "{":
+					p.skipBalanced(t, map[string]string{"(": ")", "[": "]", "{": "}"}[t])
+				default:
+					p.next()
+				}
+			}
+		}
+		switch p.peek() {
+		case ",":
+			p.next()
+		case ";":
+			p.next()
+			return nil
+		default:
+			return p.errorf("unexpected %q in declaration of %s", p.peek(), name.text)
+		}
+	}
+}
+
+// storage normalizes the old attribute/varying keywords.
+func (p *glslParser) storage(s string) string {
+	switch s {
+	case "attribute":
+		return "in"
+	case "varying":
+		if p.info.Stage == gl.VERTEX_SHADER {
+			return "out"
+		}
+		return "in"
+	}
+	return s
+}
+
+func (p *glslParser) addVar(q glslQualifiers, v GLSLVar) {
+	v.Storage = p.storage(q.storage)
+	v.Interp = q.interp
+	if v.Interp == "" && (v.Storage == "in" || v.Storage == "out") {
+		v.Interp = "smooth"
+	}
+	if loc, ok := q.layout["location"]; ok {
+		if n, err := strconv.Atoi(loc); err == nil {
+			v.Location = n
+		}
+	}
+	switch v.Storage {
+	case "in":
+		p.info.Inputs = append(p.info.Inputs, v)
+	case "out":
+		p.info.Outputs = append(p.info.Outputs, v)
+	case "uniform":
+		p.info.Uniforms = append(p.info.Uniforms, v)
+	}
+}
+
+// block reads an interface block, having seen its name and the {.
+func (p *glslParser) block(q glslQualifiers, name glslToken) error {
+	b := GLSLBlock{
+		Name:    name.text,
+		Storage: p.storage(q.storage),
+		Layout:  "shared",
+		File:    name.file,
+		Line:    name.line,
+	}
+	for _, l := range []string{"std140", "std430", "packed", "shared"} {
+		if _, ok := q.layout[l]; ok {
+			b.Layout = l
+		}
+	}
+	p.next()
+	for p.peek() != "}" {
+		if p.peek() == "" {
+			return p.errorf("unterminated block %s", b.Name)
+		}
+		mq, err := p.qualifiers()
+		if err != nil {
+			return err
+		}
+		typeTok := p.next()
+		typeArray := p.array()
+		for {
+			mname := p.next()
+			b.Members = append(b.Members, GLSLVar{
+				Name:     mname.text,
+				Type:     typeTok.text,
+				Array:    typeArray + p.array(),
+				Storage:  b.Storage,
+				Interp:   mq.interp,
+				Location: -1,
+				File:     mname.file,
+				Line:     mname.line,
+			})
+			if p.peek() != "," {
+				break
+			}
+			p.next()
+		}
+		if err := p.expect(";"); err != nil {
+			return err
+		}
+	}
+	p.next()
+	if p.peek() != ";" {
+		b.Instance = p.next().text + p.array()
+	}
+	if err := p.expect(";"); err != nil {
+		return err
+	}
+	p.info.Blocks = append(p.info.Blocks, b)
+	return nil
+}
+
+// std140 sizes and alignments of the basic types, in bytes.
+func std140Basic(t string) (size, align int, ok bool) {
+	switch t {
+	case "float", "int", "uint", "bool":
+		return 4, 4, true
+	}
+	if len(t) >= 4 {
+		base := t[:len(t)-1]
+		n := int(t[len(t)-1] - '0')
+		if (base == "vec" || base == "ivec" || base == "uvec" || base == "bvec") && n >= 2 && n <= 4 {
+			align := 4 * n
+			if n == 3 {
+				align = 16
+			}
+			return 4 * n, align, true
+		}
+	}
+	// matC and matCxR are C columns of vecR, each padded to a vec4.
+	if strings.HasPrefix(t, "mat") {
+		dims := strings.Split(t[3:], "x")
+		cols, err := strconv.Atoi(dims[0])
+		if err != nil || cols < 2 || cols > 4 {
+			return 0, 0, false
+		}
+		return 16 * cols, 16, true
+	}
+	return 0, 0, false
+}
+
+func roundUp(n, to int) int {
+	return (n + to - 1) / to * to
+}
+
+// Std140 lays the block out by the std140 rules, returning each member's
+// offset and the total size.  Structs aren't handled.
+func (b *GLSLBlock) Std140() ([]int, int, error) {
+	offsets := make([]int, len(b.Members))
+	offset := 0
+	for i, m := range b.Members {
+		size, align, ok := std140Basic(m.Type)
+		if !ok {
+			return nil, 0, fmt.Errorf("can't lay out %s %s in std140", m.Type, m.Name)
+		}
+		if m.Array != "" {
+			count := 1
+			for _, dim := range strings.Split(strings.Trim(m.Array, "[]"), "][") {
+				n, err := strconv.Atoi(dim)
+				if err != nil {
+					return nil, 0, fmt.Errorf("array size %q of %s isn't a literal", dim, m.Name)
+				}
+				count *= n
+			}
+			// Array elements are padded out to a vec4.
+			stride := roundUp(size, 16)
+			size, align = stride*count, 16
+		}
+		offset = roundUp(offset, align)
+		offsets[i] = offset
+		offset += size
+	}
+	return offsets, roundUp(offset, 16), nil
+}
+
+// Stage order in the pipeline, for pairing up outputs with inputs.
+var StageOrder = []gl.Enum{
+	gl.VERTEX_SHADER,
+	TESS_CONTROL_SHADER,
+	TESS_EVALUATION_SHADER,
+	gl.GEOMETRY_SHADER,
+	gl.FRAGMENT_SHADER,
+}
+
+func problem(file string, line int, sev Severity, format string, args ...interface{}) Diagnostic {
+	return Diagnostic{File: file, Line: line, Severity: sev, Message: fmt.Sprintf(format, args...)}
+}
+
+// CheckVersion complains about a missing #version.
+func CheckVersion(info *GLSLInfo) []Diagnostic {
+	if info.VersionLine == 0 {
+		return []Diagnostic{problem(info.File, 1, SeverityError, "no #version directive")}
+	}
+	return nil
+}
+
+// perVertex strips the outer [] that tessellation and geometry stages put on
+// their per-vertex variables.
+func perVertex(stage gl.Enum, array string, input bool) string {
+	arrayed := stage == TESS_CONTROL_SHADER || (input && (stage == TESS_EVALUATION_SHADER || stage == gl.GEOMETRY_SHADER))
+	if !arrayed || !strings.HasPrefix(array, "[") {
+		return array
+	}
+	return array[strings.Index(array, "]")+1:]
+}
+
+// CheckInterface matches the inputs of next against the outputs of prev:
+// each input needs an output with the same name, type and interpolation.
+func CheckInterface(prev, next *GLSLInfo) []Diagnostic {
+	var diags []Diagnostic
+	outs := make(map[string]*GLSLVar)
+	for i := range prev.Outputs {
+		outs[prev.Outputs[i].Name] = &prev.Outputs[i]
+	}
+	for _, in := range next.Inputs {
+		if strings.HasPrefix(in.Name, "gl_") {
+			continue
+		}
+		out, ok := outs[in.Name]
+		if !ok {
+			diags = append(diags, problem(in.File, in.Line, SeverityError,
+				"%s input %s isn't written by %s shader %s",
+				StageName(next.Stage), in.Name, StageName(prev.Stage), prev.File))
+			continue
+		}
+		inArray := perVertex(next.Stage, in.Array, true)
+		outArray := perVertex(prev.Stage, out.Array, false)
+		if in.Type != out.Type || inArray != outArray {
+			diags = append(diags, problem(in.File, in.Line, SeverityError,
+				"%s is %s%s here but %s%s in %s:%d",
+				in.Name, in.Type, inArray, out.Type, outArray, out.File, out.Line))
+		}
+		if in.Interp != out.Interp {
+			diags = append(diags, problem(in.File, in.Line, SeverityError,
+				"%s is %s here but %s in %s:%d",
+				in.Name, in.Interp, out.Interp, out.File, out.Line))
+		}
+	}
+	return diags
+}
+
+// CheckBlocks makes sure every uniform block with the same name has the same
+// members, and so the same std140 layout, wherever it's declared.
+func CheckBlocks(infos []*GLSLInfo) []Diagnostic {
+	var diags []Diagnostic
+	first := make(map[string]*GLSLBlock)
+	seen := make(map[string]bool) // the same include is parsed once per file
+	for _, info := range infos {
+		for i := range info.Blocks {
+			b := &info.Blocks[i]
+			if b.Storage != "uniform" {
+				continue
+			}
+			key := fmt.Sprintf("%s:%d:%s", b.File, b.Line, b.Name)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			if b.Layout != "std140" {
+				diags = append(diags, problem(b.File, b.Line, SeverityWarning,
+					"uniform block %s is %s, so its layout can differ between programs", b.Name, b.Layout))
+			}
+			offsets, size, err := b.Std140()
+			if err != nil {
+				diags = append(diags, problem(b.File, b.Line, SeverityWarning, "uniform block %s: %s", b.Name, err))
+				continue
+			}
+			ref, ok := first[b.Name]
+			if !ok {
+				first[b.Name] = b
+				continue
+			}
+			refOffsets, refSize, _ := ref.Std140()
+			if b.Layout != ref.Layout {
+				diags = append(diags, problem(b.File, b.Line, SeverityError,
+					"uniform block %s is %s here but %s in %s:%d", b.Name, b.Layout, ref.Layout, ref.File, ref.Line))
+			}
+			for j, m := range b.Members {
+				if j >= len(ref.Members) {
+					diags = append(diags, problem(m.File, m.Line, SeverityError,
+						"%s.%s isn't in the block in %s:%d", b.Name, m.Name, ref.File, ref.Line))
+					continue
+				}
+				r := ref.Members[j]
+				if m.Name != r.Name || m.Type != r.Type || m.Array != r.Array || offsets[j] != refOffsets[j] {
+					diags = append(diags, problem(m.File, m.Line, SeverityError,
+						"%s member %d is %s %s%s at offset %d, but %s %s%s at offset %d in %s:%d",
+						b.Name, j, m.Type, m.Name, m.Array, offsets[j],
+						r.Type, r.Name, r.Array, refOffsets[j], r.File, r.Line))
+				}
+			}
+			if size != refSize || len(b.Members) < len(ref.Members) {
+				diags = append(diags, problem(b.File, b.Line, SeverityError,
+					"uniform block %s is %d bytes here but %d in %s:%d", b.Name, size, refSize, ref.File, ref.Line))
+			}
+		}
+	}
+	return diags
+}
+
+// CheckLocations makes sure a vertex attribute name always gets the same
+// layout(location=N), and that no shader puts two attributes in one slot.
+func CheckLocations(infos []*GLSLInfo) []Diagnostic {
+	var diags []Diagnostic
+	first := make(map[string]*GLSLVar)
+	seen := make(map[string]bool)
+	for _, info := range infos {
+		if info.Stage != gl.VERTEX_SHADER {
+			continue
+		}
+		used := make(map[int]*GLSLVar)
+		for i := range info.Inputs {
+			in := &info.Inputs[i]
+			if in.Location < 0 {
+				continue
+			}
+			if other, ok := used[in.Location]; ok && other.Name != in.Name {
+				diags = append(diags, problem(in.File, in.Line, SeverityError,
+					"%s and %s both use location %d", other.Name, in.Name, in.Location))
+			}
+			used[in.Location] = in
+			// Shared includes only need complaining about once.
+			key := fmt.Sprintf("%s:%d:%s", in.File, in.Line, in.Name)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			ref, ok := first[in.Name]
+			if !ok {
+				first[in.Name] = in
+				continue
+			}
+			if ref.Location != in.Location {
+				diags = append(diags, problem(in.File, in.Line, SeverityError,
+					"attribute %s is at location %d here but %d in %s:%d",
+					in.Name, in.Location, ref.Location, ref.File, ref.Line))
+			}
+		}
+	}
+	return diags
+}
@@ -0,0 +1,266 @@
+package glutil
+
+import (
+	"fmt"
+	gl "github.com/chsc/gogl/gl33"
+	"reflect"
+	"testing"
+)
+
+// parseGLSLFiles parses each of the named files, as the stage its
+// extension says, with the others there to be included.
+func parseGLSLFiles(t *testing.T, files map[string]string, names ...string) []*GLSLInfo {
+	var infos []*GLSLInfo
+	for _, name := range names {
+		stage, err := ShaderType(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		src, err := mapFiles(files).Process(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		info, err := parseGLSLSource(name, stage, src)
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+		infos = append(infos, info)
+	}
+	return infos
+}
+
+// messages are the diagnostics as file:line: severity: message.
+func messages(diags []Diagnostic) []string {
+	var s []string
+	for _, d := range diags {
+		s = append(s, fmt.Sprintf("%s:%d: %s: %s", d.File, d.Line, d.Severity, d.Message))
+	}
+	return s
+}
+
+func TestParseGLSL(t *testing.T) {
+	files := map[string]string{"a.vert": lines(
+		"#version 330",
+		"layout(location = 0) in vec4 position;",
+		"layout(location=2) in vec3 normal;",
+		"flat out int id;",
+		"out vec2 uv[2];",
+		"uniform mat4 modelToCamera;",
+		"layout(std140) uniform Projection {",
+		"	mat4 cameraToClip;",
+		"	float zNear, zFar;",
+		"};",
+		"void main() {",
+		"	vec4 p = position; // in vec3 nothing;",
+		"}",
+	)}
+	info := parseGLSLFiles(t, files, "a.vert")[0]
+	if info.Version != "330" || info.VersionLine != 1 {
+		t.Errorf("version %q on line %d", info.Version, info.VersionLine)
+	}
+	var got []string
+	for _, vs := range [][]GLSLVar{info.Inputs, info.Outputs, info.Uniforms} {
+		for _, v := range vs {
+			got = append(got, fmt.Sprintf("%s @%d :%d", &v, v.Location, v.Line))
+		}
+	}
+	want := []string{
+		"smooth in vec4 position @0 :2",
+		"smooth in vec3 normal @2 :3",
+		"flat out int id @-1 :4",
+		"smooth out vec2 uv[2] @-1 :5",
+		"mat4 modelToCamera @-1 :6",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if len(info.Blocks) != 1 {
+		t.Fatalf("%d blocks", len(info.Blocks))
+	}
+	b := info.Blocks[0]
+	if b.Name != "Projection" || b.Layout != "std140" || b.Storage != "uniform" || len(b.Members) != 3 || b.Members[2].Name != "zFar" {
+		t.Errorf("block %+v", b)
+	}
+}
+
+func TestStd140(t *testing.T) {
+	b := GLSLBlock{Members: []GLSLVar{
+		{Name: "a", Type: "float"},
+		{Name: "b", Type: "vec3"},
+		{Name: "c", Type: "float"},
+		{Name: "m", Type: "mat4"},
+		{Name: "arr", Type: "float", Array: "[2]"},
+		{Name: "d", Type: "vec2"},
+	}}
+	offsets, size, err := b.Std140()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// vec3 aligns to 16, but c fits in its last 4 bytes; array elements are
+	// a vec4 apiece.
+	if want := []int{0, 16, 28, 32, 96, 128}; !reflect.DeepEqual(offsets, want) || size != 144 {
+		t.Errorf("offsets %v size %d, want %v 144", offsets, size, want)
+	}
+	b.Members = append(b.Members, GLSLVar{Name: "l", Type: "Light"})
+	if _, _, err := b.Std140(); err == nil {
+		t.Errorf("laid out a struct")
+	}
+}
+
+func TestCheckInterface(t *testing.T) {
+	tests := []struct {
+		name       string
+		prev, next string // file names, so stages
+		out, in    string
+		want       []string
+	}{
+		{"match", "a.vert", "b.frag",
+			"out vec3 color;", "in vec3 color;", nil},
+		{"smooth by default", "a.vert", "b.frag",
+			"smooth out vec3 color;", "in vec3 color;", nil},
+		{"unwritten", "a.vert", "b.frag",
+			"out vec3 color;", "in vec2 uv;",
+			[]string{"b.frag:2: error: fragment input uv isn't written by vertex shader a.vert"}},
+		{"type", "a.vert", "b.frag",
+			"out vec3 color;", "in vec4 color;",
+			[]string{"b.frag:2: error: color is vec4 here but vec3 in a.vert:2"}},
+		{"flat to smooth", "a.vert", "b.frag",
+			"flat out int id;", "in int id;",
+			[]string{"b.frag:2: error: id is smooth here but flat in a.vert:2"}},
+		{"smooth to flat", "a.vert", "b.frag",
+			"out vec3 n;", "flat in vec3 n;",
+			[]string{"b.frag:2: error: n is flat here but smooth in a.vert:2"}},
+		{"noperspective", "a.vert", "b.frag",
+			"noperspective out vec2 uv;", "noperspective in vec2 uv;", nil},
+		{"array size", "a.vert", "b.frag",
+			"out float w[2];", "in float w[3];",
+			[]string{"b.frag:2: error: w is float[3] here but float[2] in a.vert:2"}},
+		// Geometry inputs have a [] per vertex that the vertex shader's
+		// outputs don't.
+		{"geometry per vertex", "a.vert", "b.geom",
+			"out vec3 n;", "in vec3 n[];", nil},
+		{"geometry arrays", "a.vert", "b.geom",
+			"out float w[2];", "in float w[3][2];", nil},
+		{"geometry type", "a.vert", "b.geom",
+			"out vec3 n;", "in vec4 n[];",
+			[]string{"b.geom:2: error: n is vec4 here but vec3 in a.vert:2"}},
+		{"tessellation", "a.tesc", "b.tese",
+			"out vec3 n[];", "in vec3 n[];", nil},
+		{"built-ins", "a.vert", "b.geom",
+			"out vec3 n;", "in vec3 n[]; in gl_PerVertex { vec4 gl_Position; } gl_in[];", nil},
+	}
+	for _, tt := range tests {
+		files := map[string]string{
+			tt.prev: lines("#version 330", tt.out, "void main() {}"),
+			tt.next: lines("#version 330", tt.in, "void main() {}"),
+		}
+		infos := parseGLSLFiles(t, files, tt.prev, tt.next)
+		if got := messages(CheckInterface(infos[0], infos[1])); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCheckBlocks(t *testing.T) {
+	block := func(layout string, members ...string) string {
+		s := "#version 330\nlayout(" + layout + ") uniform Light {\n"
+		for _, m := range members {
+			s += "\t" + m + ";\n"
+		}
+		return s + "};\nvoid main() {}\n"
+	}
+	tests := []struct {
+		name string
+		a, b string
+		want []string
+	}{
+		{"same", block("std140", "vec4 color", "float k"), block("std140", "vec4 color", "float k"), nil},
+		{"renamed", block("std140", "vec4 color", "float k"), block("std140", "vec4 colour", "float k"),
+			[]string{"b.frag:3: error: Light member 0 is vec4 colour at offset 0, but vec4 color at offset 0 in a.vert:3"}},
+		{"retyped", block("std140", "vec3 dir", "float k"), block("std140", "vec4 dir", "float k"),
+			[]string{
+				"b.frag:3: error: Light member 0 is vec4 dir at offset 0, but vec3 dir at offset 0 in a.vert:3",
+				"b.frag:4: error: Light member 1 is float k at offset 16, but float k at offset 12 in a.vert:4",
+				"b.frag:2: error: uniform block Light is 32 bytes here but 16 in a.vert:2",
+			}},
+		{"extra member", block("std140", "vec4 color"), block("std140", "vec4 color", "vec4 dir"),
+			[]string{
+				"b.frag:4: error: Light.dir isn't in the block in a.vert:2",
+				"b.frag:2: error: uniform block Light is 32 bytes here but 16 in a.vert:2",
+			}},
+		{"missing member", block("std140", "vec4 color", "vec4 dir"), block("std140", "vec4 color"),
+			[]string{"b.frag:2: error: uniform block Light is 16 bytes here but 32 in a.vert:2"}},
+		{"shared", block("shared", "vec4 color"), block("std140", "vec4 color"),
+			[]string{
+				"a.vert:2: warning: uniform block Light is shared, so its layout can differ between programs",
+				"b.frag:2: error: uniform block Light is std140 here but shared in a.vert:2",
+			}},
+	}
+	for _, tt := range tests {
+		infos := parseGLSLFiles(t, map[string]string{"a.vert": tt.a, "b.frag": tt.b}, "a.vert", "b.frag")
+		if got := messages(CheckBlocks(infos)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	// A block in an include is only checked once, however many files have
+	// it.
+	files := map[string]string{
+		"light.glsl": "layout(packed) uniform Light { vec4 color; };\n",
+		"a.vert":     "#version 330\n#include \"light.glsl\"\nvoid main() {}\n",
+		"b.frag":     "#version 330\n#include \"light.glsl\"\nvoid main() {}\n",
+	}
+	got := messages(CheckBlocks(parseGLSLFiles(t, files, "a.vert", "b.frag")))
+	want := []string{"light.glsl:1: warning: uniform block Light is packed, so its layout can differ between programs"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("included: got %q, want %q", got, want)
+	}
+}
+
+func TestCheckLocations(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want []string
+	}{
+		{"same", "layout(location = 0) in vec4 position;", "layout(location = 0) in vec4 position;", nil},
+		{"unset", "in vec4 position;", "layout(location = 1) in vec4 position;", nil},
+		{"moved", "layout(location = 0) in vec4 position;", "layout(location = 1) in vec4 position;",
+			[]string{"b.vert:2: error: attribute position is at location 1 here but 0 in a.vert:2"}},
+		{"shared slot", "layout(location = 0) in vec4 position;",
+			"layout(location = 2) in vec4 color; layout(location = 2) in vec3 normal;",
+			[]string{"b.vert:2: error: color and normal both use location 2"}},
+	}
+	for _, tt := range tests {
+		files := map[string]string{
+			"a.vert": lines("#version 330", tt.a, "void main() {}"),
+			"b.vert": lines("#version 330", tt.b, "void main() {}"),
+		}
+		if got := messages(CheckLocations(parseGLSLFiles(t, files, "a.vert", "b.vert"))); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	// Only vertex inputs have attribute locations.
+	files := map[string]string{
+		"a.vert": lines("#version 330", "layout(location = 0) in vec4 position;", "void main() {}"),
+		"b.frag": lines("#version 330", "layout(location = 1) in vec4 position;", "void main() {}"),
+	}
+	if got := CheckLocations(parseGLSLFiles(t, files, "a.vert", "b.frag")); len(got) != 0 {
+		t.Errorf("fragment inputs: %q", messages(got))
+	}
+}
+
+func TestCheckVersion(t *testing.T) {
+	files := map[string]string{"a.vert": "in vec4 p;\nvoid main() {}\n", "b.vert": "#version 330 core\nvoid main() {}\n"}
+	infos := parseGLSLFiles(t, files, "a.vert", "b.vert")
+	if got := messages(CheckVersion(infos[0])); !reflect.DeepEqual(got, []string{"a.vert:1: error: no #version directive"}) {
+		t.Errorf("no #version: %q", got)
+	}
+	if got := CheckVersion(infos[1]); len(got) != 0 || infos[1].Version != "330 core" {
+		t.Errorf("#version 330 core: %q %q", infos[1].Version, messages(got))
+	}
+	if infos[0].Stage != gl.VERTEX_SHADER {
+		t.Errorf("stage %s", StageName(infos[0].Stage))
+	}
+}
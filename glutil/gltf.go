@@ -0,0 +1,684 @@
+/*
+gltf.go - glTF 2.0: .gltf JSON files, with their buffers embedded as data:
+URIs or in files beside them, and binary .glb files.
+
+The JSON is read into a struct tree, the way collada.go reads the XML.
+Accessors come out as float64s; normalized integer data keeps its raw
+values and gets a norm-* type, so GL does the normalizing just as it does
+for a gltut mesh.  Each mesh primitive becomes a Mesh with one draw command,
+as primitives don't share vertices.  glTF is already Y-up with
+counter-clockwise front faces; only texture coordinates need turning over,
+as glTF puts v = 0 at the top of the image and GL (and OBJ, and
+texture.go) at the bottom.
+*/
+package glutil
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	gl "github.com/chsc/gogl/gl33"
+	"io/ioutil"
+	"math"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Optional references are pointers, as 0 is a perfectly good index.  The
+// omitempties are for GLTF.Write, so it leaves out what the spec defaults.
+type GLTF struct {
+	Asset struct {
+		Version    string `json:"version"`
+		MinVersion string `json:"minVersion,omitempty"`
+		Generator  string `json:"generator,omitempty"`
+	} `json:"asset"`
+	Scene              *int             `json:"scene,omitempty"`
+	Scenes             []GLTFScene      `json:"scenes,omitempty"`
+	Nodes              []GLTFNode       `json:"nodes,omitempty"`
+	Meshes             []GLTFMesh       `json:"meshes,omitempty"`
+	Accessors          []GLTFAccessor   `json:"accessors,omitempty"`
+	BufferViews        []GLTFBufferView `json:"bufferViews,omitempty"`
+	Buffers            []GLTFBuffer     `json:"buffers,omitempty"`
+	Materials          []GLTFMaterial   `json:"materials,omitempty"`
+	Textures           []GLTFTexture    `json:"textures,omitempty"`
+	Images             []GLTFImage      `json:"images,omitempty"`
+	Samplers           []GLTFSampler    `json:"samplers,omitempty"`
+	Cameras            []GLTFCamera     `json:"cameras,omitempty"`
+	ExtensionsRequired []string         `json:"extensionsRequired,omitempty"`
+
+	File    string `json:"-"` // URIs are relative to it
+	buffers [][]byte
+}
+
+type GLTFScene struct {
+	Name  string `json:"name,omitempty"`
+	Nodes []int  `json:"nodes,omitempty"`
+}
+
+// A node has a matrix, or any of translation, rotation (a quaternion,
+// x y z w) and scale.
+type GLTFNode struct {
+	Name        string    `json:"name,omitempty"`
+	Children    []int     `json:"children,omitempty"`
+	Mesh        *int      `json:"mesh,omitempty"`
+	Camera      *int      `json:"camera,omitempty"`
+	Matrix      []float64 `json:"matrix,omitempty"` // column-major
+	Translation []float64 `json:"translation,omitempty"`
+	Rotation    []float64 `json:"rotation,omitempty"`
+	Scale       []float64 `json:"scale,omitempty"`
+}
+
+type GLTFMesh struct {
+	Name       string          `json:"name,omitempty"`
+	Primitives []GLTFPrimitive `json:"primitives"`
+}
+
+type GLTFPrimitive struct {
+	Attributes map[string]int `json:"attributes"` // semantic to accessor
+	Indices    *int           `json:"indices,omitempty"`
+	Material   *int           `json:"material,omitempty"`
+	Mode       *int           `json:"mode,omitempty"` // TRIANGLES if missing
+}
+
+type GLTFAccessor struct {
+	Name          string      `json:"name,omitempty"`
+	BufferView    *int        `json:"bufferView,omitempty"` // all zeros if missing
+	ByteOffset    int         `json:"byteOffset,omitempty"`
+	ComponentType int         `json:"componentType"`
+	Normalized    bool        `json:"normalized,omitempty"`
+	Count         int         `json:"count"`
+	Type          string      `json:"type"` // SCALAR, VEC2...MAT4
+	Max           []float64   `json:"max,omitempty"`
+	Min           []float64   `json:"min,omitempty"`
+	Sparse        *GLTFSparse `json:"sparse,omitempty"`
+}
+
+// Sparse accessors replace Count of their elements with other values.
+type GLTFSparse struct {
+	Count   int `json:"count"`
+	Indices struct {
+		BufferView    int `json:"bufferView"`
+		ByteOffset    int `json:"byteOffset,omitempty"`
+		ComponentType int `json:"componentType"`
+	} `json:"indices"`
+	Values struct {
+		BufferView int `json:"bufferView"`
+		ByteOffset int `json:"byteOffset,omitempty"`
+	} `json:"values"`
+}
+
+type GLTFBufferView struct {
+	Name       string `json:"name,omitempty"`
+	Buffer     int    `json:"buffer"`
+	ByteOffset int    `json:"byteOffset,omitempty"`
+	ByteLength int    `json:"byteLength"`
+	ByteStride int    `json:"byteStride,omitempty"` // 0 for tightly packed
+	Target     int    `json:"target,omitempty"`     // ARRAY_BUFFER or ELEMENT_ARRAY_BUFFER
+}
+
+type GLTFBuffer struct {
+	Name       string `json:"name,omitempty"`
+	URI        string `json:"uri,omitempty"` // missing for a .glb's BIN chunk
+	ByteLength int    `json:"byteLength"`
+}
+
+type GLTFMaterial struct {
+	Name                 string           `json:"name,omitempty"`
+	PbrMetallicRoughness *GLTFPBR         `json:"pbrMetallicRoughness,omitempty"`
+	NormalTexture        *GLTFTextureInfo `json:"normalTexture,omitempty"`
+	OcclusionTexture     *GLTFTextureInfo `json:"occlusionTexture,omitempty"`
+	EmissiveTexture      *GLTFTextureInfo `json:"emissiveTexture,omitempty"`
+	EmissiveFactor       []float64        `json:"emissiveFactor,omitempty"`
+	AlphaMode            string           `json:"alphaMode,omitempty"`
+	AlphaCutoff          *float64         `json:"alphaCutoff,omitempty"`
+	DoubleSided          bool             `json:"doubleSided,omitempty"`
+}
+
+type GLTFPBR struct {
+	BaseColorFactor          []float64        `json:"baseColorFactor,omitempty"`
+	BaseColorTexture         *GLTFTextureInfo `json:"baseColorTexture,omitempty"`
+	MetallicFactor           *float64         `json:"metallicFactor,omitempty"`
+	RoughnessFactor          *float64         `json:"roughnessFactor,omitempty"`
+	MetallicRoughnessTexture *GLTFTextureInfo `json:"metallicRoughnessTexture,omitempty"`
+}
+
+type GLTFTextureInfo struct {
+	Index    int      `json:"index"`
+	TexCoord int      `json:"texCoord,omitempty"`
+	Scale    *float64 `json:"scale,omitempty"`    // normal textures
+	Strength *float64 `json:"strength,omitempty"` // occlusion textures
+}
+
+type GLTFTexture struct {
+	Name    string `json:"name,omitempty"`
+	Sampler *int   `json:"sampler,omitempty"`
+	Source  *int   `json:"source,omitempty"`
+}
+
+type GLTFImage struct {
+	Name       string `json:"name,omitempty"`
+	URI        string `json:"uri,omitempty"`
+	MimeType   string `json:"mimeType,omitempty"`
+	BufferView *int   `json:"bufferView,omitempty"`
+}
+
+// GL enums; 0 where the file leaves it to us.
+type GLTFSampler struct {
+	MagFilter int `json:"magFilter,omitempty"`
+	MinFilter int `json:"minFilter,omitempty"`
+	WrapS     int `json:"wrapS,omitempty"`
+	WrapT     int `json:"wrapT,omitempty"`
+}
+
+type GLTFCamera struct {
+	Name         string            `json:"name,omitempty"`
+	Type         string            `json:"type"`
+	Perspective  *GLTFPerspective  `json:"perspective,omitempty"`
+	Orthographic *GLTFOrthographic `json:"orthographic,omitempty"`
+}
+
+type GLTFPerspective struct {
+	AspectRatio float64 `json:"aspectRatio,omitempty"`
+	YFov        float64 `json:"yfov"`           // radians
+	ZFar        float64 `json:"zfar,omitempty"` // 0 for an infinite projection
+	ZNear       float64 `json:"znear"`
+}
+
+type GLTFOrthographic struct {
+	XMag  float64 `json:"xmag"`
+	YMag  float64 `json:"ymag"`
+	ZFar  float64 `json:"zfar"`
+	ZNear float64 `json:"znear"`
+}
+
+// The GLB header and chunk types.
+const (
+	glbMagic     = "glTF"
+	glbChunkJSON = 0x4E4F534A
+	glbChunkBIN  = 0x004E4942
+)
+
+var gltfComponentTypes = map[int]struct {
+	name  string // as a MeshAttrib type
+	bytes int
+}{
+	5120: {"byte", 1},
+	5121: {"ubyte", 1},
+	5122: {"short", 2},
+	5123: {"ushort", 2},
+	5125: {"uint", 4},
+	5126: {"float", 4},
+}
+
+var gltfTypeSizes = map[string]int{
+	"SCALAR": 1, "VEC2": 2, "VEC3": 3, "VEC4": 4, "MAT2": 4, "MAT3": 9, "MAT4": 16,
+}
+
+// Where each vertex attribute goes, in index order.  Anything else
+// (TEXCOORD_2, morph targets...) is left out, apart from the _ATTRIBn
+// semantics Write uses for attributes that aren't one of these.
+var gltfAttribs = []struct {
+	semantic string
+	index    gl.Uint
+}{
+	{"POSITION", AttribPosition},
+	{"COLOR_0", AttribColor},
+	{"NORMAL", AttribNormal},
+	{"TANGENT", AttribTangent},
+	{"TEXCOORD_1", AttribTexCoord1},
+	{"TEXCOORD_0", AttribTexCoord},
+	{"JOINTS_0", AttribBoneIndices},
+	{"WEIGHTS_0", AttribBoneWeights},
+}
+
+// Primitive modes 0 to 6, as RenderCmd commands.
+var gltfModes = []string{"points", "lines", "line-loop", "line-strip", "triangles", "tri-strip", "tri-fan"}
+
+// LoadGLTF reads a .gltf or .glb file and the buffers it refers to.
+func LoadGLTF(file string) (*GLTF, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	g, err := ParseGLTF(data, file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", file, err)
+	}
+	return g, nil
+}
+
+// ParseGLTF reads glTF JSON, or a whole GLB.  file is where sidecar
+// buffers and images are looked for relative to.
+func ParseGLTF(data []byte, file string) (*GLTF, error) {
+	var bin []byte
+	if len(data) >= 4 && string(data[:4]) == glbMagic {
+		var err error
+		if data, bin, err = splitGLB(data); err != nil {
+			return nil, err
+		}
+	}
+	g := &GLTF{File: file}
+	if err := json.Unmarshal(data, g); err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(g.Asset.Version, "2.") {
+		return nil, fmt.Errorf("glTF version %q, only 2.x is supported", g.Asset.Version)
+	}
+	if len(g.ExtensionsRequired) > 0 {
+		return nil, fmt.Errorf("requires extensions %s", strings.Join(g.ExtensionsRequired, ", "))
+	}
+	for i, b := range g.Buffers {
+		var data []byte
+		if b.URI == "" {
+			if i != 0 || bin == nil {
+				return nil, fmt.Errorf("buffer %d has no uri", i)
+			}
+			data = bin
+		} else {
+			var err error
+			if data, err = g.readURI(b.URI); err != nil {
+				return nil, fmt.Errorf("buffer %d: %s", i, err)
+			}
+		}
+		if len(data) < b.ByteLength {
+			return nil, fmt.Errorf("buffer %d is %d bytes, should be %d", i, len(data), b.ByteLength)
+		}
+		g.buffers = append(g.buffers, data[:b.ByteLength])
+	}
+	return g, nil
+}
+
+// splitGLB returns the JSON and BIN chunks of a GLB.  The BIN chunk is nil
+// if there isn't one.
+func splitGLB(data []byte) (jsonData, bin []byte, err error) {
+	le := binary.LittleEndian
+	if len(data) < 12 {
+		return nil, nil, fmt.Errorf("GLB header truncated")
+	}
+	if v := le.Uint32(data[4:]); v != 2 {
+		return nil, nil, fmt.Errorf("GLB version %d, only 2 is supported", v)
+	}
+	length := int(le.Uint32(data[8:]))
+	if length > len(data) {
+		return nil, nil, fmt.Errorf("GLB is %d bytes, header says %d", len(data), length)
+	}
+	data = data[:length]
+	for off := 12; off+8 <= len(data); {
+		n := int(le.Uint32(data[off:]))
+		kind := le.Uint32(data[off+4:])
+		off += 8
+		if off+n > len(data) {
+			return nil, nil, fmt.Errorf("GLB chunk at %d runs past the end", off-8)
+		}
+		// The first of each kind is the one that counts.
+		switch {
+		case kind == glbChunkJSON && jsonData == nil:
+			jsonData = data[off : off+n]
+		case kind == glbChunkBIN && bin == nil:
+			bin = data[off : off+n]
+		}
+		off += n
+	}
+	if jsonData == nil {
+		return nil, nil, fmt.Errorf("GLB has no JSON chunk")
+	}
+	return jsonData, bin, nil
+}
+
+// Path is where a uri that isn't a data: URI points, on disk.
+func (g *GLTF) Path(uri string) string {
+	if p, err := url.PathUnescape(uri); err == nil {
+		uri = p
+	}
+	return filepath.Join(filepath.Dir(g.File), filepath.FromSlash(uri))
+}
+
+// readURI returns what a buffer or image uri holds: base64 data: URIs are
+// decoded, anything else is a file.
+func (g *GLTF) readURI(uri string) ([]byte, error) {
+	if strings.HasPrefix(uri, "data:") {
+		comma := strings.Index(uri, ",")
+		if comma < 0 || !strings.HasSuffix(uri[:comma], ";base64") {
+			return nil, fmt.Errorf("only base64 data uris are supported")
+		}
+		return base64.StdEncoding.DecodeString(uri[comma+1:])
+	}
+	return ioutil.ReadFile(g.Path(uri))
+}
+
+// bufferView returns the bytes of view i.
+func (g *GLTF) bufferView(i int) ([]byte, error) {
+	if i < 0 || i >= len(g.BufferViews) {
+		return nil, fmt.Errorf("no buffer view %d", i)
+	}
+	bv := &g.BufferViews[i]
+	if bv.Buffer < 0 || bv.Buffer >= len(g.buffers) {
+		return nil, fmt.Errorf("buffer view %d: no buffer %d", i, bv.Buffer)
+	}
+	buf := g.buffers[bv.Buffer]
+	if bv.ByteOffset < 0 || bv.ByteLength < 0 || bv.ByteOffset+bv.ByteLength > len(buf) {
+		return nil, fmt.Errorf("buffer view %d runs past the end of buffer %d", i, bv.Buffer)
+	}
+	return buf[bv.ByteOffset : bv.ByteOffset+bv.ByteLength], nil
+}
+
+// Accessor reads accessor i: Count elements of however many components its
+// type has, with any sparse values put in.  Matrices are column-major.
+func (g *GLTF) Accessor(i int) ([]float64, error) {
+	if i < 0 || i >= len(g.Accessors) {
+		return nil, fmt.Errorf("no accessor %d", i)
+	}
+	a := &g.Accessors[i]
+	fail := func(err error) ([]float64, error) {
+		return nil, fmt.Errorf("accessor %d: %s", i, err)
+	}
+	n, ok := gltfTypeSizes[a.Type]
+	if !ok {
+		return fail(fmt.Errorf("unknown type %q", a.Type))
+	}
+	out := make([]float64, a.Count*n)
+	if a.BufferView != nil {
+		if err := g.readElements(out, *a.BufferView, a.ByteOffset, a.ComponentType, a.Type, a.Count); err != nil {
+			return fail(err)
+		}
+	} else if _, ok := gltfComponentTypes[a.ComponentType]; !ok {
+		return fail(fmt.Errorf("unknown component type %d", a.ComponentType))
+	}
+
+	if s := a.Sparse; s != nil {
+		switch s.Indices.ComponentType {
+		case 5121, 5123, 5125:
+		default:
+			return fail(fmt.Errorf("sparse indices must be unsigned, not component type %d", s.Indices.ComponentType))
+		}
+		indices := make([]float64, s.Count)
+		values := make([]float64, s.Count*n)
+		if err := g.readElements(indices, s.Indices.BufferView, s.Indices.ByteOffset, s.Indices.ComponentType, "SCALAR", s.Count); err != nil {
+			return fail(fmt.Errorf("sparse indices: %s", err))
+		}
+		if err := g.readElements(values, s.Values.BufferView, s.Values.ByteOffset, a.ComponentType, a.Type, s.Count); err != nil {
+			return fail(fmt.Errorf("sparse values: %s", err))
+		}
+		for k, idx := range indices {
+			if int(idx) >= a.Count {
+				return fail(fmt.Errorf("sparse index %d out of range, only %d elements", int(idx), a.Count))
+			}
+			copy(out[int(idx)*n:], values[k*n:(k+1)*n])
+		}
+	}
+	return out, nil
+}
+
+// readElements reads count elements from a buffer view into out.
+func (g *GLTF) readElements(out []float64, view, offset, componentType int, typ string, count int) error {
+	data, err := g.bufferView(view)
+	if err != nil {
+		return err
+	}
+	ct, ok := gltfComponentTypes[componentType]
+	if !ok {
+		return fmt.Errorf("unknown component type %d", componentType)
+	}
+	n := gltfTypeSizes[typ]
+
+	// Each column of a matrix starts on a 4 byte boundary, which only
+	// matters for byte and short ones.
+	rows, cols := n, 1
+	switch typ {
+	case "MAT2":
+		rows, cols = 2, 2
+	case "MAT3":
+		rows, cols = 3, 3
+	case "MAT4":
+		rows, cols = 4, 4
+	}
+	colBytes := rows * ct.bytes
+	if cols > 1 {
+		colBytes = (colBytes + 3) &^ 3
+	}
+	elemBytes := cols * colBytes
+	stride := elemBytes
+	if s := g.BufferViews[view].ByteStride; s != 0 {
+		stride = s
+	}
+	if count > 0 && (offset < 0 || offset+stride*(count-1)+elemBytes > len(data)) {
+		return fmt.Errorf("%d elements at offset %d run past the end of buffer view %d", count, offset, view)
+	}
+
+	le := binary.LittleEndian
+	for e := 0; e < count; e++ {
+		for c := 0; c < cols; c++ {
+			base := offset + e*stride + c*colBytes
+			for r := 0; r < rows; r++ {
+				b := data[base+r*ct.bytes:]
+				var v float64
+				switch componentType {
+				case 5120:
+					v = float64(int8(b[0]))
+				case 5121:
+					v = float64(b[0])
+				case 5122:
+					v = float64(int16(le.Uint16(b)))
+				case 5123:
+					v = float64(le.Uint16(b))
+				case 5125:
+					v = float64(le.Uint32(b))
+				default:
+					v = float64(math.Float32frombits(le.Uint32(b)))
+				}
+				out[e*n+c*rows+r] = v
+			}
+		}
+	}
+	return nil
+}
+
+// AllMeshes imports every primitive of every mesh.
+func (g *GLTF) AllMeshes() ([]*Mesh, error) {
+	var meshes []*Mesh
+	for i := range g.Meshes {
+		ms, err := g.ImportMesh(i)
+		if err != nil {
+			return nil, err
+		}
+		meshes = append(meshes, ms...)
+	}
+	return meshes, nil
+}
+
+// ImportMesh builds a Mesh for each primitive of mesh i.  Primitives are
+// named after the mesh, with .1, .2... after the first if there are more.
+func (g *GLTF) ImportMesh(i int) ([]*Mesh, error) {
+	if i < 0 || i >= len(g.Meshes) {
+		return nil, fmt.Errorf("no mesh %d", i)
+	}
+	gm := &g.Meshes[i]
+	name := gm.Name
+	if name == "" {
+		name = fmt.Sprintf("mesh%d", i)
+	}
+	var meshes []*Mesh
+	for p := range gm.Primitives {
+		mname := name
+		if p > 0 {
+			mname = fmt.Sprintf("%s.%d", name, p)
+		}
+		m, err := g.importPrimitive(&gm.Primitives[p], mname)
+		if err != nil {
+			return nil, fmt.Errorf("mesh %s primitive %d: %s", name, p, err)
+		}
+		meshes = append(meshes, m)
+	}
+	return meshes, nil
+}
+
+func (g *GLTF) importPrimitive(prim *GLTFPrimitive, name string) (*Mesh, error) {
+	m := NewMesh(name)
+	for _, ga := range gltfAttribs {
+		acc, ok := prim.Attributes[ga.semantic]
+		if !ok {
+			continue
+		}
+		a, err := g.meshAttrib(acc)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", ga.semantic, err)
+		}
+		a.Index = ga.index
+		a.Integral = ga.semantic == "JOINTS_0"
+		if a.Index == AttribTexCoord || a.Index == AttribTexCoord1 {
+			flipV(a)
+		}
+		m.Attribs = append(m.Attribs, a)
+	}
+	// Our own attributes, as written by Write.
+	var custom []string
+	for semantic := range prim.Attributes {
+		custom = append(custom, semantic)
+	}
+	sort.Strings(custom)
+	for _, semantic := range custom {
+		index, integral, ok := customAttrib(semantic)
+		if !ok || m.Attrib(index) != nil {
+			continue
+		}
+		a, err := g.meshAttrib(prim.Attributes[semantic])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", semantic, err)
+		}
+		a.Index = index
+		a.Integral = integral
+		m.Attribs = append(m.Attribs, a)
+	}
+	pos := m.Attrib(AttribPosition)
+	if pos == nil {
+		return nil, fmt.Errorf("no POSITION")
+	}
+
+	mode := 4
+	if prim.Mode != nil {
+		mode = *prim.Mode
+	}
+	if mode < 0 || mode >= len(gltfModes) {
+		return nil, fmt.Errorf("unknown mode %d", mode)
+	}
+	cmd := &RenderCmd{Cmd: gltfModes[mode]}
+	if prim.Material != nil {
+		if *prim.Material < 0 || *prim.Material >= len(g.Materials) {
+			return nil, fmt.Errorf("no material %d", *prim.Material)
+		}
+		cmd.Material = g.MaterialName(*prim.Material)
+	}
+	if prim.Indices != nil {
+		if *prim.Indices < 0 || *prim.Indices >= len(g.Accessors) {
+			return nil, fmt.Errorf("no accessor %d", *prim.Indices)
+		}
+		acc := &g.Accessors[*prim.Indices]
+		if acc.Type != "SCALAR" {
+			return nil, fmt.Errorf("indices are %s, not SCALAR", acc.Type)
+		}
+		switch acc.ComponentType {
+		case 5121, 5123, 5125:
+		default:
+			return nil, fmt.Errorf("indices must be unsigned, not component type %d", acc.ComponentType)
+		}
+		indices, err := g.Accessor(*prim.Indices)
+		if err != nil {
+			return nil, err
+		}
+		cmd.Indexed = true
+		cmd.IndexType = gltfComponentTypes[acc.ComponentType].name
+		for _, idx := range indices {
+			cmd.Indices = append(cmd.Indices, gl.Uint(idx))
+		}
+		cmd.Count = len(cmd.Indices)
+	} else {
+		cmd.Count = pos.VertexCount()
+	}
+	m.Commands = append(m.Commands, cmd)
+	if err := m.Validate(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// customAttrib reads the semantic Write gives attributes glTF has no
+// name for: _ATTRIB<index>, or _IATTRIB<index> for integral ones.
+func customAttrib(semantic string) (index gl.Uint, integral bool, ok bool) {
+	num := ""
+	switch {
+	case strings.HasPrefix(semantic, "_ATTRIB"):
+		num = semantic[len("_ATTRIB"):]
+	case strings.HasPrefix(semantic, "_IATTRIB"):
+		num, integral = semantic[len("_IATTRIB"):], true
+	default:
+		return 0, false, false
+	}
+	n, err := strconv.ParseUint(num, 10, 32)
+	if err != nil {
+		return 0, false, false
+	}
+	return gl.Uint(n), integral, true
+}
+
+// meshAttrib reads a vertex attribute accessor.
+func (g *GLTF) meshAttrib(i int) (*MeshAttrib, error) {
+	if i < 0 || i >= len(g.Accessors) {
+		return nil, fmt.Errorf("no accessor %d", i)
+	}
+	acc := &g.Accessors[i]
+	size := gltfTypeSizes[acc.Type]
+	if size < 1 || size > 4 || strings.HasPrefix(acc.Type, "MAT") {
+		return nil, fmt.Errorf("accessor %d is %q, not a scalar or vector", i, acc.Type)
+	}
+	ct, ok := gltfComponentTypes[acc.ComponentType]
+	if !ok {
+		return nil, fmt.Errorf("accessor %d: unknown component type %d", i, acc.ComponentType)
+	}
+	a := &MeshAttrib{Type: ct.name, Size: size}
+	if acc.Normalized {
+		if ct.name == "float" {
+			return nil, fmt.Errorf("accessor %d: floats can't be normalized", i)
+		}
+		a.Type = "norm-" + ct.name
+	}
+	data, err := g.Accessor(i)
+	if err != nil {
+		return nil, err
+	}
+	a.Data = data
+	return a, nil
+}
+
+// flipV turns texture coordinates between glTF's v-down and GL's v-up.
+// Normalized ones flip around their largest value, which stands for 1.
+func flipV(a *MeshAttrib) {
+	if a.Size < 2 {
+		return
+	}
+	one := 1.0
+	if t := attribTypes[a.Type]; t.normalized {
+		one = t.max
+	}
+	for i := 1; i < len(a.Data); i += a.Size {
+		a.Data[i] = one - a.Data[i]
+	}
+}
+
+// MaterialName is what RenderCmd.Material holds for material i: its name,
+// unless it has none or an earlier material has the same one.
+func (g *GLTF) MaterialName(i int) string {
+	name := g.Materials[i].Name
+	for j := 0; j < i && name != ""; j++ {
+		if g.Materials[j].Name == name {
+			name = ""
+		}
+	}
+	if name == "" {
+		name = fmt.Sprintf("material%d", i)
+	}
+	return name
+}
@@ -0,0 +1,265 @@
+package glutil
+
+import (
+	gl "github.com/chsc/gogl/gl33"
+	"reflect"
+	"testing"
+)
+
+func loadGLTF(t *testing.T, file string) *GLTF {
+	g, err := LoadGLTF(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return g
+}
+
+func importMeshes(t *testing.T, g *GLTF) map[string]*Mesh {
+	meshes, err := g.AllMeshes()
+	if err != nil {
+		t.Fatalf("%s: %s", g.File, err)
+	}
+	byName := make(map[string]*Mesh)
+	for _, m := range meshes {
+		byName[m.Name] = m
+	}
+	return byName
+}
+
+func TestGLTFBuffers(t *testing.T) {
+	// A data: URI, a .bin next to the file, and a GLB's own chunk.
+	tests := []struct {
+		file    string
+		buffers []int
+	}{
+		{"../art/gltf/triangle.gltf", []int{44}},
+		{"../art/gltf/sparse.gltf", []int{44}},
+		{"../art/gltf/cube.gltf", []int{852}},
+		{"../art/gltf/cube.glb", []int{928}},
+	}
+	for _, tt := range tests {
+		g := loadGLTF(t, tt.file)
+		if len(g.buffers) != len(tt.buffers) {
+			t.Errorf("%s: %d buffers", tt.file, len(g.buffers))
+			continue
+		}
+		for i, n := range tt.buffers {
+			if len(g.buffers[i]) != n {
+				t.Errorf("%s: buffer %d is %d bytes, want %d", tt.file, i, len(g.buffers[i]), n)
+			}
+		}
+	}
+
+	// The GLB is the .gltf and .bin in one, with the image in the buffer.
+	a := importMeshes(t, loadGLTF(t, "../art/gltf/cube.gltf"))
+	b := importMeshes(t, loadGLTF(t, "../art/gltf/cube.glb"))
+	if !reflect.DeepEqual(a, b) {
+		t.Errorf("cube.gltf and cube.glb have different meshes")
+	}
+}
+
+func TestGLTFMeshes(t *testing.T) {
+	tri := importMeshes(t, loadGLTF(t, "../art/gltf/triangle.gltf"))["triangle"]
+	if tri == nil {
+		t.Fatal("no triangle")
+	}
+	want := []float64{0, 0, 0, 1, 0, 0, 0, 1, 0}
+	if pos := tri.Attrib(AttribPosition); pos == nil || !reflect.DeepEqual(pos.Data, want) {
+		t.Errorf("triangle positions %v", pos)
+	}
+	c := tri.Commands[0]
+	if !c.Indexed || c.IndexType != "ushort" || !reflect.DeepEqual(c.Indices, []gl.Uint{0, 1, 2}) || c.Material != "red" {
+		t.Errorf("triangle command %+v", *c)
+	}
+
+	cube := importMeshes(t, loadGLTF(t, "../art/gltf/cube.gltf"))
+	m := cube["cube"]
+	if m == nil || m.VertexCount != 24 {
+		t.Fatalf("cube %v", m)
+	}
+	for _, index := range []gl.Uint{AttribPosition, AttribNormal, AttribTexCoord} {
+		if m.Attrib(index) == nil {
+			t.Errorf("cube has no attribute %d", index)
+		}
+	}
+	// Interleaved: the second vertex's normal is after its position.
+	if n := m.Attrib(AttribNormal).Data[3:6]; !reflect.DeepEqual(n, []float64{1, 0, 0}) {
+		t.Errorf("cube normal 1 is %v", n)
+	}
+	if c := m.Commands[0]; c.IndexType != "ubyte" || c.Count != 36 || c.Material != "checker" {
+		t.Errorf("cube command %s %s, %d indices, material %q", c.Cmd, c.IndexType, c.Count, c.Material)
+	}
+}
+
+func TestGLTFSparse(t *testing.T) {
+	m := importMeshes(t, loadGLTF(t, "../art/gltf/sparse.gltf"))["points"]
+	if m == nil {
+		t.Fatal("no points")
+	}
+	// No buffer view, so zeroes with vertices 1 and 3 substituted.
+	want := []float64{0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 1, 0}
+	if pos := m.Attrib(AttribPosition); !reflect.DeepEqual(pos.Data, want) {
+		t.Errorf("positions %v, want %v", pos.Data, want)
+	}
+	// Normalized colours keep their raw values.
+	col := m.Attrib(AttribColor)
+	if col.Type != "norm-ubyte" || !reflect.DeepEqual(col.Data[:4], []float64{255, 0, 0, 255}) {
+		t.Errorf("colours %s %v", col.Type, col.Data)
+	}
+}
+
+func TestGLTFModes(t *testing.T) {
+	modes := make(map[string]gl.Enum)
+	for _, file := range []string{"../art/gltf/triangle.gltf", "../art/gltf/sparse.gltf", "../art/gltf/cube.gltf"} {
+		for name, m := range importMeshes(t, loadGLTF(t, file)) {
+			modes[name] = m.Commands[0].Primitive
+		}
+	}
+	want := map[string]gl.Enum{
+		"triangle": gl.TRIANGLES,
+		"points":   gl.POINTS,
+		"lines":    gl.LINES,
+		"lines.1":  gl.LINE_STRIP,
+		"cube":     gl.TRIANGLES,
+		"strip":    gl.TRIANGLE_STRIP,
+		"strip.1":  gl.LINE_LOOP,
+	}
+	if !reflect.DeepEqual(modes, want) {
+		t.Errorf("modes %v, want %v", modes, want)
+	}
+
+	// None of the files has a fan.
+	g := loadGLTF(t, "../art/gltf/triangle.gltf")
+	fan := 6
+	g.Meshes[0].Primitives[0].Mode = &fan
+	ms, err := g.ImportMesh(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ms[0].Commands[0].Primitive != gl.TRIANGLE_FAN {
+		t.Errorf("mode 6 is %s", ms[0].Commands[0].Cmd)
+	}
+	bad := 7
+	g.Meshes[0].Primitives[0].Mode = &bad
+	if _, err := g.ImportMesh(0); err == nil {
+		t.Errorf("mode 7 imported")
+	}
+}
+
+func TestGLTFMaterials(t *testing.T) {
+	g := loadGLTF(t, "../art/gltf/triangle.gltf")
+	red, err := g.Material(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if red.BaseColor != (Vec4{X: 1, Y: 0, Z: 0, W: 1}) || red.Metallic != 0 || red.Roughness != 0.5 ||
+		red.AlphaMode != "OPAQUE" || red.BaseColorTexture != nil {
+		t.Errorf("red %+v", *red)
+	}
+
+	for _, file := range []string{"../art/gltf/cube.gltf", "../art/gltf/cube.glb"} {
+		mats, err := loadGLTF(t, file).ImportMaterials()
+		if err != nil {
+			t.Fatal(err)
+		}
+		m := mats["checker"]
+		if m == nil {
+			t.Fatalf("%s: no checker in %v", file, mats)
+		}
+		// Roughness isn't given, so it's the default.
+		if m.Metallic != 0.25 || m.Roughness != 1 || !m.DoubleSided || m.NormalScale != 0.5 ||
+			m.Emissive != (Vec3{X: 0.1, Y: 0.1, Z: 0}) {
+			t.Errorf("%s: checker %+v", file, *m)
+		}
+		bc, nt := m.BaseColorTexture, m.NormalTexture
+		if bc == nil || nt == nil {
+			t.Fatalf("%s: textures %v and %v", file, bc, nt)
+		}
+		if !bc.Options.SRGB || bc.Options.NonColor || nt.Options.SRGB || !nt.Options.NonColor {
+			t.Errorf("%s: base colour and normal textures aren't colour and data", file)
+		}
+		o := bc.Options
+		if o.MagFilter != gl.NEAREST || o.MinFilter != gl.NEAREST || o.Mipmaps ||
+			o.WrapS != gl.CLAMP_TO_EDGE || o.WrapT != gl.MIRRORED_REPEAT {
+			t.Errorf("%s: sampler %+v", file, o)
+		}
+		img, err := bc.Image()
+		if err != nil {
+			t.Fatalf("%s: %s", file, err)
+		}
+		if img.Bounds().Dx() == 0 {
+			t.Errorf("%s: empty image", file)
+		}
+
+		line := mats["line"]
+		if line == nil || line.AlphaMode != "MASK" || line.AlphaCutoff != 0.25 || line.BaseColor != (Vec4{X: 1, Y: 1, Z: 1, W: 1}) {
+			t.Errorf("%s: line %+v", file, line)
+		}
+	}
+
+	// The .gltf's image is a file beside it, the GLB's is in its buffer.
+	mats, _ := loadGLTF(t, "../art/gltf/cube.gltf").ImportMaterials()
+	if tex := mats["checker"].BaseColorTexture; tex.Path != "../art/gltf/checker.png" || tex.Data != nil {
+		t.Errorf("cube.gltf texture at %q", tex.Path)
+	}
+	mats, _ = loadGLTF(t, "../art/gltf/cube.glb").ImportMaterials()
+	if tex := mats["checker"].BaseColorTexture; tex.Path != "" || tex.MimeType != "image/png" || len(tex.Data) != 73 {
+		t.Errorf("cube.glb texture %q, %s, %d bytes", tex.Path, tex.MimeType, len(tex.Data))
+	}
+}
+
+func TestGLTFNodes(t *testing.T) {
+	s, _, err := LoadGLTFScene("../art/gltf/cube.gltf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Roots) != 2 || s.Roots[0].Name != "root" || s.Roots[1].Name != "eye" {
+		t.Fatalf("roots %v", s.Roots)
+	}
+	root := s.Roots[0]
+	if len(root.Children) != 2 || root.Children[0].Name != "cube" || root.Children[1].Name != "spinner" {
+		t.Fatalf("root's children %v", root.Children)
+	}
+	spinner := root.Children[1]
+	tests := []struct {
+		name string
+		n    *Node
+		want *Mat4
+	}{
+		// A matrix, columns first
+		{"root", root, TranslateMat4(&Vec3{X: 0, Y: 0, Z: -5})},
+		{"spinner", spinner, RotateXMat4(45)},
+		{"strip", spinner.Children[0], TranslateMat4(&Vec3{X: 2, Y: 0, Z: 0})},
+		{"eye", s.Roots[1], TranslateMat4(&Vec3{X: 0, Y: 0, Z: 10})},
+	}
+	for _, tt := range tests {
+		if !mat4Near(&tt.n.Transform, tt.want, 1e-6) {
+			t.Errorf("%s: %v, want %v", tt.name, tt.n.Transform, *tt.want)
+		}
+	}
+
+	// Translation, rotation and scale go on in that order.
+	s, _, err = LoadGLTFScene("../art/gltf/triangle.gltf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := TranslateMat4(&Vec3{X: 1, Y: 2, Z: 3}).MulM(RotateYMat4(90)).MulM(ScaleMat4(&Vec3{X: 2, Y: 2, Z: 2}))
+	if s.Name != "Triangle" || !mat4Near(&s.Roots[0].Transform, want, 1e-6) {
+		t.Errorf("%s: tri %v, want %v", s.Name, s.Roots[0].Transform, *want)
+	}
+}
+
+func TestGLTFFlipV(t *testing.T) {
+	// The file's first two texture coordinates are (0, 0) and (1, 0), at
+	// the top of the image.
+	m := importMeshes(t, loadGLTF(t, "../art/gltf/cube.gltf"))["cube"]
+	if uv := m.Attrib(AttribTexCoord).Data[:4]; !reflect.DeepEqual(uv, []float64{0, 1, 1, 1}) {
+		t.Errorf("texture coordinates %v", uv)
+	}
+
+	a := &MeshAttrib{Type: "norm-ubyte", Size: 2, Data: []float64{0, 0, 10, 255}}
+	flipV(a)
+	if !reflect.DeepEqual(a.Data, []float64{0, 255, 10, 0}) {
+		t.Errorf("normalized: %v", a.Data)
+	}
+}
@@ -0,0 +1,439 @@
+/*
+gltfscene.go - turns a glTF scene into a Scene, and its materials into
+PBRMaterials.
+
+Node transforms are a matrix, or translation * rotation * scale.  Meshes
+are imported once however many nodes use them, and each primitive hangs off
+the node as one of its Meshes; RenderCmd.Material names the PBRMaterial in
+the map ImportMaterials returns.
+*/
+package glutil
+
+import (
+	"bytes"
+	"fmt"
+	gl "github.com/chsc/gogl/gl33"
+	"image"
+	"path/filepath"
+	"strings"
+)
+
+// A glTF metallic-roughness material.  The factors multiply whatever the
+// textures hold.
+type PBRMaterial struct {
+	Name        string
+	BaseColor   Vec4
+	Metallic    gl.Float
+	Roughness   gl.Float
+	Emissive    Vec3
+	AlphaMode   string // OPAQUE, MASK or BLEND
+	AlphaCutoff gl.Float
+	DoubleSided bool
+
+	// Any of these may be nil.  The metallic-roughness texture has
+	// roughness in green and metalness in blue.
+	BaseColorTexture         *TextureRef
+	MetallicRoughnessTexture *TextureRef
+	NormalTexture            *TextureRef
+	OcclusionTexture         *TextureRef
+	EmissiveTexture          *TextureRef
+	NormalScale              gl.Float
+	OcclusionStrength        gl.Float
+}
+
+// Where a material's texture comes from - nothing is loaded until Load.
+type TextureRef struct {
+	Path     string // the image file, or "" if it's embedded
+	Data     []byte // the embedded image
+	MimeType string
+	TexCoord int // which TEXCOORD_n it uses
+	Options  TextureOptions
+}
+
+// LoadGLTFScene reads a .gltf or .glb file and imports its scene and
+// materials.
+func LoadGLTFScene(file string) (*Scene, map[string]*PBRMaterial, error) {
+	g, err := LoadGLTF(file)
+	if err != nil {
+		return nil, nil, err
+	}
+	s, err := g.ImportScene()
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %s", file, err)
+	}
+	mats, err := g.ImportMaterials()
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %s", file, err)
+	}
+	return s, mats, nil
+}
+
+// Image decodes the texture's image.
+func (t *TextureRef) Image() (image.Image, error) {
+	if t.Data == nil {
+		return LoadImage(t.Path)
+	}
+	img, _, err := image.Decode(bytes.NewReader(t.Data))
+	return img, err
+}
+
+// Load makes a GL texture of it, with the file's sampler settings.
+func (t *TextureRef) Load() (*Texture, error) {
+	img, err := t.Image()
+	if err != nil {
+		return nil, err
+	}
+	return NewTexture(img, &t.Options)
+}
+
+// ImportMaterials converts every material, keyed by MaterialName.
+func (g *GLTF) ImportMaterials() (map[string]*PBRMaterial, error) {
+	mats := make(map[string]*PBRMaterial)
+	for i := range g.Materials {
+		m, err := g.Material(i)
+		if err != nil {
+			return nil, err
+		}
+		mats[m.Name] = m
+	}
+	return mats, nil
+}
+
+// Material converts material i, filling in the spec's defaults.
+func (g *GLTF) Material(i int) (*PBRMaterial, error) {
+	if i < 0 || i >= len(g.Materials) {
+		return nil, fmt.Errorf("no material %d", i)
+	}
+	gm := &g.Materials[i]
+	m := &PBRMaterial{
+		Name:              g.MaterialName(i),
+		BaseColor:         Vec4{1.0, 1.0, 1.0, 1.0},
+		Metallic:          1.0,
+		Roughness:         1.0,
+		AlphaMode:         "OPAQUE",
+		AlphaCutoff:       0.5,
+		DoubleSided:       gm.DoubleSided,
+		NormalScale:       1.0,
+		OcclusionStrength: 1.0,
+	}
+	fail := func(err error) (*PBRMaterial, error) {
+		return nil, fmt.Errorf("material %s: %s", m.Name, err)
+	}
+	texture := func(info *GLTFTextureInfo, color bool) (*TextureRef, error) {
+		if info == nil {
+			return nil, nil
+		}
+		return g.textureRef(info, color)
+	}
+	var err error
+	if pbr := gm.PbrMetallicRoughness; pbr != nil {
+		if len(pbr.BaseColorFactor) == 4 {
+			for k, v := range pbr.BaseColorFactor {
+				m.BaseColor.SetElem(k, gl.Float(v))
+			}
+		}
+		if pbr.MetallicFactor != nil {
+			m.Metallic = gl.Float(*pbr.MetallicFactor)
+		}
+		if pbr.RoughnessFactor != nil {
+			m.Roughness = gl.Float(*pbr.RoughnessFactor)
+		}
+		if m.BaseColorTexture, err = texture(pbr.BaseColorTexture, true); err != nil {
+			return fail(err)
+		}
+		if m.MetallicRoughnessTexture, err = texture(pbr.MetallicRoughnessTexture, false); err != nil {
+			return fail(err)
+		}
+	}
+	if len(gm.EmissiveFactor) == 3 {
+		m.Emissive = Vec3{gl.Float(gm.EmissiveFactor[0]), gl.Float(gm.EmissiveFactor[1]), gl.Float(gm.EmissiveFactor[2])}
+	}
+	if gm.AlphaMode != "" {
+		m.AlphaMode = gm.AlphaMode
+	}
+	if gm.AlphaCutoff != nil {
+		m.AlphaCutoff = gl.Float(*gm.AlphaCutoff)
+	}
+	if m.NormalTexture, err = texture(gm.NormalTexture, false); err != nil {
+		return fail(err)
+	}
+	if gm.NormalTexture != nil && gm.NormalTexture.Scale != nil {
+		m.NormalScale = gl.Float(*gm.NormalTexture.Scale)
+	}
+	if m.OcclusionTexture, err = texture(gm.OcclusionTexture, false); err != nil {
+		return fail(err)
+	}
+	if gm.OcclusionTexture != nil && gm.OcclusionTexture.Strength != nil {
+		m.OcclusionStrength = gl.Float(*gm.OcclusionTexture.Strength)
+	}
+	if m.EmissiveTexture, err = texture(gm.EmissiveTexture, true); err != nil {
+		return fail(err)
+	}
+	return m, nil
+}
+
+// textureRef finds a texture's image and sampler.  Colour textures are
+// sRGB; the rest are data, and are left alone.
+func (g *GLTF) textureRef(info *GLTFTextureInfo, color bool) (*TextureRef, error) {
+	if info.Index < 0 || info.Index >= len(g.Textures) {
+		return nil, fmt.Errorf("no texture %d", info.Index)
+	}
+	tex := &g.Textures[info.Index]
+	if tex.Source == nil {
+		return nil, fmt.Errorf("texture %d has no image", info.Index)
+	}
+	if *tex.Source < 0 || *tex.Source >= len(g.Images) {
+		return nil, fmt.Errorf("texture %d: no image %d", info.Index, *tex.Source)
+	}
+	img := &g.Images[*tex.Source]
+	t := &TextureRef{MimeType: img.MimeType, TexCoord: info.TexCoord, Options: *DefaultTextureOptions()}
+	switch {
+	case img.BufferView != nil:
+		data, err := g.bufferView(*img.BufferView)
+		if err != nil {
+			return nil, fmt.Errorf("image %d: %s", *tex.Source, err)
+		}
+		t.Data = data
+	case strings.HasPrefix(img.URI, "data:"):
+		data, err := g.readURI(img.URI)
+		if err != nil {
+			return nil, fmt.Errorf("image %d: %s", *tex.Source, err)
+		}
+		t.Data = data
+	case img.URI != "":
+		t.Path = g.Path(img.URI)
+	default:
+		return nil, fmt.Errorf("image %d has no uri or buffer view", *tex.Source)
+	}
+
+	t.Options.SRGB = color
+	t.Options.NonColor = !color
+	if tex.Sampler != nil {
+		if *tex.Sampler < 0 || *tex.Sampler >= len(g.Samplers) {
+			return nil, fmt.Errorf("texture %d: no sampler %d", info.Index, *tex.Sampler)
+		}
+		s := &g.Samplers[*tex.Sampler]
+		if s.WrapS != 0 {
+			t.Options.WrapS = gl.Int(s.WrapS)
+		}
+		if s.WrapT != 0 {
+			t.Options.WrapT = gl.Int(s.WrapT)
+		}
+		if s.MagFilter != 0 {
+			t.Options.MagFilter = gl.Int(s.MagFilter)
+		}
+		if s.MinFilter != 0 {
+			t.Options.MinFilter = gl.Int(s.MinFilter)
+			t.Options.Mipmaps = s.MinFilter != gl.NEAREST && s.MinFilter != gl.LINEAR
+		}
+	}
+	return t, nil
+}
+
+// Camera converts camera i.  glTF cameras look down -Z with +Y up, so
+// Orient is the identity.
+func (g *GLTF) Camera(i int) (*Camera, error) {
+	if i < 0 || i >= len(g.Cameras) {
+		return nil, fmt.Errorf("no camera %d", i)
+	}
+	gc := &g.Cameras[i]
+	c := &Camera{Name: gc.Name, Orient: *IdentMat4()}
+	switch {
+	case gc.Type == "perspective" && gc.Perspective != nil:
+		p := gc.Perspective
+		c.YFov = RadToDeg(gl.Float(p.YFov))
+		c.Aspect = gl.Float(p.AspectRatio)
+		c.ZNear = gl.Float(p.ZNear)
+		c.ZFar = gl.Float(p.ZFar)
+		if c.ZFar == 0 {
+			// Infinite, which Perspective can't do.
+			c.ZFar = 1000.0
+		}
+	case gc.Type == "orthographic" && gc.Orthographic != nil:
+		o := gc.Orthographic
+		c.Ortho = true
+		c.YMag = gl.Float(o.YMag)
+		if o.YMag != 0 {
+			c.Aspect = gl.Float(o.XMag / o.YMag)
+		}
+		c.ZNear = gl.Float(o.ZNear)
+		c.ZFar = gl.Float(o.ZFar)
+	default:
+		return nil, fmt.Errorf("camera %d: no %q projection", i, gc.Type)
+	}
+	return c, nil
+}
+
+// LocalMatrix is the node's transform relative to its parent.
+func (n *GLTFNode) LocalMatrix() (*Mat4, error) {
+	if n.Matrix != nil {
+		if len(n.Matrix) != 16 {
+			return nil, fmt.Errorf("matrix has %d values", len(n.Matrix))
+		}
+		var m Mat4
+		for c := 0; c < 4; c++ {
+			for r := 0; r < 4; r++ {
+				m[c].SetElem(r, gl.Float(n.Matrix[c*4+r]))
+			}
+		}
+		return &m, nil
+	}
+	m := IdentMat4()
+	if n.Translation != nil {
+		if len(n.Translation) != 3 {
+			return nil, fmt.Errorf("translation has %d values", len(n.Translation))
+		}
+		t := Vec3{gl.Float(n.Translation[0]), gl.Float(n.Translation[1]), gl.Float(n.Translation[2])}
+		m = m.MulM(TranslateMat4(&t))
+	}
+	if n.Rotation != nil {
+		if len(n.Rotation) != 4 {
+			return nil, fmt.Errorf("rotation has %d values", len(n.Rotation))
+		}
+		r := n.Rotation
+		q := Quat{gl.Float(r[3]), gl.Float(r[0]), gl.Float(r[1]), gl.Float(r[2])}
+		m = m.MulM(q.Normalize().Mat4())
+	}
+	if n.Scale != nil {
+		if len(n.Scale) != 3 {
+			return nil, fmt.Errorf("scale has %d values", len(n.Scale))
+		}
+		s := Vec3{gl.Float(n.Scale[0]), gl.Float(n.Scale[1]), gl.Float(n.Scale[2])}
+		m = m.MulM(ScaleMat4(&s))
+	}
+	return m, nil
+}
+
+type gltfSceneImport struct {
+	g       *GLTF
+	meshes  map[int][]*Mesh
+	cameras map[int]*Camera
+	seen    map[int]bool
+}
+
+// ImportScene builds the default scene - the first if the file doesn't
+// say, or every top-level node if it has no scenes at all.
+func (g *GLTF) ImportScene() (*Scene, error) {
+	s := &Scene{Name: strings.TrimSuffix(filepath.Base(g.File), filepath.Ext(g.File))}
+	var roots []int
+	switch {
+	case len(g.Scenes) > 0:
+		i := 0
+		if g.Scene != nil {
+			i = *g.Scene
+		}
+		if i < 0 || i >= len(g.Scenes) {
+			return nil, fmt.Errorf("no scene %d", i)
+		}
+		if g.Scenes[i].Name != "" {
+			s.Name = g.Scenes[i].Name
+		}
+		roots = g.Scenes[i].Nodes
+	default:
+		child := make(map[int]bool)
+		for _, n := range g.Nodes {
+			for _, c := range n.Children {
+				child[c] = true
+			}
+		}
+		for i := range g.Nodes {
+			if !child[i] {
+				roots = append(roots, i)
+			}
+		}
+	}
+
+	imp := &gltfSceneImport{g: g, meshes: make(map[int][]*Mesh),
+		cameras: make(map[int]*Camera), seen: make(map[int]bool)}
+	for _, i := range roots {
+		n, err := imp.node(i)
+		if err != nil {
+			return nil, err
+		}
+		s.Roots = append(s.Roots, n)
+	}
+	return s, nil
+}
+
+func (imp *gltfSceneImport) node(i int) (*Node, error) {
+	g := imp.g
+	if i < 0 || i >= len(g.Nodes) {
+		return nil, fmt.Errorf("no node %d", i)
+	}
+	// Nodes make a tree: no node has two parents, or is its own ancestor.
+	if imp.seen[i] {
+		return nil, fmt.Errorf("node %d is in the tree twice", i)
+	}
+	imp.seen[i] = true
+
+	gn := &g.Nodes[i]
+	name := gn.Name
+	if name == "" {
+		name = fmt.Sprintf("node%d", i)
+	}
+	n := NewNode(name)
+	m, err := gn.LocalMatrix()
+	if err != nil {
+		return nil, fmt.Errorf("node %s: %s", name, err)
+	}
+	n.Transform = *m
+
+	if gn.Mesh != nil {
+		meshes, ok := imp.meshes[*gn.Mesh]
+		if !ok {
+			if meshes, err = g.ImportMesh(*gn.Mesh); err != nil {
+				return nil, fmt.Errorf("node %s: %s", name, err)
+			}
+			imp.meshes[*gn.Mesh] = meshes
+		}
+		n.Meshes = meshes
+	}
+	if gn.Camera != nil {
+		c, ok := imp.cameras[*gn.Camera]
+		if !ok {
+			if c, err = g.Camera(*gn.Camera); err != nil {
+				return nil, fmt.Errorf("node %s: %s", name, err)
+			}
+			imp.cameras[*gn.Camera] = c
+		}
+		n.Camera = c
+	}
+	for _, ci := range gn.Children {
+		c, err := imp.node(ci)
+		if err != nil {
+			return nil, err
+		}
+		n.AddChild(c)
+	}
+	return n, nil
+}
+
+// Debug dumps the material to stdout.
+func (m *PBRMaterial) Debug() {
+	fmt.Printf("Material %s: base colour (%g, %g, %g, %g), metallic %g, roughness %g, %s",
+		m.Name, m.BaseColor.X, m.BaseColor.Y, m.BaseColor.Z, m.BaseColor.W, m.Metallic, m.Roughness, m.AlphaMode)
+	if m.DoubleSided {
+		fmt.Printf(", double sided")
+	}
+	fmt.Printf("\n")
+	textures := []struct {
+		name string
+		t    *TextureRef
+	}{
+		{"base colour", m.BaseColorTexture},
+		{"metallic-roughness", m.MetallicRoughnessTexture},
+		{"normal", m.NormalTexture},
+		{"occlusion", m.OcclusionTexture},
+		{"emissive", m.EmissiveTexture},
+	}
+	for _, tex := range textures {
+		if tex.t == nil {
+			continue
+		}
+		from := tex.t.Path
+		if tex.t.Data != nil {
+			from = fmt.Sprintf("%d embedded bytes of %s", len(tex.t.Data), tex.t.MimeType)
+		}
+		fmt.Printf("  %s texture %s, texcoord %d\n", tex.name, from, tex.t.TexCoord)
+	}
+}
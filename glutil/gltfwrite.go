@@ -0,0 +1,640 @@
+/*
+gltfwrite.go - writes a Scene, and its PBRMaterials, out as glTF 2.0: a
+.gltf with its buffer embedded as a data: URI, or a .glb.
+
+Each Mesh's attributes become accessors, shared by a primitive per draw
+command, and a node's Meshes are the primitives of one glTF mesh - so
+reading the file back with LoadGLTFScene gives a mesh per command.
+Attributes get glTF's semantics where they fit (float positions and
+normals, texture coordinates as float or normalized bytes and shorts...);
+the rest are written as _ATTRIBn, or _IATTRIBn if they're integral, which
+our loader reads back and anyone else ignores.  glTF has no int, uint or
+half attributes, so those are written as floats.
+*/
+package glutil
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	gl "github.com/chsc/gogl/gl33"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// glTF component types for our attribute types.  Anything missing is
+// written as float.
+var gltfComponentOf = map[string]int{
+	"byte": 5120, "ubyte": 5121, "short": 5122, "ushort": 5123, "float": 5126,
+	"norm-byte": 5120, "norm-ubyte": 5121, "norm-short": 5122, "norm-ushort": 5123,
+}
+
+var gltfIndexTypes = map[string]int{"ubyte": 5121, "ushort": 5123, "uint": 5125}
+
+// The bufferView targets.
+const (
+	gltfArrayBuffer        = 34962
+	gltfElementArrayBuffer = 34963
+)
+
+// MeshScene puts each mesh on a node of its own, to write loose meshes.
+func MeshScene(name string, meshes []*Mesh) *Scene {
+	s := &Scene{Name: name}
+	for _, m := range meshes {
+		n := NewNode(m.Name)
+		n.Meshes = []*Mesh{m}
+		s.Roots = append(s.Roots, n)
+	}
+	return s
+}
+
+// SaveGLTF writes the scene to file, as a .glb if that's its extension.
+// mats may be nil; commands naming a material that isn't in it get a
+// default one.
+func SaveGLTF(file string, s *Scene, mats map[string]*PBRMaterial) error {
+	g, err := ExportGLTF(s, mats, file)
+	if err != nil {
+		return fmt.Errorf("%s: %s", file, err)
+	}
+	fp, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	if strings.EqualFold(filepath.Ext(file), ".glb") {
+		err = g.WriteGLB(fp)
+	} else {
+		err = g.Write(fp)
+	}
+	if err != nil {
+		fp.Close()
+		return fmt.Errorf("%s: %s", file, err)
+	}
+	return fp.Close()
+}
+
+type gltfExport struct {
+	g    *GLTF
+	dir  string // texture paths are made relative to it
+	bin  []byte
+	mats map[string]*PBRMaterial
+
+	meshes    map[string]int // node meshes, by their Mesh pointers
+	prims     map[*Mesh]map[string]int
+	cameras   map[*Camera]int
+	materials map[string]int
+	images    map[*TextureRef]int
+	samplers  map[GLTFSampler]int
+}
+
+// ExportGLTF builds the glTF for a scene, with everything in one buffer.
+// file is where the result will be written, for texture uris.
+func ExportGLTF(s *Scene, mats map[string]*PBRMaterial, file string) (*GLTF, error) {
+	g := &GLTF{File: file}
+	g.Asset.Version = "2.0"
+	g.Asset.Generator = "opengl-go-tut glutil"
+	exp := &gltfExport{g: g, dir: filepath.Dir(file), mats: mats,
+		meshes: make(map[string]int), prims: make(map[*Mesh]map[string]int),
+		cameras: make(map[*Camera]int), materials: make(map[string]int),
+		images: make(map[*TextureRef]int), samplers: make(map[GLTFSampler]int)}
+
+	scene := GLTFScene{Name: s.Name}
+	for _, n := range s.Roots {
+		i, err := exp.node(n)
+		if err != nil {
+			return nil, err
+		}
+		scene.Nodes = append(scene.Nodes, i)
+	}
+	g.Scenes = []GLTFScene{scene}
+	zero := 0
+	g.Scene = &zero
+
+	if len(exp.bin) > 0 {
+		g.Buffers = []GLTFBuffer{{ByteLength: len(exp.bin)}}
+		g.buffers = [][]byte{exp.bin}
+	}
+	return g, nil
+}
+
+func (exp *gltfExport) node(n *Node) (int, error) {
+	g := exp.g
+	i := len(g.Nodes)
+	g.Nodes = append(g.Nodes, GLTFNode{Name: n.Name})
+	if *IdentMat4() != n.Transform {
+		g.Nodes[i].Matrix = gltfMatrix(&n.Transform)
+	}
+
+	if len(n.Meshes) > 0 {
+		mi, err := exp.mesh(n)
+		if err != nil {
+			return 0, fmt.Errorf("node %s: %s", n.Name, err)
+		}
+		g.Nodes[i].Mesh = &mi
+	}
+	var children []int
+	if c := n.Camera; c != nil {
+		ci := exp.camera(c)
+		if *IdentMat4() == c.Orient {
+			g.Nodes[i].Camera = &ci
+		} else {
+			// glTF cameras look down the node's -Z, so one turned some
+			// other way needs a node of its own.
+			children = append(children, len(g.Nodes))
+			g.Nodes = append(g.Nodes, GLTFNode{Name: n.Name + ".camera", Camera: &ci,
+				Matrix: gltfMatrix(&c.Orient)})
+		}
+	}
+	for _, c := range n.Children {
+		ci, err := exp.node(c)
+		if err != nil {
+			return 0, err
+		}
+		children = append(children, ci)
+	}
+	g.Nodes[i].Children = children
+	return i, nil
+}
+
+func gltfMatrix(m *Mat4) []float64 {
+	out := make([]float64, 16)
+	for c := 0; c < 4; c++ {
+		for r := 0; r < 4; r++ {
+			out[c*4+r] = gltfFloat(m[c].Elem(r))
+		}
+	}
+	return out
+}
+
+// gltfFloat is f with no more digits than it takes to be f, so 0.64 goes in
+// the JSON as 0.64, not 0.6399999856948853.
+func gltfFloat(f gl.Float) float64 {
+	v, _ := strconv.ParseFloat(strconv.FormatFloat(float64(f), 'g', -1, 32), 64)
+	return v
+}
+
+// relPath is path relative to dir if it can be, or else path.
+func relPath(dir, path string) string {
+	absDir, err1 := filepath.Abs(dir)
+	absPath, err2 := filepath.Abs(path)
+	if err1 != nil || err2 != nil {
+		return path
+	}
+	if rel, err := filepath.Rel(absDir, absPath); err == nil {
+		return rel
+	}
+	return path
+}
+
+// mesh writes a node's meshes as one glTF mesh - once, however many nodes
+// share them.
+func (exp *gltfExport) mesh(n *Node) (int, error) {
+	key := fmt.Sprint(n.Meshes)
+	if i, ok := exp.meshes[key]; ok {
+		return i, nil
+	}
+	gm := GLTFMesh{Name: n.Name}
+	if len(n.Meshes) == 1 {
+		gm.Name = n.Meshes[0].Name
+	}
+	for _, m := range n.Meshes {
+		if err := m.Validate(); err != nil {
+			return 0, fmt.Errorf("mesh %s: %s", m.Name, err)
+		}
+		attribs, err := exp.attribs(m)
+		if err != nil {
+			return 0, fmt.Errorf("mesh %s: %s", m.Name, err)
+		}
+		for _, c := range m.Commands {
+			prim := GLTFPrimitive{Attributes: attribs}
+			if mode := int(c.Primitive); mode != 4 {
+				prim.Mode = &mode
+			}
+			if c.Material != "" {
+				mi, err := exp.material(c.Material)
+				if err != nil {
+					return 0, err
+				}
+				prim.Material = &mi
+			}
+			if c.Indexed || c.Start != 0 || c.Count != m.VertexCount {
+				// glTF draws every vertex or indices, nothing in between.
+				indexType := c.IndexType
+				if !c.Indexed {
+					indexType = "uint"
+					if m.VertexCount <= 0xffff {
+						indexType = "ushort"
+					}
+				}
+				vs := c.Vertices()
+				data := make([]float64, len(vs))
+				for k, v := range vs {
+					data[k] = float64(v)
+				}
+				ai := exp.accessor(data, gltfIndexTypes[indexType], false, "SCALAR", gltfElementArrayBuffer)
+				prim.Indices = &ai
+			}
+			gm.Primitives = append(gm.Primitives, prim)
+		}
+	}
+	exp.g.Meshes = append(exp.g.Meshes, gm)
+	i := len(exp.g.Meshes) - 1
+	exp.meshes[key] = i
+	return i, nil
+}
+
+// attribs writes a mesh's attributes, and returns them by semantic.
+func (exp *gltfExport) attribs(m *Mesh) (map[string]int, error) {
+	if attribs, ok := exp.prims[m]; ok {
+		return attribs, nil
+	}
+	if m.Attrib(AttribPosition) == nil {
+		return nil, fmt.Errorf("no positions")
+	}
+	attribs := make(map[string]int)
+	for _, a := range m.Attribs {
+		semantic, ct, size := gltfSemantic(a)
+		t := attribTypes[a.Type]
+		normalized := t.normalized && ct != 5126
+		n := a.VertexCount()
+		data := make([]float64, n*size)
+		for v := 0; v < n; v++ {
+			for k := 0; k < size && k < a.Size; k++ {
+				f := a.Data[v*a.Size+k]
+				if t.normalized && ct == 5126 {
+					// As GL would see it.
+					f = math.Max(f/t.max, -1.0)
+				}
+				data[v*size+k] = f
+			}
+		}
+		if strings.HasPrefix(semantic, "TEXCOORD_") {
+			flipV(&MeshAttrib{Type: a.Type, Size: size, Data: data})
+		}
+		typ := []string{"", "SCALAR", "VEC2", "VEC3", "VEC4"}[size]
+		attribs[semantic] = exp.accessor(data, ct, normalized, typ, gltfArrayBuffer)
+		if semantic == "POSITION" {
+			acc := &exp.g.Accessors[attribs[semantic]]
+			acc.Min, acc.Max = gltfBounds(data, size)
+		}
+	}
+	exp.prims[m] = attribs
+	return attribs, nil
+}
+
+// gltfSemantic is what an attribute is written as: its semantic, the
+// component type and how many components.
+func gltfSemantic(a *MeshAttrib) (semantic string, componentType, size int) {
+	ct, ok := gltfComponentOf[a.Type]
+	if !ok {
+		ct = 5126
+	}
+	floatOrNorm := a.Type == "float" || a.Type == "norm-ubyte" || a.Type == "norm-ushort"
+	switch {
+	case a.Index == AttribPosition:
+		// Required, so always a float VEC3, whatever it was.
+		return "POSITION", 5126, 3
+	case a.Index == AttribNormal && a.Size == 3:
+		return "NORMAL", 5126, 3
+	case a.Index == AttribTangent && a.Size == 4 && a.Type == "float":
+		return "TANGENT", ct, 4
+	case a.Index == AttribTexCoord && a.Size == 2 && floatOrNorm:
+		return "TEXCOORD_0", ct, 2
+	case a.Index == AttribTexCoord1 && a.Size == 2 && floatOrNorm:
+		return "TEXCOORD_1", ct, 2
+	case a.Index == AttribColor && (a.Size == 3 || a.Size == 4) && floatOrNorm:
+		return "COLOR_0", ct, a.Size
+	case a.Index == AttribBoneIndices && a.Size == 4 && (a.Type == "ubyte" || a.Type == "ushort"):
+		return "JOINTS_0", ct, 4
+	case a.Index == AttribBoneWeights && a.Size == 4 && floatOrNorm:
+		return "WEIGHTS_0", ct, 4
+	}
+	if a.Integral && ct != 5126 {
+		return fmt.Sprintf("_IATTRIB%d", a.Index), ct, a.Size
+	}
+	return fmt.Sprintf("_ATTRIB%d", a.Index), ct, a.Size
+}
+
+func gltfBounds(data []float64, size int) (lo, hi []float64) {
+	lo = make([]float64, size)
+	hi = make([]float64, size)
+	for k := 0; k < size; k++ {
+		lo[k], hi[k] = math.Inf(1), math.Inf(-1)
+		for i := k; i < len(data); i += size {
+			// What's actually in the buffer.
+			f := gltfFloat(gl.Float(data[i]))
+			lo[k] = math.Min(lo[k], f)
+			hi[k] = math.Max(hi[k], f)
+		}
+	}
+	return lo, hi
+}
+
+// accessor appends data to the buffer, in a view of its own, and returns
+// the accessor for it.
+func (exp *gltfExport) accessor(data []float64, componentType int, normalized bool, typ string, target int) int {
+	le := binary.LittleEndian
+	size := gltfTypeSizes[typ]
+	count := len(data) / size
+	elem := size * gltfComponentTypes[componentType].bytes
+	stride := elem
+	if target == gltfArrayBuffer {
+		// Vertex attributes start every element on a 4 byte boundary.
+		stride = (elem + 3) &^ 3
+	}
+	b := make([]byte, count*stride)
+	for i, v := range data {
+		o := i/size*stride + i%size*gltfComponentTypes[componentType].bytes
+		switch componentType {
+		case 5120:
+			b[o] = byte(int8(v))
+		case 5121:
+			b[o] = byte(v)
+		case 5122:
+			le.PutUint16(b[o:], uint16(int16(v)))
+		case 5123:
+			le.PutUint16(b[o:], uint16(v))
+		case 5125:
+			le.PutUint32(b[o:], uint32(v))
+		default:
+			le.PutUint32(b[o:], math.Float32bits(float32(v)))
+		}
+	}
+	view := exp.view(b, target)
+	if stride != elem {
+		exp.g.BufferViews[view].ByteStride = stride
+	}
+	exp.g.Accessors = append(exp.g.Accessors, GLTFAccessor{
+		BufferView:    &view,
+		ComponentType: componentType,
+		Normalized:    normalized,
+		Count:         count,
+		Type:          typ,
+	})
+	return len(exp.g.Accessors) - 1
+}
+
+// view appends b to the buffer, 4 byte aligned, and makes a view of it.
+func (exp *gltfExport) view(b []byte, target int) int {
+	exp.bin = align4(exp.bin)
+	exp.g.BufferViews = append(exp.g.BufferViews, GLTFBufferView{
+		ByteOffset: len(exp.bin),
+		ByteLength: len(b),
+		Target:     target,
+	})
+	exp.bin = append(exp.bin, b...)
+	return len(exp.g.BufferViews) - 1
+}
+
+func (exp *gltfExport) camera(c *Camera) int {
+	if i, ok := exp.cameras[c]; ok {
+		return i
+	}
+	gc := GLTFCamera{Name: c.Name}
+	if c.Ortho {
+		aspect := c.Aspect
+		if aspect == 0 {
+			aspect = 1.0
+		}
+		gc.Type = "orthographic"
+		gc.Orthographic = &GLTFOrthographic{XMag: gltfFloat(c.YMag * aspect), YMag: gltfFloat(c.YMag),
+			ZNear: gltfFloat(c.ZNear), ZFar: gltfFloat(c.ZFar)}
+	} else {
+		yFov := c.YFov
+		if yFov == 0 {
+			aspect := c.Aspect
+			if aspect == 0 {
+				aspect = 1.0
+			}
+			yFov = XFovToYFov(c.XFov, aspect)
+		}
+		gc.Type = "perspective"
+		gc.Perspective = &GLTFPerspective{AspectRatio: gltfFloat(c.Aspect), YFov: gltfFloat(DegToRad(yFov)),
+			ZNear: gltfFloat(c.ZNear), ZFar: gltfFloat(c.ZFar)}
+	}
+	exp.g.Cameras = append(exp.g.Cameras, gc)
+	exp.cameras[c] = len(exp.g.Cameras) - 1
+	return exp.cameras[c]
+}
+
+func (exp *gltfExport) material(name string) (int, error) {
+	if i, ok := exp.materials[name]; ok {
+		return i, nil
+	}
+	m, ok := exp.mats[name]
+	if !ok {
+		m = NewPBRMaterial(name)
+	}
+	gm := GLTFMaterial{Name: name, DoubleSided: m.DoubleSided}
+	pbr := &GLTFPBR{}
+	if m.BaseColor != (Vec4{1.0, 1.0, 1.0, 1.0}) {
+		pbr.BaseColorFactor = []float64{gltfFloat(m.BaseColor.X), gltfFloat(m.BaseColor.Y),
+			gltfFloat(m.BaseColor.Z), gltfFloat(m.BaseColor.W)}
+	}
+	if m.Metallic != 1.0 {
+		f := gltfFloat(m.Metallic)
+		pbr.MetallicFactor = &f
+	}
+	if m.Roughness != 1.0 {
+		f := gltfFloat(m.Roughness)
+		pbr.RoughnessFactor = &f
+	}
+	var err error
+	fail := func(err error) (int, error) {
+		return 0, fmt.Errorf("material %s: %s", name, err)
+	}
+	if pbr.BaseColorTexture, err = exp.texture(m.BaseColorTexture); err != nil {
+		return fail(err)
+	}
+	if pbr.MetallicRoughnessTexture, err = exp.texture(m.MetallicRoughnessTexture); err != nil {
+		return fail(err)
+	}
+	if pbr.BaseColorFactor != nil || pbr.MetallicFactor != nil || pbr.RoughnessFactor != nil ||
+		pbr.BaseColorTexture != nil || pbr.MetallicRoughnessTexture != nil {
+		gm.PbrMetallicRoughness = pbr
+	}
+	if gm.NormalTexture, err = exp.texture(m.NormalTexture); err != nil {
+		return fail(err)
+	}
+	if gm.NormalTexture != nil && m.NormalScale != 1.0 {
+		f := gltfFloat(m.NormalScale)
+		gm.NormalTexture.Scale = &f
+	}
+	if gm.OcclusionTexture, err = exp.texture(m.OcclusionTexture); err != nil {
+		return fail(err)
+	}
+	if gm.OcclusionTexture != nil && m.OcclusionStrength != 1.0 {
+		f := gltfFloat(m.OcclusionStrength)
+		gm.OcclusionTexture.Strength = &f
+	}
+	if gm.EmissiveTexture, err = exp.texture(m.EmissiveTexture); err != nil {
+		return fail(err)
+	}
+	if m.Emissive != (Vec3{}) {
+		gm.EmissiveFactor = []float64{gltfFloat(m.Emissive.X), gltfFloat(m.Emissive.Y), gltfFloat(m.Emissive.Z)}
+	}
+	if m.AlphaMode != "OPAQUE" {
+		gm.AlphaMode = m.AlphaMode
+	}
+	if m.AlphaMode == "MASK" && m.AlphaCutoff != 0.5 {
+		f := gltfFloat(m.AlphaCutoff)
+		gm.AlphaCutoff = &f
+	}
+	exp.g.Materials = append(exp.g.Materials, gm)
+	exp.materials[name] = len(exp.g.Materials) - 1
+	return exp.materials[name], nil
+}
+
+// texture writes a texture's image and sampler.  Images on disk are
+// referred to; embedded ones go in the buffer.
+func (exp *gltfExport) texture(t *TextureRef) (*GLTFTextureInfo, error) {
+	if t == nil {
+		return nil, nil
+	}
+	g := exp.g
+	img, ok := exp.images[t]
+	if !ok {
+		gi := GLTFImage{}
+		if t.Data != nil {
+			mime := t.MimeType
+			if mime == "" {
+				mime = "image/png"
+			}
+			view := exp.view(t.Data, 0)
+			gi.BufferView = &view
+			gi.MimeType = mime
+		} else {
+			gi.URI = filepath.ToSlash(relPath(exp.dir, t.Path))
+		}
+		g.Images = append(g.Images, gi)
+		img = len(g.Images) - 1
+		exp.images[t] = img
+	}
+
+	o := t.Options
+	s := GLTFSampler{MagFilter: int(o.MagFilter), MinFilter: int(o.MinFilter), WrapS: int(o.WrapS), WrapT: int(o.WrapT)}
+	sampler, ok := exp.samplers[s]
+	if !ok {
+		g.Samplers = append(g.Samplers, s)
+		sampler = len(g.Samplers) - 1
+		exp.samplers[s] = sampler
+	}
+	g.Textures = append(g.Textures, GLTFTexture{Sampler: &sampler, Source: &img})
+	return &GLTFTextureInfo{Index: len(g.Textures) - 1, TexCoord: t.TexCoord}, nil
+}
+
+// Write writes the glTF as JSON, with its buffer as a data: URI.
+func (g *GLTF) Write(w io.Writer) error {
+	out := *g
+	out.Buffers = append([]GLTFBuffer(nil), g.Buffers...)
+	for i := range out.Buffers {
+		if out.Buffers[i].URI == "" && i < len(g.buffers) {
+			out.Buffers[i].URI = "data:application/octet-stream;base64," +
+				base64.StdEncoding.EncodeToString(g.buffers[i])
+		}
+	}
+	data, err := json.MarshalIndent(&out, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// WriteGLB writes the glTF as a GLB, with the first buffer as its BIN
+// chunk.
+func (g *GLTF) WriteGLB(w io.Writer) error {
+	data, err := json.Marshal(g)
+	if err != nil {
+		return err
+	}
+	for len(data)%4 != 0 {
+		data = append(data, ' ')
+	}
+	var bin []byte
+	if len(g.buffers) > 0 {
+		bin = align4(append([]byte(nil), g.buffers[0]...))
+	}
+
+	le := binary.LittleEndian
+	var buf bytes.Buffer
+	chunk := func(kind uint32, b []byte) {
+		var h [8]byte
+		le.PutUint32(h[:], uint32(len(b)))
+		le.PutUint32(h[4:], kind)
+		buf.Write(h[:])
+		buf.Write(b)
+	}
+	var header [12]byte
+	copy(header[:], glbMagic)
+	le.PutUint32(header[4:], 2)
+	length := 12 + 8 + len(data)
+	if bin != nil {
+		length += 8 + len(bin)
+	}
+	le.PutUint32(header[8:], uint32(length))
+	buf.Write(header[:])
+	chunk(glbChunkJSON, data)
+	if bin != nil {
+		chunk(glbChunkBIN, bin)
+	}
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+// NewPBRMaterial is a material with glTF's defaults: white, fully metallic
+// and fully rough.
+func NewPBRMaterial(name string) *PBRMaterial {
+	return &PBRMaterial{
+		Name:              name,
+		BaseColor:         Vec4{1.0, 1.0, 1.0, 1.0},
+		Metallic:          1.0,
+		Roughness:         1.0,
+		AlphaMode:         "OPAQUE",
+		AlphaCutoff:       0.5,
+		NormalScale:       1.0,
+		OcclusionStrength: 1.0,
+	}
+}
+
+// PBR converts an OBJ material, as near as it goes: Kd and d are the base
+// colour, nothing is metallic, and Ns becomes a roughness by the usual
+// Blinn-Phong to Beckmann fit.  dir is the .mtl file's directory, which
+// map_Kd is relative to.
+func (m *Material) PBR(dir string) *PBRMaterial {
+	p := NewPBRMaterial(m.Name)
+	p.BaseColor = Vec4{m.Kd.X, m.Kd.Y, m.Kd.Z, m.D}
+	p.Metallic = 0.0
+	p.Roughness = gl.Float(math.Sqrt(2.0 / (float64(m.Ns) + 2.0)))
+	if m.D < 1.0 {
+		p.AlphaMode = "BLEND"
+	}
+	if m.MapKd != "" {
+		opts := DefaultTextureOptions()
+		opts.SRGB = true
+		p.BaseColorTexture = &TextureRef{Path: filepath.Join(dir, filepath.FromSlash(m.MapKd)), Options: *opts}
+	}
+	return p
+}
+
+// OBJMaterial goes the other way.  Only a base colour texture on disk can
+// be kept; map_Kd is made relative to dir.
+func (p *PBRMaterial) OBJMaterial(dir string) *Material {
+	m := NewMaterial(p.Name)
+	m.Kd = Vec3{p.BaseColor.X, p.BaseColor.Y, p.BaseColor.Z}
+	m.D = p.BaseColor.W
+	r := float64(p.Roughness)
+	if r < 0.01 {
+		r = 0.01
+	}
+	m.Ns = gl.Float(2.0/(r*r) - 2.0)
+	if t := p.BaseColorTexture; t != nil && t.Path != "" {
+		m.MapKd = filepath.ToSlash(relPath(dir, t.Path))
+	}
+	return m
+}
@@ -0,0 +1,77 @@
+package glutil
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// sameMeshes compares attributes by index, and commands by the vertices
+// they draw - glTF may have indexed what was an array.
+func sameMeshes(a, b *Mesh) error {
+	for _, aa := range a.Attribs {
+		ba := b.Attrib(aa.Index)
+		if ba == nil {
+			return fmt.Errorf("attribute %d is missing", aa.Index)
+		}
+		if aa.Type != ba.Type || aa.Size != ba.Size || aa.Integral != ba.Integral {
+			return fmt.Errorf("attribute %d is %s x%d, was %s x%d", aa.Index, ba.Type, ba.Size, aa.Type, aa.Size)
+		}
+	}
+	if len(a.Commands) != len(b.Commands) {
+		return fmt.Errorf("%d commands, was %d", len(b.Commands), len(a.Commands))
+	}
+	for i, c := range a.Commands {
+		d := b.Commands[i]
+		cv, dv := c.Vertices(), d.Vertices()
+		if c.Primitive != d.Primitive || len(cv) != len(dv) {
+			return fmt.Errorf("command %d (%s) has changed", i+1, c.Cmd)
+		}
+		for _, aa := range a.Attribs {
+			ba := b.Attrib(aa.Index)
+			n := aa.Size
+			for k := range cv {
+				if !sameFloats(aa.Data[cv[k]*n:cv[k]*n+n], ba.Data[dv[k]*n:dv[k]*n+n]) {
+					return fmt.Errorf("command %d: attribute %d of vertex %d has changed", i+1, aa.Index, cv[k])
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func TestSaveGLTFRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	for _, file := range roundTripFiles(t) {
+		s, mats := loadAnyScene(t, file)
+		// One mesh comes back for each command.
+		var want []*Mesh
+		for _, m := range s.FlatMeshes() {
+			for _, c := range m.Commands {
+				one := *m
+				one.Commands = []*RenderCmd{c}
+				want = append(want, &one)
+			}
+		}
+		base := filepath.Join(dir, strings.TrimSuffix(filepath.Base(file), filepath.Ext(file)))
+		for _, ext := range []string{".gltf", ".glb"} {
+			out := base + ext
+			if err := SaveGLTF(out, s, mats); err != nil {
+				t.Errorf("%s: %s", file, err)
+				continue
+			}
+			back, _ := loadAnyScene(t, out)
+			got := back.FlatMeshes()
+			if len(got) != len(want) {
+				t.Errorf("%s to %s: %d meshes, want %d", file, ext, len(got), len(want))
+				continue
+			}
+			for i := range want {
+				if err := sameMeshes(want[i], got[i]); err != nil {
+					t.Errorf("%s to %s: mesh %s: %s", file, ext, want[i].Name, err)
+				}
+			}
+		}
+	}
+}
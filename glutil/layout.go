@@ -0,0 +1,271 @@
+/*
+layout.go - a world laid out in a file: meshes placed by hand, prefabs like
+trees and columns placed with parameters, and where the camera starts.
+
+	{"camera": {"target": [0, 0.4, 0], "sphereRelPos": [67.5, -46, 150]},
+	 "meshes": {"plane": "UnitPlane.xml", "cone": "UnitConeTint.xml"},
+	 "prefabs": {"grove": [
+		{"prefab": "tree", "translate": [-3, 0, 0]},
+		{"prefab": "tree", "translate": [3, 0, 1], "params": {"coneHeight": 4}}]},
+	 "objects": [
+		{"name": "ground", "mesh": "plane", "program": "UniformColor",
+		 "color": [0.302, 0.416, 0.0589], "scale": [100, 1, 100]},
+		{"prefab": "grove", "translate": [10, 0, 10], "rotate": [0, 45, 0]}]}
+
+Meshes are named, with files relative to the layout's.  An object is a mesh
+drawn with a program, and a colour if the program takes one, or a prefab.
+Either way it's moved by translate, rotate (degrees about X, then Y, then Z)
+and scale, in that order from the outside in.  Prefabs are the ones in
+Prefabs, or lists of objects the file makes itself, which beat the built-in
+ones of the same name.
+
+Loading builds the whole thing into a flat list of Items, each a mesh and
+its model matrix, so drawing is a loop and nothing needs GL to check.
+*/
+package glutil
+
+import (
+	"encoding/json"
+	"fmt"
+	gl "github.com/chsc/gogl/gl33"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+)
+
+// Where the camera starts: the point it looks at, and where it is round
+// that - degrees round Y, degrees above or below, and distance.
+type LayoutCamera struct {
+	Target       Vec3
+	SphereRelPos Vec3
+}
+
+// One mesh to draw.
+type LayoutItem struct {
+	Object  string // the name of the object it came from
+	Mesh    string
+	VAO     string // "" for the mesh's own
+	Program string
+	Color   *Vec4 // nil if the program doesn't take one
+	Matrix  Mat4
+}
+
+type Layout struct {
+	Camera *LayoutCamera     // nil if the file doesn't say
+	Meshes map[string]string // name to file
+	Items  []*LayoutItem
+
+	prefabs map[string]*Prefab // the file's own
+	object  string
+	placing map[string]bool // prefabs being built, so one can't place itself
+}
+
+// Something built out of meshes, or other prefabs, where ms is.  Params are
+// the ones it takes, and their defaults; Build gets every one.
+type Prefab struct {
+	Params map[string]gl.Float
+	Build  func(l *Layout, ms *MatrixStack, p map[string]gl.Float) error
+}
+
+// An object, as it is in the file.
+type LayoutObject struct {
+	Name      string             `json:"name"`
+	Mesh      string             `json:"mesh"`
+	VAO       string             `json:"vao"`
+	Program   string             `json:"program"`
+	Color     floatList          `json:"color"`
+	Prefab    string             `json:"prefab"`
+	Params    map[string]float64 `json:"params"`
+	Translate floatList          `json:"translate"`
+	Rotate    floatList          `json:"rotate"`
+	Scale     floatList          `json:"scale"`
+}
+
+type layoutFile struct {
+	Camera *struct {
+		Target       floatList `json:"target"`
+		SphereRelPos floatList `json:"sphereRelPos"`
+	} `json:"camera"`
+	Meshes  map[string]string          `json:"meshes"`
+	Prefabs map[string][]*LayoutObject `json:"prefabs"`
+	Objects []*LayoutObject            `json:"objects"`
+}
+
+func LoadLayout(file string) (*Layout, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	l, err := ParseLayout(data, filepath.Dir(file))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", file, err)
+	}
+	return l, nil
+}
+
+// ParseLayout reads a layout and builds it.  Mesh files are relative to
+// dir.
+func ParseLayout(data []byte, dir string) (*Layout, error) {
+	var f layoutFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, err
+	}
+	l := &Layout{Meshes: make(map[string]string), prefabs: make(map[string]*Prefab),
+		placing: make(map[string]bool)}
+	if f.Camera != nil {
+		target, err := f.Camera.Target.vec3(Vec3{})
+		if err != nil {
+			return nil, fmt.Errorf("camera target: %s", err)
+		}
+		pos, err := f.Camera.SphereRelPos.vec3(Vec3{})
+		if err != nil {
+			return nil, fmt.Errorf("camera sphereRelPos: %s", err)
+		}
+		l.Camera = &LayoutCamera{target, pos}
+	}
+	for name, file := range f.Meshes {
+		l.Meshes[name] = filepath.Join(dir, file)
+	}
+	for name, objs := range f.Prefabs {
+		l.prefabs[name] = filePrefab(name, objs)
+	}
+
+	var ms MatrixStack
+	ms.Init()
+	for i, o := range f.Objects {
+		l.object = o.Name
+		if l.object == "" {
+			l.object = fmt.Sprintf("%s%d", o.Prefab+o.Mesh, i)
+		}
+		if err := l.placeObject(&ms, o); err != nil {
+			return nil, fmt.Errorf("object %d (%s): %s", i, l.object, err)
+		}
+	}
+	for _, it := range l.Items {
+		if _, ok := l.Meshes[it.Mesh]; !ok {
+			return nil, fmt.Errorf("object %s: no mesh %q", it.Object, it.Mesh)
+		}
+	}
+	return l, nil
+}
+
+// A prefab that's a list of objects in the file.  It takes no parameters.
+func filePrefab(name string, objs []*LayoutObject) *Prefab {
+	return &Prefab{
+		Build: func(l *Layout, ms *MatrixStack, p map[string]gl.Float) error {
+			for i, o := range objs {
+				if err := l.placeObject(ms, o); err != nil {
+					return fmt.Errorf("%s object %d: %s", name, i, err)
+				}
+			}
+			return nil
+		},
+	}
+}
+
+func (l *Layout) placeObject(ms *MatrixStack, o *LayoutObject) error {
+	m, err := o.Matrix()
+	if err != nil {
+		return err
+	}
+	ms.Push()
+	defer ms.Pop()
+	ms.ApplyMatrix(m)
+
+	if o.Prefab != "" {
+		if o.Mesh != "" {
+			return fmt.Errorf("has both a mesh and a prefab")
+		}
+		params := make(map[string]gl.Float)
+		for name, v := range o.Params {
+			params[name] = gl.Float(v)
+		}
+		return l.Place(ms, o.Prefab, params)
+	}
+	switch {
+	case o.Mesh == "":
+		return fmt.Errorf("needs a mesh or a prefab")
+	case o.Program == "":
+		return fmt.Errorf("mesh %q needs a program", o.Mesh)
+	case len(o.Params) > 0:
+		return fmt.Errorf("params are for prefabs, not meshes")
+	}
+	var color *Vec4
+	switch len(o.Color) {
+	case 0:
+	case 3, 4:
+		color = &Vec4{gl.Float(o.Color[0]), gl.Float(o.Color[1]), gl.Float(o.Color[2]), 1.0}
+		if len(o.Color) == 4 {
+			color.W = gl.Float(o.Color[3])
+		}
+	default:
+		return fmt.Errorf("color: %d numbers, not 3 or 4", len(o.Color))
+	}
+	l.Add(ms, o.Mesh, o.VAO, o.Program, color)
+	return nil
+}
+
+// Matrix is the object's translate, rotate and scale.
+func (o *LayoutObject) Matrix() (*Mat4, error) {
+	t, err := o.Translate.vec3(Vec3{})
+	if err != nil {
+		return nil, fmt.Errorf("translate: %s", err)
+	}
+	r, err := o.Rotate.vec3(Vec3{})
+	if err != nil {
+		return nil, fmt.Errorf("rotate: %s", err)
+	}
+	s, err := o.Scale.vec3(Vec3{1.0, 1.0, 1.0})
+	if err != nil {
+		return nil, fmt.Errorf("scale: %s", err)
+	}
+	m := TranslateMat4(&t)
+	m = m.MulM(RotateXMat4(r.X)).MulM(RotateYMat4(r.Y)).MulM(RotateZMat4(r.Z))
+	return m.MulM(ScaleMat4(&s)), nil
+}
+
+// Add puts a mesh where ms is.  For prefabs to call.
+func (l *Layout) Add(ms *MatrixStack, mesh, vao, program string, color *Vec4) {
+	l.Items = append(l.Items, &LayoutItem{Object: l.object, Mesh: mesh, VAO: vao,
+		Program: program, Color: color, Matrix: *ms.Current()})
+}
+
+// Place builds the named prefab where ms is.  Parameters it isn't given
+// take their defaults.
+func (l *Layout) Place(ms *MatrixStack, name string, params map[string]gl.Float) error {
+	pf := l.prefabs[name]
+	if pf == nil {
+		pf = Prefabs[name]
+	}
+	if pf == nil {
+		return fmt.Errorf("no prefab %q", name)
+	}
+	if l.placing[name] {
+		return fmt.Errorf("prefab %q places itself", name)
+	}
+	p := make(map[string]gl.Float)
+	for k, v := range pf.Params {
+		p[k] = v
+	}
+	for k, v := range params {
+		if _, ok := pf.Params[k]; !ok {
+			return fmt.Errorf("prefab %q has no parameter %q", name, k)
+		}
+		p[k] = v
+	}
+	ms.Push()
+	defer ms.Pop()
+	l.placing[name] = true
+	defer delete(l.placing, name)
+	return pf.Build(l, ms, p)
+}
+
+// MeshNames lists the meshes the layout names, sorted.
+func (l *Layout) MeshNames() []string {
+	var names []string
+	for name := range l.Meshes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,97 @@
+package glutil
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadLayoutWorld(t *testing.T) {
+	l, err := LoadLayout("../world_tut/world.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.Camera == nil || l.Camera.Target != (Vec3{Y: 0.4}) || l.Camera.SphereRelPos != (Vec3{X: 67.5, Y: -46, Z: 150}) {
+		t.Errorf("camera %+v", l.Camera)
+	}
+	if got, want := l.MeshNames(), []string{"cone", "cube", "cubeTint", "cylinder", "plane"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("meshes %v, want %v", got, want)
+	}
+	if got, want := l.Meshes["cube"], filepath.Join("..", "world_tut", "UnitCubeColor.xml"); got != want {
+		t.Errorf("cube is %s, want %s", got, want)
+	}
+
+	// 98 trees of a trunk and a top each, the ground, and the Parthenon:
+	// its base, top, 30 columns of 3, interior and headpiece.
+	perObject := make(map[string]int)
+	for _, it := range l.Items {
+		perObject[it.Object]++
+	}
+	trees := 0
+	for name, n := range perObject {
+		if strings.HasPrefix(name, "tree") {
+			trees++
+			if n != 2 {
+				t.Errorf("%s has %d items", name, n)
+			}
+		}
+	}
+	if trees != 98 || len(perObject) != 100 || perObject["ground"] != 1 || perObject["parthenon"] != 94 {
+		t.Errorf("%d trees, %d objects, ground %d, parthenon %d", trees, len(perObject), perObject["ground"], perObject["parthenon"])
+	}
+	if len(l.Items) != 1+98*2+94 {
+		t.Errorf("%d items", len(l.Items))
+	}
+
+	ground := l.Items[0]
+	if ground.Mesh != "plane" || ground.Program != "UniformColor" || ground.Color == nil || ground.Color.W != 1 ||
+		ground.Matrix != *ScaleMat4(&Vec3{X: 100, Y: 1, Z: 100}) {
+		t.Errorf("ground %+v", *ground)
+	}
+}
+
+func TestParseLayoutErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+		want string
+	}{
+		{"unknown param", `{"objects": [{"prefab": "tree", "params": {"height": 2}}]}`,
+			`object 0 (tree0): prefab "tree" has no parameter "height"`},
+		{"unknown prefab", `{"objects": [{"prefab": "house"}]}`,
+			`object 0 (house0): no prefab "house"`},
+		{"places itself", `{"prefabs": {"loop": [{"prefab": "loop"}]}, "objects": [{"prefab": "loop"}]}`,
+			`object 0 (loop0): loop object 0: prefab "loop" places itself`},
+		{"places itself further down", `{"prefabs": {"a": [{"prefab": "b"}], "b": [{"prefab": "a"}]}, "objects": [{"prefab": "a"}]}`,
+			`object 0 (a0): a object 0: b object 0: prefab "a" places itself`},
+		{"unknown mesh", `{"meshes": {"plane": "UnitPlane.xml"}, "objects": [{"name": "ground", "mesh": "floor", "program": "UniformColor"}]}`,
+			`object ground: no mesh "floor"`},
+		{"prefab's mesh", `{"objects": [{"prefab": "tree"}]}`,
+			`object tree0: no mesh "cylinder"`},
+		{"mesh and prefab", `{"objects": [{"mesh": "cube", "prefab": "tree"}]}`,
+			`object 0 (treecube0): has both a mesh and a prefab`},
+		{"no program", `{"objects": [{"name": "x", "mesh": "cube"}]}`,
+			`object 0 (x): mesh "cube" needs a program`},
+		{"mesh params", `{"objects": [{"name": "x", "mesh": "cube", "program": "P", "params": {"a": 1}}]}`,
+			`object 0 (x): params are for prefabs, not meshes`},
+		{"color", `{"objects": [{"name": "x", "mesh": "cube", "program": "P", "color": [1, 1]}]}`,
+			`object 0 (x): color: 2 numbers, not 3 or 4`},
+	}
+	for _, tt := range tests {
+		_, err := ParseLayout([]byte(tt.json), ".")
+		if err == nil || err.Error() != tt.want {
+			t.Errorf("%s: %v, want %s", tt.name, err, tt.want)
+		}
+	}
+
+	// A file's prefab takes the place of the built-in one.
+	l, err := ParseLayout([]byte(`{"meshes": {"cube": "c.xml"}, "prefabs": {"tree": [{"mesh": "cube", "program": "P"}]},
+		"objects": [{"prefab": "tree", "translate": [1, 2, 3]}]}`), ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(l.Items) != 1 || l.Items[0].Mesh != "cube" || l.Items[0].Matrix != *TranslateMat4(&Vec3{X: 1, Y: 2, Z: 3}) {
+		t.Errorf("items %+v", l.Items)
+	}
+}
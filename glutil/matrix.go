@@ -0,0 +1,509 @@
+/*
+matrix.go - vectors and matrices in a sorta-glm way.
+
+Matrices are column-major, like OpenGL and glm: m[3] is the translation
+column, and &m[0].X can be passed straight to gl.UniformMatrix4fv.
+Angles handed to the builders are in degrees.
+*/
+package glutil
+
+import (
+	"fmt"
+	gl "github.com/chsc/gogl/gl33"
+	"math"
+	"os"
+)
+
+const Pi = gl.Float(math.Pi)
+
+// Low precision wrappers for the high-precision math funcs
+func SinGL(op gl.Float) gl.Float  { return gl.Float(math.Sin(float64(op))) }
+func CosGL(op gl.Float) gl.Float  { return gl.Float(math.Cos(float64(op))) }
+func TanGL(op gl.Float) gl.Float  { return gl.Float(math.Tan(float64(op))) }
+func SqrtGL(op gl.Float) gl.Float { return gl.Float(math.Sqrt(float64(op))) }
+func AbsGL(op gl.Float) gl.Float  { return gl.Float(math.Abs(float64(op))) }
+
+// ModGL is fmodf - the result has the sign of x.
+func ModGL(x, y gl.Float) gl.Float { return gl.Float(math.Mod(float64(x), float64(y))) }
+
+func DegToRad(fAngDeg gl.Float) gl.Float { return fAngDeg * Pi / 180.0 }
+func RadToDeg(fAngRad gl.Float) gl.Float { return fAngRad * 180.0 / Pi }
+
+// Clamp restricts fValue to the range [fMin, fMax].
+func Clamp(fValue, fMin, fMax gl.Float) gl.Float {
+	if fValue < fMin {
+		return fMin
+	}
+	if fValue > fMax {
+		return fMax
+	}
+	return fValue
+}
+
+// Mix linearly interpolates between a and b, glsl style.
+func Mix(a, b, t gl.Float) gl.Float {
+	return a + (b-a)*t
+}
+
+// Vectors
+
+type Vec2 struct {
+	X, Y gl.Float
+}
+
+type Vec3 struct {
+	X, Y, Z gl.Float
+}
+
+type Vec4 struct {
+	X, Y, Z, W gl.Float
+}
+
+func (v *Vec2) Add(o *Vec2) *Vec2     { return &Vec2{v.X + o.X, v.Y + o.Y} }
+func (v *Vec2) Sub(o *Vec2) *Vec2     { return &Vec2{v.X - o.X, v.Y - o.Y} }
+func (v *Vec2) MulS(s gl.Float) *Vec2 { return &Vec2{v.X * s, v.Y * s} }
+func (v *Vec2) Dot(o *Vec2) gl.Float  { return v.X*o.X + v.Y*o.Y }
+func (v *Vec2) Length() gl.Float      { return SqrtGL(v.Dot(v)) }
+
+func (v *Vec2) Normalize() *Vec2 {
+	l := v.Length()
+	if l == 0 {
+		return &Vec2{}
+	}
+	return v.MulS(1.0 / l)
+}
+
+func (v *Vec3) Add(o *Vec3) *Vec3     { return &Vec3{v.X + o.X, v.Y + o.Y, v.Z + o.Z} }
+func (v *Vec3) Sub(o *Vec3) *Vec3     { return &Vec3{v.X - o.X, v.Y - o.Y, v.Z - o.Z} }
+func (v *Vec3) Mul(o *Vec3) *Vec3     { return &Vec3{v.X * o.X, v.Y * o.Y, v.Z * o.Z} }
+func (v *Vec3) MulS(s gl.Float) *Vec3 { return &Vec3{v.X * s, v.Y * s, v.Z * s} }
+func (v *Vec3) Dot(o *Vec3) gl.Float  { return v.X*o.X + v.Y*o.Y + v.Z*o.Z }
+func (v *Vec3) Length() gl.Float      { return SqrtGL(v.Dot(v)) }
+
+func (v *Vec3) Cross(o *Vec3) *Vec3 {
+	return &Vec3{
+		v.Y*o.Z - v.Z*o.Y,
+		v.Z*o.X - v.X*o.Z,
+		v.X*o.Y - v.Y*o.X,
+	}
+}
+
+func (v *Vec3) Normalize() *Vec3 {
+	l := v.Length()
+	if l == 0 {
+		return &Vec3{}
+	}
+	return v.MulS(1.0 / l)
+}
+
+// Lerp returns the point t of the way from v to o.
+func (v *Vec3) Lerp(o *Vec3, t gl.Float) *Vec3 {
+	return &Vec3{Mix(v.X, o.X, t), Mix(v.Y, o.Y, t), Mix(v.Z, o.Z, t)}
+}
+
+// V3to4 widens v into a Vec4 with the given w.
+func (v *Vec3) V3to4(w gl.Float) Vec4 { return Vec4{v.X, v.Y, v.Z, w} }
+
+func (v *Vec4) Add(o *Vec4) *Vec4     { return &Vec4{v.X + o.X, v.Y + o.Y, v.Z + o.Z, v.W + o.W} }
+func (v *Vec4) Sub(o *Vec4) *Vec4     { return &Vec4{v.X - o.X, v.Y - o.Y, v.Z - o.Z, v.W - o.W} }
+func (v *Vec4) MulS(s gl.Float) *Vec4 { return &Vec4{v.X * s, v.Y * s, v.Z * s, v.W * s} }
+func (v *Vec4) Dot(o *Vec4) gl.Float  { return v.X*o.X + v.Y*o.Y + v.Z*o.Z + v.W*o.W }
+func (v *Vec4) Length() gl.Float      { return SqrtGL(v.Dot(v)) }
+
+func (v *Vec4) Normalize() *Vec4 {
+	l := v.Length()
+	if l == 0 {
+		return &Vec4{}
+	}
+	return v.MulS(1.0 / l)
+}
+
+// V4to3 drops w.
+func (v *Vec4) V4to3() Vec3 { return Vec3{v.X, v.Y, v.Z} }
+
+// Elem and SetElem index the components 0-3 as x, y, z, w.
+func (v *Vec4) Elem(i int) gl.Float {
+	switch i {
+	case 0:
+		return v.X
+	case 1:
+		return v.Y
+	case 2:
+		return v.Z
+	}
+	return v.W
+}
+
+func (v *Vec4) SetElem(i int, f gl.Float) {
+	switch i {
+	case 0:
+		v.X = f
+	case 1:
+		v.Y = f
+	case 2:
+		v.Z = f
+	default:
+		v.W = f
+	}
+}
+
+func (v *Vec3) Elem(i int) gl.Float {
+	switch i {
+	case 0:
+		return v.X
+	case 1:
+		return v.Y
+	}
+	return v.Z
+}
+
+func (v *Vec3) SetElem(i int, f gl.Float) {
+	switch i {
+	case 0:
+		v.X = f
+	case 1:
+		v.Y = f
+	default:
+		v.Z = f
+	}
+}
+
+// Matrices, stored as columns
+
+type Mat3 [3]Vec3
+
+type Mat4 [4]Vec4
+
+func IdentMat3() *Mat3 {
+	return &Mat3{
+		{1.0, 0.0, 0.0},
+		{0.0, 1.0, 0.0},
+		{0.0, 0.0, 1.0},
+	}
+}
+
+func IdentMat4() *Mat4 {
+	return &Mat4{
+		{1.0, 0.0, 0.0, 0.0},
+		{0.0, 1.0, 0.0, 0.0},
+		{0.0, 0.0, 1.0, 0.0},
+		{0.0, 0.0, 0.0, 1.0},
+	}
+}
+
+// Ptr returns a pointer suitable for gl.UniformMatrix3fv.
+func (m *Mat3) Ptr() *gl.Float { return &m[0].X }
+
+func (m *Mat3) Transpose() *Mat3 {
+	r := new(Mat3)
+	for c := 0; c < 3; c++ {
+		for row := 0; row < 3; row++ {
+			r[row].SetElem(c, m[c].Elem(row))
+		}
+	}
+	return r
+}
+
+func (m *Mat3) MulM(o *Mat3) *Mat3 {
+	r := new(Mat3)
+	for c := 0; c < 3; c++ {
+		r[c] = *m.MulV(&o[c])
+	}
+	return r
+}
+
+func (m *Mat3) MulV(v *Vec3) *Vec3 {
+	return &Vec3{
+		m[0].X*v.X + m[1].X*v.Y + m[2].X*v.Z,
+		m[0].Y*v.X + m[1].Y*v.Y + m[2].Y*v.Z,
+		m[0].Z*v.X + m[1].Z*v.Y + m[2].Z*v.Z,
+	}
+}
+
+func (m *Mat3) Determinant() gl.Float {
+	return m[0].Dot(m[1].Cross(&m[2]))
+}
+
+// Inverse returns the inverse of m, and false if m is singular.
+func (m *Mat3) Inverse() (*Mat3, bool) {
+	det := m.Determinant()
+	if det == 0 {
+		return IdentMat3(), false
+	}
+	// The rows of the inverse are the cross products of the columns.
+	r := Mat3{
+		*m[1].Cross(&m[2]),
+		*m[2].Cross(&m[0]),
+		*m[0].Cross(&m[1]),
+	}
+	return r.Transpose().MulS(1.0 / det), true
+}
+
+func (m *Mat3) MulS(s gl.Float) *Mat3 {
+	return &Mat3{*m[0].MulS(s), *m[1].MulS(s), *m[2].MulS(s)}
+}
+
+// Mat4 widens m into a Mat4 with no translation.
+func (m *Mat3) Mat4() *Mat4 {
+	return &Mat4{
+		m[0].V3to4(0.0),
+		m[1].V3to4(0.0),
+		m[2].V3to4(0.0),
+		{0.0, 0.0, 0.0, 1.0},
+	}
+}
+
+// Ptr returns a pointer suitable for gl.UniformMatrix4fv.
+func (m *Mat4) Ptr() *gl.Float { return &m[0].X }
+
+// Mat3 returns the upper-left 3x3 of m.
+func (m *Mat4) Mat3() *Mat3 {
+	return &Mat3{m[0].V4to3(), m[1].V4to3(), m[2].V4to3()}
+}
+
+func (m *Mat4) Transpose() *Mat4 {
+	r := new(Mat4)
+	for c := 0; c < 4; c++ {
+		for row := 0; row < 4; row++ {
+			r[row].SetElem(c, m[c].Elem(row))
+		}
+	}
+	return r
+}
+
+func (m *Mat4) MulM(o *Mat4) *Mat4 {
+	r := new(Mat4)
+	for c := 0; c < 4; c++ {
+		r[c] = *m.MulV(&o[c])
+	}
+	return r
+}
+
+func (m *Mat4) MulV(v *Vec4) *Vec4 {
+	return &Vec4{
+		m[0].X*v.X + m[1].X*v.Y + m[2].X*v.Z + m[3].X*v.W,
+		m[0].Y*v.X + m[1].Y*v.Y + m[2].Y*v.Z + m[3].Y*v.W,
+		m[0].Z*v.X + m[1].Z*v.Y + m[2].Z*v.Z + m[3].Z*v.W,
+		m[0].W*v.X + m[1].W*v.Y + m[2].W*v.Z + m[3].W*v.W,
+	}
+}
+
+func (m *Mat4) MulS(s gl.Float) *Mat4 {
+	return &Mat4{*m[0].MulS(s), *m[1].MulS(s), *m[2].MulS(s), *m[3].MulS(s)}
+}
+
+// TransformPoint applies m to p with w = 1.
+func (m *Mat4) TransformPoint(p *Vec3) *Vec3 {
+	v := m.MulV(&Vec4{p.X, p.Y, p.Z, 1.0})
+	r := v.V4to3()
+	return &r
+}
+
+// TransformDir applies m to d with w = 0, so translation is ignored.
+func (m *Mat4) TransformDir(d *Vec3) *Vec3 {
+	v := m.MulV(&Vec4{d.X, d.Y, d.Z, 0.0})
+	r := v.V4to3()
+	return &r
+}
+
+// elems flattens m into column-major float64s for the cofactor math.
+func (m *Mat4) elems() (a [16]float64) {
+	for c := 0; c < 4; c++ {
+		for r := 0; r < 4; r++ {
+			a[c*4+r] = float64(m[c].Elem(r))
+		}
+	}
+	return
+}
+
+// cofactors returns the adjugate of a (transposed cofactors) and the
+// determinant, straight out of the MESA gluInvertMatrix.
+func cofactors(a [16]float64) (inv [16]float64, det float64) {
+	inv[0] = a[5]*a[10]*a[15] - a[5]*a[11]*a[14] - a[9]*a[6]*a[15] +
+		a[9]*a[7]*a[14] + a[13]*a[6]*a[11] - a[13]*a[7]*a[10]
+	inv[4] = -a[4]*a[10]*a[15] + a[4]*a[11]*a[14] + a[8]*a[6]*a[15] -
+		a[8]*a[7]*a[14] - a[12]*a[6]*a[11] + a[12]*a[7]*a[10]
+	inv[8] = a[4]*a[9]*a[15] - a[4]*a[11]*a[13] - a[8]*a[5]*a[15] +
+		a[8]*a[7]*a[13] + a[12]*a[5]*a[11] - a[12]*a[7]*a[9]
+	inv[12] = -a[4]*a[9]*a[14] + a[4]*a[10]*a[13] + a[8]*a[5]*a[14] -
+		a[8]*a[6]*a[13] - a[12]*a[5]*a[10] + a[12]*a[6]*a[9]
+	inv[1] = -a[1]*a[10]*a[15] + a[1]*a[11]*a[14] + a[9]*a[2]*a[15] -
+		a[9]*a[3]*a[14] - a[13]*a[2]*a[11] + a[13]*a[3]*a[10]
+	inv[5] = a[0]*a[10]*a[15] - a[0]*a[11]*a[14] - a[8]*a[2]*a[15] +
+		a[8]*a[3]*a[14] + a[12]*a[2]*a[11] - a[12]*a[3]*a[10]
+	inv[9] = -a[0]*a[9]*a[15] + a[0]*a[11]*a[13] + a[8]*a[1]*a[15] -
+		a[8]*a[3]*a[13] - a[12]*a[1]*a[11] + a[12]*a[3]*a[9]
+	inv[13] = a[0]*a[9]*a[14] - a[0]*a[10]*a[13] - a[8]*a[1]*a[14] +
+		a[8]*a[2]*a[13] + a[12]*a[1]*a[10] - a[12]*a[2]*a[9]
+	inv[2] = a[1]*a[6]*a[15] - a[1]*a[7]*a[14] - a[5]*a[2]*a[15] +
+		a[5]*a[3]*a[14] + a[13]*a[2]*a[7] - a[13]*a[3]*a[6]
+	inv[6] = -a[0]*a[6]*a[15] + a[0]*a[7]*a[14] + a[4]*a[2]*a[15] -
+		a[4]*a[3]*a[14] - a[12]*a[2]*a[7] + a[12]*a[3]*a[6]
+	inv[10] = a[0]*a[5]*a[15] - a[0]*a[7]*a[13] - a[4]*a[1]*a[15] +
+		a[4]*a[3]*a[13] + a[12]*a[1]*a[7] - a[12]*a[3]*a[5]
+	inv[14] = -a[0]*a[5]*a[14] + a[0]*a[6]*a[13] + a[4]*a[1]*a[14] -
+		a[4]*a[2]*a[13] - a[12]*a[1]*a[6] + a[12]*a[2]*a[5]
+	inv[3] = -a[1]*a[6]*a[11] + a[1]*a[7]*a[10] + a[5]*a[2]*a[11] -
+		a[5]*a[3]*a[10] - a[9]*a[2]*a[7] + a[9]*a[3]*a[6]
+	inv[7] = a[0]*a[6]*a[11] - a[0]*a[7]*a[10] - a[4]*a[2]*a[11] +
+		a[4]*a[3]*a[10] + a[8]*a[2]*a[7] - a[8]*a[3]*a[6]
+	inv[11] = -a[0]*a[5]*a[11] + a[0]*a[7]*a[9] + a[4]*a[1]*a[11] -
+		a[4]*a[3]*a[9] - a[8]*a[1]*a[7] + a[8]*a[3]*a[5]
+	inv[15] = a[0]*a[5]*a[10] - a[0]*a[6]*a[9] - a[4]*a[1]*a[10] +
+		a[4]*a[2]*a[9] + a[8]*a[1]*a[6] - a[8]*a[2]*a[5]
+
+	det = a[0]*inv[0] + a[1]*inv[4] + a[2]*inv[8] + a[3]*inv[12]
+	return
+}
+
+func (m *Mat4) Determinant() gl.Float {
+	_, det := cofactors(m.elems())
+	return gl.Float(det)
+}
+
+// Inverse returns the inverse of m, and false if m is singular.
+func (m *Mat4) Inverse() (*Mat4, bool) {
+	inv, det := cofactors(m.elems())
+	if det == 0 {
+		return IdentMat4(), false
+	}
+	r := new(Mat4)
+	for c := 0; c < 4; c++ {
+		for row := 0; row < 4; row++ {
+			r[c].SetElem(row, gl.Float(inv[c*4+row]/det))
+		}
+	}
+	return r, true
+}
+
+// Matrix builders
+
+func TranslateMat4(v *Vec3) *Mat4 {
+	m := IdentMat4()
+	m[3] = v.V3to4(1.0)
+	return m
+}
+
+func ScaleMat4(v *Vec3) *Mat4 {
+	m := IdentMat4()
+	m[0].X = v.X
+	m[1].Y = v.Y
+	m[2].Z = v.Z
+	return m
+}
+
+func RotateXMat4(fAngDeg gl.Float) *Mat4 {
+	fCos := CosGL(DegToRad(fAngDeg))
+	fSin := SinGL(DegToRad(fAngDeg))
+	m := IdentMat4()
+	m[1].Y = fCos
+	m[2].Y = -fSin
+	m[1].Z = fSin
+	m[2].Z = fCos
+	return m
+}
+
+func RotateYMat4(fAngDeg gl.Float) *Mat4 {
+	fCos := CosGL(DegToRad(fAngDeg))
+	fSin := SinGL(DegToRad(fAngDeg))
+	m := IdentMat4()
+	m[0].X = fCos
+	m[2].X = fSin
+	m[0].Z = -fSin
+	m[2].Z = fCos
+	return m
+}
+
+func RotateZMat4(fAngDeg gl.Float) *Mat4 {
+	fCos := CosGL(DegToRad(fAngDeg))
+	fSin := SinGL(DegToRad(fAngDeg))
+	m := IdentMat4()
+	m[0].X = fCos
+	m[1].X = -fSin
+	m[0].Y = fSin
+	m[1].Y = fCos
+	return m
+}
+
+// RotateAxisMat4 rotates fAngDeg degrees around axis, which needn't be
+// normalized.
+func RotateAxisMat4(axis *Vec3, fAngDeg gl.Float) *Mat4 {
+	fCos := CosGL(DegToRad(fAngDeg))
+	fSin := SinGL(DegToRad(fAngDeg))
+	fInvCos := 1.0 - fCos
+	v := axis.Normalize()
+	m := IdentMat4()
+	m[0].X = v.X*v.X + (1-v.X*v.X)*fCos
+	m[1].X = v.X*v.Y*fInvCos - v.Z*fSin
+	m[2].X = v.X*v.Z*fInvCos + v.Y*fSin
+	m[0].Y = v.X*v.Y*fInvCos + v.Z*fSin
+	m[1].Y = v.Y*v.Y + (1-v.Y*v.Y)*fCos
+	m[2].Y = v.Y*v.Z*fInvCos - v.X*fSin
+	m[0].Z = v.X*v.Z*fInvCos - v.Y*fSin
+	m[1].Z = v.Y*v.Z*fInvCos + v.X*fSin
+	m[2].Z = v.Z*v.Z + (1-v.Z*v.Z)*fCos
+	return m
+}
+
+// LookAt builds a world-to-camera matrix looking from eye at center.
+func LookAt(eye, center, up *Vec3) *Mat4 {
+	lookDir := center.Sub(eye).Normalize()
+	upDir := up.Normalize()
+
+	rightDir := lookDir.Cross(upDir).Normalize()
+	perpUpDir := rightDir.Cross(lookDir)
+
+	rotMat := IdentMat4()
+	rotMat[0] = rightDir.V3to4(0.0)
+	rotMat[1] = perpUpDir.V3to4(0.0)
+	rotMat[2] = lookDir.MulS(-1.0).V3to4(0.0)
+	rotMat = rotMat.Transpose()
+
+	return rotMat.MulM(TranslateMat4(eye.MulS(-1.0)))
+}
+
+// Perspective builds a camera-to-clip matrix from a vertical field of view.
+func Perspective(fFovDeg, fAspect, fzNear, fzFar gl.Float) *Mat4 {
+	fFrustumScale := 1.0 / TanGL(DegToRad(fFovDeg)/2.0)
+	m := new(Mat4)
+	m[0].X = fFrustumScale / fAspect
+	m[1].Y = fFrustumScale
+	m[2].Z = (fzFar + fzNear) / (fzNear - fzFar)
+	m[2].W = -1.0
+	m[3].Z = (2 * fzFar * fzNear) / (fzNear - fzFar)
+	return m
+}
+
+// Frustum is glFrustum.
+func Frustum(left, right, bottom, top, fzNear, fzFar gl.Float) *Mat4 {
+	m := new(Mat4)
+	m[0].X = 2 * fzNear / (right - left)
+	m[1].Y = 2 * fzNear / (top - bottom)
+	m[2].X = (right + left) / (right - left)
+	m[2].Y = (top + bottom) / (top - bottom)
+	m[2].Z = -(fzFar + fzNear) / (fzFar - fzNear)
+	m[2].W = -1.0
+	m[3].Z = -(2 * fzFar * fzNear) / (fzFar - fzNear)
+	return m
+}
+
+// Ortho is glOrtho.
+func Ortho(left, right, bottom, top, fzNear, fzFar gl.Float) *Mat4 {
+	m := IdentMat4()
+	m[0].X = 2 / (right - left)
+	m[1].Y = 2 / (top - bottom)
+	m[2].Z = -2 / (fzFar - fzNear)
+	m[3].X = -(right + left) / (right - left)
+	m[3].Y = -(top + bottom) / (top - bottom)
+	m[3].Z = -(fzFar + fzNear) / (fzFar - fzNear)
+	return m
+}
+
+// DebugMat dumps m to stderr, one row per line.
+func DebugMat(m *Mat4, name string) {
+	fmt.Fprintf(os.Stderr, "*** %s ***\n", name)
+	for row := 0; row < 4; row++ {
+		fmt.Fprintf(os.Stderr, "%8.3f %8.3f %8.3f %8.3f\n",
+			m[0].Elem(row), m[1].Elem(row), m[2].Elem(row), m[3].Elem(row))
+	}
+}
@@ -0,0 +1,132 @@
+package glutil
+
+import (
+	gl "github.com/chsc/gogl/gl33"
+	"math"
+	"testing"
+)
+
+// project is m applied to p, divided by w.
+func project(m *Mat4, p *Vec3) *Vec3 {
+	v := m.MulV(&Vec4{X: p.X, Y: p.Y, Z: p.Z, W: 1})
+	return &Vec3{X: v.X / v.W, Y: v.Y / v.W, Z: v.Z / v.W}
+}
+
+func TestMat4Inverse(t *testing.T) {
+	tests := []struct {
+		name string
+		m    *Mat4
+		det  gl.Float
+	}{
+		{"identity", IdentMat4(), 1},
+		{"translate", TranslateMat4(&Vec3{X: 1, Y: -2, Z: 3}), 1},
+		{"scale", ScaleMat4(&Vec3{X: 2, Y: 3, Z: 4}), 24},
+		{"trs", TranslateMat4(&Vec3{X: 5, Y: 0, Z: -1}).MulM(RotateAxisMat4(&Vec3{X: 1, Y: 1, Z: 0}, 30)).MulM(ScaleMat4(&Vec3{X: 2, Y: 3, Z: 4})), 24},
+		{"mirror", ScaleMat4(&Vec3{X: -1, Y: 1, Z: 1}), -1},
+		{"perspective", Perspective(60, 1.5, 1, 100), 0},
+	}
+	for _, tt := range tests {
+		if tt.det != 0 && math.Abs(float64(tt.m.Determinant()-tt.det)) > 1e-4 {
+			t.Errorf("%s: determinant %g, want %g", tt.name, tt.m.Determinant(), tt.det)
+		}
+		inv, ok := tt.m.Inverse()
+		if !ok {
+			t.Errorf("%s: singular", tt.name)
+			continue
+		}
+		if !mat4Near(tt.m.MulM(inv), IdentMat4(), 1e-5) || !mat4Near(inv.MulM(tt.m), IdentMat4(), 1e-5) {
+			t.Errorf("%s: m * inverse is %v", tt.name, *tt.m.MulM(inv))
+		}
+	}
+
+	flat := ScaleMat4(&Vec3{X: 1, Y: 0, Z: 1})
+	if inv, ok := flat.Inverse(); ok || *inv != *IdentMat4() || flat.Determinant() != 0 {
+		t.Errorf("a flattening scale inverted to %v", *inv)
+	}
+}
+
+func TestMat3Inverse(t *testing.T) {
+	m := RotateXMat4(40).MulM(ScaleMat4(&Vec3{X: 2, Y: 0.5, Z: 3})).Mat3()
+	if d := m.Determinant(); math.Abs(float64(d)-3) > 1e-5 {
+		t.Errorf("determinant %g, want 3", d)
+	}
+	inv, ok := m.Inverse()
+	if !ok {
+		t.Fatal("singular")
+	}
+	if !mat4Near(m.MulM(inv).Mat4(), IdentMat4(), 1e-5) {
+		t.Errorf("m * inverse is %v", *m.MulM(inv))
+	}
+	if _, ok := (&Mat3{{X: 1}, {X: 2}, {Z: 1}}).Inverse(); ok {
+		t.Errorf("a singular Mat3 inverted")
+	}
+}
+
+func TestRotations(t *testing.T) {
+	// Counter-clockwise looking down each axis.
+	x, y, z := &Vec3{X: 1}, &Vec3{Y: 1}, &Vec3{Z: 1}
+	tests := []struct {
+		m        *Mat4
+		from, to *Vec3
+	}{
+		{RotateXMat4(90), y, z},
+		{RotateYMat4(90), z, x},
+		{RotateZMat4(90), x, y},
+		{RotateAxisMat4(&Vec3{Z: 2}, 90), x, y},
+		{RotateAxisMat4(&Vec3{X: 1, Y: 1, Z: 1}, 120), x, y},
+	}
+	for i, tt := range tests {
+		if got := tt.m.TransformDir(tt.from); !vec3Near(got, tt.to, 1e-6) {
+			t.Errorf("%d: %v goes to %v, want %v", i, *tt.from, *got, *tt.to)
+		}
+	}
+	if !mat4Near(RotateAxisMat4(&Vec3{Y: -1}, -35), RotateYMat4(35), 1e-6) {
+		t.Errorf("RotateAxisMat4 about -y is not RotateYMat4")
+	}
+}
+
+func TestProjections(t *testing.T) {
+	tests := []struct {
+		name    string
+		m       *Mat4
+		eye, nd Vec3
+	}{
+		// The near plane is at -1 in NDC, the far at +1.
+		{"perspective near", Perspective(90, 2, 1, 100), Vec3{X: 2, Y: 1, Z: -1}, Vec3{X: 1, Y: 1, Z: -1}},
+		{"perspective far", Perspective(90, 2, 1, 100), Vec3{X: -200, Y: 0, Z: -100}, Vec3{X: -1, Y: 0, Z: 1}},
+		{"frustum", Frustum(0, 2, -1, 1, 1, 10), Vec3{X: 2, Y: -1, Z: -1}, Vec3{X: 1, Y: -1, Z: -1}},
+		{"frustum centre", Frustum(0, 2, -1, 1, 1, 10), Vec3{X: 10, Y: 0, Z: -10}, Vec3{X: 0, Y: 0, Z: 1}},
+		{"ortho", Ortho(0, 800, 0, 600, -1, 1), Vec3{X: 0, Y: 0, Z: 1}, Vec3{X: -1, Y: -1, Z: -1}},
+		{"ortho far", Ortho(0, 800, 0, 600, -1, 1), Vec3{X: 800, Y: 600, Z: -1}, Vec3{X: 1, Y: 1, Z: 1}},
+	}
+	for _, tt := range tests {
+		if got := project(tt.m, &tt.eye); !vec3Near(got, &tt.nd, 1e-5) {
+			t.Errorf("%s: %v projects to %v, want %v", tt.name, tt.eye, *got, tt.nd)
+		}
+	}
+	// A symmetric frustum is a perspective.
+	if !mat4Near(Frustum(-1, 1, -1, 1, 1, 50), Perspective(90, 1, 1, 50), 1e-6) {
+		t.Errorf("Frustum(-1, 1, -1, 1) is not Perspective(90)")
+	}
+}
+
+func TestLookAt(t *testing.T) {
+	// Straight down -z is just a translate.
+	if m := LookAt(&Vec3{Z: 5}, &Vec3{}, &Vec3{Y: 1}); !mat4Near(m, TranslateMat4(&Vec3{Z: -5}), 1e-6) {
+		t.Errorf("looking down -z: %v", *m)
+	}
+	eye, center := &Vec3{X: 4, Y: 3, Z: 0}, &Vec3{X: 0, Y: 1, Z: 0}
+	m := LookAt(eye, center, &Vec3{Y: 1})
+	if got := m.TransformPoint(eye); !vec3Near(got, &Vec3{}, 1e-5) {
+		t.Errorf("the eye is at %v", *got)
+	}
+	if got := m.TransformPoint(center); !vec3Near(got, &Vec3{Z: -center.Sub(eye).Length()}, 1e-5) {
+		t.Errorf("the centre is at %v", *got)
+	}
+	if up := m.TransformDir(&Vec3{Y: 1}); up.Y <= 0 || math.Abs(float64(up.X)) > 1e-6 {
+		t.Errorf("up is %v", *up)
+	}
+	if d := m.Mat3().Determinant(); math.Abs(float64(d)-1) > 1e-5 {
+		t.Errorf("the rotation has determinant %g", d)
+	}
+}
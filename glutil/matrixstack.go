@@ -0,0 +1,118 @@
+/*
+matrixstack.go - a matrix stack, in the spirit of glutil::MatrixStack from
+the arcsynthesis tutorials.  Every transform is applied on the right of the
+current matrix, so the last call affects the model first.
+*/
+package glutil
+
+import (
+	gl "github.com/chsc/gogl/gl33"
+)
+
+type MatrixStack struct {
+	current Mat4
+	stack   []Mat4
+}
+
+func NewMatrixStack() *MatrixStack {
+	ms := new(MatrixStack)
+	ms.Init()
+	return ms
+}
+
+// Init resets the stack, leaving the identity on top.
+func (ms *MatrixStack) Init() {
+	ms.current = *IdentMat4()
+	ms.stack = ms.stack[:0]
+}
+
+// Push saves a copy of the current matrix.
+func (ms *MatrixStack) Push() {
+	ms.stack = append(ms.stack, ms.current)
+}
+
+// Pop restores the last pushed matrix.  Popping an empty stack resets the
+// current matrix to the identity.
+func (ms *MatrixStack) Pop() {
+	if len(ms.stack) == 0 {
+		ms.current = *IdentMat4()
+		return
+	}
+	ms.current = ms.stack[len(ms.stack)-1]
+	ms.stack = ms.stack[:len(ms.stack)-1]
+}
+
+// Depth is the number of matrices pushed.
+func (ms *MatrixStack) Depth() int {
+	return len(ms.stack)
+}
+
+// Top returns a pointer to the current matrix for gl.UniformMatrix4fv.
+func (ms *MatrixStack) Top() *gl.Float {
+	return &ms.current[0].X
+}
+
+// Current returns a copy of the current matrix.
+func (ms *MatrixStack) Current() *Mat4 {
+	m := ms.current
+	return &m
+}
+
+func (ms *MatrixStack) Set(m *Mat4) {
+	ms.current = *m
+}
+
+func (ms *MatrixStack) SetIdentity() {
+	ms.current = *IdentMat4()
+}
+
+// ApplyMatrix multiplies m onto the right of the current matrix.
+func (ms *MatrixStack) ApplyMatrix(m *Mat4) {
+	ms.current = *ms.current.MulM(m)
+}
+
+// Translate and Scale ignore the w component of v.
+func (ms *MatrixStack) Translate(v *Vec4) {
+	ms.ApplyMatrix(TranslateMat4(&Vec3{v.X, v.Y, v.Z}))
+}
+
+func (ms *MatrixStack) Scale(v *Vec4) {
+	ms.ApplyMatrix(ScaleMat4(&Vec3{v.X, v.Y, v.Z}))
+}
+
+func (ms *MatrixStack) RotateX(fAngDeg gl.Float) {
+	ms.ApplyMatrix(RotateXMat4(fAngDeg))
+}
+
+func (ms *MatrixStack) RotateY(fAngDeg gl.Float) {
+	ms.ApplyMatrix(RotateYMat4(fAngDeg))
+}
+
+func (ms *MatrixStack) RotateZ(fAngDeg gl.Float) {
+	ms.ApplyMatrix(RotateZMat4(fAngDeg))
+}
+
+func (ms *MatrixStack) Rotate(axis *Vec3, fAngDeg gl.Float) {
+	ms.ApplyMatrix(RotateAxisMat4(axis, fAngDeg))
+}
+
+// ApplyQuat rotates by the orientation q.
+func (ms *MatrixStack) ApplyQuat(q *Quat) {
+	ms.ApplyMatrix(q.Mat4())
+}
+
+func (ms *MatrixStack) Perspective(fFovDeg, fAspect, fzNear, fzFar gl.Float) {
+	ms.ApplyMatrix(Perspective(fFovDeg, fAspect, fzNear, fzFar))
+}
+
+func (ms *MatrixStack) Frustum(left, right, bottom, top, fzNear, fzFar gl.Float) {
+	ms.ApplyMatrix(Frustum(left, right, bottom, top, fzNear, fzFar))
+}
+
+func (ms *MatrixStack) Ortho(left, right, bottom, top, fzNear, fzFar gl.Float) {
+	ms.ApplyMatrix(Ortho(left, right, bottom, top, fzNear, fzFar))
+}
+
+func (ms *MatrixStack) LookAt(eye, center, up *Vec3) {
+	ms.ApplyMatrix(LookAt(eye, center, up))
+}
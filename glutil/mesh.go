@@ -0,0 +1,738 @@
+/*
+mesh.go - meshes in the arcsynthesis gltut XML format, the world_tut/*.xml
+files.
+
+	<mesh xmlns="http://www.arcsynthesis.com/gltut/mesh">
+		<attribute index="0" type="float" size="3"> ... </attribute>
+		<indices cmd="tri-fan" type="ushort"> ... </indices>
+		<arrays cmd="triangles" start="0" count="36"/>
+		<vao name="flat"><source attrib="0"/></vao>
+	</mesh>
+
+Every <indices> or <arrays> element becomes one draw command, in file order.
+A <vao> names a subset of the attributes, so one mesh can feed programs
+that want different inputs - see RenderVAO.
+Parsing and validation don't touch GL, so a mesh can be loaded and checked
+without a context; the buffers are made on the first Render (or Upload).
+*/
+package glutil
+
+import (
+	"encoding/binary"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	gl "github.com/chsc/gogl/gl33"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const MeshNamespace = "http://www.arcsynthesis.com/gltut/mesh"
+
+// The attribute indices imported meshes use, the same as the later gltut
+// tutorials.
+const (
+	AttribPosition    = 0
+	AttribColor       = 1
+	AttribNormal      = 2
+	AttribTangent     = 3 // glTF's TANGENT and TEXCOORD_1
+	AttribTexCoord1   = 4
+	AttribTexCoord    = 5
+	AttribBoneIndices = 6 // skinned meshes, see skinnedmesh.go
+	AttribBoneWeights = 7
+)
+
+// What each attribute type in the file means to GL.
+type attribType struct {
+	glType     gl.Enum
+	normalized bool
+	integer    bool // can be passed through as an integer, see MeshAttrib.Integral
+	bytes      int
+	min, max   float64
+}
+
+var attribTypes = map[string]attribType{
+	"float":       {gl.FLOAT, false, false, 4, -math.MaxFloat32, math.MaxFloat32},
+	"half":        {gl.HALF_FLOAT, false, false, 2, -65504, 65504},
+	"int":         {gl.INT, false, true, 4, math.MinInt32, math.MaxInt32},
+	"uint":        {gl.UNSIGNED_INT, false, true, 4, 0, math.MaxUint32},
+	"norm-int":    {gl.INT, true, false, 4, math.MinInt32, math.MaxInt32},
+	"norm-uint":   {gl.UNSIGNED_INT, true, false, 4, 0, math.MaxUint32},
+	"short":       {gl.SHORT, false, true, 2, math.MinInt16, math.MaxInt16},
+	"ushort":      {gl.UNSIGNED_SHORT, false, true, 2, 0, math.MaxUint16},
+	"norm-short":  {gl.SHORT, true, false, 2, math.MinInt16, math.MaxInt16},
+	"norm-ushort": {gl.UNSIGNED_SHORT, true, false, 2, 0, math.MaxUint16},
+	"byte":        {gl.BYTE, false, true, 1, math.MinInt8, math.MaxInt8},
+	"ubyte":       {gl.UNSIGNED_BYTE, false, true, 1, 0, math.MaxUint8},
+	"norm-byte":   {gl.BYTE, true, false, 1, math.MinInt8, math.MaxInt8},
+	"norm-ubyte":  {gl.UNSIGNED_BYTE, true, false, 1, 0, math.MaxUint8},
+}
+
+// Index types, and the largest index each can hold.
+var indexTypes = map[string]struct {
+	glType gl.Enum
+	bytes  int
+	max    gl.Uint
+}{
+	"ubyte":  {gl.UNSIGNED_BYTE, 1, math.MaxUint8},
+	"ushort": {gl.UNSIGNED_SHORT, 2, math.MaxUint16},
+	"uint":   {gl.UNSIGNED_INT, 4, math.MaxUint32},
+}
+
+var primitiveTypes = map[string]gl.Enum{
+	"triangles":  gl.TRIANGLES,
+	"tri-strip":  gl.TRIANGLE_STRIP,
+	"tri-fan":    gl.TRIANGLE_FAN,
+	"lines":      gl.LINES,
+	"line-strip": gl.LINE_STRIP,
+	"line-loop":  gl.LINE_LOOP,
+	"points":     gl.POINTS,
+}
+
+type MeshAttrib struct {
+	Index    gl.Uint
+	Type     string // as written in the file: "float", "norm-ubyte"...
+	Size     int    // components per vertex, 1 to 4
+	Integral bool   // integer types only - the shader sees ints, not floats
+	Data     []float64
+
+	offset uintptr // into the attribute buffer
+}
+
+func (a *MeshAttrib) VertexCount() int { return len(a.Data) / a.Size }
+
+// One glDrawElements or glDrawArrays call.
+type RenderCmd struct {
+	Cmd       string // as written in the file: "triangles", "tri-fan"...
+	Primitive gl.Enum
+	Material  string // from importers; the XML format doesn't have them
+
+	// Indexed commands draw Indices, which are IndexType ("ushort" or
+	// "uint", or "ubyte").  The others draw Count vertices from Start.
+	Indexed   bool
+	IndexType string
+	Indices   []gl.Uint
+	Start     int
+	Count     int
+
+	offset uintptr // into the element buffer
+}
+
+type Mesh struct {
+	Name        string
+	Attribs     []*MeshAttrib
+	Commands    []*RenderCmd
+	VertexCount int
+
+	// Named VAOs, and the attribute indices each one enables.
+	VAOs map[string][]gl.Uint
+
+	vao, attribBuffer, indexBuffer gl.Uint
+	namedVAOs                      map[string]gl.Uint
+	warned                         map[string]bool
+}
+
+func NewMesh(name string) *Mesh {
+	return &Mesh{Name: name}
+}
+
+// LoadMeshFromXML reads a gltut mesh file.  Nothing is sent to GL until the
+// mesh is first drawn.
+func LoadMeshFromXML(meshFile string) (*Mesh, error) {
+	m := NewMesh(meshFile)
+	if err := m.LoadGLUTMesh(meshFile); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// LoadGLUTMesh replaces the mesh's contents with meshFile's.
+func (m *Mesh) LoadGLUTMesh(meshFile string) error {
+	fp, err := os.Open(meshFile)
+	if err != nil {
+		return err
+	}
+	defer fp.Close()
+	if err := m.ParseGLUTMesh(fp); err != nil {
+		return fmt.Errorf("%s: %s", meshFile, err)
+	}
+	return nil
+}
+
+// A generic element, so the draw commands keep their order.
+type xmlElement struct {
+	XMLName  xml.Name
+	Attrs    []xml.Attr   `xml:",any,attr"`
+	Text     string       `xml:",chardata"`
+	Children []xmlElement `xml:",any"`
+}
+
+func (e *xmlElement) attr(name string) (string, bool) {
+	for _, a := range e.Attrs {
+		if a.Name.Local == name {
+			return a.Value, true
+		}
+	}
+	return "", false
+}
+
+// requireAttr returns an attribute that must be there.
+func (e *xmlElement) requireAttr(name string) (string, error) {
+	v, ok := e.attr(name)
+	if !ok {
+		return "", fmt.Errorf("<%s> has no %s attribute", e.XMLName.Local, name)
+	}
+	return v, nil
+}
+
+// ParseGLUTMesh reads and validates a gltut mesh from r.
+func (m *Mesh) ParseGLUTMesh(r io.Reader) error {
+	var root xmlElement
+	if err := xml.NewDecoder(r).Decode(&root); err != nil {
+		return err
+	}
+	if root.XMLName.Local != "mesh" {
+		return fmt.Errorf("root element is <%s>, not <mesh>", root.XMLName.Local)
+	}
+	if ns := root.XMLName.Space; ns != "" && ns != MeshNamespace {
+		return fmt.Errorf("unknown mesh namespace %q", ns)
+	}
+
+	m.Attribs = nil
+	m.Commands = nil
+	m.VertexCount = 0
+	m.VAOs = make(map[string][]gl.Uint)
+	// Commands are checked against the vertex count after everything's read.
+	for i, e := range root.Children {
+		var err error
+		switch e.XMLName.Local {
+		case "attribute":
+			err = m.parseAttrib(&e)
+		case "indices":
+			err = m.parseIndices(&e)
+		case "arrays":
+			err = m.parseArrays(&e)
+		case "vao":
+			err = m.parseVAO(&e)
+		default:
+			err = fmt.Errorf("unknown element <%s>", e.XMLName.Local)
+		}
+		if err != nil {
+			return fmt.Errorf("element %d: %s", i+1, err)
+		}
+	}
+	return m.Validate()
+}
+
+func (m *Mesh) parseAttrib(e *xmlElement) error {
+	s, err := e.requireAttr("index")
+	if err != nil {
+		return err
+	}
+	index, err := strconv.Atoi(s)
+	if err != nil || index < 0 || index > 15 {
+		return fmt.Errorf("attribute index %q must be 0 to 15", s)
+	}
+	a := &MeshAttrib{Index: gl.Uint(index)}
+	if a.Type, err = e.requireAttr("type"); err != nil {
+		return err
+	}
+	if s, err = e.requireAttr("size"); err != nil {
+		return err
+	}
+	if a.Size, err = strconv.Atoi(s); err != nil || a.Size < 1 || a.Size > 4 {
+		return fmt.Errorf("attribute %d: size %q must be 1 to 4", index, s)
+	}
+	if s, ok := e.attr("integral"); ok {
+		if a.Integral, err = strconv.ParseBool(s); err != nil {
+			return fmt.Errorf("attribute %d: bad integral %q", index, s)
+		}
+	}
+	for _, f := range strings.Fields(e.Text) {
+		v, err := strconv.ParseFloat(f, 64)
+		if err != nil {
+			return fmt.Errorf("attribute %d: bad number %q", index, f)
+		}
+		a.Data = append(a.Data, v)
+	}
+	m.Attribs = append(m.Attribs, a)
+	return nil
+}
+
+// parseVAO reads <vao name="..."><source attrib="N"/>...</vao>.
+func (m *Mesh) parseVAO(e *xmlElement) error {
+	name, err := e.requireAttr("name")
+	if err != nil {
+		return err
+	}
+	if _, ok := m.VAOs[name]; ok {
+		return fmt.Errorf("vao %q defined twice", name)
+	}
+	var indices []gl.Uint
+	for _, src := range e.Children {
+		if src.XMLName.Local != "source" {
+			return fmt.Errorf("vao %q: unknown element <%s>", name, src.XMLName.Local)
+		}
+		s, err := src.requireAttr("attrib")
+		if err != nil {
+			return fmt.Errorf("vao %q: %s", name, err)
+		}
+		index, err := strconv.Atoi(s)
+		if err != nil || index < 0 {
+			return fmt.Errorf("vao %q: bad attrib %q", name, s)
+		}
+		indices = append(indices, gl.Uint(index))
+	}
+	m.VAOs[name] = indices
+	return nil
+}
+
+func (m *Mesh) parseIndices(e *xmlElement) error {
+	c := &RenderCmd{Indexed: true}
+	var err error
+	if c.Cmd, err = e.requireAttr("cmd"); err != nil {
+		return err
+	}
+	if c.IndexType, err = e.requireAttr("type"); err != nil {
+		return err
+	}
+	for _, f := range strings.Fields(e.Text) {
+		v, err := strconv.ParseUint(f, 10, 32)
+		if err != nil {
+			return fmt.Errorf("bad index %q", f)
+		}
+		c.Indices = append(c.Indices, gl.Uint(v))
+	}
+	c.Count = len(c.Indices)
+	m.Commands = append(m.Commands, c)
+	return nil
+}
+
+func (m *Mesh) parseArrays(e *xmlElement) error {
+	c := &RenderCmd{}
+	var err error
+	if c.Cmd, err = e.requireAttr("cmd"); err != nil {
+		return err
+	}
+	for _, p := range []struct {
+		name string
+		dst  *int
+	}{{"start", &c.Start}, {"count", &c.Count}} {
+		s, err := e.requireAttr(p.name)
+		if err != nil {
+			return err
+		}
+		if *p.dst, err = strconv.Atoi(s); err != nil || *p.dst < 0 {
+			return fmt.Errorf("bad %s %q", p.name, s)
+		}
+	}
+	m.Commands = append(m.Commands, c)
+	return nil
+}
+
+// Validate checks that the attributes agree with each other and that every
+// command stays inside them.  Meshes built in code should call it before
+// drawing.
+func (m *Mesh) Validate() error {
+	if len(m.Attribs) == 0 {
+		return errors.New("mesh has no attributes")
+	}
+	if len(m.Commands) == 0 {
+		return errors.New("mesh has no <indices> or <arrays>")
+	}
+	seen := make(map[gl.Uint]bool)
+	m.VertexCount = -1
+	for _, a := range m.Attribs {
+		if seen[a.Index] {
+			return fmt.Errorf("attribute %d defined twice", a.Index)
+		}
+		seen[a.Index] = true
+		t, ok := attribTypes[a.Type]
+		if !ok {
+			return fmt.Errorf("attribute %d: unknown type %q", a.Index, a.Type)
+		}
+		if a.Integral && !t.integer {
+			return fmt.Errorf("attribute %d: %s can't be integral", a.Index, a.Type)
+		}
+		if len(a.Data) == 0 || len(a.Data)%a.Size != 0 {
+			return fmt.Errorf("attribute %d: %d values isn't a whole number of size %d vertices",
+				a.Index, len(a.Data), a.Size)
+		}
+		for _, v := range a.Data {
+			if v < t.min || v > t.max || (t.glType != gl.FLOAT && t.glType != gl.HALF_FLOAT && v != math.Trunc(v)) {
+				return fmt.Errorf("attribute %d: %g doesn't fit in %s", a.Index, v, a.Type)
+			}
+		}
+		if m.VertexCount == -1 {
+			m.VertexCount = a.VertexCount()
+		} else if a.VertexCount() != m.VertexCount {
+			return fmt.Errorf("attribute %d has %d vertices, attribute %d has %d",
+				a.Index, a.VertexCount(), m.Attribs[0].Index, m.VertexCount)
+		}
+	}
+
+	for name, indices := range m.VAOs {
+		if len(indices) == 0 {
+			return fmt.Errorf("vao %q has no sources", name)
+		}
+		used := make(map[gl.Uint]bool)
+		for _, index := range indices {
+			if !seen[index] {
+				return fmt.Errorf("vao %q: no attribute %d", name, index)
+			}
+			if used[index] {
+				return fmt.Errorf("vao %q: attribute %d used twice", name, index)
+			}
+			used[index] = true
+		}
+	}
+
+	for i, c := range m.Commands {
+		fail := func(format string, args ...interface{}) error {
+			return fmt.Errorf("command %d (%s): %s", i+1, c.Cmd, fmt.Sprintf(format, args...))
+		}
+		prim, ok := primitiveTypes[c.Cmd]
+		if !ok {
+			return fail("unknown cmd")
+		}
+		c.Primitive = prim
+		switch {
+		case c.Count == 0:
+			return fail("nothing to draw")
+		case prim == gl.TRIANGLES && c.Count%3 != 0:
+			return fail("%d vertices isn't a whole number of triangles", c.Count)
+		case prim == gl.LINES && c.Count%2 != 0:
+			return fail("%d vertices isn't a whole number of lines", c.Count)
+		case (prim == gl.TRIANGLE_STRIP || prim == gl.TRIANGLE_FAN) && c.Count < 3:
+			return fail("needs at least 3 vertices, has %d", c.Count)
+		}
+		if !c.Indexed {
+			if c.Start+c.Count > m.VertexCount {
+				return fail("vertices %d to %d out of range, only %d vertices",
+					c.Start, c.Start+c.Count-1, m.VertexCount)
+			}
+			continue
+		}
+		t, ok := indexTypes[c.IndexType]
+		if !ok {
+			return fail("unknown index type %q", c.IndexType)
+		}
+		if len(c.Indices) != c.Count {
+			return fail("count %d but %d indices", c.Count, len(c.Indices))
+		}
+		for _, idx := range c.Indices {
+			if idx > t.max {
+				return fail("index %d doesn't fit in %s", idx, c.IndexType)
+			}
+			if int(idx) >= m.VertexCount {
+				return fail("index %d out of range, only %d vertices", idx, m.VertexCount)
+			}
+		}
+	}
+	return nil
+}
+
+// Attrib returns the attribute bound to index, or nil.
+func (m *Mesh) Attrib(index gl.Uint) *MeshAttrib {
+	for _, a := range m.Attribs {
+		if a.Index == index {
+			return a
+		}
+	}
+	return nil
+}
+
+// Vertices returns the indices of the vertices a command draws, in order.
+func (c *RenderCmd) Vertices() []int {
+	vs := make([]int, c.Count)
+	for i := range vs {
+		if c.Indexed {
+			vs[i] = int(c.Indices[i])
+		} else {
+			vs[i] = c.Start + i
+		}
+	}
+	return vs
+}
+
+// Triangles unrolls strips and fans into a plain triangle list, keeping the
+// winding of the original.  Lines and points have no triangles.
+func (c *RenderCmd) Triangles() [][3]int {
+	vs := c.Vertices()
+	var tris [][3]int
+	switch c.Primitive {
+	case gl.TRIANGLES:
+		for i := 0; i+2 < len(vs); i += 3 {
+			tris = append(tris, [3]int{vs[i], vs[i+1], vs[i+2]})
+		}
+	case gl.TRIANGLE_STRIP:
+		for i := 0; i+2 < len(vs); i++ {
+			// Every other triangle in a strip is flipped.
+			if i%2 == 0 {
+				tris = append(tris, [3]int{vs[i], vs[i+1], vs[i+2]})
+			} else {
+				tris = append(tris, [3]int{vs[i+1], vs[i], vs[i+2]})
+			}
+		}
+	case gl.TRIANGLE_FAN:
+		for i := 1; i+1 < len(vs); i++ {
+			tris = append(tris, [3]int{vs[0], vs[i], vs[i+1]})
+		}
+	}
+	return tris
+}
+
+// Triangles returns every triangle the mesh draws.
+func (m *Mesh) Triangles() [][3]int {
+	var tris [][3]int
+	for _, c := range m.Commands {
+		tris = append(tris, c.Triangles()...)
+	}
+	return tris
+}
+
+// halfFloat converts to IEEE 754 half precision, rounding to nearest.
+func halfFloat(f float64) uint16 {
+	bits := math.Float32bits(float32(f))
+	sign := uint16(bits>>16) & 0x8000
+	exp := int(bits>>23&0xff) - 127 + 15
+	mant := bits & 0x7fffff
+	switch {
+	case exp >= 31:
+		return sign | 0x7c00
+	case exp <= 0:
+		if exp < -10 {
+			return sign
+		}
+		mant |= 0x800000
+		return sign | uint16((mant>>uint(13-exp)+1)>>1)
+	}
+	// Adding lets a rounding carry bump the exponent.
+	return sign | (uint16(exp)<<10 + uint16((mant+0x1000)>>13))
+}
+
+// bytes packs the attribute the way GL will read it.
+func (a *MeshAttrib) bytes() []byte {
+	t := attribTypes[a.Type]
+	buf := make([]byte, len(a.Data)*t.bytes)
+	le := binary.LittleEndian
+	for i, v := range a.Data {
+		b := buf[i*t.bytes:]
+		switch t.glType {
+		case gl.FLOAT:
+			le.PutUint32(b, math.Float32bits(float32(v)))
+		case gl.HALF_FLOAT:
+			le.PutUint16(b, halfFloat(v))
+		case gl.INT, gl.UNSIGNED_INT:
+			le.PutUint32(b, uint32(int64(v)))
+		case gl.SHORT, gl.UNSIGNED_SHORT:
+			le.PutUint16(b, uint16(int64(v)))
+		default:
+			b[0] = byte(int64(v))
+		}
+	}
+	return buf
+}
+
+// align4 pads buf out to a multiple of 4 bytes.
+func align4(buf []byte) []byte {
+	for len(buf)%4 != 0 {
+		buf = append(buf, 0)
+	}
+	return buf
+}
+
+// Upload puts the mesh into buffer objects and VAOs, one with every
+// attribute and one per named VAO.  Render does this itself the first time,
+// but it needs a current context.
+func (m *Mesh) Upload() {
+	if m.vao != 0 {
+		m.Delete()
+	}
+	// Attributes go one after the other in one buffer, as do the indices.
+	var attribData []byte
+	for _, a := range m.Attribs {
+		a.offset = uintptr(len(attribData))
+		attribData = align4(append(attribData, a.bytes()...))
+	}
+	var indexData []byte
+	le := binary.LittleEndian
+	for _, c := range m.Commands {
+		if !c.Indexed {
+			continue
+		}
+		c.offset = uintptr(len(indexData))
+		size := indexTypes[c.IndexType].bytes
+		b := make([]byte, len(c.Indices)*size)
+		for i, idx := range c.Indices {
+			switch size {
+			case 1:
+				b[i] = byte(idx)
+			case 2:
+				le.PutUint16(b[i*2:], uint16(idx))
+			default:
+				le.PutUint32(b[i*4:], uint32(idx))
+			}
+		}
+		indexData = align4(append(indexData, b...))
+	}
+
+	gl.GenBuffers(1, &m.attribBuffer)
+	gl.BindBuffer(gl.ARRAY_BUFFER, m.attribBuffer)
+	gl.BufferData(gl.ARRAY_BUFFER, gl.Sizeiptr(len(attribData)), gl.Pointer(&attribData[0]), gl.STATIC_DRAW)
+	if len(indexData) > 0 {
+		gl.GenBuffers(1, &m.indexBuffer)
+		gl.BindBuffer(gl.ELEMENT_ARRAY_BUFFER, m.indexBuffer)
+		gl.BufferData(gl.ELEMENT_ARRAY_BUFFER, gl.Sizeiptr(len(indexData)), gl.Pointer(&indexData[0]), gl.STATIC_DRAW)
+	}
+
+	m.vao = m.makeVAO(m.Attribs)
+	m.namedVAOs = make(map[string]gl.Uint)
+	for name, indices := range m.VAOs {
+		var attribs []*MeshAttrib
+		for _, index := range indices {
+			attribs = append(attribs, m.Attrib(index))
+		}
+		m.namedVAOs[name] = m.makeVAO(attribs)
+	}
+
+	gl.BindBuffer(gl.ARRAY_BUFFER, 0)
+	gl.BindBuffer(gl.ELEMENT_ARRAY_BUFFER, 0)
+}
+
+// UpdateAttrib uploads the attribute with the given index again, after
+// its Data has been changed in place.  The size can't change.
+func (m *Mesh) UpdateAttrib(index gl.Uint) {
+	a := m.Attrib(index)
+	if m.vao == 0 || a == nil {
+		return // the first Render uploads it
+	}
+	b := a.bytes()
+	gl.BindBuffer(gl.ARRAY_BUFFER, m.attribBuffer)
+	gl.BufferSubData(gl.ARRAY_BUFFER, gl.Intptr(a.offset), gl.Sizeiptr(len(b)), gl.Pointer(&b[0]))
+	gl.BindBuffer(gl.ARRAY_BUFFER, 0)
+}
+
+// makeVAO makes a VAO over the mesh's buffers with just attribs enabled.
+func (m *Mesh) makeVAO(attribs []*MeshAttrib) gl.Uint {
+	var vao gl.Uint
+	gl.GenVertexArrays(1, &vao)
+	gl.BindVertexArray(vao)
+	gl.BindBuffer(gl.ARRAY_BUFFER, m.attribBuffer)
+	for _, a := range attribs {
+		t := attribTypes[a.Type]
+		gl.EnableVertexAttribArray(a.Index)
+		if a.Integral {
+			gl.VertexAttribIPointer(a.Index, gl.Int(a.Size), t.glType, 0, gl.Offset(nil, a.offset))
+		} else {
+			var norm gl.Boolean = gl.FALSE
+			if t.normalized {
+				norm = gl.TRUE
+			}
+			gl.VertexAttribPointer(a.Index, gl.Int(a.Size), t.glType, norm, 0, gl.Offset(nil, a.offset))
+		}
+	}
+	if m.indexBuffer != 0 {
+		gl.BindBuffer(gl.ELEMENT_ARRAY_BUFFER, m.indexBuffer)
+	}
+	gl.BindVertexArray(0)
+	return vao
+}
+
+// Render issues every draw command with all the attributes enabled.  The
+// caller sets up the program.
+func (m *Mesh) Render() {
+	if m.vao == 0 {
+		m.Upload()
+	}
+	m.draw(m.vao)
+}
+
+// RenderVAO is Render with only the attributes of the named VAO enabled.
+// An unknown name draws nothing, and complains once.
+func (m *Mesh) RenderVAO(name string) {
+	if m.vao == 0 {
+		m.Upload()
+	}
+	vao, ok := m.namedVAOs[name]
+	if !ok {
+		if m.warned == nil {
+			m.warned = make(map[string]bool)
+		}
+		if !m.warned[name] {
+			fmt.Fprintf(os.Stderr, "Mesh %s has no VAO named %q\n", m.Name, name)
+			m.warned[name] = true
+		}
+		return
+	}
+	m.draw(vao)
+}
+
+func (m *Mesh) draw(vao gl.Uint) {
+	gl.BindVertexArray(vao)
+	for _, c := range m.Commands {
+		if c.Indexed {
+			gl.DrawElements(c.Primitive, gl.Sizei(c.Count), indexTypes[c.IndexType].glType, gl.Offset(nil, c.offset))
+		} else {
+			gl.DrawArrays(c.Primitive, gl.Int(c.Start), gl.Sizei(c.Count))
+		}
+	}
+	gl.BindVertexArray(0)
+}
+
+// Delete frees the GL objects.  The mesh data is kept, so the next Render
+// uploads it again.
+func (m *Mesh) Delete() {
+	gl.DeleteVertexArrays(1, &m.vao)
+	for _, vao := range m.namedVAOs {
+		gl.DeleteVertexArrays(1, &vao)
+	}
+	gl.DeleteBuffers(1, &m.attribBuffer)
+	if m.indexBuffer != 0 {
+		gl.DeleteBuffers(1, &m.indexBuffer)
+	}
+	m.vao, m.attribBuffer, m.indexBuffer = 0, 0, 0
+	m.namedVAOs = nil
+}
+
+// Debug dumps the mesh to stdout.
+func (m *Mesh) Debug() {
+	fmt.Printf("Mesh %s: %d vertices, %d attributes, %d commands\n",
+		m.Name, m.VertexCount, len(m.Attribs), len(m.Commands))
+	for _, a := range m.Attribs {
+		integral := ""
+		if a.Integral {
+			integral = " integral"
+		}
+		fmt.Printf("  attribute %d: %s x %d%s\n", a.Index, a.Type, a.Size, integral)
+		for v := 0; v < a.VertexCount(); v++ {
+			fmt.Printf("    %3d:", v)
+			for _, f := range a.Data[v*a.Size : (v+1)*a.Size] {
+				fmt.Printf(" %g", f)
+			}
+			fmt.Println()
+		}
+	}
+	names := make([]string, 0, len(m.VAOs))
+	for name := range m.VAOs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Printf("  vao %s: attributes %v\n", name, m.VAOs[name])
+	}
+	for i, c := range m.Commands {
+		if c.Indexed {
+			fmt.Printf("  command %d: %s, %d %s indices, %d triangles\n",
+				i+1, c.Cmd, c.Count, c.IndexType, len(c.Triangles()))
+			fmt.Printf("    %v\n", c.Indices)
+		} else {
+			fmt.Printf("  command %d: %s, vertices %d to %d, %d triangles\n",
+				i+1, c.Cmd, c.Start, c.Start+c.Count-1, len(c.Triangles()))
+		}
+	}
+}
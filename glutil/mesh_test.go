@@ -0,0 +1,98 @@
+package glutil
+
+import (
+	gl "github.com/chsc/gogl/gl33"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadMeshFromXML(t *testing.T) {
+	tests := []struct {
+		file     string
+		vertices int
+		cmds     []gl.Enum
+		counts   []int
+	}{
+		{"../world_tut/UnitConeTint.xml", 32,
+			[]gl.Enum{gl.TRIANGLE_FAN, gl.TRIANGLE_FAN}, []int{32, 32}},
+		{"../world_tut/UnitCylinderTint.xml", 62,
+			[]gl.Enum{gl.TRIANGLE_FAN, gl.TRIANGLE_FAN, gl.TRIANGLE_STRIP}, []int{32, 32, 62}},
+	}
+	for _, tt := range tests {
+		m, err := LoadMeshFromXML(tt.file)
+		if err != nil {
+			t.Errorf("%s: %s", tt.file, err)
+			continue
+		}
+		if m.VertexCount != tt.vertices {
+			t.Errorf("%s: %d vertices, want %d", tt.file, m.VertexCount, tt.vertices)
+		}
+		// Position, then colour
+		if len(m.Attribs) != 2 {
+			t.Errorf("%s: %d attributes", tt.file, len(m.Attribs))
+			continue
+		}
+		for i, size := range []int{3, 4} {
+			a := m.Attribs[i]
+			if a.Index != gl.Uint(i) || a.Type != "float" || a.Size != size || a.VertexCount() != tt.vertices {
+				t.Errorf("%s: attribute %d is %d %s x%d, %d vertices", tt.file, i, a.Index, a.Type, a.Size, a.VertexCount())
+			}
+		}
+		if len(m.Commands) != len(tt.cmds) {
+			t.Errorf("%s: %d commands, want %d", tt.file, len(m.Commands), len(tt.cmds))
+			continue
+		}
+		for i, c := range m.Commands {
+			if c.Primitive != tt.cmds[i] || !c.Indexed || c.IndexType != "ushort" || c.Count != tt.counts[i] {
+				t.Errorf("%s: command %d is %s (%#x), %s, %d indices", tt.file, i, c.Cmd, c.Primitive, c.IndexType, c.Count)
+			}
+		}
+		if len(m.VAOs) != 0 {
+			t.Errorf("%s: VAOs %v", tt.file, m.VAOs)
+		}
+	}
+}
+
+func TestLoadMeshVAOs(t *testing.T) {
+	m, err := LoadMeshFromXML("../world_tut/UnitCubeColor.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]gl.Uint{"color": {0, 1}, "flat": {0}}
+	if !reflect.DeepEqual(m.VAOs, want) {
+		t.Errorf("VAOs %v, want %v", m.VAOs, want)
+	}
+}
+
+func TestParseGLUTMeshErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		xml  string
+		want string
+	}{
+		{"index out of range",
+			`<mesh><attribute index="0" type="float" size="2">0 0 1 0 0 1</attribute>
+			<indices cmd="triangles" type="ushort">0 1 3</indices></mesh>`,
+			"command 1 (triangles): index 3 out of range, only 3 vertices"},
+		{"arrays out of range",
+			`<mesh><attribute index="0" type="float" size="2">0 0 1 0 0 1</attribute>
+			<arrays cmd="triangles" start="1" count="3"/></mesh>`,
+			"command 1 (triangles): vertices 1 to 3 out of range, only 3 vertices"},
+		{"unknown cmd",
+			`<mesh><attribute index="0" type="float" size="2">0 0 1 0 0 1</attribute>
+			<indices cmd="quads" type="ushort">0 1 2</indices></mesh>`,
+			"command 1 (quads): unknown cmd"},
+		{"vao without its attribute",
+			`<mesh><attribute index="0" type="float" size="2">0 0 1 0 0 1</attribute>
+			<vao name="lit"><source attrib="2"/></vao>
+			<indices cmd="triangles" type="ushort">0 1 2</indices></mesh>`,
+			`vao "lit": no attribute 2`},
+	}
+	for _, tt := range tests {
+		err := NewMesh(tt.name).ParseGLUTMesh(strings.NewReader(tt.xml))
+		if err == nil || err.Error() != tt.want {
+			t.Errorf("%s: got %v, want %q", tt.name, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+/*
+meshwrite.go - writes a Mesh out as gltut XML, laid out the way the
+old world_tut/Gen*.lua scripts' XmlWriter did it: a vertex to a line, a
+triangle (or line) of indices to a line, and strips and fans all on one.
+Writing a mesh loaded from one of those files gives the same bytes back.
+
+Named VAOs go between the attributes and the draw commands, sorted by name,
+since the mesh doesn't remember their order.
+*/
+package glutil
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	gl "github.com/chsc/gogl/gl33"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SaveGLUTMesh writes the mesh to meshFile.
+func (m *Mesh) SaveGLUTMesh(meshFile string) error {
+	fp, err := os.Create(meshFile)
+	if err != nil {
+		return err
+	}
+	if err := m.WriteGLUTMesh(fp); err != nil {
+		fp.Close()
+		return fmt.Errorf("%s: %s", meshFile, err)
+	}
+	return fp.Close()
+}
+
+// WriteGLUTMesh writes the mesh to w as gltut XML.  It's validated first, so
+// nothing is written that LoadGLUTMesh would refuse.
+func (m *Mesh) WriteGLUTMesh(w io.Writer) error {
+	if err := m.Validate(); err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n")
+	fmt.Fprintf(bw, "<?oxygen RNGSchema=\"../../Documents/meshFormat.rnc\" type=\"compact\"?>\n\n")
+	fmt.Fprintf(bw, "<mesh xmlns=\"%s\" >\n", MeshNamespace)
+
+	for _, a := range m.Attribs {
+		fmt.Fprintf(bw, "\t<attribute index=\"%d\" type=\"%s\" size=\"%d\"", a.Index, a.Type, a.Size)
+		if a.Integral {
+			fmt.Fprintf(bw, " integral=\"true\"")
+		}
+		fmt.Fprintf(bw, " >%s</attribute>\n", meshLines(a.Data, a.Size))
+	}
+
+	names := make([]string, 0, len(m.VAOs))
+	for name := range m.VAOs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(bw, "\t<vao name=\"%s\" >\n", xmlEscape(name))
+		for _, index := range m.VAOs[name] {
+			fmt.Fprintf(bw, "\t\t<source attrib=\"%d\" />\n", index)
+		}
+		fmt.Fprintf(bw, "\t</vao>\n")
+	}
+
+	for _, c := range m.Commands {
+		if !c.Indexed {
+			fmt.Fprintf(bw, "\t<arrays cmd=\"%s\" start=\"%d\" count=\"%d\" />\n", c.Cmd, c.Start, c.Count)
+			continue
+		}
+		per := 0 // strips and fans go on one line
+		switch c.Primitive {
+		case gl.TRIANGLES:
+			per = 3
+		case gl.LINES:
+			per = 2
+		}
+		text := indexLines(c.Indices, per)
+		fmt.Fprintf(bw, "\t<indices cmd=\"%s\" type=\"%s\" >%s</indices>\n", c.Cmd, c.IndexType, text)
+	}
+	// XmlWriter didn't end the file with a newline.
+	fmt.Fprintf(bw, "</mesh>")
+	return bw.Flush()
+}
+
+// meshLines is XmlWriter's text for an array of vectors: a blank, then
+// each vector on its own indented line.
+func meshLines(data []float64, size int) string {
+	lines := []string{" "}
+	for i := 0; i+size <= len(data); i += size {
+		strs := make([]string, size)
+		for k, v := range data[i : i+size] {
+			strs[k] = MeshFloat(v)
+		}
+		lines = append(lines, "        "+strings.Join(strs, " "))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// indexLines is the same for indices, per to a line - or with per 0, just
+// the indices.
+func indexLines(indices []gl.Uint, per int) string {
+	strs := make([]string, len(indices))
+	for i, idx := range indices {
+		strs[i] = strconv.FormatUint(uint64(idx), 10)
+	}
+	if per == 0 {
+		return strings.Join(strs, " ")
+	}
+	lines := []string{" "}
+	for i := 0; i+per <= len(strs); i += per {
+		lines = append(lines, "        "+strings.Join(strs[i:i+per], " "))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// MeshFloat prints a number the way all the writers here do: as Lua did,
+// with %.14g, and with three exponent digits like the Windows printf the
+// world_tut files were made with (1e-006).  A value that is exactly a
+// float32, as anything that has been through GL types is, is rounded to
+// the fewest digits that read back as the same float32 first, so 0.1f comes
+// out as 0.1, not 0.10000000149012.
+func MeshFloat(v float64) string {
+	if f := float32(v); float64(f) == v && !math.IsInf(v, 0) {
+		v, _ = strconv.ParseFloat(strconv.FormatFloat(v, 'e', -1, 32), 64)
+	}
+	s := strconv.FormatFloat(v, 'g', 14, 64)
+	if e := strings.IndexByte(s, 'e'); e >= 0 && len(s)-e == 4 {
+		s = s[:e+2] + "0" + s[e+2:]
+	}
+	return s
+}
+
+func xmlEscape(s string) string {
+	var b bytes.Buffer
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
@@ -0,0 +1,108 @@
+package glutil
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// roundTripFiles is every mesh in the repo the writers are checked with.
+func roundTripFiles(t *testing.T) []string {
+	var files []string
+	for _, pattern := range []string{"../world_tut/*.xml", "../art/*.obj", "../art/*.dae", "../art/*.DAE", "../art/gltf/*.gl*"} {
+		more, err := filepath.Glob(pattern)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, more...)
+	}
+	if len(files) == 0 {
+		t.Fatal("no meshes")
+	}
+	return files
+}
+
+// loadAnyScene reads any format into a scene and its materials, which may
+// be nil, as meshconv does.
+func loadAnyScene(t *testing.T, file string) (*Scene, map[string]*PBRMaterial) {
+	name := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".xml":
+		m, err := LoadMeshFromXML(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m.Name = name
+		return MeshScene(name, []*Mesh{m}), nil
+	case ".obj":
+		model, err := LoadOBJ(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		meshes, err := model.Meshes()
+		if err != nil {
+			t.Fatalf("%s: %s", file, err)
+		}
+		mats := make(map[string]*PBRMaterial)
+		for name, m := range model.Materials {
+			mats[name] = m.PBR(filepath.Dir(file))
+		}
+		return MeshScene(name, meshes), mats
+	case ".dae":
+		s, err := LoadColladaScene(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s, nil
+	case ".gltf", ".glb":
+		s, mats, err := LoadGLTFScene(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s, mats
+	}
+	t.Fatalf("%s: don't know the format", file)
+	return nil, nil
+}
+
+// sameFloats says whether a and b match to float32 precision.
+func sameFloats(a, b []float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if math.Abs(a[i]-b[i]) > 1e-6*math.Max(1.0, math.Abs(a[i])) {
+			return false
+		}
+	}
+	return true
+}
+
+func TestWriteGLUTMeshRoundTrip(t *testing.T) {
+	files, err := filepath.Glob("../world_tut/*.xml")
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no meshes: %v", err)
+	}
+	for _, file := range files {
+		orig, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m, err := LoadMeshFromXML(file)
+		if err != nil {
+			t.Errorf("%s: %s", file, err)
+			continue
+		}
+		var buf bytes.Buffer
+		if err := m.WriteGLUTMesh(&buf); err != nil {
+			t.Errorf("%s: %s", file, err)
+			continue
+		}
+		if !bytes.Equal(buf.Bytes(), orig) {
+			t.Errorf("%s: written differently", file)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+/*
+mipmap.go - building mip chains on the CPU, so we don't lean on
+glGenerateMipmap and know exactly what filter was used.
+
+Colour is averaged in linear light: each 8 bit sRGB value is decoded, the
+box filter runs on alpha-premultiplied linear values, and the result is
+encoded back.  Averaging the raw sRGB bytes would darken every level.
+Nothing here needs a GL context.
+*/
+package glutil
+
+import (
+	"image"
+	"math"
+)
+
+var srgbToLinear [256]float64
+
+func init() {
+	for i := range srgbToLinear {
+		c := float64(i) / 255.0
+		if c <= 0.04045 {
+			srgbToLinear[i] = c / 12.92
+		} else {
+			srgbToLinear[i] = math.Pow((c+0.055)/1.055, 2.4)
+		}
+	}
+}
+
+func linearToSRGB(l float64) uint8 {
+	var c float64
+	switch {
+	case l <= 0:
+		return 0
+	case l >= 1:
+		return 255
+	case l <= 0.0031308:
+		c = l * 12.92
+	default:
+		c = 1.055*math.Pow(l, 1.0/2.4) - 0.055
+	}
+	return uint8(c*255.0 + 0.5)
+}
+
+// One src pixel's share of a dst pixel.
+type boxTap struct {
+	i int
+	w float64
+}
+
+// boxWeights gives, for each of the dst pixels along one axis, the src
+// pixels it covers and how much of each.  For even sizes that's always two
+// halves; odd sizes get fractional coverage so nothing is dropped.
+func boxWeights(src, dst int) [][]boxTap {
+	weights := make([][]boxTap, dst)
+	scale := float64(src) / float64(dst)
+	for d := 0; d < dst; d++ {
+		lo, hi := float64(d)*scale, float64(d+1)*scale
+		for s := int(lo); s < src && float64(s) < hi; s++ {
+			w := math.Min(hi, float64(s+1)) - math.Max(lo, float64(s))
+			if w > 0 {
+				weights[d] = append(weights[d], boxTap{s, w / scale})
+			}
+		}
+	}
+	return weights
+}
+
+// MipSize is the size of the next level down, as GL defines it.
+func MipSize(w, h int) (int, int) {
+	w, h = w/2, h/2
+	if w < 1 {
+		w = 1
+	}
+	if h < 1 {
+		h = 1
+	}
+	return w, h
+}
+
+// Downsample halves src with a box filter.  If gammaCorrect is false the
+// bytes are averaged as they are - right for normal maps and other data
+// that isn't colour.
+func Downsample(src *image.NRGBA, gammaCorrect bool) *image.NRGBA {
+	sw, sh := src.Rect.Dx(), src.Rect.Dy()
+	dw, dh := MipSize(sw, sh)
+
+	// Premultiplied, linear, 4 floats per pixel.
+	lin := make([]float64, sw*sh*4)
+	for y := 0; y < sh; y++ {
+		for x := 0; x < sw; x++ {
+			p := src.Pix[y*src.Stride+x*4:]
+			a := float64(p[3]) / 255.0
+			o := (y*sw + x) * 4
+			for c := 0; c < 3; c++ {
+				if gammaCorrect {
+					lin[o+c] = srgbToLinear[p[c]] * a
+				} else {
+					lin[o+c] = float64(p[c]) / 255.0 * a
+				}
+			}
+			lin[o+3] = a
+		}
+	}
+
+	// Filter across, then down.
+	xw, yw := boxWeights(sw, dw), boxWeights(sh, dh)
+	across := make([]float64, dw*sh*4)
+	for y := 0; y < sh; y++ {
+		for x := 0; x < dw; x++ {
+			for _, sx := range xw[x] {
+				for c := 0; c < 4; c++ {
+					across[(y*dw+x)*4+c] += lin[(y*sw+sx.i)*4+c] * sx.w
+				}
+			}
+		}
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		for x := 0; x < dw; x++ {
+			var sum [4]float64
+			for _, sy := range yw[y] {
+				for c := 0; c < 4; c++ {
+					sum[c] += across[(sy.i*dw+x)*4+c] * sy.w
+				}
+			}
+			o := y*dst.Stride + x*4
+			a := sum[3]
+			for c := 0; c < 3; c++ {
+				v := 0.0
+				if a > 0 {
+					v = sum[c] / a
+				}
+				if gammaCorrect {
+					dst.Pix[o+c] = linearToSRGB(v)
+				} else {
+					dst.Pix[o+c] = uint8(math.Min(v*255.0+0.5, 255))
+				}
+			}
+			dst.Pix[o+3] = uint8(math.Min(a*255.0+0.5, 255))
+		}
+	}
+	return dst
+}
+
+// MipChain returns img and every level below it, down to 1x1.
+func MipChain(img image.Image, gammaCorrect bool) []*image.NRGBA {
+	levels := []*image.NRGBA{toNRGBA(img)}
+	for {
+		last := levels[len(levels)-1]
+		if last.Rect.Dx() == 1 && last.Rect.Dy() == 1 {
+			return levels
+		}
+		levels = append(levels, Downsample(last, gammaCorrect))
+	}
+}
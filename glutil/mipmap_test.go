@@ -0,0 +1,140 @@
+package glutil
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func solid(w, h int, c color.NRGBA) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.SetNRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+func row(cs ...color.NRGBA) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, len(cs), 1))
+	for x, c := range cs {
+		img.SetNRGBA(x, 0, c)
+	}
+	return img
+}
+
+func TestMipChainSizes(t *testing.T) {
+	tests := []struct {
+		w, h  int
+		sizes [][2]int
+	}{
+		{1, 1, [][2]int{{1, 1}}},
+		{4, 4, [][2]int{{4, 4}, {2, 2}, {1, 1}}},
+		{5, 3, [][2]int{{5, 3}, {2, 1}, {1, 1}}},
+		{1, 7, [][2]int{{1, 7}, {1, 3}, {1, 1}}},
+		{8, 2, [][2]int{{8, 2}, {4, 1}, {2, 1}, {1, 1}}},
+	}
+	for _, tt := range tests {
+		levels := MipChain(solid(tt.w, tt.h, color.NRGBA{1, 2, 3, 4}), true)
+		if len(levels) != len(tt.sizes) {
+			t.Errorf("%dx%d: %d levels, want %d", tt.w, tt.h, len(levels), len(tt.sizes))
+			continue
+		}
+		for i, l := range levels {
+			if l.Rect.Dx() != tt.sizes[i][0] || l.Rect.Dy() != tt.sizes[i][1] {
+				t.Errorf("%dx%d: level %d is %v, want %v", tt.w, tt.h, i, l.Rect.Size(), tt.sizes[i])
+			}
+		}
+	}
+
+	// Every power of two down to 1x1
+	if n := len(MipChain(solid(256, 64, color.NRGBA{}), true)); n != 9 {
+		t.Errorf("256x64: %d levels, want 9", n)
+	}
+}
+
+func TestMipChainSolid(t *testing.T) {
+	// A flat colour stays that colour all the way down, odd sizes and all.
+	c := color.NRGBA{200, 100, 50, 255}
+	for _, gamma := range []bool{true, false} {
+		for i, l := range MipChain(solid(13, 6, c), gamma) {
+			for y := 0; y < l.Rect.Dy(); y++ {
+				for x := 0; x < l.Rect.Dx(); x++ {
+					if got := l.NRGBAAt(x, y); got != c {
+						t.Fatalf("gamma %v, level %d (%d, %d): %v", gamma, i, x, y, got)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestDownsampleGamma(t *testing.T) {
+	black := color.NRGBA{0, 0, 0, 255}
+	white := color.NRGBA{255, 255, 255, 255}
+	bw := row(black, white)
+
+	// Half of white in linear light is 188 in sRGB, not 128.
+	if got := Downsample(bw, true).NRGBAAt(0, 0); got != (color.NRGBA{188, 188, 188, 255}) {
+		t.Errorf("gamma-correct: %v", got)
+	}
+	if got := Downsample(bw, false).NRGBAAt(0, 0); got != (color.NRGBA{128, 128, 128, 255}) {
+		t.Errorf("linear: %v", got)
+	}
+
+	// NonColor textures take the linear path.
+	opts := DefaultTextureOptions()
+	if got := opts.MipLevels(bw)[1].NRGBAAt(0, 0); got.R != 188 {
+		t.Errorf("colour texture: %v", got)
+	}
+	opts.NonColor = true
+	if got := opts.MipLevels(bw)[1].NRGBAAt(0, 0); got.R != 128 {
+		t.Errorf("NonColor texture: %v", got)
+	}
+	opts.Mipmaps = false
+	if n := len(opts.MipLevels(bw)); n != 1 {
+		t.Errorf("no mipmaps: %d levels", n)
+	}
+}
+
+func TestDownsampleOdd(t *testing.T) {
+	// 3 wide to 1: each source pixel covers a third, so the white in the
+	// middle isn't dropped.
+	black := color.NRGBA{0, 0, 0, 255}
+	white := color.NRGBA{255, 255, 255, 255}
+	if got := Downsample(row(black, white, black), false).NRGBAAt(0, 0); got != (color.NRGBA{85, 85, 85, 255}) {
+		t.Errorf("got %v", got)
+	}
+
+	// 5 to 2: the middle pixel is split between both.
+	got := Downsample(row(black, black, white, white, white), false)
+	if a, b := got.NRGBAAt(0, 0).R, got.NRGBAAt(1, 0).R; a != 51 || b != 255 {
+		t.Errorf("got %d and %d, want 51 and 255", a, b)
+	}
+}
+
+func TestDownsampleAlpha(t *testing.T) {
+	// A transparent pixel's colour mustn't bleed into its neighbours.
+	red := color.NRGBA{255, 0, 0, 255}
+	clear := color.NRGBA{0, 255, 0, 0}
+	for _, gamma := range []bool{true, false} {
+		got := Downsample(row(red, clear), gamma).NRGBAAt(0, 0)
+		if got != (color.NRGBA{255, 0, 0, 128}) {
+			t.Errorf("gamma %v: got %v", gamma, got)
+		}
+	}
+
+	// All transparent stays all transparent.
+	got := Downsample(row(clear, clear), true).NRGBAAt(0, 0)
+	if got.A != 0 {
+		t.Errorf("transparent: got %v", got)
+	}
+
+	// Half-transparent colours are weighted by their alpha.
+	half := color.NRGBA{0, 0, 255, 128}
+	got = Downsample(row(red, half), false).NRGBAAt(0, 0)
+	if got.A != 192 || got.R != 170 || got.B != 85 {
+		t.Errorf("weighted: got %v", got)
+	}
+}
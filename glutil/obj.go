@@ -0,0 +1,564 @@
+/*
+obj.go - a Wavefront OBJ and MTL loader.
+
+Faces may use any of the v, v/vt, v//vn and v/vt/vn forms, with positive or
+negative (relative) indices, and any number of sides - polygons are split
+into triangles by ear clipping.  Faces are collected into groups that change
+whenever o, g, s or usemtl does, and each group gets its own de-duplicated
+vertex list and index list, ready for a VBO and an IBO.
+
+Errors carry the file name and line number.
+*/
+package glutil
+
+import (
+	"bufio"
+	"fmt"
+	gl "github.com/chsc/gogl/gl33"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+type Material struct {
+	Name  string
+	Ka    Vec3     // ambient
+	Kd    Vec3     // diffuse
+	Ks    Vec3     // specular
+	Ns    gl.Float // specular exponent
+	D     gl.Float // dissolve - 1 is opaque
+	Illum int
+	MapKd string // diffuse texture, relative to the .mtl file's directory
+
+	// Any other map_ statements, keyed by statement.
+	Maps map[string]string
+}
+
+func NewMaterial(name string) *Material {
+	return &Material{
+		Name:  name,
+		Ka:    Vec3{0.2, 0.2, 0.2},
+		Kd:    Vec3{0.8, 0.8, 0.8},
+		Ks:    Vec3{1.0, 1.0, 1.0},
+		D:     1.0,
+		Illum: 2,
+		Maps:  make(map[string]string),
+	}
+}
+
+type OBJVertex struct {
+	Position Vec3
+	UV       Vec2
+	Normal   Vec3
+}
+
+// A run of faces sharing an object, group, smoothing group and material.
+type OBJGroup struct {
+	Object     string
+	Group      string
+	Smooth     int // 0 is off
+	Material   *Material
+	Vertices   []OBJVertex
+	Indices    []gl.Uint // triangles
+	HasUVs     bool
+	HasNormals bool
+
+	// (v, vt, vn) -> index into Vertices
+	seen map[[3]int]gl.Uint
+}
+
+type OBJModel struct {
+	Name      string
+	Positions []Vec3
+	UVs       []Vec2
+	Normals   []Vec3
+	Materials map[string]*Material
+	Groups    []*OBJGroup
+}
+
+// LoadOBJ reads an OBJ file, and any MTL files it names.
+func LoadOBJ(objFile string) (*OBJModel, error) {
+	fp, err := os.Open(objFile)
+	if err != nil {
+		return nil, err
+	}
+	defer fp.Close()
+	return ParseOBJ(fp, objFile)
+}
+
+// objLines feeds fn each statement in r, split into fields, with its line
+// number.  Comments and blank lines are dropped, and lines ending in a
+// backslash are joined to the next.
+func objLines(r io.Reader, fn func(line int, fields []string) error) error {
+	scanner := bufio.NewScanner(r)
+	line, start := 0, 0
+	var pending string
+	for scanner.Scan() {
+		line++
+		text := scanner.Text()
+		if pending == "" {
+			start = line
+		}
+		if strings.HasSuffix(text, "\\") {
+			pending += text[:len(text)-1] + " "
+			continue
+		}
+		text = pending + text
+		pending = ""
+		if i := strings.IndexByte(text, '#'); i >= 0 {
+			text = text[:i]
+		}
+		fields := strings.Fields(text)
+		if len(fields) == 0 {
+			continue
+		}
+		if err := fn(start, fields); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+func parseFloats(fields []string, min, max int) ([]gl.Float, error) {
+	if len(fields) < min {
+		return nil, fmt.Errorf("expected at least %d numbers, got %d", min, len(fields))
+	}
+	if len(fields) > max {
+		fields = fields[:max]
+	}
+	fs := make([]gl.Float, len(fields))
+	for i, f := range fields {
+		v, err := strconv.ParseFloat(f, 32)
+		if err != nil {
+			return nil, fmt.Errorf("bad number %q", f)
+		}
+		fs[i] = gl.Float(v)
+	}
+	return fs, nil
+}
+
+type objParser struct {
+	m    *OBJModel
+	file string
+
+	object, group string
+	smooth        int
+	material      *Material
+	cur           *OBJGroup
+}
+
+// ParseOBJ reads an OBJ model from r.  name is used for error messages and
+// to find MTL files, which are looked up next to it.
+func ParseOBJ(r io.Reader, name string) (*OBJModel, error) {
+	p := &objParser{
+		m:    &OBJModel{Name: name, Materials: make(map[string]*Material)},
+		file: name,
+	}
+	err := objLines(r, func(line int, fields []string) error {
+		if err := p.statement(fields); err != nil {
+			return fmt.Errorf("%s:%d: %s", name, line, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	// Drop groups that never got a face.
+	groups := p.m.Groups[:0]
+	for _, g := range p.m.Groups {
+		if len(g.Indices) > 0 {
+			g.seen = nil
+			groups = append(groups, g)
+		}
+	}
+	p.m.Groups = groups
+	return p.m, nil
+}
+
+func (p *objParser) statement(fields []string) error {
+	args := fields[1:]
+	switch fields[0] {
+	case "v":
+		f, err := parseFloats(args, 3, 3)
+		if err != nil {
+			return err
+		}
+		p.m.Positions = append(p.m.Positions, Vec3{f[0], f[1], f[2]})
+	case "vt":
+		f, err := parseFloats(args, 1, 2)
+		if err != nil {
+			return err
+		}
+		uv := Vec2{X: f[0]}
+		if len(f) > 1 {
+			uv.Y = f[1]
+		}
+		p.m.UVs = append(p.m.UVs, uv)
+	case "vn":
+		f, err := parseFloats(args, 3, 3)
+		if err != nil {
+			return err
+		}
+		p.m.Normals = append(p.m.Normals, Vec3{f[0], f[1], f[2]})
+	case "f":
+		return p.face(args)
+	case "o":
+		p.object = strings.Join(args, " ")
+		p.cur = nil
+	case "g":
+		p.group = strings.Join(args, " ")
+		p.cur = nil
+	case "s":
+		s := 0
+		if len(args) > 0 && args[0] != "off" {
+			n, err := strconv.Atoi(args[0])
+			if err != nil {
+				return fmt.Errorf("bad smoothing group %q", args[0])
+			}
+			s = n
+		}
+		if s != p.smooth {
+			p.smooth = s
+			p.cur = nil
+		}
+	case "usemtl":
+		if len(args) == 0 {
+			return fmt.Errorf("usemtl without a name")
+		}
+		name := strings.Join(args, " ")
+		mat, ok := p.m.Materials[name]
+		if !ok {
+			// Not defined (yet, or the .mtl is missing) - use the defaults.
+			mat = NewMaterial(name)
+			p.m.Materials[name] = mat
+		}
+		p.material = mat
+		p.cur = nil
+	case "mtllib":
+		for _, lib := range args {
+			path := filepath.Join(filepath.Dir(p.file), lib)
+			mats, err := LoadMTL(path)
+			if err != nil {
+				// Not fatal: usemtl falls back on the default materials.
+				fmt.Fprintf(os.Stderr, "%s: %s, using default materials\n", p.file, err)
+				continue
+			}
+			for name, mat := range mats {
+				// Fill in place, in case usemtl got there first.
+				if old, ok := p.m.Materials[name]; ok {
+					*old = *mat
+				} else {
+					p.m.Materials[name] = mat
+				}
+			}
+		}
+	default:
+		// l, p, curves and surfaces - nothing we can draw.
+	}
+	return nil
+}
+
+// groupFor returns the group faces currently go in, starting a new one if
+// anything has changed since the last face.
+func (p *objParser) groupFor() *OBJGroup {
+	if p.cur == nil {
+		p.cur = &OBJGroup{
+			Object:   p.object,
+			Group:    p.group,
+			Smooth:   p.smooth,
+			Material: p.material,
+			seen:     make(map[[3]int]gl.Uint),
+		}
+		p.m.Groups = append(p.m.Groups, p.cur)
+	}
+	return p.cur
+}
+
+// resolve turns a 1-based or negative OBJ index into a 0-based one.
+func resolve(s string, count int, what string) (int, error) {
+	if s == "" {
+		return -1, nil
+	}
+	i, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("bad %s index %q", what, s)
+	}
+	switch {
+	case i > 0 && i <= count:
+		return i - 1, nil
+	case i < 0 && -i <= count:
+		return count + i, nil
+	}
+	return 0, fmt.Errorf("%s index %d out of range (have %d)", what, i, count)
+}
+
+func (p *objParser) face(args []string) error {
+	if len(args) < 3 {
+		return fmt.Errorf("face needs at least 3 vertices, got %d", len(args))
+	}
+	g := p.groupFor()
+	idx := make([]gl.Uint, len(args))
+	pos := make([]Vec3, len(args))
+	for i, a := range args {
+		parts := strings.Split(a, "/")
+		if len(parts) > 3 || parts[0] == "" {
+			return fmt.Errorf("bad face vertex %q", a)
+		}
+		for len(parts) < 3 {
+			parts = append(parts, "")
+		}
+		var key [3]int
+		var err error
+		if key[0], err = resolve(parts[0], len(p.m.Positions), "vertex"); err != nil {
+			return err
+		}
+		if key[1], err = resolve(parts[1], len(p.m.UVs), "texture"); err != nil {
+			return err
+		}
+		if key[2], err = resolve(parts[2], len(p.m.Normals), "normal"); err != nil {
+			return err
+		}
+		pos[i] = p.m.Positions[key[0]]
+		n, ok := g.seen[key]
+		if !ok {
+			v := OBJVertex{Position: pos[i]}
+			if key[1] >= 0 {
+				v.UV = p.m.UVs[key[1]]
+				g.HasUVs = true
+			}
+			if key[2] >= 0 {
+				v.Normal = p.m.Normals[key[2]]
+				g.HasNormals = true
+			}
+			n = gl.Uint(len(g.Vertices))
+			g.Vertices = append(g.Vertices, v)
+			g.seen[key] = n
+		}
+		idx[i] = n
+	}
+	for _, tri := range Triangulate(pos) {
+		g.Indices = append(g.Indices, idx[tri[0]], idx[tri[1]], idx[tri[2]])
+	}
+	return nil
+}
+
+// Triangulate splits a simple polygon, convex or not, into triangles by
+// ear clipping, returning indices into poly.  The polygon is projected onto
+// its own plane first.  Degenerate input falls back to a fan.
+func Triangulate(poly []Vec3) [][3]int {
+	n := len(poly)
+	if n == 3 {
+		return [][3]int{{0, 1, 2}}
+	}
+	fan := func() [][3]int {
+		var tris [][3]int
+		for i := 1; i+1 < n; i++ {
+			tris = append(tris, [3]int{0, i, i + 1})
+		}
+		return tris
+	}
+
+	// Newell's method for the normal, then drop its largest axis.
+	var normal Vec3
+	for i := range poly {
+		a, b := poly[i], poly[(i+1)%n]
+		normal.X += (a.Y - b.Y) * (a.Z + b.Z)
+		normal.Y += (a.Z - b.Z) * (a.X + b.X)
+		normal.Z += (a.X - b.X) * (a.Y + b.Y)
+	}
+	ax, ay, az := AbsGL(normal.X), AbsGL(normal.Y), AbsGL(normal.Z)
+	pts := make([]Vec2, n)
+	sign := gl.Float(1.0)
+	for i, v := range poly {
+		switch {
+		case ax >= ay && ax >= az:
+			pts[i] = Vec2{v.Y, v.Z}
+			if normal.X < 0 {
+				sign = -1
+			}
+		case ay >= az:
+			pts[i] = Vec2{v.Z, v.X}
+			if normal.Y < 0 {
+				sign = -1
+			}
+		default:
+			pts[i] = Vec2{v.X, v.Y}
+			if normal.Z < 0 {
+				sign = -1
+			}
+		}
+	}
+	if ax == 0 && ay == 0 && az == 0 {
+		return fan()
+	}
+	cross := func(o, a, b Vec2) gl.Float {
+		return sign * ((a.X-o.X)*(b.Y-o.Y) - (a.Y-o.Y)*(b.X-o.X))
+	}
+	inside := func(p, a, b, c Vec2) bool {
+		return cross(a, b, p) >= 0 && cross(b, c, p) >= 0 && cross(c, a, p) >= 0
+	}
+
+	remaining := make([]int, n)
+	for i := range remaining {
+		remaining[i] = i
+	}
+	var tris [][3]int
+	for len(remaining) > 3 {
+		found := false
+		for i := range remaining {
+			prev := remaining[(i+len(remaining)-1)%len(remaining)]
+			cur := remaining[i]
+			next := remaining[(i+1)%len(remaining)]
+			if cross(pts[prev], pts[cur], pts[next]) <= 0 {
+				continue // reflex
+			}
+			ear := true
+			for _, j := range remaining {
+				if j != prev && j != cur && j != next && inside(pts[j], pts[prev], pts[cur], pts[next]) {
+					ear = false
+					break
+				}
+			}
+			if ear {
+				tris = append(tris, [3]int{prev, cur, next})
+				remaining = append(remaining[:i], remaining[i+1:]...)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fan()
+		}
+	}
+	return append(tris, [3]int{remaining[0], remaining[1], remaining[2]})
+}
+
+// LoadMTL reads the materials in an MTL file.
+func LoadMTL(mtlFile string) (map[string]*Material, error) {
+	fp, err := os.Open(mtlFile)
+	if err != nil {
+		return nil, err
+	}
+	defer fp.Close()
+	return ParseMTL(fp, mtlFile)
+}
+
+func ParseMTL(r io.Reader, name string) (map[string]*Material, error) {
+	mats := make(map[string]*Material)
+	var cur *Material
+	err := objLines(r, func(line int, fields []string) error {
+		fail := func(format string, args ...interface{}) error {
+			return fmt.Errorf("%s:%d: %s", name, line, fmt.Sprintf(format, args...))
+		}
+		args := fields[1:]
+		if fields[0] == "newmtl" {
+			if len(args) == 0 {
+				return fail("newmtl without a name")
+			}
+			cur = NewMaterial(strings.Join(args, " "))
+			mats[cur.Name] = cur
+			return nil
+		}
+		if cur == nil {
+			return fail("%s before newmtl", fields[0])
+		}
+		color := func(dst *Vec3) error {
+			if len(args) > 0 && (args[0] == "spectral" || args[0] == "xyz") {
+				return nil // not worth supporting
+			}
+			f, err := parseFloats(args, 1, 3)
+			if err != nil {
+				return fail("%s: %s", fields[0], err)
+			}
+			// A single value means grey.
+			for len(f) < 3 {
+				f = append(f, f[0])
+			}
+			*dst = Vec3{f[0], f[1], f[2]}
+			return nil
+		}
+		scalar := func(dst *gl.Float) error {
+			f, err := parseFloats(args, 1, 1)
+			if err != nil {
+				return fail("%s: %s", fields[0], err)
+			}
+			*dst = f[0]
+			return nil
+		}
+		switch key := fields[0]; {
+		case key == "Ka":
+			return color(&cur.Ka)
+		case key == "Kd":
+			return color(&cur.Kd)
+		case key == "Ks":
+			return color(&cur.Ks)
+		case key == "Ns":
+			return scalar(&cur.Ns)
+		case key == "d":
+			// "d -halo 0.5" - skip the option
+			if len(args) > 1 && args[0] == "-halo" {
+				args = args[1:]
+			}
+			return scalar(&cur.D)
+		case key == "Tr":
+			var tr gl.Float
+			if err := scalar(&tr); err != nil {
+				return err
+			}
+			cur.D = 1.0 - tr
+		case key == "illum":
+			if len(args) == 0 {
+				return fail("illum without a value")
+			}
+			n, err := strconv.Atoi(args[0])
+			if err != nil {
+				return fail("bad illum %q", args[0])
+			}
+			cur.Illum = n
+		case strings.HasPrefix(key, "map_") || key == "bump" || key == "disp" || key == "decal":
+			if len(args) == 0 {
+				return fail("%s without a file", key)
+			}
+			// Options come first (-s 1 1 1, -clamp on...); the file is last.
+			file := args[len(args)-1]
+			if key == "map_Kd" {
+				cur.MapKd = file
+			} else {
+				cur.Maps[key] = file
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return mats, nil
+}
+
+// Triangles flattens the model into unindexed triangle lists - positions
+// as x, y, z, 1, uvs as u, v and normals as x, y, z - for drawing with
+// glDrawArrays.
+func (m *OBJModel) Triangles() (positions, uvs, normals []gl.Float) {
+	for _, g := range m.Groups {
+		for _, i := range g.Indices {
+			v := g.Vertices[i]
+			positions = append(positions, v.Position.X, v.Position.Y, v.Position.Z, 1.0)
+			uvs = append(uvs, v.UV.X, v.UV.Y)
+			normals = append(normals, v.Normal.X, v.Normal.Y, v.Normal.Z)
+		}
+	}
+	return positions, uvs, normals
+}
+
+// Interleaved returns the group's vertices as x, y, z, u, v, nx, ny, nz,
+// ready for one VBO.
+func (g *OBJGroup) Interleaved() []gl.Float {
+	data := make([]gl.Float, 0, len(g.Vertices)*8)
+	for _, v := range g.Vertices {
+		data = append(data, v.Position.X, v.Position.Y, v.Position.Z,
+			v.UV.X, v.UV.Y, v.Normal.X, v.Normal.Y, v.Normal.Z)
+	}
+	return data
+}
@@ -0,0 +1,282 @@
+package glutil
+
+import (
+	gl "github.com/chsc/gogl/gl33"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseOBJMissingMTL(t *testing.T) {
+	// The .mtl isn't there, so usemtl gets the default material.
+	src := `mtllib nothere.mtl
+v 0 0 0
+v 1 0 0
+v 0 1 0
+usemtl shiny
+f 1 2 3
+`
+	m, err := ParseOBJ(strings.NewReader(src), "testdata/missing.obj")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Groups) != 1 {
+		t.Fatalf("%d groups", len(m.Groups))
+	}
+	mat := m.Groups[0].Material
+	if mat == nil || mat.Name != "shiny" || mat.Kd != NewMaterial("shiny").Kd {
+		t.Errorf("material %+v", mat)
+	}
+}
+
+func TestLoadOBJWithMTL(t *testing.T) {
+	m, err := LoadOBJ("../art/texturecube.obj")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Materials) != 2 {
+		t.Fatalf("%d materials from texturecube.mtl, want 2", len(m.Materials))
+	}
+	mat := m.Materials["Material"]
+	want := Material{Name: "Material", Ka: Vec3{}, Kd: Vec3{X: 0.64, Y: 0.64, Z: 0.64}, Ks: Vec3{X: 0.5, Y: 0.5, Z: 0.5},
+		Ns: 96.078431, D: 1, Illum: 2, MapKd: "abcdef_texture.tga", Maps: map[string]string{}}
+	if mat == nil || !reflect.DeepEqual(*mat, want) {
+		t.Errorf("Material is %+v, want %+v", mat, want)
+	}
+	if none := m.Materials["Material_NONE"]; none == nil || none.MapKd != "" || none.Kd != want.Kd {
+		t.Errorf("Material_NONE is %+v", none)
+	}
+
+	// Five faces in Material, the last in Material_NONE.  Every corner has
+	// its own UV, so nothing is shared.
+	tests := []struct {
+		material          string
+		vertices, indices int
+	}{
+		{"Material", 20, 30},
+		{"Material_NONE", 4, 6},
+	}
+	if len(m.Groups) != len(tests) {
+		t.Fatalf("%d groups", len(m.Groups))
+	}
+	for i, tt := range tests {
+		g := m.Groups[i]
+		if g.Object != "Cube" || g.Material != m.Materials[tt.material] || g.Smooth != 0 {
+			t.Errorf("group %d: %s, material %s, smooth %d", i, g.Object, g.Material.Name, g.Smooth)
+		}
+		if len(g.Vertices) != tt.vertices || len(g.Indices) != tt.indices || !g.HasUVs || g.HasNormals {
+			t.Errorf("%s: %d vertices, %d indices, uvs %v, normals %v", tt.material,
+				len(g.Vertices), len(g.Indices), g.HasUVs, g.HasNormals)
+		}
+	}
+}
+
+func parseOBJString(t *testing.T, src string) *OBJModel {
+	m, err := ParseOBJ(strings.NewReader(src), "x.obj")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestParseOBJNegativeIndices(t *testing.T) {
+	square := "v 0 0 0\nv 1 0 0\nv 1 1 0\nv 0 1 0\nvt 0 0\nvt 1 0\nvt 1 1\nvt 0 1\nvn 0 0 1\n"
+	abs := parseOBJString(t, square+"f 1/1/1 2/2/1 3/3/1 4/4/1\n")
+	rel := parseOBJString(t, square+"f -4/-4/-1 -3/-3/-1 -2/-2/-1 -1/-1/-1\n")
+	if !reflect.DeepEqual(abs.Groups, rel.Groups) {
+		t.Errorf("relative %+v, absolute %+v", rel.Groups[0], abs.Groups[0])
+	}
+
+	// Relative to the vertices so far, not the whole file.
+	m := parseOBJString(t, "v 0 0 0\nv 1 0 0\nv 0 1 0\nf -3 -2 -1\nv 5 5 5\nf -4 -3 -2\nv 0 0 9\nf 1//  2 -1\n")
+	got := m.Groups[0].Vertices
+	if len(got) != 4 || got[3].Position != (Vec3{Z: 9}) || len(m.Groups[0].Indices) != 9 {
+		t.Errorf("vertices %v", got)
+	}
+	if _, err := ParseOBJ(strings.NewReader("v 0 0 0\nv 1 0 0\nv 0 1 0\nf -4 -3 -2\n"), "x.obj"); err == nil ||
+		err.Error() != "x.obj:4: vertex index -4 out of range (have 3)" {
+		t.Errorf("-4 of 3: %v", err)
+	}
+}
+
+func TestParseOBJGroups(t *testing.T) {
+	m := parseOBJString(t, `v 0 0 0
+v 1 0 0
+v 1 1 0
+v 0 1 0
+vt 0 0
+vt 1 1
+o box
+g lid
+usemtl red
+f 1 2 3
+f 1 3 4
+s 1
+f 1 2 3
+s 1
+f 1 3 4
+usemtl blue
+g side
+usemtl green
+f 1/1 2 3
+f 1/2 2 3
+o unused
+g nothing
+`)
+	want := []struct {
+		object, group, material string
+		smooth                  int
+		vertices, indices       int
+	}{
+		// The two faces share an edge, so 4 vertices, not 6.
+		{"box", "lid", "red", 0, 4, 6},
+		// A repeated s changes nothing; a new group starts its vertices
+		// afresh.
+		{"box", "lid", "red", 1, 4, 6},
+		// blue never gets a face.  The same position with another UV is
+		// another vertex.
+		{"box", "side", "green", 1, 4, 6},
+	}
+	if len(m.Groups) != len(want) {
+		t.Fatalf("%d groups, want %d", len(m.Groups), len(want))
+	}
+	for i, w := range want {
+		g := m.Groups[i]
+		if g.Object != w.object || g.Group != w.group || g.Material.Name != w.material || g.Smooth != w.smooth ||
+			len(g.Vertices) != w.vertices || len(g.Indices) != w.indices {
+			t.Errorf("group %d: %s/%s %s s %d, %d vertices, %d indices, want %+v", i, g.Object, g.Group,
+				g.Material.Name, g.Smooth, len(g.Vertices), len(g.Indices), w)
+		}
+	}
+	if m.Groups[2].Vertices[0].UV != (Vec2{}) || m.Groups[2].Vertices[3].UV != (Vec2{X: 1, Y: 1}) || !m.Groups[2].HasUVs {
+		t.Errorf("side vertices %v", m.Groups[2].Vertices)
+	}
+	if len(m.Materials) != 3 {
+		t.Errorf("%d materials", len(m.Materials))
+	}
+}
+
+func TestTriangulate(t *testing.T) {
+	// An L, starting at the corner that can't see across the notch, so a
+	// fan from vertex 0 would go outside it.
+	l := []Vec2{{2, 1}, {1, 1}, {1, 2}, {0, 2}, {0, 0}, {2, 0}}
+	area := func(a, b, c Vec2) gl.Float {
+		return ((b.X-a.X)*(c.Y-a.Y) - (b.Y-a.Y)*(c.X-a.X)) / 2
+	}
+	tests := []struct {
+		name  string
+		embed func(p Vec2) Vec3
+		flip  bool // the winding is seen from behind
+	}{
+		{"z", func(p Vec2) Vec3 { return Vec3{X: p.X, Y: p.Y, Z: 1} }, false},
+		{"-z", func(p Vec2) Vec3 { return Vec3{X: p.Y, Y: p.X, Z: -3} }, true},
+		{"x", func(p Vec2) Vec3 { return Vec3{X: 5, Y: p.X, Z: p.Y} }, false},
+		{"tilted", func(p Vec2) Vec3 { return Vec3{X: p.X, Y: p.Y, Z: p.X + 2*p.Y} }, false},
+	}
+	for _, tt := range tests {
+		poly := make([]Vec3, len(l))
+		for i, p := range l {
+			poly[i] = tt.embed(p)
+		}
+		tris := Triangulate(poly)
+		if len(tris) != len(l)-2 {
+			t.Errorf("%s: %d triangles", tt.name, len(tris))
+			continue
+		}
+		// Every triangle wound the polygon's way, and between them
+		// covering its area of 3, means none of them is outside it.
+		var total gl.Float
+		for _, tri := range tris {
+			a := area(l[tri[0]], l[tri[1]], l[tri[2]])
+			if a <= 0 {
+				t.Errorf("%s: triangle %v is wound backwards or flat", tt.name, tri)
+			}
+			total += a
+		}
+		if AbsGL(total-3) > 1e-5 {
+			t.Errorf("%s: triangles cover %g", tt.name, total)
+		}
+	}
+
+	// Through ParseOBJ, as an n-gon face.
+	m := parseOBJString(t, "v 2 1 0\nv 1 1 0\nv 1 2 0\nv 0 2 0\nv 0 0 0\nv 2 0 0\nf 1 2 3 4 5 6\n")
+	if got := len(m.Groups[0].Indices); got != 12 {
+		t.Errorf("hexagon: %d indices", got)
+	}
+	// Collinear points fall back to a fan.
+	if got := Triangulate([]Vec3{{}, {X: 1}, {X: 2}, {X: 3}}); len(got) != 2 {
+		t.Errorf("a line: %v", got)
+	}
+}
+
+func TestParseOBJErrors(t *testing.T) {
+	// Eleven good lines first, with a comment, a blank line and a line
+	// continued with a backslash.
+	preamble := "# a triangle\nv 0 0 0\nv 1 0 0\nv 0 1 \\\n  0\n\nvt 0 0\nvn 0 0 1\no tri\ng tri\ns off\n"
+	tests := []struct {
+		line string
+		want string
+	}{
+		{"f 1 2 0", "x.obj:12: vertex index 0 out of range (have 3)"},
+		{"f 1 2 4", "x.obj:12: vertex index 4 out of range (have 3)"},
+		{"f 1/2 2 3", "x.obj:12: texture index 2 out of range (have 1)"},
+		{"f 1//2 2 3", "x.obj:12: normal index 2 out of range (have 1)"},
+		{"f 1/x 2 3", `x.obj:12: bad texture index "x"`},
+		{"f 1/1/1/1 2 3", `x.obj:12: bad face vertex "1/1/1/1"`},
+		{"f /1 2 3", `x.obj:12: bad face vertex "/1"`},
+		{"f 1 2", "x.obj:12: face needs at least 3 vertices, got 2"},
+		{"v 1 2", "x.obj:12: expected at least 3 numbers, got 2"},
+		{"vn 1 2 z", `x.obj:12: bad number "z"`},
+		{"s smooth", `x.obj:12: bad smoothing group "smooth"`},
+		{"usemtl", "x.obj:12: usemtl without a name"},
+		// A continued line is reported where it starts.
+		{"f 1 \\\n 2 \\\n 9", "x.obj:12: vertex index 9 out of range (have 3)"},
+	}
+	for _, tt := range tests {
+		_, err := ParseOBJ(strings.NewReader(preamble+tt.line+"\n"), "x.obj")
+		if err == nil || err.Error() != tt.want {
+			t.Errorf("%q: %v, want %s", tt.line, err, tt.want)
+		}
+	}
+	if m := parseOBJString(t, preamble+"f 1/1/1 2//1 3\nl 1 2\ncurv 0 1 1 2\n"); len(m.Groups[0].Indices) != 3 {
+		t.Errorf("the preamble doesn't parse")
+	}
+}
+
+func TestParseMTL(t *testing.T) {
+	mats, err := ParseMTL(strings.NewReader(`newmtl glass
+Kd 0.5
+Ks 0.1 0.2 0.3
+Tr 0.25
+map_Kd -s 2 2 1 -clamp on glass diffuse.png
+map_Bump bump.png
+bump -bm 2 other.png
+newmtl halo
+d -halo 0.5
+Ka spectral ident.rfl
+`), "x.mtl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := mats["glass"]
+	if g.Kd != (Vec3{X: 0.5, Y: 0.5, Z: 0.5}) || g.Ks != (Vec3{X: 0.1, Y: 0.2, Z: 0.3}) || g.D != 0.75 ||
+		g.MapKd != "diffuse.png" || g.Maps["map_Bump"] != "bump.png" || g.Maps["bump"] != "other.png" {
+		t.Errorf("glass %+v", g)
+	}
+	if h := mats["halo"]; h.D != 0.5 || h.Ka != NewMaterial("").Ka {
+		t.Errorf("halo %+v", h)
+	}
+
+	tests := []struct{ src, want string }{
+		{"Kd 1 1 1\n", "x.mtl:1: Kd before newmtl"},
+		{"newmtl\n", "x.mtl:1: newmtl without a name"},
+		{"newmtl a\n\nKd red\n", `x.mtl:3: Kd: bad number "red"`},
+		{"newmtl a\nillum two\n", `x.mtl:2: bad illum "two"`},
+		{"newmtl a\nmap_Kd\n", "x.mtl:2: map_Kd without a file"},
+	}
+	for _, tt := range tests {
+		if _, err := ParseMTL(strings.NewReader(tt.src), "x.mtl"); err == nil || err.Error() != tt.want {
+			t.Errorf("%q: %v, want %s", tt.src, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,233 @@
+/*
+objwrite.go - Meshes out to OBJ and MTL, and OBJ models into Meshes, so OBJ
+can go anywhere the other formats can.
+
+Every vertex of a mesh is written once, with its texture coordinate and
+normal at the same index, so faces read "f 3/3/3 ...".  Strips and fans are
+written as the triangles they draw; lines and points as l and p, which
+LoadOBJ skips.  Only positions, texture coordinates and normals fit in an
+OBJ - colours and the rest are dropped.
+*/
+package glutil
+
+import (
+	"bufio"
+	"fmt"
+	gl "github.com/chsc/gogl/gl33"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// SaveOBJ writes meshes to objFile, and their materials to an .mtl file of
+// the same name beside it.  mats may be nil.
+func SaveOBJ(objFile string, meshes []*Mesh, mats map[string]*Material) error {
+	mtlFile := ""
+	if len(mats) > 0 {
+		mtlFile = strings.TrimSuffix(objFile, filepath.Ext(objFile)) + ".mtl"
+		fp, err := os.Create(mtlFile)
+		if err != nil {
+			return err
+		}
+		if err := WriteMTL(fp, mats); err != nil {
+			fp.Close()
+			return err
+		}
+		if err := fp.Close(); err != nil {
+			return err
+		}
+		mtlFile = filepath.Base(mtlFile)
+	}
+	fp, err := os.Create(objFile)
+	if err != nil {
+		return err
+	}
+	if err := WriteOBJ(fp, meshes, mtlFile); err != nil {
+		fp.Close()
+		return fmt.Errorf("%s: %s", objFile, err)
+	}
+	return fp.Close()
+}
+
+// WriteOBJ writes meshes to w, one o statement each.  mtlFile, if not
+// empty, is named in an mtllib statement.
+func WriteOBJ(w io.Writer, meshes []*Mesh, mtlFile string) error {
+	bw := bufio.NewWriter(w)
+	if mtlFile != "" {
+		fmt.Fprintf(bw, "mtllib %s\n", mtlFile)
+	}
+	base := 1 // OBJ indices count from 1, across the whole file
+	for _, m := range meshes {
+		if err := m.Validate(); err != nil {
+			return fmt.Errorf("mesh %s: %s", m.Name, err)
+		}
+		pos := m.Attrib(AttribPosition)
+		if pos == nil {
+			return fmt.Errorf("mesh %s has no positions", m.Name)
+		}
+		uv := m.Attrib(AttribTexCoord)
+		norm := m.Attrib(AttribNormal)
+
+		fmt.Fprintf(bw, "o %s\n", m.Name)
+		objVectors(bw, "v", pos, 3)
+		objVectors(bw, "vt", uv, 2)
+		objVectors(bw, "vn", norm, 3)
+
+		vertex := func(v int) string {
+			i := base + v
+			switch {
+			case uv != nil && norm != nil:
+				return fmt.Sprintf("%d/%d/%d", i, i, i)
+			case uv != nil:
+				return fmt.Sprintf("%d/%d", i, i)
+			case norm != nil:
+				return fmt.Sprintf("%d//%d", i, i)
+			}
+			return fmt.Sprint(i)
+		}
+		material := ""
+		for _, c := range m.Commands {
+			if c.Material != "" && c.Material != material {
+				fmt.Fprintf(bw, "usemtl %s\n", c.Material)
+				material = c.Material
+			}
+			switch c.Primitive {
+			case gl.TRIANGLES, gl.TRIANGLE_STRIP, gl.TRIANGLE_FAN:
+				for _, t := range c.Triangles() {
+					fmt.Fprintf(bw, "f %s %s %s\n", vertex(t[0]), vertex(t[1]), vertex(t[2]))
+				}
+			case gl.LINES:
+				vs := c.Vertices()
+				for i := 0; i+1 < len(vs); i += 2 {
+					fmt.Fprintf(bw, "l %d %d\n", base+vs[i], base+vs[i+1])
+				}
+			case gl.LINE_STRIP, gl.LINE_LOOP:
+				vs := c.Vertices()
+				if c.Primitive == gl.LINE_LOOP {
+					vs = append(vs, vs[0])
+				}
+				fmt.Fprintf(bw, "l")
+				for _, v := range vs {
+					fmt.Fprintf(bw, " %d", base+v)
+				}
+				fmt.Fprintf(bw, "\n")
+			case gl.POINTS:
+				fmt.Fprintf(bw, "p")
+				for _, v := range c.Vertices() {
+					fmt.Fprintf(bw, " %d", base+v)
+				}
+				fmt.Fprintf(bw, "\n")
+			}
+		}
+		base += m.VertexCount
+	}
+	return bw.Flush()
+}
+
+// objVectors writes a line of the first n components of each of a's
+// vertices.  a may be nil.
+func objVectors(w io.Writer, statement string, a *MeshAttrib, n int) {
+	if a == nil {
+		return
+	}
+	for v := 0; v < a.VertexCount(); v++ {
+		fmt.Fprintf(w, "%s", statement)
+		for i := 0; i < n; i++ {
+			f := 0.0
+			if i < a.Size {
+				f = a.Data[v*a.Size+i]
+			}
+			fmt.Fprintf(w, " %s", MeshFloat(f))
+		}
+		fmt.Fprintf(w, "\n")
+	}
+}
+
+// WriteMTL writes materials to w, sorted by name.
+func WriteMTL(w io.Writer, mats map[string]*Material) error {
+	bw := bufio.NewWriter(w)
+	names := make([]string, 0, len(mats))
+	for name := range mats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for i, name := range names {
+		m := mats[name]
+		if i > 0 {
+			fmt.Fprintf(bw, "\n")
+		}
+		fmt.Fprintf(bw, "newmtl %s\n", name)
+		for _, c := range []struct {
+			key string
+			v   Vec3
+		}{{"Ka", m.Ka}, {"Kd", m.Kd}, {"Ks", m.Ks}} {
+			fmt.Fprintf(bw, "%s %s %s %s\n", c.key,
+				MeshFloat(float64(c.v.X)), MeshFloat(float64(c.v.Y)), MeshFloat(float64(c.v.Z)))
+		}
+		fmt.Fprintf(bw, "Ns %s\n", MeshFloat(float64(m.Ns)))
+		fmt.Fprintf(bw, "d %s\n", MeshFloat(float64(m.D)))
+		fmt.Fprintf(bw, "illum %d\n", m.Illum)
+		if m.MapKd != "" {
+			fmt.Fprintf(bw, "map_Kd %s\n", m.MapKd)
+		}
+		keys := make([]string, 0, len(m.Maps))
+		for key := range m.Maps {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			fmt.Fprintf(bw, "%s %s\n", key, m.Maps[key])
+		}
+	}
+	return bw.Flush()
+}
+
+// Meshes turns each group of the model into a Mesh of indexed triangles,
+// with positions, and texture coordinates and normals if the group has
+// them.  Meshes are named after the group's object, or group, or failing
+// both the model.
+func (m *OBJModel) Meshes() ([]*Mesh, error) {
+	var meshes []*Mesh
+	for _, g := range m.Groups {
+		name := g.Object
+		if name == "" {
+			name = g.Group
+		}
+		if name == "" {
+			name = strings.TrimSuffix(filepath.Base(m.Name), filepath.Ext(m.Name))
+		}
+		mesh := NewMesh(name)
+		pos := &MeshAttrib{Index: AttribPosition, Type: "float", Size: 3}
+		uv := &MeshAttrib{Index: AttribTexCoord, Type: "float", Size: 2}
+		norm := &MeshAttrib{Index: AttribNormal, Type: "float", Size: 3}
+		for _, v := range g.Vertices {
+			pos.Data = append(pos.Data, float64(v.Position.X), float64(v.Position.Y), float64(v.Position.Z))
+			uv.Data = append(uv.Data, float64(v.UV.X), float64(v.UV.Y))
+			norm.Data = append(norm.Data, float64(v.Normal.X), float64(v.Normal.Y), float64(v.Normal.Z))
+		}
+		mesh.Attribs = append(mesh.Attribs, pos)
+		if g.HasNormals {
+			mesh.Attribs = append(mesh.Attribs, norm)
+		}
+		if g.HasUVs {
+			mesh.Attribs = append(mesh.Attribs, uv)
+		}
+
+		cmd := &RenderCmd{Cmd: "triangles", Indexed: true, IndexType: "ushort", Count: len(g.Indices)}
+		if len(g.Vertices) > 0xffff {
+			cmd.IndexType = "uint"
+		}
+		cmd.Indices = append([]gl.Uint(nil), g.Indices...)
+		if g.Material != nil {
+			cmd.Material = g.Material.Name
+		}
+		mesh.Commands = append(mesh.Commands, cmd)
+		if err := mesh.Validate(); err != nil {
+			return nil, fmt.Errorf("%s: group %s: %s", m.Name, name, err)
+		}
+		meshes = append(meshes, mesh)
+	}
+	return meshes, nil
+}
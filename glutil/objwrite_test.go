@@ -0,0 +1,42 @@
+package glutil
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// triangleSoup is every triangle's corner positions, which is what
+// survives a trip through OBJ.
+func triangleSoup(meshes []*Mesh) []float64 {
+	var soup []float64
+	for _, m := range meshes {
+		pos := m.Attrib(AttribPosition)
+		for _, t := range m.Triangles() {
+			for _, v := range t {
+				soup = append(soup, pos.Data[v*pos.Size:v*pos.Size+3]...)
+			}
+		}
+	}
+	return soup
+}
+
+func TestSaveOBJRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	for _, file := range roundTripFiles(t) {
+		s, mats := loadAnyScene(t, file)
+		out := filepath.Join(dir, strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))+".obj")
+		objMats := make(map[string]*Material)
+		for name, m := range mats {
+			objMats[name] = m.OBJMaterial(dir)
+		}
+		if err := SaveOBJ(out, s.FlatMeshes(), objMats); err != nil {
+			t.Errorf("%s: %s", file, err)
+			continue
+		}
+		back, _ := loadAnyScene(t, out)
+		if !sameFloats(triangleSoup(s.FlatMeshes()), triangleSoup(back.FlatMeshes())) {
+			t.Errorf("%s: the triangles have changed", file)
+		}
+	}
+}
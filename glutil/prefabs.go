@@ -0,0 +1,147 @@
+/*
+prefabs.go - the prefabs layouts can place without defining: the trees,
+columns and Parthenon of the gltut World Scene tutorial.
+
+They're made of the world_tut meshes, which the layout has to name cube
+(UnitCubeColor.xml, with its "color" VAO), cubeTint, cylinder and cone, and
+drawn with the UniformColorTint and ObjectColor programs.
+*/
+package glutil
+
+import (
+	gl "github.com/chsc/gogl/gl33"
+)
+
+// The prefabs every layout has.  Add to it before loading to have more.
+var Prefabs = map[string]*Prefab{
+	// 3x3 in X/Z, trunkHeight+coneHeight in Y
+	"tree": {
+		Params: map[string]gl.Float{"trunkHeight": 2.0, "coneHeight": 3.0},
+		Build:  buildTree,
+	},
+	// 1x1 in X/Z, height in Y
+	"column": {
+		Params: map[string]gl.Float{"height": 5.0, "baseHeight": 0.25},
+		Build:  buildColumn,
+	},
+	"parthenon": {
+		Params: map[string]gl.Float{"width": 14.0, "length": 20.0,
+			"columnHeight": 5.0, "baseHeight": 1.0, "topHeight": 2.0},
+		Build: buildParthenon,
+	},
+}
+
+func buildTree(l *Layout, ms *MatrixStack, p map[string]gl.Float) error {
+	fTrunkHeight, fConeHeight := p["trunkHeight"], p["coneHeight"]
+
+	// The trunk
+	ms.Push()
+	ms.Scale(&Vec4{1.0, fTrunkHeight, 1.0, 1.0})
+	ms.Translate(&Vec4{0.0, 0.5, 0.0, 0.0})
+	l.Add(ms, "cylinder", "", "UniformColorTint", &Vec4{0.694, 0.4, 0.106, 1.0})
+	ms.Pop()
+
+	// The treetop
+	ms.Push()
+	ms.Translate(&Vec4{0.0, fTrunkHeight, 0.0, 0.0})
+	ms.Scale(&Vec4{3.0, fConeHeight, 3.0, 1.0})
+	l.Add(ms, "cone", "", "UniformColorTint", &Vec4{0.0, 1.0, 0.0, 1.0})
+	ms.Pop()
+	return nil
+}
+
+func buildColumn(l *Layout, ms *MatrixStack, p map[string]gl.Float) error {
+	fHeight, fBaseHeight := p["height"], p["baseHeight"]
+
+	// The bottom
+	ms.Push()
+	ms.Scale(&Vec4{1.0, fBaseHeight, 1.0, 1.0})
+	ms.Translate(&Vec4{0.0, 0.5, 0.0, 0.0})
+	l.Add(ms, "cube", "color", "UniformColorTint", &Vec4{1.0, 1.0, 1.0, 1.0})
+	ms.Pop()
+
+	// The top
+	ms.Push()
+	ms.Translate(&Vec4{0.0, fHeight - fBaseHeight, 0.0, 0.0})
+	ms.Scale(&Vec4{1.0, fBaseHeight, 1.0, 1.0})
+	ms.Translate(&Vec4{0.0, 0.5, 0.0, 0.0})
+	l.Add(ms, "cubeTint", "", "UniformColorTint", &Vec4{0.9, 0.9, 0.9, 0.9})
+	ms.Pop()
+
+	// The main column
+	ms.Push()
+	ms.Translate(&Vec4{0.0, fBaseHeight, 0.0, 0.0})
+	ms.Scale(&Vec4{0.8, fHeight - (fBaseHeight * 2.0), 0.8, 1.0})
+	ms.Translate(&Vec4{0.0, 0.5, 0.0, 0.0})
+	l.Add(ms, "cylinder", "", "UniformColorTint", &Vec4{0.9, 0.9, 0.9, 0.9})
+	ms.Pop()
+	return nil
+}
+
+func buildParthenon(l *Layout, ms *MatrixStack, p map[string]gl.Float) error {
+	fWidth, fLength := p["width"], p["length"]
+	fColumnHeight, fBaseHeight, fTopHeight := p["columnHeight"], p["baseHeight"], p["topHeight"]
+	column := map[string]gl.Float{"height": fColumnHeight, "baseHeight": 0.25}
+
+	// The base
+	ms.Push()
+	ms.Scale(&Vec4{fWidth, fBaseHeight, fLength, 1.0})
+	ms.Translate(&Vec4{0.0, 0.5, 0.0, 0.0})
+	l.Add(ms, "cubeTint", "", "UniformColorTint", &Vec4{0.9, 0.9, 0.9, 0.9})
+	ms.Pop()
+
+	// The top
+	ms.Push()
+	ms.Translate(&Vec4{0.0, fColumnHeight + fBaseHeight, 0.0, 0.0})
+	ms.Scale(&Vec4{fWidth, fTopHeight, fLength, 1.0})
+	ms.Translate(&Vec4{0.0, 0.5, 0.0, 0.0})
+	l.Add(ms, "cubeTint", "", "UniformColorTint", &Vec4{0.9, 0.9, 0.9, 0.9})
+	ms.Pop()
+
+	// The columns, front and back
+	fFrontZVal := (fLength / 2.0) - 1.0
+	fRightXVal := (fWidth / 2.0) - 1.0
+	for iColumnNum := 0; iColumnNum < int(fWidth/2.0); iColumnNum++ {
+		fXVal := (2.0 * gl.Float(iColumnNum)) - (fWidth / 2.0) + 1.0
+		for _, fZVal := range []gl.Float{fFrontZVal, -fFrontZVal} {
+			ms.Push()
+			ms.Translate(&Vec4{fXVal, fBaseHeight, fZVal, 0.0})
+			err := buildColumn(l, ms, column)
+			ms.Pop()
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	// and down the sides, leaving out the corners, which are done already.
+	for iColumnNum := 1; iColumnNum < int((fLength-2.0)/2.0); iColumnNum++ {
+		fZVal := (2.0 * gl.Float(iColumnNum)) - (fLength / 2.0) + 1.0
+		for _, fXVal := range []gl.Float{fRightXVal, -fRightXVal} {
+			ms.Push()
+			ms.Translate(&Vec4{fXVal, fBaseHeight, fZVal, 0.0})
+			err := buildColumn(l, ms, column)
+			ms.Pop()
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	// The interior
+	ms.Push()
+	ms.Translate(&Vec4{0.0, fBaseHeight, 0.0, 0.0})
+	ms.Scale(&Vec4{fWidth - 6.0, fColumnHeight, fLength - 6.0, 1.0})
+	ms.Translate(&Vec4{0.0, 0.5, 0.0, 0.0})
+	l.Add(ms, "cube", "color", "ObjectColor", nil)
+	ms.Pop()
+
+	// The headpiece
+	ms.Push()
+	ms.Translate(&Vec4{0.0, fColumnHeight + fBaseHeight + (fTopHeight / 2.0), fLength / 2.0, 0.0})
+	ms.RotateX(-135.0)
+	ms.RotateY(45.0)
+	l.Add(ms, "cube", "color", "ObjectColor", nil)
+	ms.Pop()
+	return nil
+}
@@ -0,0 +1,291 @@
+/*
+preprocess.go - a small GLSL preprocessor that runs before the source goes to
+the driver.  It handles:
+
+	#include "file"   relative to the including file, then IncludePaths
+	#include <file>   IncludePaths only
+	#pragma once      or a classic #ifndef/#define guard - included once
+	injected defines  written right after #version
+
+and emits #line directives so the driver's errors can be mapped back to the
+original file and line.  Source string 0 is always the top-level file; each
+included file gets the next number.  The #line directives use the GLSL 3.30
+meaning - the line after the directive is line N.
+
+Everything else - #define, #ifdef and friends - is left for the driver.
+*/
+package glutil
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Source is the result of preprocessing a shader.
+type Source struct {
+	Code  string
+	Files []string // Files[i] is source string number i
+}
+
+type Preprocessor struct {
+	Defines      map[string]string
+	IncludePaths []string
+
+	// ReadFile defaults to ioutil.ReadFile; tests can swap in a map.
+	ReadFile func(filename string) ([]byte, error)
+}
+
+// Preprocess expands filename with the given defines and no extra include
+// paths.
+func Preprocess(filename string, defines map[string]string) (*Source, error) {
+	pp := &Preprocessor{Defines: defines}
+	return pp.Process(filename)
+}
+
+// IncludeError is returned for a missing include or an include cycle.
+type IncludeError struct {
+	File    string
+	Line    int
+	Message string
+}
+
+func (e *IncludeError) Error() string {
+	return fmt.Sprintf("%s:%d: %s", e.File, e.Line, e.Message)
+}
+
+// per-run state
+type ppState struct {
+	pp      *Preprocessor
+	out     []string
+	files   []string
+	index   map[string]int  // file -> source string number
+	stack   []string        // files being expanded, for cycle detection
+	once    map[string]bool // files that are guarded and already included
+	version int             // the line the top-level #version is on, or 0
+}
+
+func (pp *Preprocessor) Process(filename string) (*Source, error) {
+	st := &ppState{
+		pp:    pp,
+		index: make(map[string]int),
+		once:  make(map[string]bool),
+	}
+	if err := st.expand(filepath.Clean(filename), nil, "", 0); err != nil {
+		return nil, err
+	}
+	if st.version == 0 {
+		// No #version to hang the defines off, so they go on top.
+		st.out = append(st.defines(), st.out...)
+	}
+	return &Source{Code: strings.Join(st.out, "\n") + "\n", Files: st.files}, nil
+}
+
+func (pp *Preprocessor) readFile(filename string) ([]byte, error) {
+	if pp.ReadFile != nil {
+		return pp.ReadFile(filename)
+	}
+	return ioutil.ReadFile(filename)
+}
+
+// defines renders the injected defines, followed by a #line to put the
+// top-level file back where it was.
+func (st *ppState) defines() []string {
+	if len(st.pp.Defines) == 0 {
+		return nil
+	}
+	names := make([]string, 0, len(st.pp.Defines))
+	for name := range st.pp.Defines {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var lines []string
+	for _, name := range names {
+		lines = append(lines, strings.TrimSpace("#define "+name+" "+st.pp.Defines[name]))
+	}
+	// The line after #version, or the first if there wasn't one
+	return append(lines, fmt.Sprintf("#line %d 0", st.version+1))
+}
+
+// resolve finds an included file.  Quoted names are tried next to the
+// including file first.
+func (st *ppState) resolve(name, from string, quoted bool) (string, []byte, error) {
+	var candidates []string
+	if quoted {
+		candidates = append(candidates, filepath.Join(filepath.Dir(from), name))
+	}
+	for _, dir := range st.pp.IncludePaths {
+		candidates = append(candidates, filepath.Join(dir, name))
+	}
+	for _, c := range candidates {
+		if data, err := st.pp.readFile(c); err == nil {
+			return filepath.Clean(c), data, nil
+		}
+	}
+	return "", nil, fmt.Errorf("cannot find include file %q", name)
+}
+
+// expand appends filename's lines to the output.  data is nil for the
+// top-level file, which hasn't been read yet.
+func (st *ppState) expand(filename string, data []byte, from string, fromLine int) error {
+	if data == nil {
+		var err error
+		if data, err = st.pp.readFile(filename); err != nil {
+			return err
+		}
+	}
+	for _, f := range st.stack {
+		if f == filename {
+			cycle := strings.Join(append(st.stack, filename), " -> ")
+			return &IncludeError{from, fromLine, "include cycle: " + cycle}
+		}
+	}
+
+	lines := strings.Split(strings.TrimRight(strings.Replace(string(data), "\r\n", "\n", -1), "\x00\n"), "\n")
+	guarded := isGuarded(lines)
+	if guarded && st.once[filename] {
+		return nil
+	}
+	if guarded {
+		st.once[filename] = true
+	}
+
+	idx, ok := st.index[filename]
+	if !ok {
+		idx = len(st.files)
+		st.index[filename] = idx
+		st.files = append(st.files, filename)
+	}
+	topLevel := len(st.stack) == 0
+	st.stack = append(st.stack, filename)
+	defer func() { st.stack = st.stack[:len(st.stack)-1] }()
+
+	if !topLevel {
+		st.out = append(st.out, fmt.Sprintf("#line 1 %d", idx))
+	}
+	inComment := false
+	for i, line := range lines {
+		directive, rest := "", ""
+		if !inComment {
+			directive, rest = parseDirective(line)
+		}
+		inComment = updateComment(line, inComment)
+
+		switch directive {
+		case "version":
+			if topLevel && st.version == 0 {
+				st.version = i + 1
+				st.out = append(st.out, line)
+				st.out = append(st.out, st.defines()...)
+			} else {
+				// Only the top-level #version counts.
+				st.out = append(st.out, "// "+strings.TrimSpace(line))
+			}
+		case "pragma":
+			if strings.TrimSpace(rest) == "once" {
+				st.out = append(st.out, "")
+			} else {
+				st.out = append(st.out, line)
+			}
+		case "include":
+			name, quoted, ok := parseIncludeName(rest)
+			if !ok {
+				return &IncludeError{filename, i + 1, "malformed #include: " + strings.TrimSpace(line)}
+			}
+			path, data, err := st.resolve(name, filename, quoted)
+			if err != nil {
+				return &IncludeError{filename, i + 1, err.Error()}
+			}
+			if err := st.expand(path, data, filename, i+1); err != nil {
+				return err
+			}
+			st.out = append(st.out, fmt.Sprintf("#line %d %d", i+2, idx))
+		default:
+			st.out = append(st.out, line)
+		}
+	}
+	return nil
+}
+
+// parseDirective splits "  #  include "foo"" into "include" and ` "foo"`.
+func parseDirective(line string) (string, string) {
+	t := strings.TrimSpace(line)
+	if !strings.HasPrefix(t, "#") {
+		return "", ""
+	}
+	t = strings.TrimSpace(t[1:])
+	end := strings.IndexAny(t, " \t\"<")
+	if end < 0 {
+		return t, ""
+	}
+	return t[:end], t[end:]
+}
+
+func parseIncludeName(rest string) (string, bool, bool) {
+	rest = strings.TrimSpace(rest)
+	if len(rest) < 2 {
+		return "", false, false
+	}
+	switch rest[0] {
+	case '"':
+		if end := strings.IndexByte(rest[1:], '"'); end > 0 {
+			return rest[1 : end+1], true, true
+		}
+	case '<':
+		if end := strings.IndexByte(rest[1:], '>'); end > 0 {
+			return rest[1 : end+1], false, true
+		}
+	}
+	return "", false, false
+}
+
+// updateComment tracks whether we're inside a /* */ comment at the end of
+// line.  Good enough for shaders - it doesn't know about strings, and GLSL
+// doesn't have any.
+func updateComment(line string, inComment bool) bool {
+	for {
+		if inComment {
+			end := strings.Index(line, "*/")
+			if end < 0 {
+				return true
+			}
+			line = line[end+2:]
+			inComment = false
+		}
+		lc := strings.Index(line, "//")
+		bc := strings.Index(line, "/*")
+		if bc < 0 || (lc >= 0 && lc < bc) {
+			return false
+		}
+		line = line[bc+2:]
+		inComment = true
+	}
+}
+
+// isGuarded reports whether a file asks to be included once, either with
+// #pragma once or an #ifndef X / #define X pair as its first directives.
+func isGuarded(lines []string) bool {
+	var first []string
+	inComment := false
+	for _, line := range lines {
+		if !inComment {
+			directive, rest := parseDirective(line)
+			if directive == "pragma" && strings.TrimSpace(rest) == "once" {
+				return true
+			}
+			if directive != "" && len(first) < 2 {
+				first = append(first, directive+" "+strings.TrimSpace(rest))
+			}
+		}
+		inComment = updateComment(line, inComment)
+	}
+	if len(first) < 2 {
+		return false
+	}
+	ifndef := strings.Fields(first[0])
+	define := strings.Fields(first[1])
+	return len(ifndef) == 2 && ifndef[0] == "ifndef" &&
+		len(define) >= 2 && define[0] == "define" && define[1] == ifndef[1]
+}
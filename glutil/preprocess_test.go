@@ -0,0 +1,148 @@
+package glutil
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// mapFiles makes a Preprocessor that reads from files instead of the disk.
+func mapFiles(files map[string]string) *Preprocessor {
+	return &Preprocessor{ReadFile: func(name string) ([]byte, error) {
+		if s, ok := files[name]; ok {
+			return []byte(s), nil
+		}
+		return nil, os.ErrNotExist
+	}}
+}
+
+func lines(l ...string) string { return strings.Join(l, "\n") + "\n" }
+
+func TestPreprocessDefines(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"version first",
+			"#version 330\nvoid main() {}\n",
+			lines("#version 330", "#define A 1", "#define B", "#line 2 0", "void main() {}")},
+		{"version after a comment and a blank line",
+			"// header\n\n#version 330\nvoid main() {}\n",
+			lines("// header", "", "#version 330", "#define A 1", "#define B", "#line 4 0", "void main() {}")},
+		{"no version",
+			"void main() {}\n",
+			lines("#define A 1", "#define B", "#line 1 0", "void main() {}")},
+	}
+	for _, tt := range tests {
+		pp := mapFiles(map[string]string{"main.vert": tt.src})
+		pp.Defines = map[string]string{"B": "", "A": "1"}
+		src, err := pp.Process("main.vert")
+		if err != nil {
+			t.Errorf("%s: %s", tt.name, err)
+			continue
+		}
+		if src.Code != tt.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", tt.name, src.Code, tt.want)
+		}
+	}
+}
+
+func TestPreprocessNestedIncludes(t *testing.T) {
+	pp := mapFiles(map[string]string{
+		"main.frag": lines(
+			"#version 330",
+			"#include \"a.glsl\"",
+			"#include \"sub/b.glsl\"",
+			"/* #include \"nothere.glsl\" */",
+			"void main() {}"),
+		"a.glsl": lines(
+			"#pragma once",
+			"#include \"sub/b.glsl\"",
+			"float a;"),
+		"sub/b.glsl": lines(
+			"#ifndef B_GLSL",
+			"#define B_GLSL",
+			"float b;",
+			"#endif"),
+	})
+	src, err := pp.Process("main.frag")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := lines(
+		"#version 330",
+		"#line 1 1",
+		"",
+		"#line 1 2",
+		"#ifndef B_GLSL",
+		"#define B_GLSL",
+		"float b;",
+		"#endif",
+		"#line 3 1",
+		"float a;",
+		"#line 3 0",
+		// b.glsl's guard keeps it out the second time
+		"#line 4 0",
+		"/* #include \"nothere.glsl\" */",
+		"void main() {}")
+	if src.Code != want {
+		t.Errorf("got\n%s\nwant\n%s", src.Code, want)
+	}
+	if !reflect.DeepEqual(src.Files, []string{"main.frag", "a.glsl", "sub/b.glsl"}) {
+		t.Errorf("files %v", src.Files)
+	}
+}
+
+func TestPreprocessIncludePaths(t *testing.T) {
+	pp := mapFiles(map[string]string{
+		"shaders/main.vert":  "#include <common.glsl>\n#include \"local.glsl\"\n",
+		"shaders/local.glsl": "float local;\n",
+		"lib/common.glsl":    "#version 330\nfloat common;\n",
+	})
+	pp.IncludePaths = []string{"lib"}
+	src, err := pp.Process("shaders/main.vert")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// An included #version is commented out
+	want := lines("#line 1 1", "// #version 330", "float common;", "#line 2 0",
+		"#line 1 2", "float local;", "#line 3 0")
+	if src.Code != want {
+		t.Errorf("got\n%s\nwant\n%s", src.Code, want)
+	}
+	if !reflect.DeepEqual(src.Files, []string{"shaders/main.vert", "lib/common.glsl", "shaders/local.glsl"}) {
+		t.Errorf("files %v", src.Files)
+	}
+}
+
+func TestPreprocessErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  IncludeError
+	}{
+		{"cycle", map[string]string{
+			"x.glsl": "float x;\n#include \"y.glsl\"\n",
+			"y.glsl": "#include \"x.glsl\"\n",
+		}, IncludeError{"y.glsl", 1, "include cycle: x.glsl -> y.glsl -> x.glsl"}},
+		{"missing", map[string]string{
+			"x.glsl": "\n\n#include \"gone.glsl\"\n",
+		}, IncludeError{"x.glsl", 3, `cannot find include file "gone.glsl"`}},
+		{"malformed", map[string]string{
+			"x.glsl": "#include gone.glsl\n",
+		}, IncludeError{"x.glsl", 1, "malformed #include: #include gone.glsl"}},
+	}
+	for _, tt := range tests {
+		_, err := mapFiles(tt.files).Process("x.glsl")
+		ie, ok := err.(*IncludeError)
+		if !ok {
+			t.Errorf("%s: got %v, want an IncludeError", tt.name, err)
+			continue
+		}
+		if *ie != tt.want {
+			t.Errorf("%s: got %+v, want %+v", tt.name, *ie, tt.want)
+		}
+	}
+}
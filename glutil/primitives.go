@@ -0,0 +1,353 @@
+/*
+primitives.go - builds the unit shapes in world_tut in code, the way the
+old world_tut/Gen*.lua scripts did, so with the default options
+WriteGLUTMesh gives back the shipped Unit*.xml byte for byte.  The sums are
+done in the same order as the Lua, down to its 3.14159 for pi.
+
+Spheres, tori and capsules are new, and have normals and texture
+coordinates as well.  They're indexed triangle lists wound clockwise from
+outside, the same as the Lua ones, for the tutorials' glFrontFace(GL_CW).
+*/
+package glutil
+
+import (
+	gl "github.com/chsc/gogl/gl33"
+	"math"
+)
+
+// What to build a primitive with.  The zero value of anything is "the
+// default", so GenOptions{Tint: true} is a tinted unit shape.
+type GenOptions struct {
+	Segments    int     // around the Y axis
+	Rings       int     // top to bottom, for spheres, tori and capsules
+	Radius      float64 // half the width of cubes and planes
+	Height      float64 // cylinders, cones and the straight part of capsules
+	TubeRadius  float64 // tori only
+	Tint        bool    // add colours: grey shading, or a colour per cube face
+	ColorRepeat int     // how many times the shading goes round a shape
+	FaceColors  bool    // cubes only: flat colours with "color" and "flat" VAOs
+}
+
+// DefaultGenOptions are what the Lua scripts used.
+func DefaultGenOptions() *GenOptions {
+	return &GenOptions{
+		Segments:    30,
+		Rings:       16,
+		Radius:      0.5,
+		Height:      1.0,
+		TubeRadius:  0.2,
+		ColorRepeat: 3,
+	}
+}
+
+// The Lua's pi.
+const luaPi = 3.14159
+
+// genOptions fills in the defaults for anything opts leaves at zero.
+func genOptions(opts *GenOptions) *GenOptions {
+	o := DefaultGenOptions()
+	if opts == nil {
+		return o
+	}
+	d := *o
+	*o = *opts
+	if o.Segments <= 0 {
+		o.Segments = d.Segments
+	}
+	if o.Rings <= 0 {
+		o.Rings = d.Rings
+	}
+	if o.Radius == 0 {
+		o.Radius = d.Radius
+	}
+	if o.Height == 0 {
+		o.Height = d.Height
+	}
+	if o.TubeRadius == 0 {
+		o.TubeRadius = d.TubeRadius
+	}
+	if o.ColorRepeat <= 0 {
+		o.ColorRepeat = d.ColorRepeat
+	}
+	return o
+}
+
+// genTint is the grey the cylinder and cone shade a vertex at angle,
+// going from light to dark and back repeat times round.
+func genTint(angle float64, repeat int) []float64 {
+	cycle := luaPi * 2.0 / float64(repeat)
+	high := []float64{0.9, 0.9, 0.9, 1.0}
+	low := []float64{0.5, 0.5, 0.5, 1.0}
+	dist := math.Mod(angle, cycle) / cycle
+	c := make([]float64, 4)
+	for k := range c {
+		if dist > 0.5 {
+			interp := (dist - 0.5) * 2
+			c[k] = interp*high[k] + (1-interp)*low[k]
+		} else {
+			interp := dist * 2
+			c[k] = interp*low[k] + (1-interp)*high[k]
+		}
+	}
+	return c
+}
+
+func genMesh(name string, attribs ...*MeshAttrib) *Mesh {
+	m := NewMesh(name)
+	for _, a := range attribs {
+		if a != nil {
+			m.Attribs = append(m.Attribs, a)
+		}
+	}
+	return m
+}
+
+func genIndices(cmd string, indices []gl.Uint) *RenderCmd {
+	return &RenderCmd{Cmd: cmd, Indexed: true, IndexType: "ushort", Indices: indices, Count: len(indices)}
+}
+
+// GenCube is UnitCube.xml, or UnitCubeTint.xml with Tint, or
+// UnitCubeColor.xml with FaceColors.  Radius is half the width.
+func GenCube(opts *GenOptions) *Mesh {
+	o := genOptions(opts)
+	r := o.Radius
+	corners := [][3]float64{
+		// Front
+		{r, r, r}, {r, -r, r}, {-r, -r, r}, {-r, r, r},
+		// Top
+		{r, r, r}, {-r, r, r}, {-r, r, -r}, {r, r, -r},
+		// Left
+		{r, r, r}, {r, r, -r}, {r, -r, -r}, {r, -r, r},
+		// Back
+		{r, r, -r}, {-r, r, -r}, {-r, -r, -r}, {r, -r, -r},
+		// Bottom
+		{r, -r, r}, {r, -r, -r}, {-r, -r, -r}, {-r, -r, r},
+		// Right
+		{-r, r, r}, {-r, -r, r}, {-r, -r, -r}, {-r, r, -r},
+	}
+	tints := []float64{1.0, 0.75, 0.5, 1.0, 0.75, 0.5}
+	colors := [][4]float64{
+		{0.0, 1.0, 0.0, 1.0}, {0.0, 0.0, 1.0, 1.0}, {1.0, 0.0, 0.0, 1.0},
+		{1.0, 1.0, 0.0, 1.0}, {0.0, 1.0, 1.0, 1.0}, {1.0, 0.0, 1.0, 1.0},
+	}
+
+	pos := &MeshAttrib{Index: AttribPosition, Type: "float", Size: 3}
+	var color *MeshAttrib
+	if o.Tint || o.FaceColors {
+		color = &MeshAttrib{Index: AttribColor, Type: "float", Size: 4}
+	}
+	var indices []gl.Uint
+	for face := 0; face < 6; face++ {
+		for v := 0; v < 4; v++ {
+			p := corners[face*4+v]
+			pos.Data = append(pos.Data, p[0], p[1], p[2])
+			switch {
+			case o.FaceColors:
+				c := colors[face]
+				color.Data = append(color.Data, c[0], c[1], c[2], c[3])
+			case o.Tint:
+				t := tints[face]
+				color.Data = append(color.Data, t, t, t, 1.0)
+			}
+		}
+		i := gl.Uint(face * 4)
+		indices = append(indices, i, i+1, i+2, i+2, i+3, i)
+	}
+	m := genMesh("UnitCube", pos, color)
+	if o.FaceColors {
+		m.VAOs = map[string][]gl.Uint{"color": {AttribPosition, AttribColor}, "flat": {AttribPosition}}
+	}
+	m.Commands = append(m.Commands, genIndices("triangles", indices))
+	return m
+}
+
+// GenPlane is UnitPlane.xml: a square in XZ, facing both ways.
+func GenPlane(opts *GenOptions) *Mesh {
+	o := genOptions(opts)
+	r := o.Radius
+	pos := &MeshAttrib{Index: AttribPosition, Type: "float", Size: 3,
+		Data: []float64{r, 0.0, -r, r, 0.0, r, -r, 0.0, r, -r, 0.0, -r}}
+	m := genMesh("UnitPlane", pos)
+	m.Commands = append(m.Commands, genIndices("triangles", []gl.Uint{0, 1, 2, 0, 2, 1, 2, 3, 0, 2, 0, 3}))
+	return m
+}
+
+// GenCylinder is UnitCylinder.xml, or UnitCylinderTint.xml with Tint: a
+// fan for each cap and a strip round the side, centred on the origin.
+func GenCylinder(opts *GenOptions) *Mesh {
+	o := genOptions(opts)
+	n := o.Segments
+	top, bottom := o.Height/2.0, -o.Height/2.0
+	angle := luaPi * 2.0 / float64(n)
+
+	pos := &MeshAttrib{Index: AttribPosition, Type: "float", Size: 3}
+	color := &MeshAttrib{Index: AttribColor, Type: "float", Size: 4}
+	pos.Data = append(pos.Data, 0.0, top, 0.0)
+	color.Data = append(color.Data, 1.0, 1.0, 1.0, 1.0)
+	topFan := []gl.Uint{0}
+	botFan := []gl.Uint{gl.Uint(n*2 + 1)}
+	var strip []gl.Uint
+	for seg := 0; seg < n; seg++ {
+		a := float64(seg) * angle
+		x, z := o.Radius*math.Cos(a), o.Radius*math.Sin(a)
+		pos.Data = append(pos.Data, x, top, z, x, bottom, z)
+		c := genTint(a, o.ColorRepeat)
+		color.Data = append(color.Data, c...)
+		color.Data = append(color.Data, c...)
+
+		topFan = append(topFan, gl.Uint(1+seg*2))
+		botFan = append(botFan, gl.Uint(1+((n-seg)*2-1)))
+		strip = append(strip, gl.Uint(1+seg*2), gl.Uint(1+seg*2+1))
+	}
+	topFan = append(topFan, topFan[1])
+	botFan = append(botFan, botFan[1])
+	strip = append(strip, strip[0], strip[1])
+	pos.Data = append(pos.Data, 0.0, bottom, 0.0)
+	color.Data = append(color.Data, 1.0, 1.0, 1.0, 1.0)
+
+	if !o.Tint {
+		color = nil
+	}
+	m := genMesh("UnitCylinder", pos, color)
+	m.Commands = append(m.Commands, genIndices("tri-fan", topFan),
+		genIndices("tri-fan", botFan), genIndices("tri-strip", strip))
+	return m
+}
+
+// GenCone is UnitCone.xml, or UnitConeTint.xml with Tint: its base is on
+// the origin and its point Height above.  The Lua's was 0.866 high, so
+// that's the default here rather than Height's usual 1.
+func GenCone(opts *GenOptions) *Mesh {
+	o := genOptions(opts)
+	if opts == nil || opts.Height == 0 {
+		o.Height = 0.866
+	}
+	n := o.Segments
+	angle := luaPi * 2.0 / float64(n)
+
+	pos := &MeshAttrib{Index: AttribPosition, Type: "float", Size: 3}
+	color := &MeshAttrib{Index: AttribColor, Type: "float", Size: 4}
+	pos.Data = append(pos.Data, 0.0, o.Height, 0.0)
+	color.Data = append(color.Data, 1.0, 1.0, 1.0, 1.0)
+	side := []gl.Uint{0}
+	for seg := 0; seg < n; seg++ {
+		a := float64(seg) * angle
+		pos.Data = append(pos.Data, o.Radius*math.Cos(a), 0.0, o.Radius*math.Sin(a))
+		color.Data = append(color.Data, genTint(a, o.ColorRepeat)...)
+		side = append(side, gl.Uint(seg+1))
+	}
+	side = append(side, side[1])
+
+	base := []gl.Uint{gl.Uint(n + 1)}
+	for seg := n - 1; seg >= 0; seg-- {
+		base = append(base, gl.Uint(seg+1))
+	}
+	base = append(base, base[1])
+	pos.Data = append(pos.Data, 0.0, 0.0, 0.0)
+	color.Data = append(color.Data, 0.9, 0.9, 0.9, 1.0)
+
+	if !o.Tint {
+		color = nil
+	}
+	m := genMesh("UnitCone", pos, color)
+	m.Commands = append(m.Commands, genIndices("tri-fan", side), genIndices("tri-fan", base))
+	return m
+}
+
+// A ring of a surface of revolution: its radius and height, and the
+// normal's outward and upward parts.
+type genRing struct {
+	r, y, nr, ny float64
+}
+
+// genRevolve sweeps rings, top to bottom, round the Y axis.  Each ring
+// has Segments+1 vertices, so the texture can wrap; rings of radius 0 are
+// poles, and get no triangles.
+func genRevolve(name string, o *GenOptions, rings []genRing) *Mesh {
+	n := o.Segments
+	pos := &MeshAttrib{Index: AttribPosition, Type: "float", Size: 3}
+	norm := &MeshAttrib{Index: AttribNormal, Type: "float", Size: 3}
+	uv := &MeshAttrib{Index: AttribTexCoord, Type: "float", Size: 2}
+	color := &MeshAttrib{Index: AttribColor, Type: "float", Size: 4}
+	for i, ring := range rings {
+		for s := 0; s <= n; s++ {
+			a := 2.0 * math.Pi * float64(s) / float64(n)
+			cos, sin := math.Cos(a), math.Sin(a)
+			pos.Data = append(pos.Data, ring.r*cos, ring.y, ring.r*sin)
+			norm.Data = append(norm.Data, ring.nr*cos, ring.ny, ring.nr*sin)
+			uv.Data = append(uv.Data, float64(s)/float64(n), 1.0-float64(i)/float64(len(rings)-1))
+			color.Data = append(color.Data, genTint(a*luaPi/math.Pi, o.ColorRepeat)...)
+		}
+	}
+
+	var indices []gl.Uint
+	for i := 0; i+1 < len(rings); i++ {
+		for s := 0; s < n; s++ {
+			a := gl.Uint(i*(n+1) + s)
+			b := a + gl.Uint(n+1)
+			if rings[i].r != 0 {
+				indices = append(indices, a, b, a+1)
+			}
+			if rings[i+1].r != 0 {
+				indices = append(indices, a+1, b, b+1)
+			}
+		}
+	}
+	if !o.Tint {
+		color = nil
+	}
+	m := genMesh(name, pos, color, norm, uv)
+	cmd := genIndices("triangles", indices)
+	if pos.VertexCount() > 0xffff {
+		cmd.IndexType = "uint"
+	}
+	m.Commands = append(m.Commands, cmd)
+	return m
+}
+
+// genArc is the rings of an arc of a circle of the given radius centred
+// at height y, from latitude phi0 to phi1 (0 at the top, pi at the
+// bottom).
+func genArc(radius, y, phi0, phi1 float64, steps int) []genRing {
+	var rings []genRing
+	for i := 0; i <= steps; i++ {
+		phi := phi0 + (phi1-phi0)*float64(i)/float64(steps)
+		sin, cos := math.Sin(phi), math.Cos(phi)
+		if phi == 0 || phi == math.Pi {
+			sin = 0 // exactly a pole
+		}
+		rings = append(rings, genRing{radius * sin, y + radius*cos, sin, cos})
+	}
+	return rings
+}
+
+// GenSphere is a UV sphere of Rings bands and Segments slices.
+func GenSphere(opts *GenOptions) *Mesh {
+	o := genOptions(opts)
+	return genRevolve("UnitSphere", o, genArc(o.Radius, 0.0, 0.0, math.Pi, o.Rings))
+}
+
+// GenTorus is a ring of Radius round the Y axis, of a tube TubeRadius
+// thick, Rings round the tube.
+func GenTorus(opts *GenOptions) *Mesh {
+	o := genOptions(opts)
+	var rings []genRing
+	for i := 0; i <= o.Rings; i++ {
+		// Starting on the outside and going down, as a sphere's rings do,
+		// so the winding comes out the same.
+		psi := -2.0 * math.Pi * float64(i) / float64(o.Rings)
+		cos, sin := math.Cos(psi), math.Sin(psi)
+		rings = append(rings, genRing{o.Radius + o.TubeRadius*cos, o.TubeRadius * sin, cos, sin})
+	}
+	return genRevolve("UnitTorus", o, rings)
+}
+
+// GenCapsule is a cylinder Height long with hemispheres of Radius on the
+// ends, Rings bands top to bottom.
+func GenCapsule(opts *GenOptions) *Mesh {
+	o := genOptions(opts)
+	half := (o.Rings + 1) / 2
+	rings := genArc(o.Radius, o.Height/2.0, 0.0, math.Pi/2.0, half)
+	rings = append(rings, genArc(o.Radius, -o.Height/2.0, math.Pi/2.0, math.Pi, half)...)
+	return genRevolve("UnitCapsule", o, rings)
+}
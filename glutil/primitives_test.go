@@ -0,0 +1,89 @@
+package glutil
+
+import (
+	"bytes"
+	gl "github.com/chsc/gogl/gl33"
+	"io/ioutil"
+	"math"
+	"testing"
+)
+
+func TestGenUnitMeshes(t *testing.T) {
+	// With the default options, the files the Lua scripts made.
+	tests := []struct {
+		file string
+		gen  func(*GenOptions) *Mesh
+		opts GenOptions
+	}{
+		{"UnitCube.xml", GenCube, GenOptions{}},
+		{"UnitCubeTint.xml", GenCube, GenOptions{Tint: true}},
+		{"UnitCubeColor.xml", GenCube, GenOptions{FaceColors: true}},
+		{"UnitPlane.xml", GenPlane, GenOptions{}},
+		{"UnitCylinder.xml", GenCylinder, GenOptions{}},
+		{"UnitCylinderTint.xml", GenCylinder, GenOptions{Tint: true}},
+		{"UnitCone.xml", GenCone, GenOptions{}},
+		{"UnitConeTint.xml", GenCone, GenOptions{Tint: true}},
+	}
+	for _, tt := range tests {
+		want, err := ioutil.ReadFile("../world_tut/" + tt.file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		if err := tt.gen(&tt.opts).WriteGLUTMesh(&buf); err != nil {
+			t.Errorf("%s: %s", tt.file, err)
+			continue
+		}
+		if !bytes.Equal(buf.Bytes(), want) {
+			t.Errorf("%s: generated differently", tt.file)
+		}
+	}
+}
+
+func TestGenRevolved(t *testing.T) {
+	// 30 segments have 31 vertices a ring, for the texture seam.  The poles
+	// of spheres and capsules get one triangle a segment, not two.
+	tests := []struct {
+		name      string
+		gen       func(*GenOptions) *Mesh
+		vertices  int
+		triangles int
+	}{
+		{"sphere", GenSphere, 17 * 31, (14*2 + 2) * 30},
+		{"torus", GenTorus, 17 * 31, 16 * 2 * 30},
+		{"capsule", GenCapsule, 18 * 31, (15*2 + 2) * 30},
+	}
+	for _, tt := range tests {
+		for _, tint := range []bool{false, true} {
+			m := tt.gen(&GenOptions{Tint: tint})
+			if err := m.Validate(); err != nil {
+				t.Errorf("%s: %s", tt.name, err)
+				continue
+			}
+			if m.VertexCount != tt.vertices {
+				t.Errorf("%s: %d vertices, want %d", tt.name, m.VertexCount, tt.vertices)
+			}
+			if len(m.Commands) != 1 || m.Commands[0].Cmd != "triangles" || m.Commands[0].Count != 3*tt.triangles {
+				t.Errorf("%s: %d commands, %d indices, want %d", tt.name, len(m.Commands), m.Commands[0].Count, 3*tt.triangles)
+			}
+			for _, index := range []gl.Uint{AttribPosition, AttribNormal, AttribTexCoord} {
+				if m.Attrib(index) == nil {
+					t.Errorf("%s: no attribute %d", tt.name, index)
+				}
+			}
+			if (m.Attrib(AttribColor) != nil) != tint {
+				t.Errorf("%s: tint %v, but colours %v", tt.name, tint, m.Attrib(AttribColor) != nil)
+			}
+		}
+	}
+
+	// Every sphere vertex is on it, and its normal points out.
+	m := GenSphere(nil)
+	pos, norm := m.Attrib(AttribPosition), m.Attrib(AttribNormal)
+	for v := 0; v < m.VertexCount; v++ {
+		p, n := attribVec3(pos, v), attribVec3(norm, v)
+		if math.Abs(float64(p.Length())-0.5) > 1e-6 || !vec3Near(p.MulS(2), n, 1e-6) {
+			t.Fatalf("vertex %d at %v, normal %v", v, *p, *n)
+		}
+	}
+}
@@ -0,0 +1,318 @@
+/*
+program.go - a linked shader program that knows its own uniforms and
+attributes, so callers can set them by name instead of caching locations.
+*/
+package glutil
+
+import (
+	"fmt"
+	gl "github.com/chsc/gogl/gl33"
+	"os"
+	"sort"
+	"strings"
+)
+
+// An active uniform or attribute, as reported by the driver after linking.
+type Variable struct {
+	Name     string
+	Type     gl.Enum
+	Size     gl.Int // array length, 1 for non-arrays
+	Location gl.Int // -1 for uniforms that live in a uniform block
+}
+
+type Program struct {
+	ID       gl.Uint
+	Files    []string
+	Defines  map[string]string
+	Uniforms map[string]*Variable
+	Attribs  map[string]*Variable
+
+	// Names we've already complained about, so a bad name in a draw loop
+	// doesn't flood stderr.
+	warned map[string]bool
+}
+
+// NewProgram compiles and links shaderFiles, then introspects the result.
+func NewProgram(shaderFiles []string) (*Program, error) {
+	return NewProgramDefines(shaderFiles, nil)
+}
+
+// NewProgramDefines is NewProgram with #defines injected into every stage.
+func NewProgramDefines(shaderFiles []string, defines map[string]string) (*Program, error) {
+	id, err := CreateShaderProgramDefines(shaderFiles, defines)
+	if err != nil {
+		return nil, err
+	}
+	p := &Program{ID: id, Files: shaderFiles, Defines: defines}
+	p.introspect()
+	return p, nil
+}
+
+// introspect enumerates the active uniforms and attributes of the program.
+func (p *Program) introspect() {
+	p.Uniforms = make(map[string]*Variable)
+	p.Attribs = make(map[string]*Variable)
+	p.warned = make(map[string]bool)
+
+	var count, maxLen gl.Int
+	gl.GetProgramiv(p.ID, gl.ACTIVE_UNIFORMS, &count)
+	gl.GetProgramiv(p.ID, gl.ACTIVE_UNIFORM_MAX_LENGTH, &maxLen)
+	for i := gl.Int(0); i < count; i++ {
+		v := activeVariable(p.ID, gl.Uint(i), maxLen, gl.GetActiveUniform)
+		glName := gl.GLString(v.Name)
+		v.Location = gl.GetUniformLocation(p.ID, glName)
+		gl.GLStringFree(glName)
+		p.Uniforms[v.Name] = v
+	}
+
+	gl.GetProgramiv(p.ID, gl.ACTIVE_ATTRIBUTES, &count)
+	gl.GetProgramiv(p.ID, gl.ACTIVE_ATTRIBUTE_MAX_LENGTH, &maxLen)
+	for i := gl.Int(0); i < count; i++ {
+		v := activeVariable(p.ID, gl.Uint(i), maxLen, gl.GetActiveAttrib)
+		glName := gl.GLString(v.Name)
+		v.Location = gl.GetAttribLocation(p.ID, glName)
+		gl.GLStringFree(glName)
+		p.Attribs[v.Name] = v
+	}
+}
+
+type activeFunc func(gl.Uint, gl.Uint, gl.Sizei, *gl.Sizei, *gl.Int, *gl.Enum, *gl.Char)
+
+func activeVariable(program, index gl.Uint, maxLen gl.Int, get activeFunc) *Variable {
+	var length gl.Sizei
+	v := new(Variable)
+	name := gl.GLStringAlloc(gl.Sizei(maxLen + 1))
+	defer gl.GLStringFree(name)
+	get(program, index, gl.Sizei(maxLen+1), &length, &v.Size, &v.Type, name)
+	// Arrays are reported as "name[0]"; we want to look them up as "name".
+	v.Name = strings.TrimSuffix(gl.GoString(name), "[0]")
+	return v
+}
+
+func (p *Program) Use() {
+	gl.UseProgram(p.ID)
+}
+
+func (p *Program) Delete() {
+	gl.DeleteProgram(p.ID)
+	p.ID = 0
+}
+
+// warnOnce prints msg to stderr the first time it's seen for this program.
+func (p *Program) warnOnce(msg string) {
+	if p.warned[msg] {
+		return
+	}
+	p.warned[msg] = true
+	fmt.Fprintf(os.Stderr, "Program %d: %s\n", p.ID, msg)
+}
+
+// Uniform returns the location of the named uniform, or an error if the
+// program has no such active uniform (the compiler may have optimized it
+// away) or it lives in a uniform block.
+func (p *Program) Uniform(name string) (gl.Int, error) {
+	v, ok := p.Uniforms[name]
+	if !ok {
+		return -1, fmt.Errorf("no active uniform %q in %v", name, p.Files)
+	}
+	if v.Location < 0 {
+		return -1, fmt.Errorf("uniform %q is in a uniform block", name)
+	}
+	return v.Location, nil
+}
+
+// Attrib returns the location of the named vertex attribute.
+func (p *Program) Attrib(name string) (gl.Uint, error) {
+	v, ok := p.Attribs[name]
+	if !ok || v.Location < 0 {
+		return 0, fmt.Errorf("no active attribute %q in %v", name, p.Files)
+	}
+	return gl.Uint(v.Location), nil
+}
+
+// UniformLoc is Uniform, but warns once and returns -1 instead of erroring.
+func (p *Program) UniformLoc(name string) gl.Int {
+	loc, err := p.Uniform(name)
+	if err != nil {
+		p.warnOnce(err.Error())
+	}
+	return loc
+}
+
+// AttribLoc is Attrib, but warns once instead of erroring.
+func (p *Program) AttribLoc(name string) gl.Uint {
+	loc, err := p.Attrib(name)
+	if err != nil {
+		p.warnOnce(err.Error())
+	}
+	return loc
+}
+
+// setLoc looks up name for a setter, checking it has one of the given types.
+// It returns false if there is nothing to set.
+func (p *Program) setLoc(name string, types ...gl.Enum) (gl.Int, bool) {
+	loc, err := p.Uniform(name)
+	if err != nil {
+		p.warnOnce(err.Error())
+		return -1, false
+	}
+	v := p.Uniforms[name]
+	for _, t := range types {
+		if v.Type == t {
+			return loc, true
+		}
+	}
+	p.warnOnce(fmt.Sprintf("uniform %q is a %s, not a %s", name,
+		TypeName(v.Type), TypeName(types[0])))
+	return -1, false
+}
+
+// The typed setters write to the program currently in use, as glUniform*
+// does.  Unknown names and type mismatches are reported once on stderr.
+
+func (p *Program) SetFloat(name string, f gl.Float) {
+	if loc, ok := p.setLoc(name, gl.FLOAT); ok {
+		gl.Uniform1f(loc, f)
+	}
+}
+
+// The uniform types glUniform1i sets: ints, bools and texture units.
+var intTypes = []gl.Enum{gl.INT, gl.BOOL,
+	gl.SAMPLER_1D, gl.SAMPLER_2D, gl.SAMPLER_3D, gl.SAMPLER_CUBE,
+	gl.SAMPLER_1D_SHADOW, gl.SAMPLER_2D_SHADOW, gl.SAMPLER_CUBE_SHADOW,
+	gl.SAMPLER_1D_ARRAY, gl.SAMPLER_2D_ARRAY,
+	gl.SAMPLER_1D_ARRAY_SHADOW, gl.SAMPLER_2D_ARRAY_SHADOW,
+	gl.SAMPLER_2D_RECT, gl.SAMPLER_BUFFER,
+	gl.INT_SAMPLER_1D, gl.INT_SAMPLER_2D, gl.INT_SAMPLER_3D, gl.INT_SAMPLER_CUBE,
+	gl.INT_SAMPLER_1D_ARRAY, gl.INT_SAMPLER_2D_ARRAY,
+	gl.INT_SAMPLER_2D_RECT, gl.INT_SAMPLER_BUFFER,
+	gl.UNSIGNED_INT_SAMPLER_1D, gl.UNSIGNED_INT_SAMPLER_2D,
+	gl.UNSIGNED_INT_SAMPLER_3D, gl.UNSIGNED_INT_SAMPLER_CUBE,
+	gl.UNSIGNED_INT_SAMPLER_1D_ARRAY, gl.UNSIGNED_INT_SAMPLER_2D_ARRAY,
+	gl.UNSIGNED_INT_SAMPLER_2D_RECT, gl.UNSIGNED_INT_SAMPLER_BUFFER,
+}
+
+// SetInt also sets samplers and bools.
+func (p *Program) SetInt(name string, i gl.Int) {
+	if loc, ok := p.setLoc(name, intTypes...); ok {
+		gl.Uniform1i(loc, i)
+	}
+}
+
+func (p *Program) SetVec2(name string, v *Vec2) {
+	if loc, ok := p.setLoc(name, gl.FLOAT_VEC2); ok {
+		gl.Uniform2f(loc, v.X, v.Y)
+	}
+}
+
+func (p *Program) SetVec3(name string, v *Vec3) {
+	if loc, ok := p.setLoc(name, gl.FLOAT_VEC3); ok {
+		gl.Uniform3f(loc, v.X, v.Y, v.Z)
+	}
+}
+
+func (p *Program) SetVec4(name string, v *Vec4) {
+	if loc, ok := p.setLoc(name, gl.FLOAT_VEC4); ok {
+		gl.Uniform4f(loc, v.X, v.Y, v.Z, v.W)
+	}
+}
+
+func (p *Program) SetMat3(name string, m *Mat3) {
+	if loc, ok := p.setLoc(name, gl.FLOAT_MAT3); ok {
+		gl.UniformMatrix3fv(loc, 1, gl.FALSE, m.Ptr())
+	}
+}
+
+func (p *Program) SetMat4(name string, m *Mat4) {
+	if loc, ok := p.setLoc(name, gl.FLOAT_MAT4); ok {
+		gl.UniformMatrix4fv(loc, 1, gl.FALSE, m.Ptr())
+	}
+}
+
+// SetMat4Array uploads len(ms) matrices starting at name[0].
+func (p *Program) SetMat4Array(name string, ms []Mat4) {
+	if len(ms) == 0 {
+		return
+	}
+	if loc, ok := p.setLoc(name, gl.FLOAT_MAT4); ok {
+		gl.UniformMatrix4fv(loc, gl.Sizei(len(ms)), gl.FALSE, ms[0].Ptr())
+	}
+}
+
+var typeNames = map[gl.Enum]string{
+	gl.FLOAT:                         "float",
+	gl.FLOAT_VEC2:                    "vec2",
+	gl.FLOAT_VEC3:                    "vec3",
+	gl.FLOAT_VEC4:                    "vec4",
+	gl.INT:                           "int",
+	gl.INT_VEC2:                      "ivec2",
+	gl.INT_VEC3:                      "ivec3",
+	gl.INT_VEC4:                      "ivec4",
+	gl.UNSIGNED_INT:                  "uint",
+	gl.UNSIGNED_INT_VEC2:             "uvec2",
+	gl.UNSIGNED_INT_VEC3:             "uvec3",
+	gl.UNSIGNED_INT_VEC4:             "uvec4",
+	gl.BOOL:                          "bool",
+	gl.BOOL_VEC2:                     "bvec2",
+	gl.BOOL_VEC3:                     "bvec3",
+	gl.BOOL_VEC4:                     "bvec4",
+	gl.FLOAT_MAT2:                    "mat2",
+	gl.FLOAT_MAT3:                    "mat3",
+	gl.FLOAT_MAT4:                    "mat4",
+	gl.SAMPLER_1D:                    "sampler1D",
+	gl.SAMPLER_2D:                    "sampler2D",
+	gl.SAMPLER_3D:                    "sampler3D",
+	gl.SAMPLER_CUBE:                  "samplerCube",
+	gl.SAMPLER_2D_SHADOW:             "sampler2DShadow",
+	gl.SAMPLER_1D_SHADOW:             "sampler1DShadow",
+	gl.SAMPLER_CUBE_SHADOW:           "samplerCubeShadow",
+	gl.SAMPLER_1D_ARRAY:              "sampler1DArray",
+	gl.SAMPLER_2D_ARRAY:              "sampler2DArray",
+	gl.SAMPLER_1D_ARRAY_SHADOW:       "sampler1DArrayShadow",
+	gl.SAMPLER_2D_ARRAY_SHADOW:       "sampler2DArrayShadow",
+	gl.SAMPLER_2D_RECT:               "sampler2DRect",
+	gl.SAMPLER_BUFFER:                "samplerBuffer",
+	gl.INT_SAMPLER_1D:                "isampler1D",
+	gl.INT_SAMPLER_2D:                "isampler2D",
+	gl.INT_SAMPLER_3D:                "isampler3D",
+	gl.INT_SAMPLER_CUBE:              "isamplerCube",
+	gl.INT_SAMPLER_1D_ARRAY:          "isampler1DArray",
+	gl.INT_SAMPLER_2D_ARRAY:          "isampler2DArray",
+	gl.INT_SAMPLER_2D_RECT:           "isampler2DRect",
+	gl.INT_SAMPLER_BUFFER:            "isamplerBuffer",
+	gl.UNSIGNED_INT_SAMPLER_1D:       "usampler1D",
+	gl.UNSIGNED_INT_SAMPLER_2D:       "usampler2D",
+	gl.UNSIGNED_INT_SAMPLER_3D:       "usampler3D",
+	gl.UNSIGNED_INT_SAMPLER_CUBE:     "usamplerCube",
+	gl.UNSIGNED_INT_SAMPLER_1D_ARRAY: "usampler1DArray",
+	gl.UNSIGNED_INT_SAMPLER_2D_ARRAY: "usampler2DArray",
+	gl.UNSIGNED_INT_SAMPLER_2D_RECT:  "usampler2DRect",
+	gl.UNSIGNED_INT_SAMPLER_BUFFER:   "usamplerBuffer",
+}
+
+// TypeName gives the glsl name of a GL type enum.
+func TypeName(t gl.Enum) string {
+	if s, ok := typeNames[t]; ok {
+		return s
+	}
+	return fmt.Sprintf("0x%04X", uint32(t))
+}
+
+// Debug dumps the program's active variables to stdout.
+func (p *Program) Debug() {
+	fmt.Fprintf(os.Stdout, "*** Program %d %v ***\n", p.ID, p.Files)
+	dump := func(kind string, vars map[string]*Variable) {
+		names := make([]string, 0, len(vars))
+		for name := range vars {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			v := vars[name]
+			fmt.Fprintf(os.Stdout, "%s\t%-12s %-24s size %d, location %d\n",
+				kind, TypeName(v.Type), v.Name, v.Size, v.Location)
+		}
+	}
+	dump("attrib", p.Attribs)
+	dump("uniform", p.Uniforms)
+}
@@ -0,0 +1,32 @@
+package glutil
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSetIntTypes(t *testing.T) {
+	// Every sampler has a name, and SetInt sets it.
+	set := make(map[string]bool)
+	for _, typ := range intTypes {
+		name := TypeName(typ)
+		if strings.HasPrefix(name, "0x") {
+			t.Errorf("SetInt type %s has no name", name)
+		}
+		set[name] = true
+		// A reload carries it over.
+		if size, ok := uniformSizes[typ]; !ok || size.n != 1 || !size.isInt {
+			t.Errorf("a reload doesn't copy a %s", name)
+		}
+	}
+	for _, name := range typeNames {
+		if strings.Contains(name, "sampler") && !set[name] {
+			t.Errorf("SetInt can't set a %s", name)
+		}
+	}
+	for _, name := range []string{"int", "bool", "sampler1D", "isampler2D", "usamplerCube"} {
+		if !set[name] {
+			t.Errorf("SetInt can't set a %s", name)
+		}
+	}
+}
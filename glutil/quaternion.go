@@ -0,0 +1,190 @@
+/*
+quaternion.go - unit quaternions for orientations that can be interpolated
+without gimbal lock.  Angles are in degrees, like the rest of glutil.
+*/
+package glutil
+
+import (
+	gl "github.com/chsc/gogl/gl33"
+	"math"
+)
+
+type Quat struct {
+	W, X, Y, Z gl.Float
+}
+
+func QuatIdent() *Quat {
+	return &Quat{1.0, 0.0, 0.0, 0.0}
+}
+
+// QuatFromAxisAngle rotates fAngDeg degrees around axis, which needn't be
+// normalized.
+func QuatFromAxisAngle(axis *Vec3, fAngDeg gl.Float) *Quat {
+	v := axis.Normalize()
+	fHalf := DegToRad(fAngDeg) / 2.0
+	s := SinGL(fHalf)
+	return &Quat{CosGL(fHalf), v.X * s, v.Y * s, v.Z * s}
+}
+
+// QuatFromEuler is the same rotation as calling RotateZ(z), RotateY(y) and
+// RotateX(x) on a MatrixStack, in that order - x is applied to the model
+// first.
+func QuatFromEuler(x, y, z gl.Float) *Quat {
+	qx := QuatFromAxisAngle(&Vec3{1.0, 0.0, 0.0}, x)
+	qy := QuatFromAxisAngle(&Vec3{0.0, 1.0, 0.0}, y)
+	qz := QuatFromAxisAngle(&Vec3{0.0, 0.0, 1.0}, z)
+	return qz.Mul(qy).Mul(qx)
+}
+
+// QuatFromMat3 extracts the rotation from m, which must be orthonormal.
+func QuatFromMat3(m *Mat3) *Quat {
+	m00, m11, m22 := float64(m[0].X), float64(m[1].Y), float64(m[2].Z)
+	trace := m00 + m11 + m22
+	var q Quat
+	switch {
+	case trace > 0:
+		s := math.Sqrt(trace+1.0) * 2
+		q.W = gl.Float(0.25 * s)
+		q.X = (m[1].Z - m[2].Y) / gl.Float(s)
+		q.Y = (m[2].X - m[0].Z) / gl.Float(s)
+		q.Z = (m[0].Y - m[1].X) / gl.Float(s)
+	case m00 > m11 && m00 > m22:
+		s := math.Sqrt(1.0+m00-m11-m22) * 2
+		q.W = (m[1].Z - m[2].Y) / gl.Float(s)
+		q.X = gl.Float(0.25 * s)
+		q.Y = (m[1].X + m[0].Y) / gl.Float(s)
+		q.Z = (m[2].X + m[0].Z) / gl.Float(s)
+	case m11 > m22:
+		s := math.Sqrt(1.0+m11-m00-m22) * 2
+		q.W = (m[2].X - m[0].Z) / gl.Float(s)
+		q.X = (m[1].X + m[0].Y) / gl.Float(s)
+		q.Y = gl.Float(0.25 * s)
+		q.Z = (m[2].Y + m[1].Z) / gl.Float(s)
+	default:
+		s := math.Sqrt(1.0+m22-m00-m11) * 2
+		q.W = (m[0].Y - m[1].X) / gl.Float(s)
+		q.X = (m[2].X + m[0].Z) / gl.Float(s)
+		q.Y = (m[2].Y + m[1].Z) / gl.Float(s)
+		q.Z = gl.Float(0.25 * s)
+	}
+	return q.Normalize()
+}
+
+// QuatFromMat4 extracts the rotation from the upper-left 3x3 of m.
+func QuatFromMat4(m *Mat4) *Quat {
+	return QuatFromMat3(m.Mat3())
+}
+
+// Mul returns q * o, the rotation o followed by q.
+func (q *Quat) Mul(o *Quat) *Quat {
+	return &Quat{
+		q.W*o.W - q.X*o.X - q.Y*o.Y - q.Z*o.Z,
+		q.W*o.X + q.X*o.W + q.Y*o.Z - q.Z*o.Y,
+		q.W*o.Y - q.X*o.Z + q.Y*o.W + q.Z*o.X,
+		q.W*o.Z + q.X*o.Y - q.Y*o.X + q.Z*o.W,
+	}
+}
+
+func (q *Quat) Dot(o *Quat) gl.Float {
+	return q.W*o.W + q.X*o.X + q.Y*o.Y + q.Z*o.Z
+}
+
+func (q *Quat) Length() gl.Float {
+	return SqrtGL(q.Dot(q))
+}
+
+func (q *Quat) Normalize() *Quat {
+	l := q.Length()
+	if l == 0 {
+		return QuatIdent()
+	}
+	return &Quat{q.W / l, q.X / l, q.Y / l, q.Z / l}
+}
+
+func (q *Quat) Conjugate() *Quat {
+	return &Quat{q.W, -q.X, -q.Y, -q.Z}
+}
+
+func (q *Quat) Inverse() *Quat {
+	d := q.Dot(q)
+	if d == 0 {
+		return QuatIdent()
+	}
+	c := q.Conjugate()
+	return &Quat{c.W / d, c.X / d, c.Y / d, c.Z / d}
+}
+
+// AxisAngle is the inverse of QuatFromAxisAngle.  The identity comes back as
+// a zero rotation around +X.
+func (q *Quat) AxisAngle() (*Vec3, gl.Float) {
+	n := q.Normalize()
+	s := SqrtGL(1.0 - n.W*n.W)
+	if s < 1e-6 {
+		return &Vec3{1.0, 0.0, 0.0}, 0.0
+	}
+	fAngRad := 2.0 * gl.Float(math.Acos(float64(Clamp(n.W, -1.0, 1.0))))
+	return &Vec3{n.X / s, n.Y / s, n.Z / s}, RadToDeg(fAngRad)
+}
+
+// Rotate applies q to v.
+func (q *Quat) Rotate(v *Vec3) *Vec3 {
+	return q.Mat3().MulV(v)
+}
+
+func (q *Quat) Mat3() *Mat3 {
+	n := q.Normalize()
+	xx, yy, zz := n.X*n.X, n.Y*n.Y, n.Z*n.Z
+	xy, xz, yz := n.X*n.Y, n.X*n.Z, n.Y*n.Z
+	wx, wy, wz := n.W*n.X, n.W*n.Y, n.W*n.Z
+	return &Mat3{
+		{1 - 2*(yy+zz), 2 * (xy + wz), 2 * (xz - wy)},
+		{2 * (xy - wz), 1 - 2*(xx+zz), 2 * (yz + wx)},
+		{2 * (xz + wy), 2 * (yz - wx), 1 - 2*(xx+yy)},
+	}
+}
+
+func (q *Quat) Mat4() *Mat4 {
+	return q.Mat3().Mat4()
+}
+
+// Nlerp is a normalized linear interpolation - cheap, and close enough to
+// Slerp for small steps.  It takes the short way around.
+func (q *Quat) Nlerp(o *Quat, t gl.Float) *Quat {
+	b := *o
+	if q.Dot(o) < 0 {
+		b = Quat{-o.W, -o.X, -o.Y, -o.Z}
+	}
+	r := &Quat{
+		Mix(q.W, b.W, t),
+		Mix(q.X, b.X, t),
+		Mix(q.Y, b.Y, t),
+		Mix(q.Z, b.Z, t),
+	}
+	return r.Normalize()
+}
+
+// Slerp interpolates along the great arc from q to o at constant angular
+// speed, taking the short way around.
+func (q *Quat) Slerp(o *Quat, t gl.Float) *Quat {
+	b := *o
+	fDot := q.Dot(o)
+	if fDot < 0 {
+		b = Quat{-o.W, -o.X, -o.Y, -o.Z}
+		fDot = -fDot
+	}
+	// Nearly parallel, so the sines below blow up.
+	if fDot > 0.9995 {
+		return q.Nlerp(&b, t)
+	}
+	theta := math.Acos(float64(fDot))
+	sinTheta := math.Sin(theta)
+	s0 := gl.Float(math.Sin((1-float64(t))*theta) / sinTheta)
+	s1 := gl.Float(math.Sin(float64(t)*theta) / sinTheta)
+	r := &Quat{
+		q.W*s0 + b.W*s1,
+		q.X*s0 + b.X*s1,
+		q.Y*s0 + b.Y*s1,
+		q.Z*s0 + b.Z*s1,
+	}
+	return r.Normalize()
+}
@@ -0,0 +1,101 @@
+package glutil
+
+import (
+	gl "github.com/chsc/gogl/gl33"
+	"math"
+	"testing"
+)
+
+// quatNear allows for q and -q being the same rotation.
+func quatNear(a, b *Quat, eps float64) bool {
+	return math.Abs(math.Abs(float64(a.Dot(b)))-1) < eps
+}
+
+func TestQuatMatrices(t *testing.T) {
+	tests := []struct {
+		axis Vec3
+		deg  gl.Float
+		m    *Mat4
+	}{
+		{Vec3{X: 1}, 90, RotateXMat4(90)},
+		{Vec3{Y: 1}, -30, RotateYMat4(-30)},
+		{Vec3{Z: 1}, 45, RotateZMat4(45)},
+		// The half turns take each of QuatFromMat3's branches.
+		{Vec3{X: 1}, 180, RotateXMat4(180)},
+		{Vec3{Y: 1}, 180, RotateYMat4(180)},
+		{Vec3{Z: 1}, 180, RotateZMat4(180)},
+		{Vec3{X: 1, Y: 2, Z: 3}, 200, RotateAxisMat4(&Vec3{X: 1, Y: 2, Z: 3}, 200)},
+	}
+	for _, tt := range tests {
+		q := QuatFromAxisAngle(&tt.axis, tt.deg)
+		if !mat4Near(q.Mat4(), tt.m, 1e-6) {
+			t.Errorf("%v %g: matrix %v, want %v", tt.axis, tt.deg, *q.Mat4(), *tt.m)
+		}
+		if back := QuatFromMat4(tt.m); !quatNear(back, q, 1e-6) {
+			t.Errorf("%v %g: from the matrix %v, want %v", tt.axis, tt.deg, *back, *q)
+		}
+		v := Vec3{X: 0.3, Y: -2, Z: 1}
+		if !vec3Near(q.Rotate(&v), tt.m.TransformDir(&v), 1e-5) {
+			t.Errorf("%v %g: rotates %v to %v", tt.axis, tt.deg, v, *q.Rotate(&v))
+		}
+	}
+
+	// QuatFromEuler matches RotateZ, RotateY, RotateX on a stack.
+	want := RotateZMat4(30).MulM(RotateYMat4(-60)).MulM(RotateXMat4(15))
+	if got := QuatFromEuler(15, -60, 30).Mat4(); !mat4Near(got, want, 1e-6) {
+		t.Errorf("QuatFromEuler: %v, want %v", *got, *want)
+	}
+	q := QuatFromAxisAngle(&Vec3{Y: 1}, 50).Mul(QuatFromAxisAngle(&Vec3{X: 1}, 20))
+	if !mat4Near(q.Mat4(), RotateYMat4(50).MulM(RotateXMat4(20)), 1e-6) {
+		t.Errorf("Mul is not the matrix product")
+	}
+	if !quatNear(q.Mul(q.Inverse()), QuatIdent(), 1e-6) {
+		t.Errorf("q * q.Inverse() is %v", *q.Mul(q.Inverse()))
+	}
+
+	axis, deg := QuatFromAxisAngle(&Vec3{X: 0, Y: 3, Z: 4}, 70).AxisAngle()
+	if !vec3Near(axis, &Vec3{Y: 0.6, Z: 0.8}, 1e-5) || math.Abs(float64(deg)-70) > 1e-3 {
+		t.Errorf("AxisAngle: %v %g", *axis, deg)
+	}
+}
+
+func TestQuatInterpolation(t *testing.T) {
+	a := QuatFromAxisAngle(&Vec3{Z: 1}, 10)
+	b := QuatFromAxisAngle(&Vec3{Z: 1}, 130)
+	// -b is the same rotation as b, and both take the short way to it.
+	negB := &Quat{W: -b.W, X: -b.X, Y: -b.Y, Z: -b.Z}
+	tests := []struct {
+		t    gl.Float
+		want gl.Float // degrees about z
+	}{
+		{0, 10},
+		{1, 130},
+		{0.5, 70},
+		{0.25, 40},
+	}
+	for _, to := range []*Quat{b, negB} {
+		for _, tt := range tests {
+			want := QuatFromAxisAngle(&Vec3{Z: 1}, tt.want)
+			if got := a.Slerp(to, tt.t); !quatNear(got, want, 1e-6) {
+				t.Errorf("Slerp %g: %v, want %v", tt.t, *got, *want)
+			}
+		}
+		// Nlerp agrees at the ends and the middle, but not in between.
+		for _, tt := range tests[:3] {
+			want := QuatFromAxisAngle(&Vec3{Z: 1}, tt.want)
+			if got := a.Nlerp(to, tt.t); !quatNear(got, want, 1e-6) {
+				t.Errorf("Nlerp %g: %v, want %v", tt.t, *got, *want)
+			}
+		}
+	}
+	if quatNear(a.Nlerp(b, 0.25), QuatFromAxisAngle(&Vec3{Z: 1}, 40), 1e-6) {
+		t.Errorf("Nlerp moves at a constant speed")
+	}
+
+	// Nearly the same rotation falls back to Nlerp rather than dividing by
+	// nothing.
+	c := QuatFromAxisAngle(&Vec3{Z: 1}, 10.5)
+	if got := a.Slerp(c, 0.5); !quatNear(got, QuatFromAxisAngle(&Vec3{Z: 1}, 10.25), 1e-6) {
+		t.Errorf("Slerp of nearby rotations: %v", *got)
+	}
+}
@@ -0,0 +1,240 @@
+/*
+reload.go - a Program that rebuilds itself when its shader files change on
+disk.  Call Poll once a frame, between draws; it stats the source files (and
+everything they #include) and recompiles when any of them is newer.
+
+A broken edit doesn't take the demo down: the old program stays in use and
+the error is printed, and the next save gets another try.
+
+A reload swaps the new program into the same *Program, so holding on to
+it is fine, but its ID changes and the old one is deleted.  Anything that
+kept the ID, or a location from UniformLoc or AttribLoc, has to look it up
+again - the Set* methods do.
+*/
+package glutil
+
+import (
+	"fmt"
+	gl "github.com/chsc/gogl/gl33"
+	"os"
+	"time"
+)
+
+type ReloadProgram struct {
+	*Program
+
+	// How often Poll actually looks at the files.
+	Interval time.Duration
+	// The last compile or link failure, nil once a reload works.
+	LastError error
+	// Called with the program after a successful reload, before it is
+	// used - for anything that isn't a plain uniform, like block bindings.
+	// It's the same *Program as always, with the new ID.
+	OnReload func(p *Program)
+
+	deps     map[string]time.Time
+	lastPoll time.Time
+}
+
+func NewReloadProgram(shaderFiles []string, defines map[string]string) (*ReloadProgram, error) {
+	p, err := NewProgramDefines(shaderFiles, defines)
+	if err != nil {
+		return nil, err
+	}
+	r := &ReloadProgram{Program: p, Interval: 250 * time.Millisecond}
+	r.deps = Dependencies(shaderFiles, defines)
+	return r, nil
+}
+
+// Dependencies lists every file the shaders read, includes and all, with
+// its modification time.  Files that can't be read are left out.
+func Dependencies(shaderFiles []string, defines map[string]string) map[string]time.Time {
+	deps := make(map[string]time.Time)
+	for _, file := range shaderFiles {
+		files := []string{file}
+		if src, err := Preprocess(file, defines); err == nil {
+			files = src.Files
+		}
+		for _, f := range files {
+			if fi, err := os.Stat(f); err == nil {
+				deps[f] = fi.ModTime()
+			}
+		}
+	}
+	return deps
+}
+
+// changed reports whether any dependency has been touched since we last
+// looked.
+func (r *ReloadProgram) changed() bool {
+	for f, mtime := range r.deps {
+		fi, err := os.Stat(f)
+		if err != nil {
+			// Editors often delete and rewrite; wait for it to come back.
+			continue
+		}
+		if !fi.ModTime().Equal(mtime) {
+			return true
+		}
+	}
+	return false
+}
+
+// Poll recompiles the program if its sources have changed, and returns true
+// if the program was replaced - see Reload for what that invalidates.
+func (r *ReloadProgram) Poll() bool {
+	if time.Since(r.lastPoll) < r.Interval {
+		return false
+	}
+	r.lastPoll = time.Now()
+	if !r.changed() {
+		return false
+	}
+	return r.Reload()
+}
+
+// Reload recompiles unconditionally.  On failure the current program is
+// kept, the error is printed and stored in LastError.  On success the new
+// program takes the old one's place in r.Program and the old GL program is
+// deleted, so IDs and locations taken from it are stale.
+func (r *ReloadProgram) Reload() bool {
+	// Pick up the new set of includes first, so fixing a broken include
+	// is noticed too.
+	r.deps = Dependencies(r.Files, r.Defines)
+
+	p, err := NewProgramDefines(r.Files, r.Defines)
+	if err != nil {
+		r.LastError = err
+		fmt.Fprintf(os.Stderr, "Reload failed, keeping program %d:\n%s\n", r.ID, err)
+		return false
+	}
+	r.LastError = nil
+
+	var current gl.Int
+	gl.GetIntegerv(gl.CURRENT_PROGRAM, &current)
+	copyUniforms(r.Program, p)
+
+	// Swap in place, so anyone holding r.Program has the new one
+	old := *r.Program
+	oldID := old.ID
+	*r.Program = *p
+	old.Delete()
+	if r.OnReload != nil {
+		r.OnReload(r.Program)
+	}
+	if gl.Uint(current) == oldID {
+		r.Use()
+	} else {
+		gl.UseProgram(gl.Uint(current))
+	}
+	fmt.Fprintf(os.Stdout, "Reloaded %v as program %d\n", r.Files, r.ID)
+	return true
+}
+
+// Component counts for copying uniforms, and whether they're ints.
+type uniformSize struct {
+	n     int
+	isInt bool
+}
+
+var uniformSizes = map[gl.Enum]uniformSize{
+	gl.FLOAT:      {1, false},
+	gl.FLOAT_VEC2: {2, false},
+	gl.FLOAT_VEC3: {3, false},
+	gl.FLOAT_VEC4: {4, false},
+	gl.FLOAT_MAT2: {4, false},
+	gl.FLOAT_MAT3: {9, false},
+	gl.FLOAT_MAT4: {16, false},
+	gl.INT:        {1, true},
+	gl.INT_VEC2:   {2, true},
+	gl.INT_VEC3:   {3, true},
+	gl.INT_VEC4:   {4, true},
+	gl.BOOL:       {1, true},
+	gl.BOOL_VEC2:  {2, true},
+	gl.BOOL_VEC3:  {3, true},
+	gl.BOOL_VEC4:  {4, true},
+}
+
+func init() {
+	// Samplers are texture units, which SetInt sets.
+	for _, t := range intTypes {
+		if _, ok := uniformSizes[t]; !ok {
+			uniformSizes[t] = uniformSize{1, true}
+		}
+	}
+}
+
+// copyUniforms carries uniform values over from old to fresh, so things set
+// once at startup (the projection matrix, sampler units) survive a reload.
+// Uniforms that changed type or vanished are skipped.  It leaves fresh in use.
+func copyUniforms(old, fresh *Program) {
+	fresh.Use()
+	for name, nv := range fresh.Uniforms {
+		ov, ok := old.Uniforms[name]
+		size, known := uniformSizes[nv.Type]
+		if !ok || !known || ov.Type != nv.Type || nv.Location < 0 || ov.Location < 0 {
+			continue
+		}
+		count := int(nv.Size)
+		if ov.Size < nv.Size {
+			count = int(ov.Size)
+		}
+		for i := 0; i < count; i++ {
+			oloc, nloc := ov.Location, nv.Location
+			if i > 0 {
+				elem := fmt.Sprintf("%s[%d]", name, i)
+				oloc, nloc = uniformLocation(old.ID, elem), uniformLocation(fresh.ID, elem)
+				if oloc < 0 || nloc < 0 {
+					continue
+				}
+			}
+			if size.isInt {
+				var v [4]gl.Int
+				gl.GetUniformiv(old.ID, oloc, &v[0])
+				setUniformiv(nloc, size.n, &v[0])
+			} else {
+				var v [16]gl.Float
+				gl.GetUniformfv(old.ID, oloc, &v[0])
+				setUniformfv(nv.Type, nloc, &v[0])
+			}
+		}
+	}
+}
+
+func uniformLocation(program gl.Uint, name string) gl.Int {
+	glName := gl.GLString(name)
+	defer gl.GLStringFree(glName)
+	return gl.GetUniformLocation(program, glName)
+}
+
+func setUniformiv(loc gl.Int, n int, v *gl.Int) {
+	switch n {
+	case 1:
+		gl.Uniform1iv(loc, 1, v)
+	case 2:
+		gl.Uniform2iv(loc, 1, v)
+	case 3:
+		gl.Uniform3iv(loc, 1, v)
+	case 4:
+		gl.Uniform4iv(loc, 1, v)
+	}
+}
+
+func setUniformfv(t gl.Enum, loc gl.Int, v *gl.Float) {
+	switch t {
+	case gl.FLOAT:
+		gl.Uniform1fv(loc, 1, v)
+	case gl.FLOAT_VEC2:
+		gl.Uniform2fv(loc, 1, v)
+	case gl.FLOAT_VEC3:
+		gl.Uniform3fv(loc, 1, v)
+	case gl.FLOAT_VEC4:
+		gl.Uniform4fv(loc, 1, v)
+	case gl.FLOAT_MAT2:
+		gl.UniformMatrix2fv(loc, 1, gl.FALSE, v)
+	case gl.FLOAT_MAT3:
+		gl.UniformMatrix3fv(loc, 1, gl.FALSE, v)
+	case gl.FLOAT_MAT4:
+		gl.UniformMatrix4fv(loc, 1, gl.FALSE, v)
+	}
+}
@@ -0,0 +1,323 @@
+/*
+scene.go - a node tree of meshes, cameras and lights, as imported from
+COLLADA.  Drawing walks the tree on a MatrixStack, the same way the
+tutorials draw their hand-built hierarchies.
+*/
+package glutil
+
+import (
+	"fmt"
+	gl "github.com/chsc/gogl/gl33"
+	"math"
+)
+
+type Node struct {
+	Name      string
+	Transform Mat4 // relative to the parent
+	Parent    *Node
+	Children  []*Node
+
+	// What's attached.  Any of these may be empty.
+	Meshes []*Mesh
+	Camera *Camera
+	Light  *Light
+}
+
+type Camera struct {
+	Name string
+
+	// Perspective cameras have a vertical field of view, or failing that a
+	// horizontal one.  Orthographic ones have a half height.  Aspect is
+	// what the camera was authored with, 0 if it didn't say.
+	Ortho  bool
+	YFov   gl.Float // degrees
+	XFov   gl.Float
+	YMag   gl.Float
+	Aspect gl.Float
+	ZNear  gl.Float
+	ZFar   gl.Float
+
+	// The camera's own axes relative to its node - it looks down -Z with +Y
+	// up.  Not the identity when the file was converted to Y-up.
+	Orient Mat4
+}
+
+type Light struct {
+	Name  string
+	Type  string // "ambient", "directional", "point" or "spot"
+	Color Vec3
+
+	// Point and spot lights fall off as
+	// 1 / (constant + linear*d + quadratic*d*d)
+	ConstantAttenuation  gl.Float
+	LinearAttenuation    gl.Float
+	QuadraticAttenuation gl.Float
+
+	// Spot lights only.
+	FalloffAngle    gl.Float // degrees
+	FalloffExponent gl.Float
+
+	// As for Camera.  Directional and spot lights shine down -Z.
+	Orient Mat4
+}
+
+type Scene struct {
+	Name  string
+	Roots []*Node
+}
+
+func NewNode(name string) *Node {
+	return &Node{Name: name, Transform: *IdentMat4()}
+}
+
+// AddChild attaches c under n.
+func (n *Node) AddChild(c *Node) {
+	c.Parent = n
+	n.Children = append(n.Children, c)
+}
+
+// WorldMatrix is the node's transform with all its parents'.
+func (n *Node) WorldMatrix() *Mat4 {
+	m := n.Transform
+	for p := n.Parent; p != nil; p = p.Parent {
+		m = *p.Transform.MulM(&m)
+	}
+	return &m
+}
+
+// Walk calls fn for n and everything below it, parents first.  Returning
+// false skips a node's children.
+func (n *Node) Walk(fn func(*Node) bool) {
+	if !fn(n) {
+		return
+	}
+	for _, c := range n.Children {
+		c.Walk(fn)
+	}
+}
+
+// Walk calls fn for every node in the scene, parents first.
+func (s *Scene) Walk(fn func(*Node) bool) {
+	for _, r := range s.Roots {
+		r.Walk(fn)
+	}
+}
+
+// Find returns the first node with the given name, or nil.
+func (s *Scene) Find(name string) *Node {
+	var found *Node
+	s.Walk(func(n *Node) bool {
+		if found == nil && n.Name == name {
+			found = n
+		}
+		return found == nil
+	})
+	return found
+}
+
+// Cameras returns the nodes with a camera on them, in file order.
+func (s *Scene) Cameras() []*Node {
+	var cams []*Node
+	s.Walk(func(n *Node) bool {
+		if n.Camera != nil {
+			cams = append(cams, n)
+		}
+		return true
+	})
+	return cams
+}
+
+// Lights returns the nodes with a light on them.
+func (s *Scene) Lights() []*Node {
+	var lights []*Node
+	s.Walk(func(n *Node) bool {
+		if n.Light != nil {
+			lights = append(lights, n)
+		}
+		return true
+	})
+	return lights
+}
+
+// Draw walks the scene on ms, calling draw for every mesh with ms holding
+// that mesh's model-to-world matrix on top.  ms is left as it was found.
+func (s *Scene) Draw(ms *MatrixStack, draw func(n *Node, m *Mesh, ms *MatrixStack)) {
+	for _, r := range s.Roots {
+		r.Draw(ms, draw)
+	}
+}
+
+func (n *Node) Draw(ms *MatrixStack, draw func(n *Node, m *Mesh, ms *MatrixStack)) {
+	ms.Push()
+	ms.ApplyMatrix(&n.Transform)
+	for _, m := range n.Meshes {
+		draw(n, m, ms)
+	}
+	for _, c := range n.Children {
+		c.Draw(ms, draw)
+	}
+	ms.Pop()
+}
+
+// ViewMatrix is the world-to-camera matrix for a camera on n.
+func (n *Node) ViewMatrix() *Mat4 {
+	frame := n.WorldMatrix()
+	if n.Camera != nil {
+		frame = frame.MulM(&n.Camera.Orient)
+	}
+	view, ok := frame.Inverse()
+	if !ok {
+		return IdentMat4()
+	}
+	return view
+}
+
+// Projection is the camera-to-clip matrix.  aspect is the window's width
+// over height, so the picture isn't stretched; pass 0 to use the camera's.
+func (c *Camera) Projection(aspect gl.Float) *Mat4 {
+	if aspect == 0 {
+		aspect = c.Aspect
+	}
+	if aspect == 0 {
+		aspect = 1.0
+	}
+	if c.Ortho {
+		return Ortho(-c.YMag*aspect, c.YMag*aspect, -c.YMag, c.YMag, c.ZNear, c.ZFar)
+	}
+	yFov := c.YFov
+	if yFov == 0 {
+		yFov = XFovToYFov(c.XFov, aspect)
+	}
+	return Perspective(yFov, aspect, c.ZNear, c.ZFar)
+}
+
+// XFovToYFov converts a horizontal field of view to a vertical one.
+func XFovToYFov(xFovDeg, aspect gl.Float) gl.Float {
+	x := float64(DegToRad(xFovDeg)) / 2.0
+	return RadToDeg(gl.Float(2.0 * math.Atan(math.Tan(x)/float64(aspect))))
+}
+
+// FlatMeshes returns every mesh in the scene with its node's world
+// transform baked into it, for formats that have no scene graph.  Meshes
+// on untransformed nodes come back as they are; the rest are copies with
+// float positions, normals and tangents, and triangles turned the other
+// way round if the transform mirrors them.
+func (s *Scene) FlatMeshes() []*Mesh {
+	var meshes []*Mesh
+	s.Walk(func(n *Node) bool {
+		world := n.WorldMatrix()
+		for _, m := range n.Meshes {
+			if *world == *IdentMat4() {
+				meshes = append(meshes, m)
+			} else {
+				meshes = append(meshes, m.Transformed(world))
+			}
+		}
+		return true
+	})
+	return meshes
+}
+
+// Transformed is a copy of the mesh moved by mat.
+func (m *Mesh) Transformed(mat *Mat4) *Mesh {
+	normalMat, ok := mat.Mat3().Inverse()
+	if !ok {
+		normalMat = IdentMat3()
+	}
+	normalMat = normalMat.Transpose()
+
+	out := NewMesh(m.Name)
+	for _, a := range m.Attribs {
+		b := *a
+		b.Data = append([]float64(nil), a.Data...)
+		if (a.Index == AttribPosition || a.Index == AttribNormal || a.Index == AttribTangent) && a.Size >= 3 {
+			b.Type, b.Integral = "float", false
+			t := attribTypes[a.Type]
+			for i := 0; i+a.Size <= len(b.Data); i += a.Size {
+				v := Vec3{gl.Float(b.Data[i]), gl.Float(b.Data[i+1]), gl.Float(b.Data[i+2])}
+				if t.normalized {
+					v = *v.MulS(gl.Float(1.0 / t.max))
+				}
+				switch a.Index {
+				case AttribPosition:
+					v = *mat.TransformPoint(&v)
+				case AttribNormal:
+					v = *normalMat.MulV(&v).Normalize()
+				default:
+					v = *mat.TransformDir(&v).Normalize()
+				}
+				for k := 0; k < 3; k++ {
+					b.Data[i+k] = float64(v.Elem(k))
+				}
+			}
+		}
+		out.Attribs = append(out.Attribs, &b)
+	}
+	mirrored := mat.Mat3().Determinant() < 0
+	for _, c := range m.Commands {
+		d := *c
+		d.Indices = append([]gl.Uint(nil), c.Indices...)
+		if tris := c.Triangles(); mirrored && tris != nil {
+			d.Cmd, d.Indexed, d.IndexType, d.Start = "triangles", true, "ushort", 0
+			if m.VertexCount > 0xffff {
+				d.IndexType = "uint"
+			}
+			d.Indices = d.Indices[:0]
+			for _, t := range tris {
+				d.Indices = append(d.Indices, gl.Uint(t[0]), gl.Uint(t[2]), gl.Uint(t[1]))
+			}
+			d.Count = len(d.Indices)
+		}
+		out.Commands = append(out.Commands, &d)
+	}
+	for name, indices := range m.VAOs {
+		if out.VAOs == nil {
+			out.VAOs = make(map[string][]gl.Uint)
+		}
+		out.VAOs[name] = append([]gl.Uint(nil), indices...)
+	}
+	out.Validate()
+	return out
+}
+
+// Position is where a light (or anything else) on n is in the world.
+func (n *Node) Position() *Vec3 {
+	return n.WorldMatrix().TransformPoint(&Vec3{0.0, 0.0, 0.0})
+}
+
+// Direction is the way a directional or spot light on n shines, in world
+// space.
+func (n *Node) Direction() *Vec3 {
+	frame := n.WorldMatrix()
+	if n.Light != nil {
+		frame = frame.MulM(&n.Light.Orient)
+	}
+	return frame.TransformDir(&Vec3{0.0, 0.0, -1.0}).Normalize()
+}
+
+// Debug dumps the tree to stdout.
+func (s *Scene) Debug() {
+	fmt.Printf("Scene %s\n", s.Name)
+	var dump func(n *Node, depth int)
+	dump = func(n *Node, depth int) {
+		indent := fmt.Sprintf("%*s", depth*2+2, "")
+		t := n.Transform[3]
+		fmt.Printf("%s%s at (%g, %g, %g)\n", indent, n.Name, t.X, t.Y, t.Z)
+		for _, m := range n.Meshes {
+			fmt.Printf("%s  mesh %s: %d vertices, %d triangles\n", indent, m.Name, m.VertexCount, len(m.Triangles()))
+		}
+		if c := n.Camera; c != nil {
+			fmt.Printf("%s  camera %s: yfov %g, aspect %g, near %g, far %g\n", indent, c.Name, c.YFov, c.Aspect, c.ZNear, c.ZFar)
+		}
+		if l := n.Light; l != nil {
+			fmt.Printf("%s  %s light %s: colour (%g, %g, %g), attenuation %g %g %g\n", indent, l.Type, l.Name,
+				l.Color.X, l.Color.Y, l.Color.Z, l.ConstantAttenuation, l.LinearAttenuation, l.QuadraticAttenuation)
+		}
+		for _, c := range n.Children {
+			dump(c, depth+1)
+		}
+	}
+	for _, r := range s.Roots {
+		dump(r, 0)
+	}
+}
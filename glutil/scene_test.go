@@ -0,0 +1,50 @@
+package glutil
+
+import "testing"
+
+// facesOut checks every triangle winds counter-clockwise seen from the
+// side its first corner's normal points to.
+func facesOut(m *Mesh) bool {
+	pos, norm := m.Attrib(AttribPosition), m.Attrib(AttribNormal)
+	for _, tri := range m.Triangles() {
+		a, b, c := attribVec3(pos, tri[0]), attribVec3(pos, tri[1]), attribVec3(pos, tri[2])
+		if b.Sub(a).Cross(c.Sub(a)).Dot(attribVec3(norm, tri[0])) <= 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestMeshTransformed(t *testing.T) {
+	m := loadColladaMesh(t, "../world_tut/texture_cube.dae")
+	if !facesOut(m) {
+		t.Fatal("texture_cube.dae winds inwards to begin with")
+	}
+	tests := []struct {
+		name     string
+		mat      *Mat4
+		mirrored bool
+	}{
+		{"rotate", RotateAxisMat4(&Vec3{X: 1, Y: 2, Z: 3}, 50), false},
+		{"scale", ScaleMat4(&Vec3{X: 2, Y: 0.5, Z: 3}), false},
+		{"mirror", ScaleMat4(&Vec3{X: -1, Y: 1, Z: 1}), true},
+		{"mirror twice", ScaleMat4(&Vec3{X: -1, Y: -1, Z: 1}), false},
+		{"mirror and move", TranslateMat4(&Vec3{X: 5}).MulM(ScaleMat4(&Vec3{X: 1, Y: 1, Z: -2})), true},
+	}
+	first := m.Commands[0].Indices[:3]
+	for _, tt := range tests {
+		out := m.Transformed(tt.mat)
+		if !facesOut(out) {
+			t.Errorf("%s: faces wind inwards", tt.name)
+		}
+		// A mirror swaps each triangle's last two corners.
+		got := out.Commands[0].Indices[:3]
+		flipped := got[0] == first[0] && got[1] == first[2] && got[2] == first[1]
+		if flipped != tt.mirrored {
+			t.Errorf("%s: first triangle %v, was %v", tt.name, got, first)
+		}
+		if p := attribVec3(out.Attrib(AttribPosition), 0); !vec3Near(p, tt.mat.TransformPoint(attribVec3(m.Attrib(AttribPosition), 0)), 1e-5) {
+			t.Errorf("%s: vertex 0 at %v", tt.name, *p)
+		}
+	}
+}
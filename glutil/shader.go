@@ -0,0 +1,317 @@
+/* Loads fragment and vertex shader code from the supplied files. */
+
+package glutil
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	gl "github.com/chsc/gogl/gl33"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Reads a file and returns its contents as a string.
+func ReadSourceFile(filename string) (string, error) {
+
+	fp, err := os.Open(filename)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ReadSourceFile: Could not open %s!\n", filename)
+		fmt.Fprintf(os.Stderr, "os.Open: %e\n", err)
+		return "", err
+	}
+	defer fp.Close()
+
+	r := bufio.NewReaderSize(fp, 4*1024)
+	var buffer bytes.Buffer
+	for {
+		line, err := r.ReadString('\n')
+		buffer.WriteString(line)
+		if err == io.EOF {
+			// We've read the last string. Make sure there's a null byte.
+			buffer.WriteByte('\000')
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	return buffer.String(), nil
+
+}
+
+// gl33 stops at 3.3, so the later stages aren't in it.  Nor is
+// glDispatchCompute: a compute program built here needs a 4.3 context, and
+// has to be run through bindings that have it.
+const (
+	TESS_EVALUATION_SHADER gl.Enum = 0x8E87
+	TESS_CONTROL_SHADER    gl.Enum = 0x8E88
+	COMPUTE_SHADER         gl.Enum = 0x91B9
+)
+
+// The file extensions CreateShaderProgram understands, short and long form.
+var stageExtensions = map[string]gl.Enum{
+	".vert":                 gl.VERTEX_SHADER,
+	".vertexshader":         gl.VERTEX_SHADER,
+	".frag":                 gl.FRAGMENT_SHADER,
+	".fragmentshader":       gl.FRAGMENT_SHADER,
+	".geom":                 gl.GEOMETRY_SHADER,
+	".geometryshader":       gl.GEOMETRY_SHADER,
+	".tesc":                 TESS_CONTROL_SHADER,
+	".tesscontrolshader":    TESS_CONTROL_SHADER,
+	".tese":                 TESS_EVALUATION_SHADER,
+	".tessevaluationshader": TESS_EVALUATION_SHADER,
+	".comp":                 COMPUTE_SHADER,
+	".computeshader":        COMPUTE_SHADER,
+}
+
+// The GL version each stage first appeared in, as major*10 + minor.
+var stageVersions = map[gl.Enum]int{
+	gl.VERTEX_SHADER:       20,
+	gl.FRAGMENT_SHADER:     20,
+	gl.GEOMETRY_SHADER:     32,
+	TESS_CONTROL_SHADER:    40,
+	TESS_EVALUATION_SHADER: 40,
+	COMPUTE_SHADER:         43,
+}
+
+// StageName gives a human name for a shader type, for messages.
+func StageName(shaderType gl.Enum) string {
+	switch shaderType {
+	case gl.VERTEX_SHADER:
+		return "vertex"
+	case gl.FRAGMENT_SHADER:
+		return "fragment"
+	case gl.GEOMETRY_SHADER:
+		return "geometry"
+	case TESS_CONTROL_SHADER:
+		return "tessellation control"
+	case TESS_EVALUATION_SHADER:
+		return "tessellation evaluation"
+	case COMPUTE_SHADER:
+		return "compute"
+	}
+	return fmt.Sprintf("unknown (0x%04X)", uint32(shaderType))
+}
+
+// ShaderType works out a shader's stage from its file extension.
+func ShaderType(filename string) (gl.Enum, error) {
+	ext := filepath.Ext(filename)
+	if t, ok := stageExtensions[strings.ToLower(ext)]; ok {
+		return t, nil
+	}
+	var accepted []string
+	for e := range stageExtensions {
+		accepted = append(accepted, e)
+	}
+	sort.Strings(accepted)
+	return 0, fmt.Errorf("%s: don't understand extension %q, accepted extensions: %s",
+		filename, ext, strings.Join(accepted, ", "))
+}
+
+// ValidateStages checks that a set of stages can be linked into one program:
+// compute shaders stand alone, everything else needs a vertex shader, and a
+// tessellation control shader is useless without an evaluation shader.
+func ValidateStages(shaderTypes []gl.Enum) error {
+	has := make(map[gl.Enum]bool)
+	for _, t := range shaderTypes {
+		if _, ok := stageVersions[t]; !ok {
+			return fmt.Errorf("unsupported shader type %s", StageName(t))
+		}
+		has[t] = true
+	}
+	switch {
+	case len(shaderTypes) == 0:
+		return fmt.Errorf("no shaders to link")
+	case has[COMPUTE_SHADER] && len(has) > 1:
+		return fmt.Errorf("compute shaders can't be linked with other stages")
+	case has[COMPUTE_SHADER]:
+		return nil
+	case !has[gl.VERTEX_SHADER]:
+		return fmt.Errorf("program has no vertex shader")
+	case has[TESS_CONTROL_SHADER] && !has[TESS_EVALUATION_SHADER]:
+		return fmt.Errorf("tessellation control shader without an evaluation shader")
+	}
+	return nil
+}
+
+// checkStageVersion says whether a context of version have, as from
+// contextVersion, can compile shaderType.  A context that couldn't say is
+// taken to be 2.x.
+func checkStageVersion(shaderType gl.Enum, have int) error {
+	need, ok := stageVersions[shaderType]
+	if !ok {
+		return fmt.Errorf("unsupported shader type %s", StageName(shaderType))
+	}
+	if have == 0 {
+		if need > 20 {
+			return fmt.Errorf("%s shaders need OpenGL %d.%d, context is older than 3.0",
+				StageName(shaderType), need/10, need%10)
+		}
+		return nil
+	}
+	if have < need {
+		return fmt.Errorf("%s shaders need OpenGL %d.%d, context is %d.%d",
+			StageName(shaderType), need/10, need%10, have/10, have%10)
+	}
+	return nil
+}
+
+// contextVersion is the current context's GL version as major*10 + minor,
+// or 0 if it can't be told (pre-3.0 contexts don't answer MAJOR_VERSION).
+func contextVersion() int {
+	var major, minor gl.Int
+	gl.GetIntegerv(gl.MAJOR_VERSION, &major)
+	gl.GetIntegerv(gl.MINOR_VERSION, &minor)
+	return int(major)*10 + int(minor)
+}
+
+// shaderInfoLog fetches the info log of a shader object.
+func shaderInfoLog(shaderId gl.Uint) string {
+	var infoLogLength gl.Int
+	gl.GetShaderiv(shaderId, gl.INFO_LOG_LENGTH, &infoLogLength)
+	if infoLogLength <= 0 {
+		return ""
+	}
+	errorMsg := gl.GLStringAlloc(gl.Sizei(infoLogLength))
+	defer gl.GLStringFree(errorMsg)
+	gl.GetShaderInfoLog(shaderId, gl.Sizei(infoLogLength), nil, errorMsg)
+	return gl.GoString(errorMsg)
+}
+
+// programInfoLog fetches the info log of a program object.
+func programInfoLog(programId gl.Uint) string {
+	var infoLogLength gl.Int
+	gl.GetProgramiv(programId, gl.INFO_LOG_LENGTH, &infoLogLength)
+	if infoLogLength <= 0 {
+		return ""
+	}
+	errorMsg := gl.GLStringAlloc(gl.Sizei(infoLogLength))
+	defer gl.GLStringFree(errorMsg)
+	gl.GetProgramInfoLog(programId, gl.Sizei(infoLogLength), nil, errorMsg)
+	return gl.GoString(errorMsg)
+}
+
+// Create and Compile a shader, and return its object.  A failed compile
+// comes back as a *ShaderError.
+func CreateShader(shaderType gl.Enum, filePath string) (gl.Uint, error) {
+	return CreateShaderDefines(shaderType, filePath, nil)
+}
+
+// CreateShaderDefines is CreateShader with extra #defines injected after the
+// #version line.  The source goes through Preprocess first, so #include
+// works, and errors in included files are reported against them.
+func CreateShaderDefines(shaderType gl.Enum, filePath string, defines map[string]string) (gl.Uint, error) {
+
+	if err := checkStageVersion(shaderType, contextVersion()); err != nil {
+		return 0, fmt.Errorf("CreateShader: %s: %s", filePath, err)
+	}
+
+	// Load the GLSL source code from the shader file, and expand includes
+	src, err := Preprocess(filePath, defines)
+	if err != nil {
+		return 0, err
+	}
+
+	// Create and compile the shader
+	shaderId := gl.CreateShader(shaderType)
+	fmt.Fprintf(os.Stdout, "Compiling shader: %s\n", filePath)
+	glslCode := gl.GLStringArray(src.Code)
+	defer gl.GLStringArrayFree(glslCode)
+	gl.ShaderSource(shaderId, gl.Sizei(len(glslCode)), &glslCode[0], nil)
+	gl.CompileShader(shaderId)
+
+	// Check the status of the compile - did it work?
+	var result gl.Int = gl.TRUE
+	gl.GetShaderiv(shaderId, gl.COMPILE_STATUS, &result)
+	infoLog := shaderInfoLog(shaderId)
+	if result == gl.FALSE {
+		gl.DeleteShader(shaderId)
+		serr := NewShaderError(src.Files[0], StageName(shaderType), infoLog)
+		serr.MapSources(src.Files)
+		return 0, serr
+	}
+	if len(strings.TrimSpace(strings.TrimRight(infoLog, "\x00"))) > 0 {
+		// Compiled, but the driver had something to say.
+		fmt.Fprintf(os.Stdout, "Shader info for %s: %s", filePath, infoLog)
+	}
+
+	return shaderId, nil
+}
+
+// LinkShaders links the compiled shader objects into a new program.  The
+// shaders are detached afterwards, so the caller can delete them.  files is
+// only used for error messages.
+func LinkShaders(files []string, shaderIds ...gl.Uint) (gl.Uint, error) {
+	var ProgramID gl.Uint = gl.CreateProgram()
+	for _, sid := range shaderIds {
+		gl.AttachShader(ProgramID, sid)
+	}
+	gl.LinkProgram(ProgramID)
+	for _, sid := range shaderIds {
+		gl.DetachShader(ProgramID, sid)
+	}
+
+	// Check the program
+	var result gl.Int = gl.TRUE
+	gl.GetProgramiv(ProgramID, gl.LINK_STATUS, &result)
+	infoLog := programInfoLog(ProgramID)
+	if result == gl.FALSE {
+		gl.DeleteProgram(ProgramID)
+		return 0, NewShaderError(strings.Join(files, ", "), "link", infoLog)
+	}
+	if len(strings.TrimSpace(strings.TrimRight(infoLog, "\x00"))) > 0 {
+		fmt.Fprintf(os.Stdout, "Program Info: %s\n", infoLog)
+	}
+	return ProgramID, nil
+}
+
+// CreateShaderProgram - compile the shaders defined by the files in the
+// slice, link them into a program and return the programID.  Compile and
+// link failures come back as a *ShaderError, and nothing is leaked.
+func CreateShaderProgram(shaderFiles []string) (gl.Uint, error) {
+	return CreateShaderProgramDefines(shaderFiles, nil)
+}
+
+// CreateShaderProgramDefines is CreateShaderProgram with defines injected
+// into every stage, so one set of files can build several variants.
+func CreateShaderProgramDefines(shaderFiles []string, defines map[string]string) (gl.Uint, error) {
+
+	// For each shader, figure out its extension, and check the stages make
+	// a program before compiling anything.
+	shaderTypes := make([]gl.Enum, len(shaderFiles))
+	for i, shader := range shaderFiles {
+		t, err := ShaderType(shader)
+		if err != nil {
+			return 0, err
+		}
+		shaderTypes[i] = t
+	}
+	if err := ValidateStages(shaderTypes); err != nil {
+		return 0, fmt.Errorf("%s: %s", strings.Join(shaderFiles, ", "), err)
+	}
+
+	var shaderIds []gl.Uint
+	defer func() {
+		for _, sid := range shaderIds {
+			gl.DeleteShader(sid)
+		}
+	}()
+	for i, shader := range shaderFiles {
+		sid, err := CreateShaderDefines(shaderTypes[i], shader, defines)
+		if err != nil {
+			return 0, err
+		}
+		shaderIds = append(shaderIds, sid)
+	}
+
+	ProgramID, err := LinkShaders(shaderFiles, shaderIds...)
+	if err != nil {
+		return 0, err
+	}
+	fmt.Fprintf(os.Stdout, "\nLoadShader completed, ProgramID: %d\n", ProgramID)
+	return ProgramID, nil
+}
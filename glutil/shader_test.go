@@ -0,0 +1,96 @@
+package glutil
+
+import (
+	gl "github.com/chsc/gogl/gl33"
+	"strings"
+	"testing"
+)
+
+func TestShaderType(t *testing.T) {
+	tests := []struct {
+		file string
+		want gl.Enum
+	}{
+		{"data/Standard.vert", gl.VERTEX_SHADER},
+		{"StandardShading.vertexshader", gl.VERTEX_SHADER},
+		{"a.frag", gl.FRAGMENT_SHADER},
+		{"a.FragmentShader", gl.FRAGMENT_SHADER},
+		{"a.geom", gl.GEOMETRY_SHADER},
+		{"a.tesc", TESS_CONTROL_SHADER},
+		{"a.tese", TESS_EVALUATION_SHADER},
+		{"a.comp", COMPUTE_SHADER},
+		{"a.computeshader", COMPUTE_SHADER},
+	}
+	for _, tt := range tests {
+		got, err := ShaderType(tt.file)
+		if err != nil || got != tt.want {
+			t.Errorf("ShaderType(%q) = %s, %v, want %s", tt.file, StageName(got), err, StageName(tt.want))
+		}
+	}
+
+	_, err := ShaderType("a.glsl")
+	if err == nil || !strings.Contains(err.Error(), `don't understand extension ".glsl"`) ||
+		!strings.Contains(err.Error(), ".comp, .computeshader, .frag") {
+		t.Errorf("ShaderType(a.glsl): %v", err)
+	}
+}
+
+func TestValidateStages(t *testing.T) {
+	var vert, frag, geom gl.Enum = gl.VERTEX_SHADER, gl.FRAGMENT_SHADER, gl.GEOMETRY_SHADER
+	tests := []struct {
+		stages []gl.Enum
+		want   string // "" for none
+	}{
+		{[]gl.Enum{vert, frag}, ""},
+		{[]gl.Enum{vert}, ""},
+		{[]gl.Enum{vert, geom, frag}, ""},
+		{[]gl.Enum{vert, TESS_CONTROL_SHADER, TESS_EVALUATION_SHADER, frag}, ""},
+		{[]gl.Enum{vert, TESS_EVALUATION_SHADER, frag}, ""},
+		{[]gl.Enum{COMPUTE_SHADER}, ""},
+		{nil, "no shaders to link"},
+		{[]gl.Enum{frag}, "program has no vertex shader"},
+		{[]gl.Enum{geom, frag}, "program has no vertex shader"},
+		{[]gl.Enum{vert, TESS_CONTROL_SHADER, frag}, "tessellation control shader without an evaluation shader"},
+		{[]gl.Enum{COMPUTE_SHADER, vert, frag}, "compute shaders can't be linked with other stages"},
+		{[]gl.Enum{vert, 0x1234}, "unsupported shader type unknown (0x1234)"},
+	}
+	for _, tt := range tests {
+		err := ValidateStages(tt.stages)
+		got := ""
+		if err != nil {
+			got = err.Error()
+		}
+		if got != tt.want {
+			t.Errorf("ValidateStages(%v) = %q, want %q", tt.stages, got, tt.want)
+		}
+	}
+}
+
+func TestCheckStageVersion(t *testing.T) {
+	tests := []struct {
+		stage gl.Enum
+		have  int
+		want  string
+	}{
+		{gl.VERTEX_SHADER, 33, ""},
+		{gl.VERTEX_SHADER, 0, ""},
+		{gl.GEOMETRY_SHADER, 32, ""},
+		{gl.GEOMETRY_SHADER, 31, "geometry shaders need OpenGL 3.2, context is 3.1"},
+		{TESS_CONTROL_SHADER, 33, "tessellation control shaders need OpenGL 4.0, context is 3.3"},
+		{TESS_EVALUATION_SHADER, 41, ""},
+		{COMPUTE_SHADER, 42, "compute shaders need OpenGL 4.3, context is 4.2"},
+		{COMPUTE_SHADER, 43, ""},
+		{COMPUTE_SHADER, 0, "compute shaders need OpenGL 4.3, context is older than 3.0"},
+		{0x1234, 46, "unsupported shader type unknown (0x1234)"},
+	}
+	for _, tt := range tests {
+		err := checkStageVersion(tt.stage, tt.have)
+		got := ""
+		if err != nil {
+			got = err.Error()
+		}
+		if got != tt.want {
+			t.Errorf("%s at %d: %q, want %q", StageName(tt.stage), tt.have, got, tt.want)
+		}
+	}
+}
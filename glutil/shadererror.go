@@ -0,0 +1,195 @@
+/*
+shadererror.go - structured shader compile and link errors.
+
+Drivers all format their info logs differently.  ParseInfoLog understands
+the three we run into:
+
+	NVIDIA:       0(12) : error C0000: syntax error, unexpected '}'
+	Mesa:         0:12(5): error: `foo' undeclared
+	AMD / Intel:  ERROR: 0:12: 'foo' : undeclared identifier
+
+It is plain Go and needs no GL context.
+*/
+package glutil
+
+import (
+	"bufio"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type Severity int
+
+const (
+	SeverityError Severity = iota
+	SeverityWarning
+	SeverityInfo
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityError:
+		return "error"
+	case SeverityWarning:
+		return "warning"
+	}
+	return "info"
+}
+
+func parseSeverity(s string) Severity {
+	switch strings.ToLower(s) {
+	case "error", "fatal error":
+		return SeverityError
+	case "warning":
+		return SeverityWarning
+	}
+	return SeverityInfo
+}
+
+// A single message from a driver info log.  Line and Column are 1-based,
+// and zero when the driver didn't give one.
+type Diagnostic struct {
+	Source   int    // the source string index, almost always 0
+	File     string // the file Source came from, once MapSources is called
+	Line     int
+	Column   int
+	Severity Severity
+	Code     string // vendor error code, e.g. C0000 or #143
+	Message  string
+}
+
+func (d Diagnostic) String() string {
+	pos := strconv.Itoa(d.Line)
+	if d.Column > 0 {
+		pos += ":" + strconv.Itoa(d.Column)
+	}
+	msg := d.Message
+	if d.Code != "" {
+		msg = d.Code + ": " + msg
+	}
+	return fmt.Sprintf("%s: %s: %s", pos, d.Severity, msg)
+}
+
+var (
+	// 0(12) : error C0000: syntax error
+	nvidiaLog = regexp.MustCompile(`^(\d+)\((\d+)\)\s*:\s*(fatal error|error|warning)\s+(C\d+)\s*:\s*(.*)$`)
+	// 0:12(5): error: syntax error
+	mesaLog = regexp.MustCompile(`^(\d+):(\d+)\((\d+)\):\s*(error|warning|info)\s*:\s*(.*)$`)
+	// ERROR: 0:12: error(#143) Undeclared identifier foo
+	// WARNING: 0:3: extension not supported
+	amdLog = regexp.MustCompile(`^(ERROR|WARNING):\s*(\d+):(\d+):\s*(?:(?:error|warning)\((#\d+)\)\s*)?(.*)$`)
+)
+
+func atoi(s string) int {
+	i, _ := strconv.Atoi(s)
+	return i
+}
+
+// ParseInfoLog pulls the diagnostics out of a compile or link log.  Lines it
+// doesn't recognise, like AMD's "2 compilation errors" summary, are skipped.
+func ParseInfoLog(log string) []Diagnostic {
+	var diags []Diagnostic
+	scanner := bufio.NewScanner(strings.NewReader(log))
+	for scanner.Scan() {
+		line := strings.TrimSpace(strings.TrimRight(scanner.Text(), "\x00"))
+		if m := nvidiaLog.FindStringSubmatch(line); m != nil {
+			diags = append(diags, Diagnostic{
+				Source:   atoi(m[1]),
+				Line:     atoi(m[2]),
+				Severity: parseSeverity(m[3]),
+				Code:     m[4],
+				Message:  m[5],
+			})
+		} else if m := mesaLog.FindStringSubmatch(line); m != nil {
+			diags = append(diags, Diagnostic{
+				Source:   atoi(m[1]),
+				Line:     atoi(m[2]),
+				Column:   atoi(m[3]),
+				Severity: parseSeverity(m[4]),
+				Message:  m[5],
+			})
+		} else if m := amdLog.FindStringSubmatch(line); m != nil {
+			diags = append(diags, Diagnostic{
+				Source:   atoi(m[2]),
+				Line:     atoi(m[3]),
+				Severity: parseSeverity(m[1]),
+				Code:     m[4],
+				Message:  m[5],
+			})
+		}
+	}
+	return diags
+}
+
+// ShaderError is returned when a shader fails to compile, or a program fails
+// to link.  For link errors Stage is "link" and File lists every file.
+type ShaderError struct {
+	File        string
+	Stage       string
+	Log         string
+	Diagnostics []Diagnostic
+}
+
+func NewShaderError(file, stage, log string) *ShaderError {
+	return &ShaderError{
+		File:        file,
+		Stage:       stage,
+		Log:         strings.TrimRight(log, "\x00\n"),
+		Diagnostics: ParseInfoLog(log),
+	}
+}
+
+func (e *ShaderError) Error() string {
+	what := e.Stage + " shader compile"
+	if e.Stage == "link" {
+		what = "program link"
+	}
+	if len(e.Diagnostics) == 0 {
+		return fmt.Sprintf("%s: %s failed:\n%s", e.File, what, e.Log)
+	}
+	lines := []string{fmt.Sprintf("%s: %s failed:", e.File, what)}
+	for _, d := range e.Diagnostics {
+		file := e.File
+		if d.File != "" {
+			file = d.File
+		}
+		lines = append(lines, file+":"+d.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// MapSources fills in each diagnostic's File from the source string table
+// the preprocessor built, so errors in included files point at them.
+func (e *ShaderError) MapSources(files []string) {
+	for i := range e.Diagnostics {
+		if src := e.Diagnostics[i].Source; src >= 0 && src < len(files) {
+			e.Diagnostics[i].File = files[src]
+		}
+	}
+}
+
+// Annotate returns src, the contents of e.File, with each diagnostic printed
+// under the line it refers to, for showing failures in context.
+func (e *ShaderError) Annotate(src string) string {
+	byLine := make(map[int][]Diagnostic)
+	for _, d := range e.Diagnostics {
+		if d.File != "" && d.File != e.File {
+			continue
+		}
+		byLine[d.Line] = append(byLine[d.Line], d)
+	}
+	var out []string
+	for i, line := range strings.Split(strings.TrimRight(src, "\x00"), "\n") {
+		out = append(out, fmt.Sprintf("%4d  %s", i+1, line))
+		for _, d := range byLine[i+1] {
+			marker := "      "
+			if d.Column > 0 {
+				marker += strings.Repeat(" ", d.Column-1)
+			}
+			out = append(out, fmt.Sprintf("%s^ %s: %s", marker, d.Severity, d.Message))
+		}
+	}
+	return strings.Join(out, "\n")
+}
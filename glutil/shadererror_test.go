@@ -0,0 +1,129 @@
+package glutil
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseInfoLog(t *testing.T) {
+	tests := []struct {
+		name string
+		log  string
+		want []Diagnostic
+	}{
+		{"nvidia error", "0(12) : error C0000: syntax error, unexpected '}'\n",
+			[]Diagnostic{{Line: 12, Severity: SeverityError, Code: "C0000", Message: "syntax error, unexpected '}'"}}},
+		{"nvidia warning", "0(3) : warning C7022: unrecognized profile specifier \"foo\"",
+			[]Diagnostic{{Line: 3, Severity: SeverityWarning, Code: "C7022", Message: "unrecognized profile specifier \"foo\""}}},
+		{"nvidia fatal, second source", "1(7) : fatal error C9999: too many errors",
+			[]Diagnostic{{Source: 1, Line: 7, Severity: SeverityError, Code: "C9999", Message: "too many errors"}}},
+		{"mesa error", "0:12(5): error: `foo' undeclared\x00",
+			[]Diagnostic{{Line: 12, Column: 5, Severity: SeverityError, Message: "`foo' undeclared"}}},
+		{"mesa warning", "2:40(17): warning: `x' used uninitialized",
+			[]Diagnostic{{Source: 2, Line: 40, Column: 17, Severity: SeverityWarning, Message: "`x' used uninitialized"}}},
+		{"mesa info", "0:1(1): info: loop unrolled",
+			[]Diagnostic{{Line: 1, Column: 1, Severity: SeverityInfo, Message: "loop unrolled"}}},
+		{"amd error with code", "ERROR: 0:12: error(#143) Undeclared identifier foo",
+			[]Diagnostic{{Line: 12, Severity: SeverityError, Code: "#143", Message: "Undeclared identifier foo"}}},
+		{"amd without code", "ERROR: 0:4: 'foo' : undeclared identifier",
+			[]Diagnostic{{Line: 4, Severity: SeverityError, Message: "'foo' : undeclared identifier"}}},
+		{"amd warning", "WARNING: 0:3: extension not supported",
+			[]Diagnostic{{Line: 3, Severity: SeverityWarning, Message: "extension not supported"}}},
+		{"amd summary skipped", "ERROR: 0:2: error(#132) Syntax error\nERROR: error(#273) 1 compilation errors.  No code generated\n",
+			[]Diagnostic{{Line: 2, Severity: SeverityError, Code: "#132", Message: "Syntax error"}}},
+		{"noise skipped", "Vertex shader failed to compile with the following errors:\n\n   \n", nil},
+		{"mixed, in order", "0(1) : error C0001: a\n0:2(3): error: b\nERROR: 0:4: c\n",
+			[]Diagnostic{
+				{Line: 1, Severity: SeverityError, Code: "C0001", Message: "a"},
+				{Line: 2, Column: 3, Severity: SeverityError, Message: "b"},
+				{Line: 4, Severity: SeverityError, Message: "c"},
+			}},
+	}
+	for _, tt := range tests {
+		got := ParseInfoLog(tt.log)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDiagnosticString(t *testing.T) {
+	d := Diagnostic{Line: 12, Column: 5, Severity: SeverityWarning, Code: "C7022", Message: "hmm"}
+	if s := d.String(); s != "12:5: warning: C7022: hmm" {
+		t.Errorf("got %q", s)
+	}
+	d = Diagnostic{Line: 3, Severity: SeverityError, Message: "bad"}
+	if s := d.String(); s != "3: error: bad" {
+		t.Errorf("got %q", s)
+	}
+}
+
+func TestShaderErrorMapSources(t *testing.T) {
+	// The driver's source numbers are the ones the preprocessor's #line
+	// directives gave it.
+	files := map[string]string{
+		"main.frag":  "#version 330\n#include \"light.glsl\"\nvoid main() {\n\tbad;\n}\n",
+		"light.glsl": "#pragma once\nvec3 light() {\n\treturn nope;\n}\n",
+	}
+	pp := &Preprocessor{ReadFile: func(name string) ([]byte, error) {
+		return []byte(files[name]), nil
+	}}
+	src, err := pp.Process("main.frag")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(src.Files, []string{"main.frag", "light.glsl"}) {
+		t.Fatalf("files %v", src.Files)
+	}
+	for _, want := range []string{"#line 1 1\n", "#line 3 0\n"} {
+		if !strings.Contains(src.Code, want) {
+			t.Fatalf("no %q in\n%s", want, src.Code)
+		}
+	}
+
+	e := NewShaderError("main.frag", "fragment", "0:4(2): error: `bad' undeclared\n1:3(9): error: `nope' undeclared\n")
+	e.MapSources(src.Files)
+	if e.Diagnostics[0].File != "main.frag" || e.Diagnostics[1].File != "light.glsl" {
+		t.Fatalf("mapped to %q and %q", e.Diagnostics[0].File, e.Diagnostics[1].File)
+	}
+	msg := e.Error()
+	for _, want := range []string{
+		"main.frag: fragment shader compile failed:",
+		"main.frag:4:2: error: `bad' undeclared",
+		"light.glsl:3:9: error: `nope' undeclared",
+	} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("Error() = %q, missing %q", msg, want)
+		}
+	}
+
+	// Only main.frag's diagnostics go under its lines.
+	got := e.Annotate(files["main.frag"])
+	want := strings.Join([]string{
+		"   1  #version 330",
+		"   2  #include \"light.glsl\"",
+		"   3  void main() {",
+		"   4  \tbad;",
+		"       ^ error: `bad' undeclared",
+		"   5  }",
+		"   6  ",
+	}, "\n")
+	if got != want {
+		t.Errorf("Annotate:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestShaderErrorUnparsed(t *testing.T) {
+	e := NewShaderError("a.vert", "vertex", "something odd happened\x00")
+	if len(e.Diagnostics) != 0 {
+		t.Fatalf("diagnostics %v", e.Diagnostics)
+	}
+	if got := e.Error(); got != "a.vert: vertex shader compile failed:\nsomething odd happened" {
+		t.Errorf("got %q", got)
+	}
+	e = NewShaderError("a.vert a.frag", "link", "")
+	if got := e.Error(); !strings.HasPrefix(got, "a.vert a.frag: program link failed:") {
+		t.Errorf("got %q", got)
+	}
+}
@@ -0,0 +1,203 @@
+/*
+skin.go - skeletons, poses and skins for skeletal animation.
+
+A Skeleton is a flat list of joints with parents before children, so the
+world matrices of a pose come out of one pass down the list.  A Skin ties
+each vertex of a Mesh to up to MaxInfluences of those joints; its palette
+is what the vertices get multiplied by, here on the CPU or in a shader.
+*/
+package glutil
+
+import (
+	"fmt"
+	gl "github.com/chsc/gogl/gl33"
+)
+
+// Influences past this many per vertex are dropped, and the rest
+// renormalized.
+const MaxInfluences = 4
+
+type Skeleton struct {
+	Joints []*Joint
+	up     Mat4 // the file's up axis, see Local
+}
+
+type Joint struct {
+	Name   string
+	ID     string
+	SID    string
+	Parent int // -1 for the top of the tree
+
+	// The node's transform elements as they were authored.  Animation
+	// channels write into copies of these.
+	Elements []TransformElement
+	Rest     Mat4 // the local transform the file was saved with, Y-up
+}
+
+// One <translate>, <rotate>, <matrix>... of a node.
+type TransformElement struct {
+	Kind   string
+	SID    string
+	Values []gl.Float // matrices row-major, as in the file
+}
+
+// A Pose is a local transform for every joint of a skeleton.
+type Pose struct {
+	Skeleton *Skeleton
+	Local    []Mat4
+}
+
+type Skin struct {
+	Name     string
+	Mesh     *Mesh // in its bind shape
+	Skeleton *Skeleton
+
+	// Vertices go through BindShape, then each influencing joint's
+	// inverse bind matrix, then that joint's world matrix.
+	BindShape Mat4
+	Joints    []int // the skeleton joint for each of the skin's joints
+	InvBind   []Mat4
+
+	// Per mesh vertex, heaviest first.  Influences index Joints, or are
+	// len(Joints) for weight on the bind shape itself, which no joint
+	// moves; unused slots have a weight of 0.
+	Influences [][MaxInfluences]int
+	Weights    [][MaxInfluences]gl.Float
+
+	// How many vertices had more than MaxInfluences joints.
+	Capped int
+}
+
+// Find returns the joint with the given name, sid or id, or -1.
+func (s *Skeleton) Find(name string) int {
+	for i, j := range s.Joints {
+		if j.Name == name || j.SID == name || j.ID == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// Local multiplies out a joint's transform elements, and turns the result
+// into Y-up.
+func (s *Skeleton) Local(elems []TransformElement) (*Mat4, error) {
+	m := IdentMat4()
+	for i := range elems {
+		e, err := colladaTransformMatrix(elems[i].Kind, elems[i].Values)
+		if err != nil {
+			return nil, err
+		}
+		m = m.MulM(e)
+	}
+	// As Collada.toYUp.
+	return s.up.MulM(m).MulM(s.up.Transpose()), nil
+}
+
+// RestPose is the skeleton as the file left it.
+func (s *Skeleton) RestPose() *Pose {
+	p := &Pose{Skeleton: s, Local: make([]Mat4, len(s.Joints))}
+	for i, j := range s.Joints {
+		p.Local[i] = j.Rest
+	}
+	return p
+}
+
+// World is each joint's transform with all its parents'.
+func (p *Pose) World() []Mat4 {
+	world := make([]Mat4, len(p.Local))
+	for i, j := range p.Skeleton.Joints {
+		if j.Parent < 0 {
+			world[i] = p.Local[i]
+		} else {
+			world[i] = *world[j.Parent].MulM(&p.Local[i])
+		}
+	}
+	return world
+}
+
+// Palette is the matrix for each of the skin's joints in pose p, taking a
+// bind shape vertex to where the pose puts it.  One more on the end is for
+// weight on the bind shape, and leaves the vertex where it was bound.
+func (s *Skin) Palette(p *Pose) []Mat4 {
+	world := p.World()
+	palette := make([]Mat4, len(s.Joints)+1)
+	for i, j := range s.Joints {
+		palette[i] = *world[j].MulM(&s.InvBind[i]).MulM(&s.BindShape)
+	}
+	palette[len(s.Joints)] = s.BindShape
+	return palette
+}
+
+// Deform skins the mesh on the CPU, returning its positions and normals
+// (nil if it has none) in the pose the palette is for.
+func (s *Skin) Deform(palette []Mat4) (positions, normals []Vec3) {
+	pos := s.Mesh.Attrib(AttribPosition)
+	norm := s.Mesh.Attrib(AttribNormal)
+	if pos == nil {
+		return nil, nil
+	}
+	positions = make([]Vec3, len(s.Influences))
+	if norm != nil {
+		normals = make([]Vec3, len(s.Influences))
+	}
+	for v := range s.Influences {
+		m := s.blend(palette, v)
+		positions[v] = *m.TransformPoint(attribVec3(pos, v))
+		if norm != nil {
+			normals[v] = *m.TransformDir(attribVec3(norm, v)).Normalize()
+		}
+	}
+	return positions, normals
+}
+
+// blend is the weighted sum of vertex v's palette matrices.
+func (s *Skin) blend(palette []Mat4, v int) *Mat4 {
+	m := new(Mat4)
+	for k, j := range s.Influences[v] {
+		w := s.Weights[v][k]
+		if w == 0 {
+			break
+		}
+		for c := range m {
+			m[c] = *m[c].Add(palette[j][c].MulS(w))
+		}
+	}
+	return m
+}
+
+func attribVec3(a *MeshAttrib, v int) *Vec3 {
+	var p Vec3
+	for i := 0; i < a.Size && i < 3; i++ {
+		p.SetElem(i, gl.Float(a.Data[v*a.Size+i]))
+	}
+	return &p
+}
+
+// Debug dumps where the pose puts each joint to stdout.
+func (p *Pose) Debug() {
+	world := p.World()
+	for i, j := range p.Skeleton.Joints {
+		depth := 0
+		for k := j.Parent; k >= 0; k = p.Skeleton.Joints[k].Parent {
+			depth++
+		}
+		t := world[i][3]
+		fmt.Printf("%*s%s at (%g, %g, %g)\n", depth*2+2, "", j.Name, t.X, t.Y, t.Z)
+	}
+}
+
+// Debug dumps a summary of the skin to stdout.
+func (s *Skin) Debug() {
+	most := 0
+	for _, w := range s.Weights {
+		n := 0
+		for n < MaxInfluences && w[n] != 0 {
+			n++
+		}
+		if n > most {
+			most = n
+		}
+	}
+	fmt.Printf("Skin %s on %s: %d joints, %d vertices, up to %d influences each, %d capped\n",
+		s.Name, s.Mesh.Name, len(s.Joints), len(s.Influences), most, s.Capped)
+}
@@ -0,0 +1,148 @@
+package glutil
+
+import (
+	"encoding/xml"
+	gl "github.com/chsc/gogl/gl33"
+	"math"
+	"strings"
+	"testing"
+)
+
+func vec3Near(a, b *Vec3, eps float64) bool {
+	d := a.Sub(b)
+	return math.Abs(float64(d.X)) <= eps && math.Abs(float64(d.Y)) <= eps && math.Abs(float64(d.Z)) <= eps
+}
+
+// bindPose is the pose the skin was bound in: each joint where its
+// inverse bind matrix says.  Joints the skin doesn't use keep their rest
+// transform.
+func bindPose(t *testing.T, s *Skin) *Pose {
+	p := s.Skeleton.RestPose()
+	world := p.World()
+	bound := make(map[int]bool)
+	for i, j := range s.Joints {
+		m, ok := s.InvBind[i].Inverse()
+		if !ok {
+			t.Fatalf("joint %d's inverse bind matrix is singular", j)
+		}
+		world[j] = *m
+		bound[j] = true
+	}
+	for j, joint := range s.Skeleton.Joints {
+		switch {
+		case joint.Parent < 0:
+			p.Local[j] = world[j]
+		case bound[j]:
+			inv, _ := world[joint.Parent].Inverse()
+			p.Local[j] = *inv.MulM(&world[j])
+		default:
+			world[j] = *world[joint.Parent].MulM(&p.Local[j])
+		}
+	}
+	return p
+}
+
+func TestSkinDeformBindPose(t *testing.T) {
+	skins, _, err := LoadColladaSkins("../art/astroBoy_walk_Maya.dae")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := skins[0]
+	// In the bind pose each palette matrix is just the bind shape matrix,
+	// so the mesh comes out as it went in.  (astroBoy's nodes were saved on
+	// the walk's first frame, so its rest pose isn't this.)
+	positions, normals := s.Deform(s.Palette(bindPose(t, s)))
+	pos, norm := s.Mesh.Attrib(AttribPosition), s.Mesh.Attrib(AttribNormal)
+	if len(positions) != s.Mesh.VertexCount || len(normals) != s.Mesh.VertexCount {
+		t.Fatalf("%d positions and %d normals for %d vertices", len(positions), len(normals), s.Mesh.VertexCount)
+	}
+	for v := range positions {
+		want := s.BindShape.TransformPoint(attribVec3(pos, v))
+		if !vec3Near(&positions[v], want, 1e-3) {
+			t.Fatalf("vertex %d at %v, bind shape %v", v, positions[v], *want)
+		}
+		wantN := s.BindShape.TransformDir(attribVec3(norm, v)).Normalize()
+		if !vec3Near(&normals[v], wantN, 1e-3) {
+			t.Fatalf("vertex %d normal %v, bind shape %v", v, normals[v], *wantN)
+		}
+	}
+}
+
+func TestSkinDeformPosed(t *testing.T) {
+	skins, clip, err := LoadColladaSkins("../art/astroBoy_walk_Maya.dae")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sm, err := NewSkinnedMesh(skins[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := sm.Skin
+	pose := clip.Pose(0.5)
+
+	// The palette is what goes in the BonePalette block.
+	palette := s.Palette(pose)
+	world := pose.World()
+	if len(palette) != len(s.Joints)+1 || palette[len(s.Joints)] != s.BindShape {
+		t.Fatalf("%d matrices for %d joints", len(palette), len(s.Joints))
+	}
+	for i, j := range s.Joints {
+		want := world[j].MulM(&s.InvBind[i]).MulM(&s.BindShape)
+		if !mat4Near(&palette[i], want, 1e-5) {
+			t.Fatalf("palette %d (%s) is\n%v\nwant\n%v", i, clip.Skeleton.Joints[j].Name, palette[i], *want)
+		}
+	}
+
+	// Do what Skinned.vert does, from the attributes the GPU gets.
+	positions, _ := sm.Vertices(pose)
+	pos := sm.Mesh.Attrib(AttribPosition)
+	bones, weights := sm.Mesh.Attrib(AttribBoneIndices), sm.Mesh.Attrib(AttribBoneWeights)
+	moved := 0
+	for v := range positions {
+		p := attribVec3(pos, v)
+		var want Vec3
+		for k := 0; k < MaxInfluences; k++ {
+			w := weights.Data[v*MaxInfluences+k]
+			b := int(bones.Data[v*MaxInfluences+k])
+			want = *want.Add(palette[b].TransformPoint(p).MulS(gl.Float(w)))
+		}
+		if !vec3Near(&positions[v], &want, 1e-3) {
+			t.Fatalf("vertex %d at %v, shader would put it at %v", v, positions[v], want)
+		}
+		if !vec3Near(&positions[v], s.BindShape.TransformPoint(p), 1e-2) {
+			moved++
+		}
+	}
+	if moved == 0 {
+		t.Errorf("nothing moved at 0.5 seconds")
+	}
+}
+
+func TestSkinBindShapeWeight(t *testing.T) {
+	// The middle vertex is weighted to the bind shape alone, the last
+	// partly to it.
+	dae := strings.Replace(cappedSkinDAE, "2 6  -1 1 4 3", "-1 6  -1 1 4 3", 1)
+	var c Collada
+	if err := xml.Unmarshal([]byte(dae), &c); err != nil {
+		t.Fatal(err)
+	}
+	skins, _, err := c.ImportSkins()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sm, err := NewSkinnedMesh(skins[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	pose := sm.Skin.Skeleton.RestPose()
+	for j := range pose.Local {
+		pose.Local[j] = *TranslateMat4(&Vec3{Y: 5})
+	}
+	positions, _ := sm.Vertices(pose)
+	want := []Vec3{{Y: 5}, {X: 1}, {Y: 1 + 5*0.25/0.55}}
+	for v := range want {
+		if !vec3Near(&positions[v], &want[v], 1e-5) {
+			t.Errorf("vertex %d at %v, want %v", v, positions[v], want[v])
+		}
+	}
+}
@@ -0,0 +1,116 @@
+/*
+skinnedmesh.go - draws a Skin in any pose.  The bind shape mesh gets two more
+attributes, each vertex's bone indices and weights, and the pose's palette
+goes to the shader in a uniform buffer - see shaders/Skinned.vert.
+
+With CPU set the skinning is done here instead, and the positions and
+normals are uploaded again every draw; the shader wants CPU_SKINNING
+defined then.  Both ways add up the same weighted matrices, so Vertices
+gives what the GPU draws, without a context.
+*/
+package glutil
+
+import (
+	"fmt"
+	gl "github.com/chsc/gogl/gl33"
+)
+
+const (
+	MaxBones           = 64 // the size of the BonePalette block
+	BonePaletteBinding = 1  // the uniform buffer binding point it uses
+)
+
+type SkinnedMesh struct {
+	Skin *Skin
+	Mesh *Mesh // the bind shape plus AttribBoneIndices and AttribBoneWeights
+	CPU  bool
+
+	palette *UniformBuffer
+}
+
+// NewSkinnedMesh builds the mesh for skin.  No GL is touched until the
+// first Draw.
+func NewSkinnedMesh(skin *Skin) (*SkinnedMesh, error) {
+	// The palette has the bind shape after the joints.
+	if len(skin.Joints) >= MaxBones {
+		return nil, fmt.Errorf("skin %s has %d joints, the shader takes %d", skin.Name, len(skin.Joints), MaxBones-1)
+	}
+	src := skin.Mesh
+	if len(skin.Influences) != src.VertexCount {
+		return nil, fmt.Errorf("skin %s has weights for %d vertices, mesh %s has %d",
+			skin.Name, len(skin.Influences), src.Name, src.VertexCount)
+	}
+
+	// A copy, as the CPU path writes into it.
+	m := NewMesh(src.Name)
+	for _, a := range src.Attribs {
+		c := *a
+		c.Data = append([]float64(nil), a.Data...)
+		m.Attribs = append(m.Attribs, &c)
+	}
+	for _, cmd := range src.Commands {
+		c := *cmd
+		m.Commands = append(m.Commands, &c)
+	}
+
+	indices := &MeshAttrib{Index: AttribBoneIndices, Type: "ubyte", Size: MaxInfluences, Integral: true}
+	weights := &MeshAttrib{Index: AttribBoneWeights, Type: "float", Size: MaxInfluences}
+	for v := range skin.Influences {
+		for k := 0; k < MaxInfluences; k++ {
+			indices.Data = append(indices.Data, float64(skin.Influences[v][k]))
+			weights.Data = append(weights.Data, float64(skin.Weights[v][k]))
+		}
+	}
+	m.Attribs = append(m.Attribs, indices, weights)
+	if err := m.Validate(); err != nil {
+		return nil, err
+	}
+	return &SkinnedMesh{Skin: skin, Mesh: m}, nil
+}
+
+// Vertices skins the mesh on the CPU: the positions and normals Draw puts
+// on screen for pose p, before the model matrix.
+func (s *SkinnedMesh) Vertices(p *Pose) (positions, normals []Vec3) {
+	return s.Skin.Deform(s.Skin.Palette(p))
+}
+
+// Draw draws the mesh in pose p.  The caller has the program in use, with
+// its BonePalette block bound to BonePaletteBinding unless CPU is set.
+func (s *SkinnedMesh) Draw(p *Pose) {
+	palette := s.Skin.Palette(p)
+	if s.CPU {
+		positions, normals := s.Skin.Deform(palette)
+		s.setVec3s(AttribPosition, positions)
+		s.setVec3s(AttribNormal, normals)
+		s.Mesh.Render()
+		return
+	}
+	if s.palette == nil {
+		s.palette = NewUniformBuffer(BonePaletteBinding, MaxBones*64)
+	}
+	// Something else may have had the binding point since.
+	gl.BindBufferBase(gl.UNIFORM_BUFFER, BonePaletteBinding, s.palette.ID)
+	s.palette.SetMat4s(0, palette)
+	s.Mesh.Render()
+}
+
+func (s *SkinnedMesh) setVec3s(index gl.Uint, vs []Vec3) {
+	a := s.Mesh.Attrib(index)
+	if a == nil || vs == nil {
+		return
+	}
+	for v := range vs {
+		for i := 0; i < a.Size && i < 3; i++ {
+			a.Data[v*a.Size+i] = float64(vs[v].Elem(i))
+		}
+	}
+	s.Mesh.UpdateAttrib(index)
+}
+
+func (s *SkinnedMesh) Delete() {
+	s.Mesh.Delete()
+	if s.palette != nil {
+		s.palette.Delete()
+		s.palette = nil
+	}
+}
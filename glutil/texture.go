@@ -0,0 +1,230 @@
+/*
+texture.go - getting images off disk and into GL textures.
+
+A Texture is made from any image.Image - TGA and BMP are registered by
+glutil, PNG and JPEG by the standard library.  Mipmaps are built on the CPU
+(see mipmap.go) and the sampler state comes from TextureOptions.
+*/
+package glutil
+
+import (
+	"errors"
+	gl "github.com/chsc/gogl/gl33"
+	"image"
+	"image/draw"
+	_ "image/jpeg"
+	_ "image/png"
+	"os"
+	"unsafe"
+)
+
+// From EXT_texture_filter_anisotropic, which every desktop driver has but
+// gl33 doesn't know about.
+const (
+	TEXTURE_MAX_ANISOTROPY_EXT     gl.Enum = 0x84FE
+	MAX_TEXTURE_MAX_ANISOTROPY_EXT gl.Enum = 0x84FF
+)
+
+type TextureOptions struct {
+	WrapS, WrapT gl.Int // REPEAT, CLAMP_TO_EDGE, MIRRORED_REPEAT...
+	MinFilter    gl.Int // a *_MIPMAP_* filter only makes sense with Mipmaps
+	MagFilter    gl.Int
+	Anisotropy   gl.Float // 1 is off; clamped to what the driver allows
+	Mipmaps      bool
+	SRGB         bool // store as SRGB8_ALPHA8, so sampling returns linear colour
+	NonColor     bool // normal maps etc. - don't gamma-correct the mipmaps
+}
+
+// DefaultTextureOptions is what the tutorials have always used: repeat
+// wrapping and trilinear filtering.
+func DefaultTextureOptions() *TextureOptions {
+	return &TextureOptions{
+		WrapS:      gl.REPEAT,
+		WrapT:      gl.REPEAT,
+		MinFilter:  gl.LINEAR_MIPMAP_LINEAR,
+		MagFilter:  gl.LINEAR,
+		Anisotropy: 1.0,
+		Mipmaps:    true,
+	}
+}
+
+type Texture struct {
+	ID      gl.Uint
+	Width   int
+	Height  int
+	Levels  int
+	Options TextureOptions
+}
+
+// LoadImage decodes any format the image package knows about, which
+// includes TGA and BMP once glutil is imported.
+func LoadImage(imagePath string) (image.Image, error) {
+	fp, err := os.Open(imagePath)
+	if err != nil {
+		return nil, err
+	}
+	defer fp.Close()
+	img, _, err := image.Decode(fp)
+	return img, err
+}
+
+// LoadTexture reads an image file into a new texture.  opts may be nil for
+// the defaults.
+func LoadTexture(imagePath string, opts *TextureOptions) (*Texture, error) {
+	img, err := LoadImage(imagePath)
+	if err != nil {
+		return nil, err
+	}
+	return NewTexture(img, opts)
+}
+
+// NewTexture uploads img, and its mip chain if asked for, into a new
+// texture.  opts may be nil for the defaults.  The texture is left bound.
+func NewTexture(img image.Image, opts *TextureOptions) (*Texture, error) {
+	if opts == nil {
+		opts = DefaultTextureOptions()
+	}
+	b := img.Bounds()
+	if b.Empty() {
+		return nil, errors.New("NewTexture: empty image")
+	}
+	t := &Texture{Width: b.Dx(), Height: b.Dy(), Options: *opts}
+
+	levels := opts.MipLevels(img)
+	t.Levels = len(levels)
+
+	var internalFormat gl.Int = gl.RGBA8
+	if opts.SRGB {
+		internalFormat = gl.SRGB8_ALPHA8
+	}
+	gl.GenTextures(1, &t.ID)
+	gl.BindTexture(gl.TEXTURE_2D, t.ID)
+	for i, level := range levels {
+		texImage2D(gl.TEXTURE_2D, i, internalFormat, level)
+	}
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_BASE_LEVEL, 0)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAX_LEVEL, gl.Int(t.Levels-1))
+	t.SetWrap(opts.WrapS, opts.WrapT)
+	t.SetFilter(opts.MinFilter, opts.MagFilter)
+	t.SetAnisotropy(opts.Anisotropy)
+	return t, nil
+}
+
+// MipLevels is what NewTexture uploads for img: just img, or its whole mip
+// chain, gamma-corrected unless NonColor.
+func (opts *TextureOptions) MipLevels(img image.Image) []*image.NRGBA {
+	if !opts.Mipmaps {
+		return []*image.NRGBA{toNRGBA(img)}
+	}
+	return MipChain(img, !opts.NonColor)
+}
+
+// The setters below bind the texture to the active unit.
+
+func (t *Texture) SetWrap(s, tt gl.Int) {
+	gl.BindTexture(gl.TEXTURE_2D, t.ID)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_S, s)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_T, tt)
+	t.Options.WrapS, t.Options.WrapT = s, tt
+}
+
+func (t *Texture) SetFilter(min, mag gl.Int) {
+	// A mipmapped min filter on a texture with one level samples nothing.
+	if t.Levels == 1 {
+		switch min {
+		case gl.NEAREST_MIPMAP_NEAREST, gl.NEAREST_MIPMAP_LINEAR:
+			min = gl.NEAREST
+		case gl.LINEAR_MIPMAP_NEAREST, gl.LINEAR_MIPMAP_LINEAR:
+			min = gl.LINEAR
+		}
+	}
+	gl.BindTexture(gl.TEXTURE_2D, t.ID)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, min)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, mag)
+	t.Options.MinFilter, t.Options.MagFilter = min, mag
+}
+
+// SetAnisotropy sets the anisotropic filtering level, clamped to the
+// driver's maximum.  Values <= 1 turn it off.
+func (t *Texture) SetAnisotropy(a gl.Float) {
+	if a < 1.0 {
+		a = 1.0
+	}
+	var max gl.Float
+	gl.GetFloatv(MAX_TEXTURE_MAX_ANISOTROPY_EXT, &max)
+	if max < 1.0 {
+		// No extension; leave it alone.
+		t.Options.Anisotropy = 1.0
+		return
+	}
+	if a > max {
+		a = max
+	}
+	gl.BindTexture(gl.TEXTURE_2D, t.ID)
+	gl.TexParameterf(gl.TEXTURE_2D, TEXTURE_MAX_ANISOTROPY_EXT, a)
+	t.Options.Anisotropy = a
+}
+
+// Bind binds the texture to texture unit unit.
+func (t *Texture) Bind(unit int) {
+	gl.ActiveTexture(gl.TEXTURE0 + gl.Enum(unit))
+	gl.BindTexture(gl.TEXTURE_2D, t.ID)
+}
+
+// BindTo binds the texture to unit and points p's sampler uniform at it.
+// p must be in use.
+func (t *Texture) BindTo(p *Program, sampler string, unit int) {
+	t.Bind(unit)
+	p.SetInt(sampler, gl.Int(unit))
+}
+
+func (t *Texture) Delete() {
+	gl.DeleteTextures(1, &t.ID)
+	t.ID = 0
+}
+
+// toNRGBA returns img as non-premultiplied RGBA at the origin, copying only
+// if it has to.
+func toNRGBA(img image.Image) *image.NRGBA {
+	if n, ok := img.(*image.NRGBA); ok && n.Rect.Min == (image.Point{}) && n.Stride == 4*n.Rect.Dx() {
+		return n
+	}
+	b := img.Bounds()
+	n := image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(n, n.Bounds(), img, b.Min, draw.Src)
+	return n
+}
+
+// flipRows returns the pixels bottom row first, which is how GL wants them.
+func flipRows(n *image.NRGBA) []byte {
+	h := n.Rect.Dy()
+	pix := make([]byte, len(n.Pix))
+	for y := 0; y < h; y++ {
+		copy(pix[(h-1-y)*n.Stride:(h-y)*n.Stride], n.Pix[y*n.Stride:(y+1)*n.Stride])
+	}
+	return pix
+}
+
+func texImage2D(target gl.Enum, level int, internalFormat gl.Int, n *image.NRGBA) {
+	pix := flipRows(n)
+	gl.PixelStorei(gl.UNPACK_ALIGNMENT, 1)
+	gl.TexImage2D(target, gl.Int(level), internalFormat,
+		gl.Sizei(n.Rect.Dx()), gl.Sizei(n.Rect.Dy()), 0,
+		gl.RGBA, gl.UNSIGNED_BYTE, gl.Pointer(unsafe.Pointer(&pix[0])))
+}
+
+// TexImage2D uploads img to the currently bound texture at the given mip
+// level, as RGBA8.
+func TexImage2D(target gl.Enum, level int, img image.Image) {
+	texImage2D(target, level, gl.RGBA8, toNRGBA(img))
+}
+
+// UploadTexture2D makes a new texture from img with the default options,
+// and leaves it bound.
+func UploadTexture2D(img image.Image) (gl.Uint, error) {
+	t, err := NewTexture(img, nil)
+	if err != nil {
+		return 0, err
+	}
+	return t.ID, nil
+}
@@ -0,0 +1,265 @@
+/*
+tga.go - a Truevision TGA decoder, registered with the image package so
+image.Decode understands .tga files once glutil is imported.
+
+Handles uncompressed and RLE images, true-colour (15/16/24/32 bit),
+greyscale (8 bit, or 16 with alpha) and colour-mapped, in any of the four
+origins.  The result is always top-down, like every other image.Image.
+*/
+package glutil
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"io/ioutil"
+)
+
+const (
+	tgaColorMapped    = 1
+	tgaTrueColor      = 2
+	tgaGrey           = 3
+	tgaRLEColorMapped = 9
+	tgaRLETrueColor   = 10
+	tgaRLEGrey        = 11
+	tgaRightToLeft    = 0x10
+	tgaTopToBottom    = 0x20
+	tgaAlphaBitsMask  = 0x0f
+)
+
+type tgaHeader struct {
+	IDLength      uint8
+	ColorMapType  uint8
+	ImageType     uint8
+	ColorMapFirst uint16
+	ColorMapLen   uint16
+	ColorMapDepth uint8
+	XOrigin       uint16
+	YOrigin       uint16
+	Width         uint16
+	Height        uint16
+	Depth         uint8
+	Descriptor    uint8
+}
+
+func init() {
+	// TGA has no magic number; the colour map flag and image type in bytes
+	// 1 and 2 are the best we have.
+	for _, magic := range []string{
+		"?\x00\x02", "?\x00\x03", "?\x00\x0a", "?\x00\x0b",
+		"?\x01\x01", "?\x01\x09",
+	} {
+		image.RegisterFormat("tga", magic, DecodeTGA, DecodeTGAConfig)
+	}
+}
+
+func readTGAHeader(r io.Reader) (*tgaHeader, error) {
+	h := new(tgaHeader)
+	if err := binary.Read(r, binary.LittleEndian, h); err != nil {
+		return nil, fmt.Errorf("tga: reading header: %s", err)
+	}
+	switch h.ImageType {
+	case tgaColorMapped, tgaRLEColorMapped:
+		if h.ColorMapType != 1 {
+			return nil, errors.New("tga: colour-mapped image without a colour map")
+		}
+		if h.Depth != 8 {
+			return nil, fmt.Errorf("tga: unsupported colour-mapped depth %d", h.Depth)
+		}
+		switch h.ColorMapDepth {
+		case 15, 16, 24, 32:
+		default:
+			return nil, fmt.Errorf("tga: unsupported colour map depth %d", h.ColorMapDepth)
+		}
+	case tgaTrueColor, tgaRLETrueColor:
+		switch h.Depth {
+		case 15, 16, 24, 32:
+		default:
+			return nil, fmt.Errorf("tga: unsupported true-colour depth %d", h.Depth)
+		}
+	case tgaGrey, tgaRLEGrey:
+		if h.Depth != 8 && h.Depth != 16 {
+			return nil, fmt.Errorf("tga: unsupported greyscale depth %d", h.Depth)
+		}
+	default:
+		return nil, fmt.Errorf("tga: unsupported image type %d", h.ImageType)
+	}
+	if h.Width == 0 || h.Height == 0 {
+		return nil, errors.New("tga: empty image")
+	}
+	return h, nil
+}
+
+func (h *tgaHeader) colorModel() color.Model {
+	if (h.ImageType == tgaGrey || h.ImageType == tgaRLEGrey) && h.Depth == 8 {
+		return color.GrayModel
+	}
+	return color.NRGBAModel
+}
+
+// DecodeTGAConfig returns the size and colour model without reading the
+// pixels.
+func DecodeTGAConfig(r io.Reader) (image.Config, error) {
+	h, err := readTGAHeader(r)
+	if err != nil {
+		return image.Config{}, err
+	}
+	return image.Config{ColorModel: h.colorModel(), Width: int(h.Width), Height: int(h.Height)}, nil
+}
+
+// tgaColor turns a little-endian pixel of the given depth into a colour.
+// 16 bit pixels only have alpha if the descriptor says so.
+func tgaColor(p []byte, depth int, alpha bool) color.NRGBA {
+	switch depth {
+	case 15, 16:
+		v := uint16(p[0]) | uint16(p[1])<<8
+		expand := func(c uint16) uint8 { return uint8(c<<3 | c>>2) }
+		c := color.NRGBA{expand(v >> 10 & 0x1f), expand(v >> 5 & 0x1f), expand(v & 0x1f), 0xff}
+		if alpha && depth == 16 && v&0x8000 == 0 {
+			c.A = 0
+		}
+		return c
+	case 24:
+		return color.NRGBA{p[2], p[1], p[0], 0xff}
+	}
+	c := color.NRGBA{p[2], p[1], p[0], p[3]}
+	if !alpha {
+		c.A = 0xff
+	}
+	return c
+}
+
+// DecodeTGA reads a TGA image.  Greyscale images come back as *image.Gray
+// (or *image.NRGBA with alpha), everything else as *image.NRGBA.
+func DecodeTGA(r io.Reader) (image.Image, error) {
+	h, err := readTGAHeader(r)
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("tga: %s", err)
+	}
+	if int(h.IDLength) > len(data) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	data = data[h.IDLength:]
+
+	alpha := h.Descriptor&tgaAlphaBitsMask != 0
+
+	// The colour map, if any, comes before the pixels - even for true-colour
+	// images that don't use it.
+	var palette []color.NRGBA
+	if h.ColorMapType == 1 {
+		entry := (int(h.ColorMapDepth) + 7) / 8
+		size := entry * int(h.ColorMapLen)
+		if size > len(data) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		for i := 0; i < int(h.ColorMapLen); i++ {
+			// A 32 bit map always carries alpha.
+			palette = append(palette, tgaColor(data[i*entry:], int(h.ColorMapDepth), alpha || h.ColorMapDepth == 32))
+		}
+		data = data[size:]
+	}
+
+	// Unpack to one pixel per bpp bytes, in file order.
+	bpp := (int(h.Depth) + 7) / 8
+	w, ht := int(h.Width), int(h.Height)
+	n := w * ht * bpp
+	pixels := data
+	switch h.ImageType {
+	case tgaRLEColorMapped, tgaRLETrueColor, tgaRLEGrey:
+		if pixels, err = tgaUnRLE(data, n, bpp); err != nil {
+			return nil, err
+		}
+	}
+	if len(pixels) < n {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	var img image.Image
+	var set func(x, y int, p []byte) error
+	switch {
+	case h.colorModel() == color.GrayModel:
+		g := image.NewGray(image.Rect(0, 0, w, ht))
+		set = func(x, y int, p []byte) error {
+			g.Pix[y*g.Stride+x] = p[0]
+			return nil
+		}
+		img = g
+	default:
+		nrgba := image.NewNRGBA(image.Rect(0, 0, w, ht))
+		set = func(x, y int, p []byte) error {
+			var c color.NRGBA
+			switch h.ImageType {
+			case tgaColorMapped, tgaRLEColorMapped:
+				i := int(p[0]) - int(h.ColorMapFirst)
+				if i < 0 || i >= len(palette) {
+					return fmt.Errorf("tga: colour index %d out of range", p[0])
+				}
+				c = palette[i]
+			case tgaGrey, tgaRLEGrey:
+				c = color.NRGBA{p[0], p[0], p[0], p[1]}
+			default:
+				c = tgaColor(p, int(h.Depth), alpha)
+			}
+			o := y*nrgba.Stride + x*4
+			nrgba.Pix[o], nrgba.Pix[o+1], nrgba.Pix[o+2], nrgba.Pix[o+3] = c.R, c.G, c.B, c.A
+			return nil
+		}
+		img = nrgba
+	}
+
+	// TGAs are bottom-up unless they say otherwise.
+	for row := 0; row < ht; row++ {
+		y := ht - 1 - row
+		if h.Descriptor&tgaTopToBottom != 0 {
+			y = row
+		}
+		for col := 0; col < w; col++ {
+			x := col
+			if h.Descriptor&tgaRightToLeft != 0 {
+				x = w - 1 - col
+			}
+			if err := set(x, y, pixels[(row*w+col)*bpp:]); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return img, nil
+}
+
+// tgaUnRLE expands run-length packets into n bytes.  Packets are allowed to
+// run across scanlines.
+func tgaUnRLE(data []byte, n, bpp int) ([]byte, error) {
+	out := make([]byte, 0, n)
+	for len(out) < n {
+		if len(data) < 1 {
+			return nil, io.ErrUnexpectedEOF
+		}
+		count := int(data[0]&0x7f) + 1
+		run := data[0]&0x80 != 0
+		data = data[1:]
+		if run {
+			if len(data) < bpp {
+				return nil, io.ErrUnexpectedEOF
+			}
+			for i := 0; i < count; i++ {
+				out = append(out, data[:bpp]...)
+			}
+			data = data[bpp:]
+		} else {
+			if len(data) < count*bpp {
+				return nil, io.ErrUnexpectedEOF
+			}
+			out = append(out, data[:count*bpp]...)
+			data = data[count*bpp:]
+		}
+	}
+	// Some writers pad the last packet; ignore the overrun.
+	return out[:n], nil
+}
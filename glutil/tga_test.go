@@ -0,0 +1,202 @@
+package glutil
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"io"
+	"os"
+	"testing"
+)
+
+func decodeFile(t *testing.T, file string) image.Image {
+	f, err := os.Open(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	if err != nil {
+		t.Fatalf("%s: %s", file, err)
+	}
+	return img
+}
+
+func TestDecodeTGAFiles(t *testing.T) {
+	tests := []struct {
+		file string
+		w, h int
+	}{
+		{"../art/liske.tga", 256, 256},
+		{"../art/raine.tga", 281, 256},
+		{"../art/CDtest.tga", 256, 256},
+		{"../art/abcdef_texture.tga", 1536, 256},
+	}
+	for _, tt := range tests {
+		img := decodeFile(t, tt.file)
+		if b := img.Bounds(); b.Dx() != tt.w || b.Dy() != tt.h {
+			t.Errorf("%s: %dx%d, want %dx%d", tt.file, b.Dx(), b.Dy(), tt.w, tt.h)
+		}
+	}
+}
+
+func TestDecodeTGAMatchesBMP(t *testing.T) {
+	tga := decodeFile(t, "../art/CDtest.tga")
+	bmp := decodeFile(t, "../art/CDtest.BMP")
+	if tga.Bounds() != bmp.Bounds() {
+		t.Fatalf("bounds %v and %v", tga.Bounds(), bmp.Bounds())
+	}
+	b := tga.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c1 := color.NRGBAModel.Convert(tga.At(x, y))
+			c2 := color.NRGBAModel.Convert(bmp.At(x, y))
+			if c1 != c2 {
+				t.Fatalf("(%d, %d): tga %v, bmp %v", x, y, c1, c2)
+			}
+		}
+	}
+}
+
+// tgaBytes makes a TGA file from a header and whatever follows it.
+func tgaBytes(h tgaHeader, rest ...[]byte) []byte {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, &h)
+	for _, r := range rest {
+		buf.Write(r)
+	}
+	return buf.Bytes()
+}
+
+func nrgbaPixels(t *testing.T, data []byte) []color.NRGBA {
+	img, err := DecodeTGA(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var px []color.NRGBA
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			px = append(px, color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA))
+		}
+	}
+	return px
+}
+
+var (
+	red   = color.NRGBA{0xff, 0, 0, 0xff}
+	green = color.NRGBA{0, 0xff, 0, 0xff}
+	blue  = color.NRGBA{0, 0, 0xff, 0xff}
+	white = color.NRGBA{0xff, 0xff, 0xff, 0xff}
+)
+
+// 2x2, 24 bit BGR, in file order: red green, blue white.
+var tgaQuad = []byte{0, 0, 0xff, 0, 0xff, 0, 0xff, 0, 0, 0xff, 0xff, 0xff}
+
+func TestDecodeTGAOrigins(t *testing.T) {
+	tests := []struct {
+		name       string
+		descriptor uint8
+		want       []color.NRGBA // top-down, left to right
+	}{
+		{"bottom left", 0, []color.NRGBA{blue, white, red, green}},
+		{"top left", tgaTopToBottom, []color.NRGBA{red, green, blue, white}},
+		{"bottom right", tgaRightToLeft, []color.NRGBA{white, blue, green, red}},
+		{"top right", tgaTopToBottom | tgaRightToLeft, []color.NRGBA{green, red, white, blue}},
+	}
+	for _, tt := range tests {
+		h := tgaHeader{ImageType: tgaTrueColor, Width: 2, Height: 2, Depth: 24, Descriptor: tt.descriptor}
+		got := nrgbaPixels(t, tgaBytes(h, tgaQuad))
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestDecodeTGARLE(t *testing.T) {
+	// 3x2: a run of four reds across the scanline, then a raw green, blue
+	h := tgaHeader{ImageType: tgaRLETrueColor, Width: 3, Height: 2, Depth: 24, Descriptor: tgaTopToBottom}
+	rle := []byte{0x83, 0, 0, 0xff, 0x01, 0, 0xff, 0, 0xff, 0, 0}
+	want := []color.NRGBA{red, red, red, red, green, blue}
+	got := nrgbaPixels(t, tgaBytes(h, rle))
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+	}
+
+	// Packets that stop short are an error
+	if _, err := DecodeTGA(bytes.NewReader(tgaBytes(h, rle[:5]))); err != io.ErrUnexpectedEOF {
+		t.Errorf("short RLE: got %v", err)
+	}
+}
+
+func TestDecodeTGAColorMapped(t *testing.T) {
+	// Indices start at 4, the palette is 24 bit
+	h := tgaHeader{ColorMapType: 1, ImageType: tgaColorMapped, ColorMapFirst: 4, ColorMapLen: 3,
+		ColorMapDepth: 24, Width: 2, Height: 2, Depth: 8, Descriptor: tgaTopToBottom}
+	palette := []byte{0, 0, 0xff, 0, 0xff, 0, 0xff, 0, 0}
+	got := nrgbaPixels(t, tgaBytes(h, palette, []byte{4, 5, 6, 4}))
+	want := []color.NRGBA{red, green, blue, red}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+	}
+
+	// The same, run-length encoded: a run of two 6s and a raw 4 5
+	h.ImageType = tgaRLEColorMapped
+	got = nrgbaPixels(t, tgaBytes(h, palette, []byte{0x81, 6, 0x01, 4, 5}))
+	want = []color.NRGBA{blue, blue, red, green}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("RLE: got %v, want %v", got, want)
+		}
+	}
+
+	h.ImageType = tgaColorMapped
+	if _, err := DecodeTGA(bytes.NewReader(tgaBytes(h, palette, []byte{4, 5, 9, 4}))); err == nil {
+		t.Errorf("index 9 out of a 4..6 palette decoded")
+	}
+}
+
+func TestDecodeTGAGreyAndAlpha(t *testing.T) {
+	h := tgaHeader{ImageType: tgaGrey, Width: 2, Height: 1, Depth: 8}
+	img, err := DecodeTGA(bytes.NewReader(tgaBytes(h, []byte{0x10, 0xf0})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, ok := img.(*image.Gray)
+	if !ok || g.GrayAt(0, 0).Y != 0x10 || g.GrayAt(1, 0).Y != 0xf0 {
+		t.Errorf("8 bit grey: got %T %v", img, img)
+	}
+
+	// 32 bit keeps alpha only if the descriptor has alpha bits
+	h = tgaHeader{ImageType: tgaTrueColor, Width: 1, Height: 1, Depth: 32}
+	px := []byte{0x30, 0x20, 0x10, 0x80}
+	if got := nrgbaPixels(t, tgaBytes(h, px))[0]; got != (color.NRGBA{0x10, 0x20, 0x30, 0xff}) {
+		t.Errorf("32 bit, no alpha bits: %v", got)
+	}
+	h.Descriptor = 8
+	if got := nrgbaPixels(t, tgaBytes(h, px))[0]; got != (color.NRGBA{0x10, 0x20, 0x30, 0x80}) {
+		t.Errorf("32 bit with alpha: %v", got)
+	}
+
+	// 16 bit is 1-5-5-5, with the top bit for alpha
+	h = tgaHeader{ImageType: tgaTrueColor, Width: 2, Height: 1, Depth: 16, Descriptor: 1}
+	got := nrgbaPixels(t, tgaBytes(h, []byte{0x1f, 0x80, 0xe0, 0x03}))
+	if got[0] != (color.NRGBA{0, 0, 0xff, 0xff}) || got[1] != (color.NRGBA{0, 0xff, 0, 0}) {
+		t.Errorf("16 bit: %v", got)
+	}
+}
+
+func TestDecodeTGATruncated(t *testing.T) {
+	h := tgaHeader{ImageType: tgaTrueColor, Width: 2, Height: 2, Depth: 24}
+	if _, err := DecodeTGA(bytes.NewReader(tgaBytes(h, tgaQuad[:9]))); err != io.ErrUnexpectedEOF {
+		t.Errorf("got %v", err)
+	}
+}
@@ -0,0 +1,61 @@
+/*
+ubo.go - uniform buffers, for std140 uniform blocks that are shared between
+programs or too big to set a uniform at a time, like a skinning palette.
+
+A buffer sits on a binding point; Program.BindBlock points a block at the
+same binding point.
+*/
+package glutil
+
+import (
+	"fmt"
+	gl "github.com/chsc/gogl/gl33"
+)
+
+type UniformBuffer struct {
+	ID      gl.Uint
+	Binding gl.Uint
+	Size    int // bytes
+}
+
+// NewUniformBuffer makes a buffer of size bytes and binds it to binding.
+func NewUniformBuffer(binding gl.Uint, size int) *UniformBuffer {
+	b := &UniformBuffer{Binding: binding, Size: size}
+	gl.GenBuffers(1, &b.ID)
+	gl.BindBuffer(gl.UNIFORM_BUFFER, b.ID)
+	gl.BufferData(gl.UNIFORM_BUFFER, gl.Sizeiptr(size), nil, gl.STREAM_DRAW)
+	gl.BindBuffer(gl.UNIFORM_BUFFER, 0)
+	gl.BindBufferBase(gl.UNIFORM_BUFFER, binding, b.ID)
+	return b
+}
+
+// SetMat4s writes ms at byte offset.  A mat4 array is 64 bytes a matrix in
+// std140, the same as a []Mat4.  Whatever doesn't fit is dropped.
+func (b *UniformBuffer) SetMat4s(offset int, ms []Mat4) {
+	if n := (b.Size - offset) / 64; len(ms) > n {
+		ms = ms[:n]
+	}
+	if len(ms) == 0 {
+		return
+	}
+	gl.BindBuffer(gl.UNIFORM_BUFFER, b.ID)
+	gl.BufferSubData(gl.UNIFORM_BUFFER, gl.Intptr(offset), gl.Sizeiptr(len(ms)*64), gl.Pointer(ms[0].Ptr()))
+	gl.BindBuffer(gl.UNIFORM_BUFFER, 0)
+}
+
+func (b *UniformBuffer) Delete() {
+	gl.DeleteBuffers(1, &b.ID)
+	b.ID = 0
+}
+
+// BindBlock points the program's named uniform block at a binding point.
+func (p *Program) BindBlock(name string, binding gl.Uint) error {
+	glName := gl.GLString(name)
+	index := gl.GetUniformBlockIndex(p.ID, glName)
+	gl.GLStringFree(glName)
+	if index == gl.INVALID_INDEX {
+		return fmt.Errorf("no active uniform block %q in %v", name, p.Files)
+	}
+	gl.UniformBlockBinding(p.ID, index, binding)
+	return nil
+}
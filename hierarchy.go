@@ -1,9 +1,11 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	gl "github.com/chsc/gogl/gl33"
 	"github.com/go-gl/glfw"
+	glut "github.com/ysgard/opengl-go-tut/glutil"
 	//"math"
 	"os"
 	"runtime"
@@ -18,14 +20,11 @@ const (
 )
 
 // Shader vars
-var theProgram gl.Uint
+var theProgram *glut.Program
 var positionAttrib gl.Uint
 var colorAttrib gl.Uint
 
-var modelToCameraMatrixUnif gl.Int
-var cameraToClipMatrixUnif gl.Int
-
-var cameraToClipMatrix Mat4
+var cameraToClipMatrix glut.Mat4
 
 var shaderFiles = []string{
 	"shaders/PosColorLocalTransform.vert",
@@ -145,297 +144,183 @@ var indexData = []gl.Short{
 	22, 23, 20,
 }
 
-var gArmature Hierarchy
-
-const StandardAngleIncrement = 11.25
-const SmallAngleIncrement = 9.0
-
-// Hierarchy of objects
-type Hierarchy struct {
-	posBase      *Vec4
-	angBase      gl.Float
-	posBaseLeft  *Vec4
-	posBaseRight *Vec4
-	scaleBaseZ   gl.Float
-
-	angUpperArm   gl.Float
-	sizeUpperArm  gl.Float
-	posLowerArm   *Vec4
-	angLowerArm   gl.Float
-	lenLowerArm   gl.Float
-	widthLowerArm gl.Float
-
-	posWrist      *Vec4
-	angWristRoll  gl.Float
-	angWristPitch gl.Float
-	lenWrist      gl.Float
-	widthWrist    gl.Float
-
-	posLeftFinger  *Vec4
-	posRightFinger *Vec4
-	angFingerOpen  gl.Float
-	lenFinger      gl.Float
-	widthFinger    gl.Float
-	angLowerFinger gl.Float
-}
+// The robot arm, or whatever armature was named on the command line
+var gArmature *glut.Armature
 
-func (h *Hierarchy) Init() {
-	h.posBase = &Vec4{3.0, -5.0, -40.0, 1.0}
-	h.angBase = -45.0
-	h.posBaseLeft = &Vec4{2.0, 0.0, 0.0, 1.0}
-	h.posBaseRight = &Vec4{-2.0, 0.0, 0.0, 1.0}
-	h.scaleBaseZ = 3.0
-
-	h.angUpperArm = -33.75
-	h.sizeUpperArm = 9.0
-	h.posLowerArm = &Vec4{0.0, 0.0, 8.0, 1.0}
-	h.angLowerArm = 146.25
-	h.lenLowerArm = 5.0
-	h.widthLowerArm = 1.5
-
-	h.posWrist = &Vec4{0.0, 0.0, 5.0, 1.0}
-	h.angWristRoll = 0.0
-	h.angWristPitch = 67.5
-	h.lenWrist = 2.0
-	h.widthWrist = 2.0
-
-	h.posLeftFinger = &Vec4{1.0, 0.0, 1.0, 1.0}
-	h.posRightFinger = &Vec4{-1.0, 0.0, 1.0, 1.0}
-	h.angFingerOpen = 180.0
-	h.lenFinger = 2.0
-	h.widthFinger = 0.5
-	h.angLowerFinger = 45.0
-}
+var armatureFile = "art/robotarm.json"
+
+// Poses and keyframes.  o saves the pose and l loads it back; k keys the
+// pose a second after the last key, p plays or stops the timeline, m
+// changes how it plays and v saves it.
+var poseFile = flag.String("pose", "pose.json", "where o and l save and load the pose")
+var timelineFile = flag.String("timeline", "timeline.json", "timeline to play, and where v saves it")
+
+var gTimeline = &glut.Timeline{}
+var gPlaying = false
+var gPlayStart float64
+
+var playModes = []string{glut.PlayOnce, glut.PlayLoop, glut.PlayPingPong}
+
+// IK.  i puts a cursor on the gripper and the arrow keys and page up and
+// down drag it about, with the arm following; b swaps CCD for FABRIK.
+var gIKMode = false
+var gCursor glut.Vec3
+var gSolver = "ccd"
+var gReached = true
 
-func (h *Hierarchy) Draw() {
-	var modelToCameraStack MatrixStack
+const CursorStep = 0.5
+
+func DrawArmature() {
+	var modelToCameraStack glut.MatrixStack
 	modelToCameraStack.Init()
 
-	gl.UseProgram(theProgram)
+	theProgram.Use()
 	gl.BindVertexArray(vao)
 
-	modelToCameraStack.Translate(h.posBase)
-	modelToCameraStack.RotateY(h.angBase)
-
-	// Draw left base
-	modelToCameraStack.Push()
-	modelToCameraStack.Translate(h.posBaseLeft)
-	modelToCameraStack.Scale(&Vec4{1.0, 1.0, h.scaleBaseZ, 1.0})
-	gl.UniformMatrix4fv(modelToCameraMatrixUnif, 1, gl.FALSE,
-		&(modelToCameraStack.Top()[0].x))
-	gl.DrawElements(gl.TRIANGLES, (gl.Sizei)(len(indexData)), gl.UNSIGNED_SHORT, nil)
-	modelToCameraStack.Pop()
-
-	// Draw Right Base
-	modelToCameraStack.Push()
-	modelToCameraStack.Translate(h.posBaseRight)
-	modelToCameraStack.Scale(&Vec4{1.0, 1.0, h.scaleBaseZ, 1.0})
-	gl.UniformMatrix4fv(modelToCameraMatrixUnif, 1, gl.FALSE,
-		&(modelToCameraStack.Top()[0].x))
-	gl.DrawElements(gl.TRIANGLES, (gl.Sizei)(len(indexData)), gl.UNSIGNED_SHORT, nil)
-	modelToCameraStack.Pop()
-
-	// Draw Main Arm
-	h.DrawUpperArm(&modelToCameraStack)
+	// Every part is the same cube, scaled by its shape
+	gArmature.Draw(&modelToCameraStack, func(j *glut.Part, ms *glut.MatrixStack) {
+		theProgram.SetMat4("modelToCameraMatrix", ms.Current())
+		gl.DrawElements(gl.TRIANGLES, (gl.Sizei)(len(indexData)), gl.UNSIGNED_SHORT, nil)
+	})
+
+	if gIKMode {
+		modelToCameraStack.Push()
+		modelToCameraStack.ApplyMatrix(glut.TranslateMat4(&gCursor))
+		modelToCameraStack.Scale(&glut.Vec4{X: 0.3, Y: 0.3, Z: 0.3, W: 1.0})
+		theProgram.SetMat4("modelToCameraMatrix", modelToCameraStack.Current())
+		gl.DrawElements(gl.TRIANGLES, (gl.Sizei)(len(indexData)), gl.UNSIGNED_SHORT, nil)
+		modelToCameraStack.Pop()
+	}
 
 	gl.BindVertexArray(0)
 	gl.UseProgram(0)
 }
 
-func (h *Hierarchy) AdjBase(bIncrement bool) {
-	if bIncrement == true {
-		h.angBase += StandardAngleIncrement
-	} else {
-		h.angUpperArm -= StandardAngleIncrement
+func WritePose() {
+	fmt.Fprintf(os.Stdout, "*** POSE SETTINGS ***\n")
+	fmt.Fprintf(os.Stdout, "%s", gArmature.Pose().Format(gArmature))
+	for _, e := range gArmature.Effectors {
+		pos, _ := gArmature.Position(nil, e.Name)
+		fmt.Fprintf(os.Stdout, "%-15s %6.2f %6.2f %6.2f\n", e.Name+":", pos.X, pos.Y, pos.Z)
 	}
-	h.angBase = ModGL(h.angBase, 360.0)
+	fmt.Fprintf(os.Stdout, "\n")
 }
 
-func (h *Hierarchy) AdjUpperArm(bIncrement bool) {
-	if bIncrement == true {
-		h.angUpperArm += StandardAngleIncrement
-	} else {
-		h.angUpperArm -= StandardAngleIncrement
+// Timeline keys; true if the key was one of them.
+func timelineKey(key rune) bool {
+	switch key {
+	case 'o':
+		if err := gArmature.Pose().Save(*poseFile); err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err)
+			break
+		}
+		fmt.Fprintf(os.Stdout, "Saved pose to %s\n", *poseFile)
+	case 'l':
+		pose, err := glut.LoadPose(*poseFile)
+		if err == nil {
+			err = pose.Check(gArmature)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err)
+			break
+		}
+		gPlaying = false
+		gArmature.SetPose(pose)
+	case 'k':
+		t := 0.0
+		if len(gTimeline.Keys) > 0 {
+			t = gTimeline.Keys[len(gTimeline.Keys)-1].Time + 1.0
+		}
+		gTimeline.AddKey(t, gArmature.Pose())
+		fmt.Fprintf(os.Stdout, "Key %d at %.1fs\n", len(gTimeline.Keys), t)
+	case 'p':
+		gPlaying = !gPlaying && len(gTimeline.Keys) > 0
+		gPlayStart = glfw.Time()
+	case 'm':
+		next := 0
+		for i, m := range playModes {
+			if m == gTimeline.Mode {
+				next = (i + 1) % len(playModes)
+			}
+		}
+		gTimeline.Mode = playModes[next]
+		fmt.Fprintf(os.Stdout, "Play mode: %s\n", gTimeline.Mode)
+	case 'v':
+		if err := gTimeline.Save(*timelineFile); err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err)
+			break
+		}
+		fmt.Fprintf(os.Stdout, "Saved %d keys to %s\n", len(gTimeline.Keys), *timelineFile)
+	default:
+		return false
 	}
-	h.angUpperArm = Clamp(h.angUpperArm, -90.0, 0)
+	return true
 }
 
-func (h *Hierarchy) AdjLowerArm(bIncrement bool) {
-	if bIncrement == true {
-		h.angLowerArm += StandardAngleIncrement
-	} else {
-		h.angLowerArm -= StandardAngleIncrement
+// Reach for the cursor, and say when it goes in or out of reach
+func SolveIK() {
+	res, _ := gArmature.IK.Solve(gSolver, &gCursor)
+	if res.Reached != gReached {
+		if res.Reached {
+			fmt.Fprintf(os.Stdout, "Reached the cursor\n")
+		} else if res.InReach {
+			fmt.Fprintf(os.Stdout, "Can't bend to the cursor: %.2f off\n", res.Distance)
+		} else {
+			fmt.Fprintf(os.Stdout, "The cursor's out of reach: %.2f off\n", res.Distance)
+		}
 	}
-	h.angLowerArm = Clamp(h.angLowerArm, 0, 146.25)
+	gReached = res.Reached
 }
 
-func (h *Hierarchy) AdjWristPitch(bIncrement bool) {
-	if bIncrement == true {
-		h.angWristPitch += StandardAngleIncrement
-	} else {
-		h.angWristPitch -= StandardAngleIncrement
+// IK keys; true if the key was one of them.
+func ikKey(key int) bool {
+	if key == 'I' {
+		if gArmature.IK == nil {
+			fmt.Fprintf(os.Stderr, "%s has no IK chain\n", gArmature.Name)
+			return true
+		}
+		gIKMode = !gIKMode
+		gCursor = *gArmature.IK.Effector()
+		gReached = true
+		return true
 	}
-	h.angWristPitch = Clamp(h.angWristPitch, 0, 90.0)
-}
-
-func (h *Hierarchy) AdjWristRoll(bIncrement bool) {
-	if bIncrement == true {
-		h.angWristRoll += StandardAngleIncrement
-	} else {
-		h.angWristRoll -= StandardAngleIncrement
+	if !gIKMode {
+		return false
 	}
-	h.angWristRoll = ModGL(h.angWristRoll, 360.0)
-}
-
-func (h *Hierarchy) AdjFingerOpen(bIncrement bool) {
-	if bIncrement == true {
-		h.angFingerOpen += SmallAngleIncrement
-	} else {
-		h.angFingerOpen -= SmallAngleIncrement
+	switch key {
+	case 'B':
+		if gSolver == "ccd" {
+			gSolver = "fabrik"
+		} else {
+			gSolver = "ccd"
+		}
+		fmt.Fprintf(os.Stdout, "IK solver: %s\n", gSolver)
+	case glfw.KeyLeft:
+		gCursor.X -= CursorStep
+	case glfw.KeyRight:
+		gCursor.X += CursorStep
+	case glfw.KeyUp:
+		gCursor.Y += CursorStep
+	case glfw.KeyDown:
+		gCursor.Y -= CursorStep
+	case glfw.KeyPageup:
+		gCursor.Z -= CursorStep
+	case glfw.KeyPagedown:
+		gCursor.Z += CursorStep
+	default:
+		return false
 	}
-	h.angFingerOpen = Clamp(h.angFingerOpen, 9.0, 180.0)
-}
-
-func (h *Hierarchy) WritePose() {
-	fmt.Fprintf(os.Stdout, "*** POSE SETTINGS ***\n")
-	fmt.Fprintf(os.Stdout, "angBase:\t%6.2f\n", h.angBase)
-	fmt.Fprintf(os.Stdout, "angUpperArm:\t%6.2f\n", h.angUpperArm)
-	fmt.Fprintf(os.Stdout, "angLowerArm:\t%6.2f\n", h.angLowerArm)
-	fmt.Fprintf(os.Stdout, "angWristPitch:\t%6.2f\n", h.angWristPitch)
-	fmt.Fprintf(os.Stdout, "angWristRoll:\t%6.2f\n", h.angWristRoll)
-	fmt.Fprintf(os.Stdout, "angFingerOpen:\t%6.2f\n", h.angFingerOpen)
-	fmt.Fprintf(os.Stdout, "\n")
-}
-
-func (h *Hierarchy) DrawFingers(modelToCameraStack *MatrixStack) {
-	// Draw left finger
-	modelToCameraStack.Push()
-	modelToCameraStack.Translate(h.posLeftFinger)
-	modelToCameraStack.RotateY(h.angFingerOpen)
-
-	modelToCameraStack.Push()
-	modelToCameraStack.Translate(&Vec4{0.0, 0.0, h.lenFinger / 2.0, 1.0})
-	modelToCameraStack.Scale(&Vec4{h.widthFinger / 2.0,
-		h.widthFinger / 2.0, h.lenFinger / 2.0, 1.0})
-
-	gl.UniformMatrix4fv(modelToCameraMatrixUnif, 1, gl.FALSE,
-		&(modelToCameraStack.Top()[0].x))
-	gl.DrawElements(gl.TRIANGLES, (gl.Sizei)(len(indexData)),
-		gl.UNSIGNED_SHORT, nil)
-	modelToCameraStack.Pop()
-
-	// Draw left lower finger
-	modelToCameraStack.Push()
-	modelToCameraStack.Translate(&Vec4{0.0, 0.0, h.lenFinger, 1.0})
-	modelToCameraStack.RotateY(-h.angLowerFinger)
-
-	modelToCameraStack.Push()
-	modelToCameraStack.Translate(&Vec4{0.0, 0.0, h.lenFinger / 2.0, 1.0})
-	modelToCameraStack.Scale(&Vec4{h.widthFinger / 2.0, h.widthFinger / 2.0,
-		h.lenFinger / 2.0, 1.0})
-
-	gl.UniformMatrix4fv(modelToCameraMatrixUnif, 1, gl.FALSE,
-		&(modelToCameraStack.Top()[0].x))
-	gl.DrawElements(gl.TRIANGLES, (gl.Sizei)(len(indexData)),
-		gl.UNSIGNED_SHORT, nil)
-	modelToCameraStack.Pop()
-	modelToCameraStack.Pop()
-
-	modelToCameraStack.Pop()
-
-	// Draw right finger
-	modelToCameraStack.Push()
-	modelToCameraStack.Translate(h.posRightFinger)
-	modelToCameraStack.RotateY(-h.angFingerOpen)
-
-	modelToCameraStack.Push()
-	modelToCameraStack.Translate(&Vec4{0.0, 0.0, h.lenFinger / 2.0, 1.0})
-	modelToCameraStack.Scale(&Vec4{h.widthFinger / 2.0, h.widthFinger / 2.0,
-		h.lenFinger / 2.0, 1.0})
-	gl.UniformMatrix4fv(modelToCameraMatrixUnif, 1, gl.FALSE,
-		&(modelToCameraStack.Top()[0].x))
-	gl.DrawElements(gl.TRIANGLES, (gl.Sizei)(len(indexData)), gl.UNSIGNED_SHORT, nil)
-	modelToCameraStack.Pop()
-
-	// Draw right lower finger
-	modelToCameraStack.Push()
-	modelToCameraStack.Translate(&Vec4{0.0, 0.0, h.lenFinger, 1.0})
-	modelToCameraStack.RotateY(h.angLowerFinger)
-
-	modelToCameraStack.Push()
-	modelToCameraStack.Translate(&Vec4{0.0, 0.0, h.lenFinger / 2.0, 1.0})
-	modelToCameraStack.Scale(&Vec4{h.widthFinger / 2.0, h.widthFinger / 2.0,
-		h.lenFinger / 2.0, 1.0})
-
-	gl.UniformMatrix4fv(modelToCameraMatrixUnif, 1, gl.FALSE,
-		&(modelToCameraStack.Top()[0].x))
-	gl.DrawElements(gl.TRIANGLES, (gl.Sizei)(len(indexData)),
-		gl.UNSIGNED_SHORT, nil)
-
-	modelToCameraStack.Pop()
-	modelToCameraStack.Pop()
-	modelToCameraStack.Pop()
-}
-
-func (h *Hierarchy) DrawWrist(modelToCameraStack *MatrixStack) {
-	modelToCameraStack.Push()
-	modelToCameraStack.Translate(h.posWrist)
-	modelToCameraStack.RotateZ(h.angWristRoll)
-	modelToCameraStack.RotateX(h.angWristPitch)
-
-	modelToCameraStack.Push()
-	modelToCameraStack.Scale(&Vec4{h.widthWrist / 2.0, h.widthWrist / 2.0,
-		h.lenWrist / 2.0, 1.0})
-	gl.UniformMatrix4fv(modelToCameraMatrixUnif, 1, gl.FALSE,
-		&(modelToCameraStack.Top()[0].x))
-	gl.DrawElements(gl.TRIANGLES, (gl.Sizei)(len(indexData)), gl.UNSIGNED_SHORT, nil)
-	modelToCameraStack.Pop()
-
-	h.DrawFingers(modelToCameraStack)
-	modelToCameraStack.Pop()
-}
-
-func (h *Hierarchy) DrawLowerArm(modelToCameraStack *MatrixStack) {
-	modelToCameraStack.Push()
-	modelToCameraStack.Translate(h.posLowerArm)
-	modelToCameraStack.RotateX(h.angLowerArm)
-
-	modelToCameraStack.Push()
-	modelToCameraStack.Translate(&Vec4{0.0, 0.0, h.lenLowerArm / 2.0, 1.0})
-	modelToCameraStack.Scale(&Vec4{h.widthLowerArm / 2.0, h.widthLowerArm / 2.0,
-		h.lenLowerArm / 2.0, 1.0})
-
-	gl.UniformMatrix4fv(modelToCameraMatrixUnif, 1, gl.FALSE,
-		&(modelToCameraStack.Top()[0].x))
-	gl.DrawElements(gl.TRIANGLES, (gl.Sizei)(len(indexData)), gl.UNSIGNED_SHORT, nil)
-	modelToCameraStack.Pop()
-
-	h.DrawWrist(modelToCameraStack)
-	modelToCameraStack.Pop()
-}
-
-func (h *Hierarchy) DrawUpperArm(modelToCameraStack *MatrixStack) {
-	modelToCameraStack.Push()
-	modelToCameraStack.RotateX(h.angUpperArm)
-
-	modelToCameraStack.Push()
-	modelToCameraStack.Translate(&Vec4{0.0, 0.0, h.sizeUpperArm/2.0 - 1.0, 1.0})
-	modelToCameraStack.Scale(&Vec4{1.0, 1.0, h.sizeUpperArm / 2.0, 1.0})
-	gl.UniformMatrix4fv(modelToCameraMatrixUnif, 1, gl.FALSE,
-		&(modelToCameraStack.Top()[0].x))
-	gl.DrawElements(gl.TRIANGLES, (gl.Sizei)(len(indexData)), gl.UNSIGNED_SHORT, nil)
-	modelToCameraStack.Pop()
-
-	h.DrawLowerArm(modelToCameraStack)
-	modelToCameraStack.Pop()
+	gPlaying = false
+	SolveIK()
+	return true
 }
 
 // Frustum scale
 var fFrustumScale gl.Float
 
 func CalcFrustumScale(fFovDeg gl.Float) gl.Float {
-	fFovRad := fFovDeg * degToRad
-	return 1.0 / TanGL(fFovRad/2.0)
+	fFovRad := glut.DegToRad(fFovDeg)
+	return 1.0 / glut.TanGL(fFovRad/2.0)
 }
 
 // Initialize vertex array objects
@@ -480,24 +365,26 @@ func InitializeVAO() {
 func InitializeShaders() {
 
 	// Shader program creation, bind attributes
-	theProgram = CreateShaderProgram(shaderFiles)
-	positionAttrib = gl.Uint(gl.GetAttribLocation(theProgram, gl.GLString("position")))
-	colorAttrib = gl.Uint(gl.GetAttribLocation(theProgram, gl.GLString("color")))
-
-	modelToCameraMatrixUnif = (gl.Int)(gl.GetUniformLocation(theProgram, gl.GLString("modelToCameraMatrix")))
-	cameraToClipMatrixUnif = (gl.Int)(gl.GetUniformLocation(theProgram, gl.GLString("cameraToClipMatrix")))
+	var err error
+	theProgram, err = glut.NewProgram(shaderFiles)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
+	positionAttrib = theProgram.AttribLoc("position")
+	colorAttrib = theProgram.AttribLoc("color")
 
 	fzNear := gl.Float(1.0)
 	fzFar := gl.Float(100.0)
 
-	cameraToClipMatrix[0].x = fFrustumScale
-	cameraToClipMatrix[1].y = fFrustumScale
-	cameraToClipMatrix[2].z = (fzFar + fzNear) / (fzNear - fzFar)
-	cameraToClipMatrix[2].w = -1.0
-	cameraToClipMatrix[3].z = (2 * fzFar * fzNear) / (fzNear - fzFar)
+	cameraToClipMatrix[0].X = fFrustumScale
+	cameraToClipMatrix[1].Y = fFrustumScale
+	cameraToClipMatrix[2].Z = (fzFar + fzNear) / (fzNear - fzFar)
+	cameraToClipMatrix[2].W = -1.0
+	cameraToClipMatrix[3].Z = (2 * fzFar * fzNear) / (fzNear - fzFar)
 
-	gl.UseProgram(theProgram)
-	gl.UniformMatrix4fv(cameraToClipMatrixUnif, 1, gl.FALSE, &cameraToClipMatrix[0].x)
+	theProgram.Use()
+	theProgram.SetMat4("cameraToClipMatrix", &cameraToClipMatrix)
 	gl.UseProgram(0)
 }
 
@@ -542,16 +429,14 @@ func Initialize() {
 	gl.DepthFunc(gl.LEQUAL)
 	gl.DepthRange(0.0, 1.0)
 
-	// Initialize object hierarchy
-	gArmature.Init()
 }
 
 func reshape(w, h int) {
-	cameraToClipMatrix[0].x = fFrustumScale * (gl.Float)(h) / (gl.Float)(w)
-	cameraToClipMatrix[1].y = fFrustumScale
+	cameraToClipMatrix[0].X = fFrustumScale * (gl.Float)(h) / (gl.Float)(w)
+	cameraToClipMatrix[1].Y = fFrustumScale
 
-	gl.UseProgram(theProgram)
-	gl.UniformMatrix4fv(cameraToClipMatrixUnif, 1, gl.FALSE, &cameraToClipMatrix[0].x)
+	theProgram.Use()
+	theProgram.SetMat4("cameraToClipMatrix", &cameraToClipMatrix)
 	gl.UseProgram(0)
 
 	gl.Viewport(0, 0, (gl.Sizei)(w), (gl.Sizei)(h))
@@ -563,35 +448,23 @@ func keyboard(key, state int) {
 		case glfw.KeyEsc:
 			shutdown()
 			return
-		case 65: // a
-			gArmature.AdjBase(true)
-		case 68: // d
-			gArmature.AdjBase(false)
-		case 87: // w
-			gArmature.AdjUpperArm(false)
-		case 83: // s
-			gArmature.AdjUpperArm(true)
-		case 82: // r
-			gArmature.AdjLowerArm(false)
-		case 70: // f
-			gArmature.AdjLowerArm(true)
-		case 84: // t
-			gArmature.AdjWristPitch(false)
-		case 71: // g
-			gArmature.AdjWristPitch(true)
-		case 90: // z
-			gArmature.AdjWristRoll(true)
-		case 67: // c
-			gArmature.AdjWristRoll(false)
-		case 81: // q
-			gArmature.AdjFingerOpen(true)
-		case 69: // e
-			gArmature.AdjFingerOpen(false)
 		case glfw.KeyEnter:
-			gArmature.WritePose()
+			WritePose()
 		default:
-			return
-
+			if ikKey(key) {
+				return
+			}
+			// Letters come in as upper case
+			if key < 'A' || key > 'Z' {
+				return
+			}
+			if timelineKey(rune(key - 'A' + 'a')) {
+				return
+			}
+			if ax, bIncrement := gArmature.KeyAxis(rune(key - 'A' + 'a')); ax != nil {
+				gPlaying = false
+				ax.Adjust(bIncrement)
+			}
 		}
 	}
 }
@@ -610,7 +483,12 @@ func display() {
 	gl.ClearDepth(1.0)
 	gl.Clear(gl.COLOR_BUFFER_BIT | gl.DEPTH_BUFFER_BIT)
 
-	gArmature.Draw()
+	if gPlaying {
+		t := glfw.Time() - gPlayStart
+		gTimeline.Apply(gArmature, t)
+		gPlaying = !gTimeline.Done(t)
+	}
+	DrawArmature()
 
 	glfw.SwapBuffers()
 }
@@ -620,6 +498,26 @@ func main() {
 	// Sit. Down. Good boy.
 	runtime.LockOSThread()
 
+	flag.Parse()
+	if flag.NArg() > 0 {
+		armatureFile = flag.Arg(0)
+	}
+	var err error
+	if gArmature, err = glut.LoadArmature(armatureFile); err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
+	// Carry on from a saved timeline, if there is one
+	if _, err := os.Stat(*timelineFile); err == nil {
+		if gTimeline, err = glut.LoadTimeline(*timelineFile); err == nil {
+			err = gTimeline.Check(gArmature)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err)
+			os.Exit(1)
+		}
+	}
+
 	// Initialize subsystems
 	Initialize()
 	// Set the key handler for the main loop
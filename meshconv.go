@@ -0,0 +1,94 @@
+/* meshconv.go - converts meshes between the formats glutil reads: gltut
+XML, OBJ (with its MTL), COLLADA and glTF.  No GL needed.
+
+go run meshconv.go in.[xml|obj|dae|gltf|glb] out.[xml|obj|gltf|glb]
+
+OBJ and XML have no scene graph, so scenes are flattened into them, and an
+XML file holds a single mesh.  The writers are checked against the loaders
+by glutil's tests.
+*/
+
+package main
+
+import (
+	"fmt"
+	glut "github.com/ysgard/opengl-go-tut/glutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// load reads any format into a scene and its materials, which may be nil.
+func load(file string) (*glut.Scene, map[string]*glut.PBRMaterial, error) {
+	name := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".xml":
+		mesh, err := glut.LoadMeshFromXML(file)
+		if err != nil {
+			return nil, nil, err
+		}
+		mesh.Name = name
+		return glut.MeshScene(name, []*glut.Mesh{mesh}), nil, nil
+	case ".obj":
+		model, err := glut.LoadOBJ(file)
+		if err != nil {
+			return nil, nil, err
+		}
+		meshes, err := model.Meshes()
+		if err != nil {
+			return nil, nil, err
+		}
+		mats := make(map[string]*glut.PBRMaterial)
+		for name, m := range model.Materials {
+			mats[name] = m.PBR(filepath.Dir(file))
+		}
+		return glut.MeshScene(name, meshes), mats, nil
+	case ".dae":
+		s, err := glut.LoadColladaScene(file)
+		return s, nil, err
+	case ".gltf", ".glb":
+		return glut.LoadGLTFScene(file)
+	}
+	return nil, nil, fmt.Errorf("%s: don't know the format", file)
+}
+
+// save writes a scene in the format out's extension says.
+func save(out string, s *glut.Scene, mats map[string]*glut.PBRMaterial) error {
+	switch strings.ToLower(filepath.Ext(out)) {
+	case ".xml":
+		meshes := s.FlatMeshes()
+		if len(meshes) != 1 {
+			return fmt.Errorf("%s: a gltut mesh holds one mesh, not %d", out, len(meshes))
+		}
+		return meshes[0].SaveGLUTMesh(out)
+	case ".obj":
+		objMats := make(map[string]*glut.Material)
+		for name, m := range mats {
+			objMats[name] = m.OBJMaterial(filepath.Dir(out))
+		}
+		return glut.SaveOBJ(out, s.FlatMeshes(), objMats)
+	case ".gltf", ".glb":
+		return glut.SaveGLTF(out, s, mats)
+	}
+	return fmt.Errorf("%s: can't write that format", out)
+}
+
+func convert(in, out string) error {
+	s, mats, err := load(in)
+	if err != nil {
+		return err
+	}
+	return save(out, s, mats)
+}
+
+func main() {
+	args := os.Args[1:]
+	if len(args) != 2 {
+		fmt.Fprintf(os.Stderr, "usage: meshconv in out\n")
+		os.Exit(2)
+	}
+	if err := convert(args[0], args[1]); err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
+}
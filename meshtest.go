@@ -1,15 +1,106 @@
+/* meshtest.go - loads gltut XML meshes, the geometry, skins and
+animation in COLLADA files, or glTF scenes, and dumps them, no GL needed.
+
+go run meshtest.go [mesh.xml|scene.dae|scene.gltf|scene.glb...]
+*/
+
 package main
 
 import (
 	"fmt"
-	glut "github.com/Ysgard/goglutils"
+	glut "github.com/ysgard/opengl-go-tut/glutil"
+	"os"
+	"path/filepath"
+	"strings"
 )
 
-func main() {
-	mesh := glut.NewMesh("TestMesh")
-	err := mesh.LoadGLUTMesh("world_tut/UnitCylinderTint.xml")
+// loadCollada imports the geometry, and dumps the scene tree and any
+// skins and animation.
+func loadCollada(file string) ([]*glut.Mesh, error) {
+	c, err := glut.LoadCollada(file)
+	if err != nil {
+		return nil, err
+	}
+	if len(c.VisualScenes) > 0 {
+		scene, err := c.ImportScene()
+		if err != nil {
+			return nil, err
+		}
+		scene.Debug()
+	}
+	if len(c.Controllers) > 0 {
+		skins, skel, err := c.ImportSkins()
+		if err != nil {
+			return nil, err
+		}
+		clip, err := c.ImportAnimation(skel)
+		if err != nil {
+			return nil, err
+		}
+		for _, skin := range skins {
+			skin.Debug()
+		}
+		clip.Debug()
+		clip.Pose(clip.Start).Debug()
+	}
+	return c.Meshes()
+}
+
+// loadGLTF dumps the scene tree and materials, and imports every mesh.
+func loadGLTF(file string) ([]*glut.Mesh, error) {
+	g, err := glut.LoadGLTF(file)
 	if err != nil {
-		fmt.Printf("meshtest: Cannot load glutmesh:\n\t%s\n", err)
+		return nil, err
+	}
+	scene, err := g.ImportScene()
+	if err != nil {
+		return nil, err
+	}
+	scene.Debug()
+	for i := range g.Materials {
+		m, err := g.Material(i)
+		if err != nil {
+			return nil, err
+		}
+		m.Debug()
+	}
+	return g.AllMeshes()
+}
+
+func main() {
+	files := os.Args[1:]
+	if len(files) == 0 {
+		files = []string{"world_tut/UnitCylinderTint.xml", "world_tut/UnitConeTint.xml"}
+	}
+	failed := false
+	for _, file := range files {
+		ext := strings.ToLower(filepath.Ext(file))
+		if ext == ".dae" || ext == ".gltf" || ext == ".glb" {
+			load, what := loadCollada, "collada"
+			if ext != ".dae" {
+				load, what = loadGLTF, "glTF"
+			}
+			meshes, err := load(file)
+			if err != nil {
+				fmt.Printf("meshtest: Cannot load %s:\n\t%s\n", what, err)
+				failed = true
+				continue
+			}
+			for _, mesh := range meshes {
+				mesh.Debug()
+			}
+			continue
+		}
+		mesh := glut.NewMesh(file)
+		err := mesh.LoadGLUTMesh(file)
+		if err != nil {
+			fmt.Printf("meshtest: Cannot load glutmesh:\n\t%s\n", err)
+			failed = true
+			continue
+		}
+		mesh.Debug()
+	}
+	if failed {
+		os.Exit(1)
 	}
-	mesh.Debug()
 }
@@ -209,7 +209,11 @@ func main() {
 	}
 
 	// Load the shaders
-	currentShader = CreateShaderProgram(shaders)
+	var err error
+	if currentShader, err = CreateShaderProgram(shaders); err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
 
 	// Main loop - run until it dies, or we find something better
 	for (glfw.Key(glfw.KeyEsc) != glfw.KeyPress) &&
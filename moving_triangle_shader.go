@@ -59,7 +59,11 @@ func glInit() {
 	gl.Init()
 
 	// Load the shaders
-	currentShader = CreateShaderProgram(shaders)
+	var err error
+	if currentShader, err = CreateShaderProgram(shaders); err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
 	elapsedTimeUniform = gl.GetUniformLocation(currentShader, gl.GLString("time"))
 	loopDurationUnf = gl.GetUniformLocation(currentShader, gl.GLString("loopDuration"))
 	fragLoopDurUnf := gl.GetUniformLocation(currentShader, gl.GLString("fragLoopDuration"))
@@ -0,0 +1,24 @@
+/* objloader.go - loads Wavefront OBJ models for the tutorials.
+
+go build tutorial07.go shader.go objloader.go
+*/
+
+package main
+
+import (
+	"fmt"
+	gl "github.com/chsc/gogl/gl33"
+	glut "github.com/ysgard/opengl-go-tut/glutil"
+)
+
+// loadOBJ reads objFile and flattens it into triangles for glDrawArrays:
+// positions as x, y, z, 1, uvs as u, v and normals as x, y, z.  Anything
+// the file doesn't have comes back as zeroes.
+func loadOBJ(objFile string) (vertices, uvs, normals []gl.Float, err error) {
+	model, err := glut.LoadOBJ(objFile)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("loadOBJ: %s", err)
+	}
+	vertices, uvs, normals = model.Triangles()
+	return vertices, uvs, normals, nil
+}
@@ -1,10 +1,11 @@
-// go build rotation.go matrix.go shader.go
+// go build rotation.go shader.go
 package main
 
 import (
 	"fmt"
 	gl "github.com/chsc/gogl/gl33"
 	"github.com/go-gl/glfw"
+	glut "github.com/ysgard/opengl-go-tut/glutil"
 	"math"
 	"os"
 	"runtime"
@@ -39,7 +40,7 @@ var modelToCameraMatrixUnif gl.Int
 var cameraToClipMatrixUnif gl.Int
 
 // camera?
-var cameraToClipMatrix = IdentMat4()
+var cameraToClipMatrix = glut.IdentMat4()
 var fzNear = gl.Float(1.0)
 var fzFar = gl.Float(45.0)
 
@@ -97,26 +98,27 @@ var indexData = []gl.Ushort{
 
 type Instance struct {
 	name       string
-	RotateFunc func(gl.Float) *Mat4
-	offset     Vec4
+	RotateFunc func(gl.Float) *glut.Mat4
+	offset     glut.Vec4
 }
 
-func (i Instance) constructMatrix(fElapsedTime gl.Float) *Mat4 {
+func (i Instance) constructMatrix(fElapsedTime gl.Float) *glut.Mat4 {
 	theMat := i.RotateFunc(fElapsedTime)
 	theMat[3] = i.offset
 	return theMat
 }
 
 var instanceList = []Instance{
-	{"NullRotation", NullRotation, Vec4{0.0, 0.0, -25.0, 1.0}},
-	{"RotateX", RotateX, Vec4{-5.0, -5.0, -25.0, 1.0}},
-	{"RotateY", RotateY, Vec4{-5.0, 5.0, -25.0, 1.0}},
-	{"RotateZ", RotateZ, Vec4{5.0, 5.0, -25.0, 1.0}},
-	{"RotateAxis", RotateAxis, Vec4{5.0, -5.0, -25.0, 1.0}},
+	{"NullRotation", NullRotation, glut.Vec4{X: 0.0, Y: 0.0, Z: -25.0, W: 1.0}},
+	{"RotateX", RotateX, glut.Vec4{X: -5.0, Y: -5.0, Z: -25.0, W: 1.0}},
+	{"RotateY", RotateY, glut.Vec4{X: -5.0, Y: 5.0, Z: -25.0, W: 1.0}},
+	{"RotateZ", RotateZ, glut.Vec4{X: 5.0, Y: 5.0, Z: -25.0, W: 1.0}},
+	{"RotateAxis", RotateAxis, glut.Vec4{X: 5.0, Y: -5.0, Z: -25.0, W: 1.0}},
+	{"SlerpKeys", SlerpKeys, glut.Vec4{X: 0.0, Y: 5.0, Z: -25.0, W: 1.0}},
 }
 
 func CalcLerpFactor(fElapsedTime, fLoopDuration gl.Float) gl.Float {
-	fValue := ModGL(fElapsedTime, fLoopDuration) / fLoopDuration
+	fValue := glut.ModGL(fElapsedTime, fLoopDuration) / fLoopDuration
 	if fValue > 0.5 {
 		fValue = 1.0 - fValue
 	}
@@ -124,78 +126,83 @@ func CalcLerpFactor(fElapsedTime, fLoopDuration gl.Float) gl.Float {
 }
 
 func ComputeAngleRad(fElapsedTime, fLoopDuration gl.Float) gl.Float {
-	fScale := Pi * 2.0 / fLoopDuration
-	fCurrTimeThroughLoop := ModGL(fElapsedTime, fLoopDuration)
+	fScale := glut.Pi * 2.0 / fLoopDuration
+	fCurrTimeThroughLoop := glut.ModGL(fElapsedTime, fLoopDuration)
 	return fCurrTimeThroughLoop * fScale
 }
 
-func NullRotation(_ gl.Float) *Mat4 {
-	return IdentMat4()
+func NullRotation(_ gl.Float) *glut.Mat4 {
+	return glut.IdentMat4()
 }
 
-func RotateX(fElapsedTime gl.Float) *Mat4 {
+func RotateX(fElapsedTime gl.Float) *glut.Mat4 {
 	fAngRad := ComputeAngleRad(fElapsedTime, 3.0)
-	fCos := CosGL(fAngRad)
-	fSin := SinGL(fAngRad)
-	theMat := IdentMat4()
-	theMat[1].y = fCos
-	theMat[2].y = -fSin
-	theMat[1].z = fSin
-	theMat[2].z = fCos
+	fCos := glut.CosGL(fAngRad)
+	fSin := glut.SinGL(fAngRad)
+	theMat := glut.IdentMat4()
+	theMat[1].Y = fCos
+	theMat[2].Y = -fSin
+	theMat[1].Z = fSin
+	theMat[2].Z = fCos
 	return theMat
 }
 
-func RotateY(fElapsedTime gl.Float) *Mat4 {
+func RotateY(fElapsedTime gl.Float) *glut.Mat4 {
 	fAngRad := ComputeAngleRad(fElapsedTime, 2.0)
-	fCos := CosGL(fAngRad)
-	fSin := SinGL(fAngRad)
-	theMat := IdentMat4()
-	theMat[0].x = fCos
-	theMat[2].x = fSin
-	theMat[0].z = -fSin
-	theMat[2].z = fCos
+	fCos := glut.CosGL(fAngRad)
+	fSin := glut.SinGL(fAngRad)
+	theMat := glut.IdentMat4()
+	theMat[0].X = fCos
+	theMat[2].X = fSin
+	theMat[0].Z = -fSin
+	theMat[2].Z = fCos
 	return theMat
 }
 
-func RotateZ(fElapsedTime gl.Float) *Mat4 {
+func RotateZ(fElapsedTime gl.Float) *glut.Mat4 {
 	fAngRad := ComputeAngleRad(fElapsedTime, 2.0)
-	fCos := CosGL(fAngRad)
-	fSin := SinGL(fAngRad)
-	theMat := IdentMat4()
-	theMat[0].x = fCos
-	theMat[1].x = -fSin
-	theMat[0].y = fSin
-	theMat[1].y = fCos
+	fCos := glut.CosGL(fAngRad)
+	fSin := glut.SinGL(fAngRad)
+	theMat := glut.IdentMat4()
+	theMat[0].X = fCos
+	theMat[1].X = -fSin
+	theMat[0].Y = fSin
+	theMat[1].Y = fCos
 	return theMat
 }
 
-func RotateAxis(fElapsedTime gl.Float) *Mat4 {
+func RotateAxis(fElapsedTime gl.Float) *glut.Mat4 {
 	fAngRad := ComputeAngleRad(fElapsedTime, 2.0)
-	fCos := CosGL(fAngRad)
-	fSin := CosGL(fAngRad)
-	fInvCos := 1.0 - fCos
-	//fInvSin := 1.0 - fSin
-	v := Vec4{1.0, 1.0, 1.0, 1.0}
-	v.Normalize()
-	m := IdentMat4()
-	m[0].x = (v.x * v.x) + ((1 - v.x*v.x) * fCos)
-	m[1].x = v.x*v.y*fInvCos - v.z*fSin
-	m[2].x = v.x*v.z*fInvCos + v.y*fSin
-	m[0].y = v.x*v.y*fInvCos + v.z*fSin
-	m[1].y = v.y*v.y + (1-v.y*v.y)*fCos
-	m[2].y = v.y*v.z*fInvCos - v.x*fSin
-	m[0].z = v.x*v.z*fInvCos - v.y*fSin
-	m[1].z = v.y*v.z*fInvCos + v.x*fSin
-	m[2].z = v.z*v.z + (1-v.z*v.z)*fCos
-	return m
+	axis := &glut.Vec3{X: 1.0, Y: 1.0, Z: 1.0}
+	return glut.QuatFromAxisAngle(axis, glut.RadToDeg(fAngRad)).Mat4()
 }
 
-func DynamicNonUniformScale(fElapsedTime gl.Float) Vec4 {
+// Key orientations for SlerpKeys to move between.
+var keyOrientations = []*glut.Quat{
+	glut.QuatIdent(),
+	glut.QuatFromEuler(90.0, 0.0, 0.0),
+	glut.QuatFromEuler(90.0, 90.0, 0.0),
+	glut.QuatFromEuler(0.0, 180.0, 45.0),
+	glut.QuatFromAxisAngle(&glut.Vec3{X: 1.0, Y: 1.0, Z: 0.0}, -120.0),
+}
+
+// SlerpKeys spends a second going from each key orientation to the next,
+// then wraps back to the first.
+func SlerpKeys(fElapsedTime gl.Float) *glut.Mat4 {
+	fLoopDuration := gl.Float(len(keyOrientations))
+	fCurrTime := glut.ModGL(fElapsedTime, fLoopDuration)
+	iKey := int(fCurrTime)
+	from := keyOrientations[iKey]
+	to := keyOrientations[(iKey+1)%len(keyOrientations)]
+	return from.Slerp(to, fCurrTime-gl.Float(iKey)).Mat4()
+}
+
+func DynamicNonUniformScale(fElapsedTime gl.Float) glut.Vec4 {
 	fXLoopDuration := gl.Float(3.0)
 	fZLoopDuration := gl.Float(5.0)
 	mixx := 1.0 + 4.0*CalcLerpFactor(fElapsedTime, fXLoopDuration)
 	mixz := 1.0 + 9.0*CalcLerpFactor(fElapsedTime, fZLoopDuration)
-	return Vec4{mixx, 1.0, mixz, 1.0}
+	return glut.Vec4{X: mixx, Y: 1.0, Z: mixz, W: 1.0}
 }
 
 func CalcFrustumScale(fFovDeg gl.Float) gl.Float {
@@ -257,7 +264,11 @@ func glfwInitWindow() {
 
 func InitializeProgram() {
 	// Create shaders and bind their variables
-	currentShader = CreateShaderProgram(shaders)
+	var err error
+	if currentShader, err = CreateShaderProgram(shaders); err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
 	modelToCameraMatrixUnif = gl.GetUniformLocation(currentShader, gl.GLString("modelToCameraMatrix"))
 	if modelToCameraMatrixUnif == -1 {
 		fmt.Fprintf(os.Stderr, "Invalid value error from glGetUniformLocation: modelToCameraMatrix\n")
@@ -269,14 +280,14 @@ func InitializeProgram() {
 
 	//DebugMat(cameraToClipMatrix, "Camera Matrix")
 
-	cameraToClipMatrix[0].x = fFrustumScale
-	cameraToClipMatrix[1].y = fFrustumScale
-	cameraToClipMatrix[2].z = (fzFar + fzNear) / (fzNear - fzFar)
-	cameraToClipMatrix[2].w = -1.0
-	cameraToClipMatrix[3].z = (2 * fzFar * fzNear) / (fzNear - fzFar)
+	cameraToClipMatrix[0].X = fFrustumScale
+	cameraToClipMatrix[1].Y = fFrustumScale
+	cameraToClipMatrix[2].Z = (fzFar + fzNear) / (fzNear - fzFar)
+	cameraToClipMatrix[2].W = -1.0
+	cameraToClipMatrix[3].Z = (2 * fzFar * fzNear) / (fzNear - fzFar)
 
 	gl.UseProgram(currentShader)
-	gl.UniformMatrix4fv(cameraToClipMatrixUnif, 1, gl.FALSE, &cameraToClipMatrix[0].x)
+	gl.UniformMatrix4fv(cameraToClipMatrixUnif, 1, gl.FALSE, &cameraToClipMatrix[0].X)
 	gl.UseProgram(0)
 }
 
@@ -331,7 +342,7 @@ func display() {
 		xform := instanceList[i].constructMatrix((gl.Float)(fElapsedTime))
 		//xformT := ToColumnMajor(xform)
 		//DebugMat(xform, instanceList[i].name)
-		gl.UniformMatrix4fv(modelToCameraMatrixUnif, 1, gl.FALSE, &xform[0].x)
+		gl.UniformMatrix4fv(modelToCameraMatrixUnif, 1, gl.FALSE, &xform[0].X)
 		fmt.Fprintf(os.Stderr, "Drawing %d elements\n", gl.Sizei(len(indexData)))
 		gl.DrawElements(
 			gl.TRIANGLES,
@@ -347,11 +358,11 @@ func display() {
 }
 
 func reshape(w, h int) {
-	cameraToClipMatrix[0].x = fFrustumScale * (gl.Float)(h) / (gl.Float)(w)
-	cameraToClipMatrix[1].y = fFrustumScale
+	cameraToClipMatrix[0].X = fFrustumScale * (gl.Float)(h) / (gl.Float)(w)
+	cameraToClipMatrix[1].Y = fFrustumScale
 
 	gl.UseProgram(currentShader)
-	gl.UniformMatrix4fv(cameraToClipMatrixUnif, 1, gl.FALSE, &cameraToClipMatrix[0].x)
+	gl.UniformMatrix4fv(cameraToClipMatrixUnif, 1, gl.FALSE, &cameraToClipMatrix[0].X)
 	gl.UseProgram(0)
 
 	gl.Viewport(0, 0, (gl.Sizei)(w), (gl.Sizei)(h))
@@ -0,0 +1,5 @@
+// Vertex inputs shared by the position + colour shaders.
+#pragma once
+
+layout(location = 0) in vec4 position;
+layout(location = 1) in vec4 color;
@@ -1,16 +1,15 @@
-#version 330
-
-layout(location = 0) in vec4 position;
-layout(location = 1) in vec4 color;
-
-smooth out vec4 theColor;
-
-uniform mat4 cameraToClipMatrix;
-uniform mat4 modelToCameraMatrix;
-
-void main()
-{
-	vec4 cameraPos = modelToCameraMatrix * position;
-	gl_Position = cameraToClipMatrix * cameraPos;
-	theColor = color;
-}
+#version 330
+
+#include "PosColorInputs.glsl"
+
+smooth out vec4 theColor;
+
+uniform mat4 cameraToClipMatrix;
+uniform mat4 modelToCameraMatrix;
+
+void main()
+{
+	vec4 cameraPos = modelToCameraMatrix * position;
+	gl_Position = cameraToClipMatrix * cameraPos;
+	theColor = color;
+}
@@ -0,0 +1,43 @@
+#version 330
+
+// Linear blend skinning.  Each vertex is moved by up to four of the bones,
+// weighted; the palette takes a bind shape vertex to where the pose puts it.
+// With CPU_SKINNING defined the vertices come already skinned and the bone
+// attributes are ignored.
+
+layout(location = 0) in vec4 position;
+layout(location = 2) in vec3 normal;
+layout(location = 6) in ivec4 boneIndices;
+layout(location = 7) in vec4 boneWeights;
+
+#ifndef CPU_SKINNING
+// As big as glutil.MaxBones.
+layout(std140) uniform BonePalette
+{
+	mat4 bones[64];
+};
+#endif
+
+uniform mat4 cameraToClipMatrix;
+uniform mat4 modelToCameraMatrix;
+
+smooth out vec3 cameraNormal;
+
+void main()
+{
+#ifdef CPU_SKINNING
+	vec4 skinPos = position;
+	vec3 skinNormal = normal;
+#else
+	mat4 skin = boneWeights.x * bones[boneIndices.x] +
+		boneWeights.y * bones[boneIndices.y] +
+		boneWeights.z * bones[boneIndices.z] +
+		boneWeights.w * bones[boneIndices.w];
+	vec4 skinPos = skin * position;
+	vec3 skinNormal = mat3(skin) * normal;
+#endif
+
+	vec4 cameraPos = modelToCameraMatrix * skinPos;
+	gl_Position = cameraToClipMatrix * cameraPos;
+	cameraNormal = mat3(modelToCameraMatrix) * skinNormal;
+}
@@ -0,0 +1,14 @@
+#version 330
+
+smooth in vec3 cameraNormal;
+
+uniform vec4 baseColor;
+uniform vec3 dirToLight; // camera space
+
+out vec4 outputColor;
+
+void main()
+{
+	float cosAng = clamp(dot(normalize(cameraNormal), dirToLight), 0.0, 1.0);
+	outputColor = baseColor * (0.2 + 0.8 * cosAng);
+}
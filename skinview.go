@@ -0,0 +1,289 @@
+// skinview - plays a COLLADA character's animation, skinned on the GPU.
+//
+//	go run skinview.go [file.dae]
+//
+// Space pauses, left/right scrub a frame at a time, up/down change the
+// speed, c swaps to skinning on the CPU and back, a/d orbit the camera and
+// w/s zoom.  Enter prints where the clip is.
+package main
+
+import (
+	"fmt"
+	gl "github.com/chsc/gogl/gl33"
+	"github.com/go-gl/glfw"
+	glut "github.com/ysgard/opengl-go-tut/glutil"
+	"math"
+	"os"
+	"runtime"
+	"time"
+)
+
+const (
+	Width  = 640
+	Height = 640
+	Title  = "Skinned Animation"
+)
+
+var shaderFiles = []string{
+	"shaders/Skinned.vert",
+	"shaders/SkinnedLit.frag",
+}
+
+// GPU skinning, and the same shaders with it switched off.
+var gpuProgram *glut.ReloadProgram
+var cpuProgram *glut.ReloadProgram
+
+var g_skins []*glut.SkinnedMesh
+var g_clip *glut.AnimationClip
+
+// Playback
+var g_fTime float64
+var g_fSpeed = 1.0
+var g_bPaused = false
+var g_bCPU = false
+
+const FrameStep = 1.0 / 30.0
+
+// Camera, orbiting g_center
+var g_center glut.Vec3
+var g_fRadius gl.Float = 1.0
+var g_fCamYaw gl.Float = 0.0
+var g_fCamDist gl.Float = 3.0
+
+var fzNear = gl.Float(0.1)
+var fzFar = gl.Float(1000.0)
+var cameraToClipMatrix *glut.Mat4
+
+func LoadProgram(defines map[string]string) *glut.ReloadProgram {
+	prog, err := glut.NewReloadProgram(shaderFiles, defines)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
+	return prog
+}
+
+func InitializeProgram() {
+	gpuProgram = LoadProgram(nil)
+	bindPalette := func(p *glut.Program) {
+		if err := p.BindBlock("BonePalette", glut.BonePaletteBinding); err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err)
+		}
+	}
+	bindPalette(gpuProgram.Program)
+	gpuProgram.OnReload = bindPalette
+
+	cpuProgram = LoadProgram(map[string]string{"CPU_SKINNING": "1"})
+}
+
+// LoadCharacter reads the skins and their clip, and frames the camera on
+// the first pose.
+func LoadCharacter(file string) {
+	skins, clip, err := glut.LoadColladaSkins(file)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
+	if len(skins) == 0 || clip == nil {
+		fmt.Fprintf(os.Stderr, "%s has no animated skins\n", file)
+		os.Exit(1)
+	}
+	g_clip = clip
+	g_fTime = clip.Start
+	for _, skin := range skins {
+		s, err := glut.NewSkinnedMesh(skin)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err)
+			os.Exit(1)
+		}
+		g_skins = append(g_skins, s)
+	}
+
+	lo := glut.Vec3{X: math.MaxFloat32, Y: math.MaxFloat32, Z: math.MaxFloat32}
+	hi := glut.Vec3{X: -math.MaxFloat32, Y: -math.MaxFloat32, Z: -math.MaxFloat32}
+	pose := clip.Pose(clip.Start)
+	for _, s := range g_skins {
+		positions, _ := s.Vertices(pose)
+		for _, p := range positions {
+			for i := 0; i < 3; i++ {
+				if p.Elem(i) < lo.Elem(i) {
+					lo.SetElem(i, p.Elem(i))
+				}
+				if p.Elem(i) > hi.Elem(i) {
+					hi.SetElem(i, p.Elem(i))
+				}
+			}
+		}
+	}
+	g_center = *lo.Lerp(&hi, 0.5)
+	g_fRadius = hi.Sub(&lo).Length() / 2.0
+	if g_fRadius <= 0 {
+		g_fRadius = 1.0
+	}
+	g_fCamDist = g_fRadius * 3.0
+}
+
+func glfwInitWindow() {
+	glfw.Init()
+	glfw.OpenWindowHint(glfw.FsaaSamples, 4)
+	glfw.OpenWindowHint(glfw.OpenGLVersionMajor, 3)
+	glfw.OpenWindowHint(glfw.OpenGLVersionMinor, 2)
+	glfw.OpenWindowHint(glfw.OpenGLProfile, glfw.OpenGLCoreProfile)
+
+	if err := glfw.OpenWindow(Width, Height, 0, 0, 0, 0, 32, 0, glfw.Windowed); err != nil {
+		fmt.Fprintf(os.Stderr, "glfw.OpenWindow failed: %s\n", err)
+		os.Exit(1)
+	}
+	glfw.SetWindowTitle(Title)
+	glfw.Enable(glfw.StickyKeys)
+}
+
+func Initialize(file string) {
+	glfwInitWindow()
+	gl.Init()
+
+	InitializeProgram()
+	LoadCharacter(file)
+
+	gl.Enable(gl.CULL_FACE)
+	gl.CullFace(gl.BACK)
+	gl.FrontFace(gl.CCW)
+
+	gl.Enable(gl.DEPTH_TEST)
+	gl.DepthMask(gl.TRUE)
+	gl.DepthFunc(gl.LEQUAL)
+	gl.DepthRange(0.0, 1.0)
+
+	reshape(Width, Height)
+}
+
+func reshape(w, h int) {
+	if h == 0 {
+		h = 1
+	}
+	cameraToClipMatrix = glut.Perspective(45.0, gl.Float(w)/gl.Float(h), fzNear, fzFar)
+	gl.Viewport(0, 0, gl.Sizei(w), gl.Sizei(h))
+}
+
+// Advance moves the clip on by dt seconds of wall time, wrapping at the end.
+func Advance(dt float64) {
+	g_fTime += dt * g_fSpeed
+	d := g_clip.Duration()
+	if d <= 0 {
+		g_fTime = g_clip.Start
+		return
+	}
+	g_fTime = g_clip.Start + math.Mod(g_fTime-g_clip.Start, d)
+	if g_fTime < g_clip.Start {
+		g_fTime += d
+	}
+}
+
+func keyboard(key, state int) {
+	if state == glfw.KeyPress {
+		switch key {
+		case glfw.KeyEsc:
+			shutdown()
+			return
+		case glfw.KeySpace:
+			g_bPaused = !g_bPaused
+		case glfw.KeyLeft:
+			g_bPaused = true
+			Advance(-FrameStep / g_fSpeed)
+		case glfw.KeyRight:
+			g_bPaused = true
+			Advance(FrameStep / g_fSpeed)
+		case glfw.KeyUp:
+			g_fSpeed *= 1.25
+		case glfw.KeyDown:
+			g_fSpeed /= 1.25
+		case 67: // c
+			g_bCPU = !g_bCPU
+			for _, s := range g_skins {
+				s.CPU = g_bCPU
+			}
+		case 65: // a
+			g_fCamYaw -= 11.25
+		case 68: // d
+			g_fCamYaw += 11.25
+		case 87: // w
+			g_fCamDist = glut.Clamp(g_fCamDist*0.9, g_fRadius*0.5, g_fRadius*20.0)
+		case 83: // s
+			g_fCamDist = glut.Clamp(g_fCamDist/0.9, g_fRadius*0.5, g_fRadius*20.0)
+		case glfw.KeyEnter:
+			mode := "GPU"
+			if g_bCPU {
+				mode = "CPU"
+			}
+			fmt.Printf("%s: %.3fs of %g..%g, speed %.2f, %s skinning\n",
+				g_clip.Name, g_fTime, g_clip.Start, g_clip.End, g_fSpeed, mode)
+		}
+	}
+}
+
+func shutdown() {
+	for _, s := range g_skins {
+		s.Delete()
+	}
+	gpuProgram.Delete()
+	cpuProgram.Delete()
+	glfw.Terminate()
+}
+
+func display() {
+	gpuProgram.Poll()
+	cpuProgram.Poll()
+
+	gl.ClearColor(0.2, 0.2, 0.25, 0.0)
+	gl.ClearDepth(1.0)
+	gl.Clear(gl.COLOR_BUFFER_BIT | gl.DEPTH_BUFFER_BIT)
+
+	yaw := glut.DegToRad(g_fCamYaw)
+	eye := glut.Vec3{
+		X: g_center.X + g_fCamDist*glut.SinGL(yaw),
+		Y: g_center.Y + g_fRadius*0.25,
+		Z: g_center.Z + g_fCamDist*glut.CosGL(yaw)}
+	modelToCamera := glut.LookAt(&eye, &g_center, &glut.Vec3{X: 0.0, Y: 1.0, Z: 0.0})
+
+	prog := gpuProgram
+	if g_bCPU {
+		prog = cpuProgram
+	}
+	prog.Use()
+	prog.SetMat4("cameraToClipMatrix", cameraToClipMatrix)
+	prog.SetMat4("modelToCameraMatrix", modelToCamera)
+	prog.SetVec4("baseColor", &glut.Vec4{X: 0.9, Y: 0.85, Z: 0.8, W: 1.0})
+	// A light over the camera's shoulder.
+	prog.SetVec3("dirToLight", (&glut.Vec3{X: 0.3, Y: 0.6, Z: 1.0}).Normalize())
+
+	pose := g_clip.Pose(g_fTime)
+	for _, s := range g_skins {
+		s.Draw(pose)
+	}
+	gl.UseProgram(0)
+
+	glfw.SwapBuffers()
+}
+
+func main() {
+	runtime.LockOSThread()
+
+	file := "art/astroBoy_walk_Maya.dae"
+	if len(os.Args) > 1 {
+		file = os.Args[1]
+	}
+	Initialize(file)
+	glfw.SetKeyCallback(keyboard)
+	glfw.SetWindowSizeCallback(reshape)
+
+	last := time.Now()
+	for glfw.WindowParam(glfw.Opened) == 1 {
+		now := time.Now()
+		if !g_bPaused {
+			Advance(now.Sub(last).Seconds())
+		}
+		last = now
+		time.Sleep(time.Millisecond)
+		display()
+	}
+}
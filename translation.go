@@ -237,7 +237,11 @@ func ToColumnMajor(m []gl.Float) []gl.Float {
 
 func InitializeProgram() {
 	// Create shaders and bind their variables
-	currentShader = CreateShaderProgram(shaders)
+	var err error
+	if currentShader, err = CreateShaderProgram(shaders); err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
 	modelToCameraMatrixUnif = gl.GetUniformLocation(currentShader, gl.GLString("modelToCameraMatrix"))
 	if modelToCameraMatrixUnif == -1 {
 		fmt.Fprintf(os.Stderr, "Invalid value error from glGetUniformLocation: modelToCameraMatrix\n")
@@ -52,9 +52,13 @@ func main() {
 	gl.Init()
 
 	// Load Shaders
-	var programID gl.Uint = LoadShaders(
+	programID, err := LoadShaders(
 		"shaders/simple_vertex_shader.glsl",
 		"shaders/simple_fragment_shader.glsl")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
 	gl.ValidateProgram(programID)
 	var validationErr gl.Int
 	gl.GetProgramiv(programID, gl.VALIDATE_STATUS, &validationErr)
@@ -64,9 +64,13 @@ func main() {
 	gl.ClearColor(0.0, 0.0, 0.2, 0.0)
 
 	// Load Shaders
-	var programID gl.Uint = LoadShaders(
+	programID, err := LoadShaders(
 		VertexFile,
 		FragmentFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
 	gl.ValidateProgram(programID)
 	var validationErr gl.Int
 	gl.GetProgramiv(programID, gl.VALIDATE_STATUS, &validationErr)
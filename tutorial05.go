@@ -9,6 +9,7 @@ import (
 	"fmt"
 	gl "github.com/chsc/gogl/gl33"
 	"github.com/go-gl/glfw"
+	glut "github.com/ysgard/opengl-go-tut/glutil"
 	"github.com/jragonmiris/mathgl"
 	"os"
 	"runtime"
@@ -28,25 +29,20 @@ const (
 )
 
 func loadTGA(imagePath string) gl.Uint {
-	// Create one OpenGL texture
-	var txid gl.Uint
-	gl.GenTextures(1, &txid)
-
-	// "Bind" the newly created texture: all future functions will modify this texture
-	gl.BindTexture(gl.TEXTURE_2D, txid)
-
-	// Read the file, call glTexImage2d with the right parameters
-	glfw.LoadTexture2D(imagePath, 0)
-
-	// Nice trilinear filtering.
-	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_S, gl.REPEAT)
-	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_T, gl.REPEAT)
-	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.LINEAR)
-	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.LINEAR_MIPMAP_LINEAR)
-	gl.GenerateMipmap(gl.TEXTURE_2D)
+	// Read the file - glutil registers a TGA decoder with the image package
+	img, err := glut.LoadImage(imagePath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "loadTGA: %s\n", err)
+		os.Exit(1)
+	}
 
-	// Return the ID of the texture we just created
-	return txid
+	// Create one OpenGL texture from it, with nice trilinear filtering
+	texture, err := glut.UploadTexture2D(img)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "loadTGA: %s\n", err)
+		os.Exit(1)
+	}
+	return texture
 }
 
 func main() {
@@ -85,9 +81,13 @@ func main() {
 	gl.ClearColor(0.0, 0.0, 0.01, 0.0)
 
 	// Load Shaders
-	var programID gl.Uint = LoadShaders(
+	programID, err := LoadShaders(
 		VertexFile,
 		FragmentFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
 	gl.ValidateProgram(programID)
 	var validationErr gl.Int
 	gl.GetProgramiv(programID, gl.VALIDATE_STATUS, &validationErr)
@@ -9,6 +9,7 @@ import (
 	"fmt"
 	gl "github.com/chsc/gogl/gl33"
 	"github.com/go-gl/glfw"
+	glut "github.com/ysgard/opengl-go-tut/glutil"
 	"github.com/jragonmiris/mathgl"
 	"math"
 	"os"
@@ -28,26 +29,17 @@ const (
 	TextureFile  = "art/raine.tga"
 )
 
-func loadTGA(imagePath string) gl.Uint {
-	// Create one OpenGL texture
-	var txid gl.Uint
-	gl.GenTextures(1, &txid)
-
-	// "Bind" the newly created texture: all future functions will modify this texture
-	gl.BindTexture(gl.TEXTURE_2D, txid)
-
-	// Read the file, call glTexImage2d with the right parameters
-	glfw.LoadTexture2D(imagePath, 0)
-
-	// Nice trilinear filtering.
-	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_S, gl.REPEAT)
-	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_T, gl.REPEAT)
-	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.LINEAR)
-	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.LINEAR_MIPMAP_LINEAR)
-	gl.GenerateMipmap(gl.TEXTURE_2D)
-
-	// Return the ID of the texture we just created
-	return txid
+// loadTexture reads any image glutil can decode - TGA, BMP, PNG or JPEG -
+// into a mipmapped texture.
+func loadTexture(imagePath string) *glut.Texture {
+	opts := glut.DefaultTextureOptions()
+	opts.Anisotropy = 4.0
+	texture, err := glut.LoadTexture(imagePath, opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "loadTexture: %s\n", err)
+		os.Exit(1)
+	}
+	return texture
 }
 
 func xForm(data []gl.Float, xform mathgl.Mat4f) {
@@ -103,9 +95,13 @@ func main() {
 	gl.ClearColor(0.0, 0.0, 0.01, 0.0)
 
 	// Load Shaders
-	var programID gl.Uint = LoadShaders(
+	programID, err := LoadShaders(
 		VertexFile,
 		FragmentFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
 	gl.ValidateProgram(programID)
 	var validationErr gl.Int
 	gl.GetProgramiv(programID, gl.VALIDATE_STATUS, &validationErr)
@@ -145,7 +141,7 @@ func main() {
 	}*/
 
 	// Load the texture
-	texture := loadTGA(TextureFile)
+	texture := loadTexture(TextureFile)
 	var textureID gl.Int = gl.GetUniformLocation(programID, gl.GLString("myTextureSampler"))
 
 	// Three consecutive floats give a single 3D vertex
@@ -349,7 +345,7 @@ func main() {
 
 }
 
-func render(vertexBuffer, uvBuffer gl.Uint, vertexData, uvData []gl.Float, textureID gl.Int, texture gl.Uint) {
+func render(vertexBuffer, uvBuffer gl.Uint, vertexData, uvData []gl.Float, textureID gl.Int, texture *glut.Texture) {
 	// Draw an object with the given vertex, UV data, texture buffer and texture
 	// Buffer the new data
 	vBufferLen := unsafe.Sizeof(vertexData[0]) * (uintptr)(len(vertexData))
@@ -370,8 +366,7 @@ func render(vertexBuffer, uvBuffer gl.Uint, vertexData, uvData []gl.Float, textu
 		gl.STATIC_DRAW)
 
 	// texture in Texture Unit 0
-	gl.ActiveTexture(gl.TEXTURE0)
-	gl.BindTexture(gl.TEXTURE_2D, texture)
+	texture.Bind(0)
 	gl.Uniform1i(textureID, 0)
 
 	// 1st attribute buffer: vertices
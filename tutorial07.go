@@ -47,7 +47,7 @@ func glfwInitWindow() {
 
 	// Open a window and initialize its OpenGL context
 	if err := glfw.OpenWindow(Width, Height, 0, 0, 0, 0, 32, 0, glfw.Windowed); err != nil {
-		fmt.Fprintf(os.Stderr, "OpenWindow failed: glfw: %s\n")
+		fmt.Fprintf(os.Stderr, "OpenWindow failed: glfw: %s\n", err)
 		os.Exit(1)
 	}
 
@@ -160,7 +160,11 @@ func main() {
 	})
 
 	// Data prep.  Make it a slice
-	vertexPositions, _, _ := loadOBJ(objFile)
+	vertexPositions, _, _, err := loadOBJ(objFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
 
 	vertexColors := []gl.Float{
 		// Color data in RGBA format
@@ -170,7 +174,10 @@ func main() {
 	}
 
 	// Load the shaders
-	currentShader = CreateShaderProgram(shaders)
+	if currentShader, err = CreateShaderProgram(shaders); err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
 
 	// Main loop - run until it dies, or we find something better
 	for (glfw.Key(glfw.KeyEsc) != glfw.KeyPress) &&
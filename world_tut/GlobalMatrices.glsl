@@ -0,0 +1,11 @@
+// The camera matrices, shared by every program through one uniform buffer.
+#ifndef GLOBAL_MATRICES_GLSL
+#define GLOBAL_MATRICES_GLSL
+
+layout(std140) uniform GlobalMatrices
+{
+	mat4 cameraToClipMatrix;
+	mat4 worldToCameraMatrix;
+};
+
+#endif
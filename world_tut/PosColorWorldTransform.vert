@@ -1,7 +1,6 @@
 #version 330
 
-layout(location = 0) in vec4 position;
-layout(location = 1) in vec4 color;
+#include "../shaders/PosColorInputs.glsl"
 
 smooth out vec4 interpColor;
 
@@ -1,15 +1,10 @@
 #version 330
 
-layout(location = 0) in vec4 position;
-layout(location = 1) in vec4 color;
+#include "../shaders/PosColorInputs.glsl"
 
 smooth out vec4 interpColor;
 
-layout(std140) uniform GlobalMatrices
-{
-	mat4 cameraToClipMatrix;
-	mat4 worldToCameraMatrix;
-};
+#include "GlobalMatrices.glsl"
 
 uniform mat4 modelToWorldMatrix;
 
@@ -2,11 +2,7 @@
 
 layout(location = 0) in vec4 position;
 
-layout(std140) uniform GlobalMatrices
-{
-	mat4 cameraToClipMatrix;
-	mat4 worldToCameraMatrix;
-};
+#include "GlobalMatrices.glsl"
 
 uniform mat4 modelToWorldMatrix;
 
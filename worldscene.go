@@ -1,54 +1,75 @@
 package main
 
 import (
+	"flag"
 	"fmt"
-	glut "github.com/Ysgard/goglutils"
 	gl "github.com/chsc/gogl/gl33"
 	"github.com/go-gl/glfw"
+	glut "github.com/ysgard/opengl-go-tut/glutil"
+	"os"
+	"runtime"
+	"time"
 )
 
-// shader program structure
-type ProgramData struct {
-	theProgram              gl.Uint
-	modelToWorldMatrixUnif  gl.Uint
-	worldToCameraMatrixUnif gl.Uint
-	cameraToClipMatrixUnif  gl.Uint
-	baseColorUnif           gl.Uint
-}
+const (
+	Width  = 500
+	Height = 500
+	Title  = "World Scene"
+)
 
-type TreeData struct {
-	fXPos, fZPos, fTrunkHeight, fConeHeight gl.Float
-}
+// The world's laid out in a file, see glutil/layout.go
+var layoutFile = flag.String("layout", "world_tut/world.json", "the layout to draw")
 
-func (p *ProgramData) LoadProgram(shaders []string) {
-	p.theProgram = glut.CreateShaderProgram(shaders)
-	p.modelToWorldMatrixUnif = gl.GetUniformLocation(p.theProgram, gl.String("modelToWorldMatrix"))
-	p.worldToCameraMatrixUnif = gl.GetUniformLocation(p.theProgram, gl.String("worldToCameraMatrix"))
-	p.cameraToClipMatrixUnif = gl.GetUniformLocation(p.theProgram, gl.String("cameraToClipMatrix"))
-	p.baseColorUnif = gl.GetUniformLocation(p.theProgram, gl.String("baseColor"))
+// LoadProgram builds a program, or bails out - there's nothing to draw
+// without it.  The programs reload themselves when the shader files are
+// edited, see PollPrograms.
+func LoadProgram(shaders []string) *glut.ReloadProgram {
+	prog, err := glut.NewReloadProgram(shaders, nil)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
+	return prog
 }
 
 // camera zoom
 var fzNear = gl.Float(1.0)
 var fzFar = gl.Float(1000.0)
 
-var UniformColor ProgramData
-var ObjectColor ProgramData
-var UniformColorTint ProgramData
+var UniformColor *glut.ReloadProgram
+var ObjectColor *glut.ReloadProgram
+var UniformColorTint *glut.ReloadProgram
+
+// The programs, by the names layouts use
+var g_programs map[string]*glut.ReloadProgram
 
 func InitializeProgram() {
-	UniformColor.LoadProgram([]string{
+	UniformColor = LoadProgram([]string{
 		"world_tut/PosOnlyWorldTransform.vert",
 		"world_tut/ColorUniform.frag",
 	})
-	ObjectColor.LoadProgram([]string{
+	ObjectColor = LoadProgram([]string{
 		"world_tut/PosColorWorldTransform.vert",
 		"world_tut/ColorPassthrough.frag",
 	})
-	UniformColorTint.LoadProgram([]string{
+	UniformColorTint = LoadProgram([]string{
 		"world_tut/PosColorWorldTransform.vert",
 		"world_tut/ColorMultUniform.frag",
 	})
+	g_programs = map[string]*glut.ReloadProgram{
+		"UniformColor":     UniformColor,
+		"ObjectColor":      ObjectColor,
+		"UniformColorTint": UniformColorTint,
+	}
+}
+
+// PollPrograms picks up any shader edits.  Call it between frames.  A
+// reloaded program keeps its *ReloadProgram but gets a new ID, so set
+// uniforms by name - a location looked up before is stale.
+func PollPrograms() {
+	UniformColor.Poll()
+	ObjectColor.Poll()
+	UniformColorTint.Poll()
 }
 
 func CalcLookAtMatrix(cameraPt, lookPt, upPt *glut.Vec3) *glut.Mat4 {
@@ -65,7 +86,7 @@ func CalcLookAtMatrix(cameraPt, lookPt, upPt *glut.Vec3) *glut.Mat4 {
 
 	rotMat = rotMat.Transpose()
 
-	transMat = glut.IdentMat4()
+	transMat := glut.IdentMat4()
 	transMat[3] = (cameraPt.MulS(-1.0)).V3to4(1.0)
 
 	return rotMat.MulM(transMat)
@@ -74,316 +95,127 @@ func CalcLookAtMatrix(cameraPt, lookPt, upPt *glut.Vec3) *glut.Mat4 {
 var g_fYAngle = gl.Float(0.0)
 var g_fXAngle = gl.Float(0.0)
 
-const g_fColumnBaseHeight = gl.Float(0.25)
-
-const g_fParthenonWidth = gl.Float(14.0)
-const g_fParthenonLength = gl.Float(20.0)
-const g_fParthenonColumnHeight = 5.0
-const g_fParthenonBaseHeight = 1.0
-const g_fParthenonTopHeight = 2.0
-
 var g_bDrawLookatPoint = bool(false)
-var g_camTarget = &glut.Vec3{0.0, 0.4, 0.0}
+var g_camTarget = &glut.Vec3{X: 0.0, Y: 0.4, Z: 0.0}
 // Spherical coordinates
-var g_sphereCamRelPos = &glut.Vec3{67.5, -46.0, 150.0}
+var g_sphereCamRelPos = &glut.Vec3{X: 67.5, Y: -46.0, Z: 150.0}
 
-var g_pConeMesh *glut.Mesh
-var g_pCylinderMesh *glut.Mesh
-var g_pCubeTintMesh *glut.Mesh
-var g_pCubeColorMesh *glut.Mesh
-var g_pPlaneMesh *glut.Mesh
+var g_layout *glut.Layout
+// The layout's meshes by name.  Its "cube" is UnitCubeColor.xml, which has
+// two VAOs: "color" for the programs that take a colour attribute, and
+// "flat", positions only, for UniformColor.  The look-at point uses "flat":
+// it's drawn without the depth test, so one colour keeps its faces from
+// showing through each other.
+var g_meshes map[string]*glut.Mesh
+
+// A COLLADA scene given on the command line is drawn instead of the world,
+// from its own camera if it has one.
+var g_daeScene *glut.Scene
+var g_daeCamera *glut.Node
 
 func LoadMesh(file string) *glut.Mesh {
 	mptr, err := glut.LoadMeshFromXML(file)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Could not load UnitConeTint.xml, exiting...")
-		exit()
+		fmt.Fprintf(os.Stderr, "Could not load %s, exiting...\n%s\n", file, err)
+		os.Exit(1)
 	}
 	return mptr
 }
 
-void Initialize() {
-	InitializeProgram()
-	err := nil
-	g_pConeMesh = LoadMesh("UnitConeTint.xml")
-	g_pCylinderMesh = LoadMesh("UnitCylinderTint.xml")
-	g_pCubeTintMesh = LoadMesh("UnitCubeTint.xml")
-	g_pCubeColorMesh = LoadMesh("UnitCubeColor.xml")
-	g_pPlaneMesh = LoadMesh("UnitPlane.xml")
-
-	gl.Enable(gl.CULL_FACE)
-	gl.CullFace(gl.BACK)
-	gl.FrontFace(gl.CW)
-	gl.Enable(gl.DEPTH_TEST)
-	gl.DepthMask(gl.TRUE)
-	gl.DepthFunc(gl.LEQUAL)
-	gl.DepthRange(0.0, 1.0)
-	gl.Enable(gl.DEPTH_CLAMP)
-}
-
-
-// Trees are 3x3 in X/Y, and fTrunkHeight+fConeHeight in the Y
-func DrawTree(modelMatrix *glut.MatrixStack, fTrunkHeight gl.Float, fConeHeight gl.Float) {
-	if fTrunkHeight == -1.0 {
-		fTrunkHeight = 2.0
+func LoadScene(file string) {
+	scene, err := glut.LoadColladaScene(file)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Could not load %s, exiting...\n%s\n", file, err)
+		os.Exit(1)
 	}
-	if fConeHeight == -1.0 {
-		fConeHeight = 3.0
+	g_daeScene = scene
+	if cams := scene.Cameras(); len(cams) > 0 {
+		g_daeCamera = cams[0]
 	}
+}
 
-	// Draw the trunk
-	modelMatrix.Push()
-	modelMatrix.Scale(&glut.Vec4(1.0, fTrunkHeight, 1.0, 1.0))
-	modelMatrix.Translate(&glut.Vec4(0.0, 0.5, 0.0, 0.0))
-
-	gl.UseProgram(UniformColorTint.theProgram)
-	gl.UniformMatrix4fv(UniformColorTint.modelToWorldMatrixUnif, 1, gl.FALSE, modelMatrix.Top())
-	gl.Uniform4f(UniformColorTint.baseColorUnif, 0.694, 0.4, 0.106, 1.0)
-	g_pCyclinderMesh.Render()
-	gl.UseProgram(0)
-
-	// Draw the treetop
-	modelMatrix.Push()
-	modelMatrix.Translate(&glut.Vec4(0.0, fTrunkHeight, 0.0, 1.0))
-	modelMatrix.Scale(&glut.Vec4(3.0, fConeHeight, 3.0))
-	gl.UseProgram(UniformColorTint.theProgram)
-	gl.UniformMatrix4fv(UniformColorTint.modelToWorldMatrixUnif, 1, gl.FALSE, modelMatrix.Top())
-	gl.Uniform4f(UniformColorTint.baseColorUnif, 0.0, 1.0, 0.0, 1.0)
-	g_pConeMesh.Render()
+// DrawScene draws every mesh in the COLLADA scene in flat grey.
+func DrawScene(modelMatrix *glut.MatrixStack) {
+	UniformColor.Use()
+	UniformColor.SetVec4("baseColor", &glut.Vec4{X: 0.8, Y: 0.8, Z: 0.8, W: 1.0})
+	g_daeScene.Draw(modelMatrix, func(n *glut.Node, m *glut.Mesh, ms *glut.MatrixStack) {
+		UniformColor.SetMat4("modelToWorldMatrix", ms.Current())
+		m.Render()
+	})
 	gl.UseProgram(0)
 }
 
-// Columns are 1x1 in the X/Z, and fHeight units in the Y
-func DrawColumn(modelMatrix *glut.ModelStack, fHeight gl.Float) {
-	if fHeight == -1.0 {
-		fHeight = 5.0
+// LoadLayout reads the layout and starts the camera where it says.  No GL
+// yet, so a bad file is caught before the window opens.
+func LoadLayout(file string) {
+	l, err := glut.LoadLayout(file)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Could not load %s, exiting...\n%s\n", file, err)
+		os.Exit(1)
+	}
+	g_layout = l
+	if l.Camera != nil {
+		*g_camTarget = l.Camera.Target
+		*g_sphereCamRelPos = l.Camera.SphereRelPos
 	}
-
-	// Draw the bottom of the column
-	modelMatrix.Push()
-	modelMatrix.Scale(&glut.Vec4(1.0, g_fColumnBaseHeight, 1.0, 1.0))
-	modelMatrix.Translate(&glut.Vec4(0.0, 0.5, 0.0, 0.0))
-	gl.UseProgram(UniformColorTint.theProgram)
-	gl.UniformMatrix4fv(UniformColorTint.modelToWorldMatrixUnif, 1, gl.FALSE, modelMatrix.Top())
-	gl.Uniform4f(UniformColorTint.baseColorUnif, 1.0, 1.0, 1.0, 1.0)
-	g_pCubeColorMesh.Render()
-	gl.UseProgram(0)
-
-	// Draw the top of the column
-	modelMatrix.Push()
-	modelMatrix.Translate(&glut.Vec4(0.0, fHeight - g_fColumnBaseHeight, 0.0, 0.0))
-	modelMatrix.Scale(&glut.Vec4(1.0, g_fColumnBaseHeight, 1.0, 1.0))
-	modelMatrix.Translate(&glut.Vec4(0.0, 0.5, 0.0))
-	gl.UseProgram(UniformColorTint.theProgram)
-	gl.Uniform4fv(UniformColorTint.modelToWorldMatrixUnif, 1, gl.FALSE, modelMatrix.Top())
-	gl.Uniform4f(UniformColorTint.baseColorUnif, 0.9, 0.9, 0.9, 0.9)
-	g_pCubeTintMesh.Render()
-	gl.UseProgram(0)
-
-	// Draw the main column
-	modelMatrix.Push()
-	modelMatrix.Translate(&glut.Vec4(0.0, g_fColumnBaseHeight, 0.0, 0.0))
-	modelMatrix.Scale(&glut.Vec4(0.8, fHeight - (g_fColumnBaseHeight * 2.0), 0.8, 1.0))
-	modelMatrix.Translate(&glut.Vec4(0.0, 0.5, 0.0, 0.0))
-	gl.UseProgram(UniformColorTint.theProgram)
-	gl.UniformMatrix4fv(UniformColorTint.modelToWorldMatrixUnif, 1.0, gl.FALSE, modelMatrix.Top())
-	gl.Uniform4f(UniformColorTint.baseColorUnif, 0.9, 0.9, 0.9, 0.9)
-	g_pCylinderMesh.Render()
-	gl.UseProgram(0)
 }
 
-func DrawParthenon(modelMatrix *glut.MatrixStack) {
-	// Draw base.
-	modelMatrix.Push()
-	modelMatrix.Scale(&glut.Vec4{g_fParthenonWidth, g_fParthenonBaseHeight, g_fParthenonLength, 1.0})
-	modelMatrix.Translate(&glut.Vec4{0.0, 0.5, 0.0, 0.0})
-	gl.UseProgram(UniformColorTint.theProgram)
-	gl.UniformMatrix4fv(UniformColorTint.baseColorUnif, 0.9, 0.9, 0.9, 0.9)
-	g_pCubeTintMesh.Render()
-	gl.UseProgram(0)
-
-	// Draw Top
-	modelMatrix.Push()
-	modelMatrix.Translate(&glut.Vec4{0.0, g_fParthenonColumnHeight, g_fParthenonLength, 0.0})
-	modelMatrix.Scale(&glut.Vec4{g_fParthenonWidth, g_fParthenonTopHeight, g_fParthenonLength, 1.0})
-	modelMatrix.Translate(&glut.Vec4{0.0, 0.5, 0.0, 0.0})
-	gl.UseProgram(UniformColorTint.theProgram)
-	gl.UniformMatrix4fv(UniformColorTint.modelToWorldMatrixUnif, 1.0, gl.FALSE, modelMatrix.Top())
-	gl.Uniform4f(UniformColorTint.baseColorUnif, 0.9, 0.9, 0.9, 0.9)
-	g_pCubeTintMesh.Render()
-	gl.UseProgram(0)
-
-	// Draw Columns
-	fFrontZVal := (g_fParthenonLength/2.0) - 1.0
-	fRightXVal := (g_fParthenonWidth/2.0) -1.0
-
-	for iColumnNum := 0; iColumnNum < (int)(g_fParthenonWidth / 2.0); iColumnNum++ {
-		modelMatrix.Push()
-		modelMatrix.Translate(&glut.Vec4{(2.0*iColumnNum)-(g_fParthenonWidth/2.0)+1.0, g_fParthenonBaseHeight, fFrontZVal, 0.0})
-		DrawColumn(modelMatrix, g_fParthenonColumnHeight)
-
-		modelMatrix.Push()
-		modelMatrix.Translate(&glut.Vec4{(2.0*iColumnNum)-(g_fParthenonWidth/2.0)+1.0, g_fParthenonBaseHeight, -fFrontZVal, 0.0})
-		DrawColumn(modelMatrix, g_fParthenonColumnHeight)
+func Initialize() {
+	InitializeProgram()
+	g_meshes = make(map[string]*glut.Mesh)
+	for _, name := range g_layout.MeshNames() {
+		g_meshes[name] = LoadMesh(g_layout.Meshes[name])
 	}
-
-	// Don't draw the first or last columns, since they've been drawn already.
-	for iColumnNum := 1; iColumnNum < (int)((g_fParthenonLength - 2.0)/2.0); iColumnNum++ {
-		modelMatrix.Push()
-		modelMatrix.Translate(*glut.Vec4{fRightXVal, g_fParthenonBaseHeight, (2.0*iColumnNum) - (g_fParthenonLength / 2.0) + 1.0, 0.0})
-		DrawColumn(modelMatrix, g_fParthenonColumnHeight)		
+	for _, it := range g_layout.Items {
+		if g_programs[it.Program] == nil {
+			fmt.Fprintf(os.Stderr, "%s: object %s: no program %q, exiting...\n", *layoutFile, it.Object, it.Program)
+			os.Exit(1)
+		}
+	}
+	if flag.NArg() > 0 {
+		LoadScene(flag.Arg(0))
 	}
 
-	// Draw interior
-	modelMatrix.Push()
-	modelMatrix.Translate(&glut.Vec4{0.0, 1.0, 0.0, 0.0})
-	modelMatrix.Scale(&glut.Vec4{g_fParthenonWidth - 6.0, g_fParthenonColumnHeight, g_fParthenonLength - 6.0, 1.0})
-	modelMatrix.Translate(&glut.Vec4{0.0, 0.5, 0.0, 0.0})
-	gl.UseProgram(ObjectColor.theProgram)
-	gl.UniformMatrix4fv(ObjectColor.modelToWorldMatrixUnif, 1, gl.FALSE, modelMatrix.Top())
-	g_pCubeColorMesh.Render()
-	gl.UseProgram(0)
-
-	// Draw Headpiece
-	modelMatrix.Push()
-	modelMatrix.Translate(&glut.Vec4{0.0, g_fParthenonColumnHeight + g_fParthenonBaseHeight + (g_fParthenonTopHeight / 2.0),
-		g_fParthenonLength / 2.0, 0.0})
-	modelMatrix.RotateX(-135.0)
-	modelMatrix.RotateY(45.0)
-	gl.UseProgram(ObjectColor.theProgram)
-	gl.UniformMatrix4fv(ObjectColor.modelToWorldMatrixUnif, 1, gl.FALSE, modelMatrix.Top())
-	g_pCubeColorMesh.Render()
-	gl.UseProgram(0)
-}
-
-var g_forest []TreeData {
-
-	{-45.0, -40.0, 2.0, 3.0},
-	{-42.0, -35.0, 2.0, 3.0},
-	{-39.0, -29.0, 2.0, 4.0},
-	{-44.0, -26.0, 3.0, 3.0},
-	{-40.0, -22.0, 2.0, 4.0},
-	{-36.0, -15.0, 3.0, 3.0},
-	{-41.0, -11.0, 2.0, 3.0},
-	{-37.0, -6.0, 3.0, 3.0},
-	{-45.0, 0.0, 2.0, 3.0},
-	{-39.0, 4.0, 3.0, 4.0},
-	{-36.0, 8.0, 2.0, 3.0},
-	{-44.0, 13.0, 3.0, 3.0},
-	{-42.0, 17.0, 2.0, 3.0},
-	{-38.0, 23.0, 3.0, 4.0},
-	{-41.0, 27.0, 2.0, 3.0},
-	{-39.0, 32.0, 3.0, 3.0},
-	{-44.0, 37.0, 3.0, 4.0},
-	{-36.0, 42.0, 2.0, 3.0},
-
-	{-32.0, -45.0, 2.0, 3.0},
-	{-30.0, -42.0, 2.0, 4.0},
-	{-34.0, -38.0, 3.0, 5.0},
-	{-33.0, -35.0, 3.0, 4.0},
-	{-29.0, -28.0, 2.0, 3.0},
-	{-26.0, -25.0, 3.0, 5.0},
-	{-35.0, -21.0, 3.0, 4.0},
-	{-31.0, -17.0, 3.0, 3.0},
-	{-28.0, -12.0, 2.0, 4.0},
-	{-29.0, -7.0, 3.0, 3.0},
-	{-26.0, -1.0, 2.0, 4.0},
-	{-32.0, 6.0, 2.0, 3.0},
-	{-30.0, 10.0, 3.0, 5.0},
-	{-33.0, 14.0, 2.0, 4.0},
-	{-35.0, 19.0, 3.0, 4.0},
-	{-28.0, 22.0, 2.0, 3.0},
-	{-33.0, 26.0, 3.0, 3.0},
-	{-29.0, 31.0, 3.0, 4.0},
-	{-32.0, 38.0, 2.0, 3.0},
-	{-27.0, 41.0, 3.0, 4.0},
-	{-31.0, 45.0, 2.0, 4.0},
-	{-28.0, 48.0, 3.0, 5.0},
-
-	{-25.0, -48.0, 2.0, 3.0},
-	{-20.0, -42.0, 3.0, 4.0},
-	{-22.0, -39.0, 2.0, 3.0},
-	{-19.0, -34.0, 2.0, 3.0},
-	{-23.0, -30.0, 3.0, 4.0},
-	{-24.0, -24.0, 2.0, 3.0},
-	{-16.0, -21.0, 2.0, 3.0},
-	{-17.0, -17.0, 3.0, 3.0},
-	{-25.0, -13.0, 2.0, 4.0},
-	{-23.0, -8.0, 2.0, 3.0},
-	{-17.0, -2.0, 3.0, 3.0},
-	{-16.0, 1.0, 2.0, 3.0},
-	{-19.0, 4.0, 3.0, 3.0},
-	{-22.0, 8.0, 2.0, 4.0},
-	{-21.0, 14.0, 2.0, 3.0},
-	{-16.0, 19.0, 2.0, 3.0},
-	{-23.0, 24.0, 3.0, 3.0},
-	{-18.0, 28.0, 2.0, 4.0},
-	{-24.0, 31.0, 2.0, 3.0},
-	{-20.0, 36.0, 2.0, 3.0},
-	{-22.0, 41.0, 3.0, 3.0},
-	{-21.0, 45.0, 2.0, 3.0},
-
-	{-12.0, -40.0, 2.0, 4.0},
-	{-11.0, -35.0, 3.0, 3.0},
-	{-10.0, -29.0, 1.0, 3.0},
-	{-9.0, -26.0, 2.0, 2.0},
-	{-6.0, -22.0, 2.0, 3.0},
-	{-15.0, -15.0, 1.0, 3.0},
-	{-8.0, -11.0, 2.0, 3.0},
-	{-14.0, -6.0, 2.0, 4.0},
-	{-12.0, 0.0, 2.0, 3.0},
-	{-7.0, 4.0, 2.0, 2.0},
-	{-13.0, 8.0, 2.0, 2.0},
-	{-9.0, 13.0, 1.0, 3.0},
-	{-13.0, 17.0, 3.0, 4.0},
-	{-6.0, 23.0, 2.0, 3.0},
-	{-12.0, 27.0, 1.0, 2.0},
-	{-8.0, 32.0, 2.0, 3.0},
-	{-10.0, 37.0, 3.0, 3.0},
-	{-11.0, 42.0, 2.0, 2.0},
-
-
-	{15.0, 5.0, 2.0, 3.0},
-	{15.0, 10.0, 2.0, 3.0},
-	{15.0, 15.0, 2.0, 3.0},
-	{15.0, 20.0, 2.0, 3.0},
-	{15.0, 25.0, 2.0, 3.0},
-	{15.0, 30.0, 2.0, 3.0},
-	{15.0, 35.0, 2.0, 3.0},
-	{15.0, 40.0, 2.0, 3.0},
-	{15.0, 45.0, 2.0, 3.0},
-
-	{25.0, 5.0, 2.0, 3.0},
-	{25.0, 10.0, 2.0, 3.0},
-	{25.0, 15.0, 2.0, 3.0},
-	{25.0, 20.0, 2.0, 3.0},
-	{25.0, 25.0, 2.0, 3.0},
-	{25.0, 30.0, 2.0, 3.0},
-	{25.0, 35.0, 2.0, 3.0},
-	{25.0, 40.0, 2.0, 3.0},
-	{25.0, 45.0, 2.0, 3.0},
+	gl.Enable(gl.CULL_FACE)
+	gl.CullFace(gl.BACK)
+	gl.FrontFace(gl.CW)
+	gl.Enable(gl.DEPTH_TEST)
+	gl.DepthMask(gl.TRUE)
+	gl.DepthFunc(gl.LEQUAL)
+	gl.DepthRange(0.0, 1.0)
+	gl.Enable(gl.DEPTH_CLAMP)
 }
 
-func DrawForest(modelMatrix *glut.MatrixStack) {
-	for iTree := 0; iTree < len(g_forest); iTree++ {
-		currTree := g_forest[iTree]
+// DrawLayout draws everything in the layout.
+func DrawLayout(modelMatrix *glut.MatrixStack) {
+	for _, it := range g_layout.Items {
+		prog := g_programs[it.Program]
 		modelMatrix.Push()
-		modelMatrix.Translate(&glut.Vec4{currTree.fXPos, 0.0, currTree.fZPos, 0.0})
-		DrawTree(modelMatrix, currTree.fTrunkHeight, currTree.fConeHeight)
+		modelMatrix.ApplyMatrix(&it.Matrix)
+		prog.Use()
+		prog.SetMat4("modelToWorldMatrix", modelMatrix.Current())
+		if it.Color != nil {
+			prog.SetVec4("baseColor", it.Color)
+		}
+		if it.VAO != "" {
+			g_meshes[it.Mesh].RenderVAO(it.VAO)
+		} else {
+			g_meshes[it.Mesh].Render()
+		}
+		gl.UseProgram(0)
+		modelMatrix.Pop()
 	}
 }
 
 func ResolveCamPosition() *glut.Vec3 {
-	tempMat := GetMatrixStack()
-	phi := glut.DegToRad(g_sphereCamRelPos.x)
-	theta := glut.DegToRad(g_sphereCamRelPos.y + 90.0)
+	phi := glut.DegToRad(g_sphereCamRelPos.X)
+	theta := glut.DegToRad(g_sphereCamRelPos.Y + 90.0)
 	fSinTheta := glut.SinGL(theta)
 	fCosTheta := glut.CosGL(theta)
 	fCosPhi := glut.CosGL(phi)
 	fSinPhi := glut.SinGL(phi)
-	dirToCamera := &glut.Vec3{fSinTheta * fCosPhi, fCosTheta, fSinTheta * fSinPhi}
-	dirToCamera.MulS(g_sphereCamRelPos.z).Add(g_camTarget)
-	return dirToCamera
+	dirToCamera := &glut.Vec3{X: fSinTheta * fCosPhi, Y: fCosTheta, Z: fSinTheta * fSinPhi}
+	return dirToCamera.MulS(g_sphereCamRelPos.Z).Add(g_camTarget)
 }
 
 // Called to update the display
@@ -391,50 +223,49 @@ func ResolveCamPosition() *glut.Vec3 {
 // If you need continuous updates of the screen, call glutPostRedisplay() at the end of the 
 // function.
 func display() {
+	PollPrograms()
+
 	gl.ClearColor(0.0, 0.0, 0.0, 0.0)
 	gl.ClearDepth(1.0)
 	gl.Clear(gl.COLOR_BUFFER_BIT|gl.DEPTH_BUFFER_BIT)
 
-	campPos := ResolveCamPosition()
-	camMatrix := glut.GetMatrixStack()
-	camMatrix.Set(CalcLookAtMatrix(camPos, g_camTarget, &glut.Vec3{0.0, 1.0, 0.0}))
-
-	gl.UseProgram(UniformColor.theProgram)
-	gl.UniformMatrix4fv(UniformColor.worldToCameraMatrixUnif, 1, gl.FALSE, camMatrix.Top())
-	gl.UseProgram(ObjectColor.theProgram)
-	gl.UniformMatrix4fv(ObjectColor.worldToCameraMatrixUnif, 1, gl.FALSE, camMatrix.Top())
-	gl.UseProgram(UniformColorTint.theProgram)
-	gl.UniformMatrix4fv(UniformColorTint.worldToCameraMatrixUnif, 1, gl.FALSE, camMatrix.Top())
-	gl.UseProgram(0)
+	camPos := ResolveCamPosition()
+	camMatrix := glut.NewMatrixStack()
+	if g_daeCamera != nil {
+		camMatrix.Set(g_daeCamera.ViewMatrix())
+	} else {
+		camMatrix.Set(CalcLookAtMatrix(camPos, g_camTarget, &glut.Vec3{X: 0.0, Y: 1.0, Z: 0.0}))
+	}
 
-	modelMatrix := GetMatrixStack()
-	modelMatrix.Push()
-	modelMatrix.Scale(&glut.Vec4(100.0, 1.0, 100.0, 1.0))
-	gl.UseProgram(UniformColor.theProgram)
-	gl.UniformMatrix4fv(UniformColor.modelToWorldMatrixUnif, 1, gl.FALSE, modelMatrix.Top())
-	gl.Uniform4f(UniformColor.baseColorUnif, 0.302, 0.416, 0.0589, 1.0)
-	g_pPlaneMesh.Render()
+	UniformColor.Use()
+	UniformColor.SetMat4("worldToCameraMatrix", camMatrix.Current())
+	ObjectColor.Use()
+	ObjectColor.SetMat4("worldToCameraMatrix", camMatrix.Current())
+	UniformColorTint.Use()
+	UniformColorTint.SetMat4("worldToCameraMatrix", camMatrix.Current())
 	gl.UseProgram(0)
 
-	DrawForest(modelMatrix)
-
-	// Draw the building
-	modelMatrix.Push()
-	modelMatrix.Translate(&glut.Vec4{20.0, 0.0, -10.0, 0.0})
-	DrawParthenon(modelMatrix)
+	modelMatrix := glut.NewMatrixStack()
+	if g_daeScene != nil {
+		DrawScene(modelMatrix)
+		glfw.SwapBuffers()
+		return
+	}
+	DrawLayout(modelMatrix)
 
-	if g_bDrawLookatPoint == true {
+	// A layout without a cube has no look-at point to draw.
+	if g_bDrawLookatPoint == true && g_meshes["cube"] != nil {
 		gl.Disable(gl.DEPTH_TEST)
-		identity := IdentMat4()
-		modelMatrix.Push()
+		identity := glut.IdentMat4()
 		cameraAimVec := g_camTarget.Sub(camPos)
-		modelMatrix.Translate(&glut.Vec4{0.0, 0.0, -cameraAimVec.Length(), 0.0})
-		modelMatrix.Scale(&glut.Vec4{1.0, 1.0, 1.0, 1.0})
-
-		gl.UseProgram(ObjectColor.theProgram)
-		gl.UniformMatrix4fv(ObjectColor.modelToWorldMatrixUnif, 1, gl.FALSE, modelMatrix.Top())
-		gl.UniformMatrix4fv(ObjectColor.worldToCameraMatrixUnif, 1, gl.FALSE, identity)
-		g_pCubeColorMesh.Render()
+		modelMatrix.Translate(&glut.Vec4{X: 0.0, Y: 0.0, Z: -cameraAimVec.Length(), W: 0.0})
+		modelMatrix.Scale(&glut.Vec4{X: 1.0, Y: 1.0, Z: 1.0, W: 1.0})
+
+		UniformColor.Use()
+		UniformColor.SetVec4("baseColor", &glut.Vec4{X: 1.0, Y: 0.9, Z: 0.2, W: 1.0})
+		UniformColor.SetMat4("modelToWorldMatrix", modelMatrix.Current())
+		UniformColor.SetMat4("worldToCameraMatrix", identity)
+		g_meshes["cube"].RenderVAO("flat")
 		gl.UseProgram(0)
 		gl.Enable(gl.DEPTH_TEST)
 	}
@@ -446,15 +277,19 @@ func display() {
 // This is an opportunity to call glViewPort or glScissor to keep up with the change
 // in size
 func reshape(w, h int) {
-	persMatrix := GetMatrixStack()
-	persMatrix.Perspective(45.0, gl.Float(w) / gl.Float(h), fzNear, fzFar)
-
-	gl.UseProgram(UniformColor.theProgram)
-	gl.UniformMatrix4fv(UniformColor.cameraToClipMatrixUnif, 1, gl.FALSE, persMatrix.Top())
-	gl.UseProgram(ObjectColor.theProgram)
-	gl.UniformMatrix4fv(ObjectColor.cameraToClipMatrixUnif, 1, gl.FALSE, persMatrix.Top())
-	gl.UseProgram(UniformColorTint.theProgram)
-	gl.UniformMatrix4fv(UniformColorTint.cameraToClipMatrixUnif, 1, gl.FALSE, persMatrix.Top())
+	persMatrix := glut.NewMatrixStack()
+	if g_daeCamera != nil {
+		persMatrix.Set(g_daeCamera.Camera.Projection(gl.Float(w) / gl.Float(h)))
+	} else {
+		persMatrix.Perspective(45.0, gl.Float(w) / gl.Float(h), fzNear, fzFar)
+	}
+
+	UniformColor.Use()
+	UniformColor.SetMat4("cameraToClipMatrix", persMatrix.Current())
+	ObjectColor.Use()
+	ObjectColor.SetMat4("cameraToClipMatrix", persMatrix.Current())
+	UniformColorTint.Use()
+	UniformColorTint.SetMat4("cameraToClipMatrix", persMatrix.Current())
 	gl.UseProgram(0)
 
 	gl.Viewport(0, 0, gl.Sizei(w), gl.Sizei(h))
@@ -471,45 +306,45 @@ func keyboard(key, state int) {
 			shutdown()
 			return
 		case 87: // w
-			g_camTarget.z -= 4.0
+			g_camTarget.Z -= 4.0
 		case 83: // s
-			g_camTarget.z += 4.0
+			g_camTarget.Z += 4.0
 		case 68: // w
-			g_camTarget.x += 4.0
+			g_camTarget.X += 4.0
 		case 65: // a
-			g_camTarget.x -= 4.0
+			g_camTarget.X -= 4.0
 		case 69: // e
-			g_camTarget.y -= 4.0
+			g_camTarget.Y -= 4.0
 		case 81: // q
-			g_camTarget.y += 4.0
+			g_camTarget.Y += 4.0
 
 		case 73: // i
-			g_sphereCamRelPos.y -= 11.25
+			g_sphereCamRelPos.Y -= 11.25
 		case 75: // k
-			g_sphereCamRelPos.y += 11.25
+			g_sphereCamRelPos.Y += 11.25
 		case 74: // j
-			g_sphereCamRelPos.x -= 11.25
+			g_sphereCamRelPos.X -= 11.25
 		case 76: // l
-			g_sphereCamRelPos.x += 11.25
+			g_sphereCamRelPos.X += 11.25
 		case 111: // o
-			g_sphereCamRelPos.z -= 5.0
+			g_sphereCamRelPos.Z -= 5.0
 		case 117: // u
-			g_sphereCamRelPos.z += 5.0
+			g_sphereCamRelPos.Z += 5.0
 		case glfw.KeyEnter:
 			g_bDrawLookatPoint = !g_bDrawLookatPoint
-			fmt.Fprintf(os.Stdout, "Target: %f, %f, %f\n", g_camTarget.x, g_camTarget.y, g_camTarget.z)
-			fmt.Fprintf(os.Stdout, "Position: %f, %f, %f\n", g_sphereCamRelPos.x, g_sphereCamRelPos.y, g_sphereCamRelPos.z)
+			fmt.Fprintf(os.Stdout, "Target: %f, %f, %f\n", g_camTarget.X, g_camTarget.Y, g_camTarget.Z)
+			fmt.Fprintf(os.Stdout, "Position: %f, %f, %f\n", g_sphereCamRelPos.X, g_sphereCamRelPos.Y, g_sphereCamRelPos.Z)
 		default:
 			return
 
 		}
 	}
-	g_sphereCamRelPos.y = glutil.Clamp(g_sphereCamRelPos.y, -78.75, -1.0)
-	if g_camTarget.y < 0.0 {
-		g_camTarget.y = 0.0
+	g_sphereCamRelPos.Y = glut.Clamp(g_sphereCamRelPos.Y, -78.75, -1.0)
+	if g_camTarget.Y < 0.0 {
+		g_camTarget.Y = 0.0
 	}
-	if g_sphereCamRelPos.z < 5.0 {
-		g_sphereCamRelPos.z = 5.0
+	if g_sphereCamRelPos.Z < 5.0 {
+		g_sphereCamRelPos.Z = 5.0
 	}
 }
 
@@ -523,12 +358,37 @@ func shutdown() {
 	glfw.Terminate()
 }
 
+func glfwInitWindow() {
+	// Initialize glfw
+	glfw.Init()
+	// Set some basic params or the window
+	glfw.OpenWindowHint(glfw.FsaaSamples, 4) // 4x antialiasing
+	glfw.OpenWindowHint(glfw.OpenGLVersionMajor, 3)
+	glfw.OpenWindowHint(glfw.OpenGLVersionMinor, 2)
+	// Core, not compat
+	glfw.OpenWindowHint(glfw.OpenGLProfile, glfw.OpenGLCoreProfile)
+
+	// Open a window and initialize its OpenGL content
+	if err := glfw.OpenWindow(Width, Height, 0, 0, 0, 0, 32, 0, glfw.Windowed); err != nil {
+		fmt.Fprintf(os.Stderr, "glfw.OpenWindow failed: %s\n", err)
+		os.Exit(1)
+	}
+
+	// Set the Window title
+	glfw.SetWindowTitle(Title)
+
+	// Make sure we can capture the escape key
+	glfw.Enable(glfw.StickyKeys)
+}
 
 // Main loop
 func main() {
 	// Sit. Down. Good boy.
 	runtime.LockOSThread()
 
+	flag.Parse()
+	LoadLayout(*layoutFile)
+
 	// Initialize 
 	glfwInitWindow()
 	gl.Init()
@@ -547,4 +407,4 @@ func main() {
 		display()
 	}
 
-}
\ No newline at end of file
+}